@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
@@ -16,7 +22,10 @@ import (
 	"s3-test-app/internal/config"
 	"s3-test-app/internal/db"
 	"s3-test-app/internal/handler"
+	"s3-test-app/internal/mail"
+	"s3-test-app/internal/metrics"
 	mw "s3-test-app/internal/middleware"
+	"s3-test-app/internal/ratelimit"
 	"s3-test-app/internal/service"
 )
 
@@ -46,14 +55,63 @@ func main() {
 	if err != nil {
 		logger.Fatal("Failed to initialize S3 service", zap.Error(err))
 	}
+	// Changes are queued for replication from the first write on
+	s3Svc.SetReplicationQueue(database)
+
+	// Refuse to start if the backend rejects the configured server-side encryption
+	sseCtx, cancelSSE := context.WithTimeout(context.Background(), 15*time.Second)
+	if err := s3Svc.ProbeEncryption(sseCtx); err != nil {
+		logger.Fatal("Server-side encryption check failed", zap.Error(err))
+	}
+	cancelSSE()
+
+	// Probe whether the backend accepts browser POST policy uploads
+	probeCtx, cancelProbe := context.WithTimeout(context.Background(), 15*time.Second)
+	if s3Svc.ProbePostPolicy(probeCtx) {
+		logger.Info("S3 backend supports POST policy uploads")
+	} else {
+		logger.Info("S3 backend does not support POST policy uploads, direct uploads disabled")
+	}
+	cancelProbe()
 
 	// Create token manager
 	tokenManager := auth.NewTokenManager(cfg.Auth.Secret)
+	signingKeys, err := cfg.Auth.SigningKeyRing()
+	if err != nil {
+		logger.Fatal("Invalid signing keys", zap.Error(err))
+	}
+	keys := make([]auth.SigningKey, 0, len(signingKeys))
+	for _, key := range signingKeys {
+		keys = append(keys, auth.SigningKey{ID: key.ID, Secret: key.Secret})
+	}
+	if err := tokenManager.SetSigningKeys(keys); err != nil {
+		logger.Fatal("Invalid signing keys", zap.Error(err))
+	}
+	tokenManager.SetUserStore(database)
+	tokenManager.SetRevocationStore(database)
+	tokenManager.SetAPITokenStore(database)
+	tokenManager.SetRefreshGrace(cfg.Auth.RefreshGrace)
+	tokenManager.SetCookieSecure(cfg.Auth.CookieSecure)
+	tokenManager.SetAcceptLegacyTokens(cfg.Auth.AcceptLegacyTokens)
 
 	// Create handlers
-	h := handler.NewHandler(s3Svc, logger)
-	authHandler := handler.NewAuthHandler(tokenManager, database, logger, cfg)
-	adminHandler := handler.NewAdminHandler(database, logger)
+	h := handler.NewHandler(s3Svc, database, logger, cfg.Keys, cfg.Uploads, cfg.Server.MaxUploadSize, cfg.Trash)
+	h.SetHealthCheckTimeout(cfg.Server.HealthCheckTimeout)
+	h.SetBaseURL(cfg.Server.BaseURL)
+	h.SetDefaultQuota(cfg.Server.UserQuota)
+	loginLimiter := ratelimit.New(cfg.Auth.LoginMaxAttempts, cfg.Auth.LoginWindow)
+	authHandler := handler.NewAuthHandler(tokenManager, database, logger, cfg, loginLimiter, mail.NewSender(logger, cfg.Mail.LogDelivery))
+	approvalHandler := handler.NewApprovalHandler(database, logger, &cfg.Approval)
+	h.SetApprovals(approvalHandler)
+	adminHandler := handler.NewAdminHandler(tokenManager, database, logger, approvalHandler)
+	legalHoldHandler := handler.NewLegalHoldHandler(database, logger, approvalHandler)
+	rateLimits := ratelimit.NewRegistry(cfg.RateLimits)
+	settingsHandler := handler.NewSettingsHandler(database, logger, approvalHandler, rateLimits)
+	if err := settingsHandler.LoadOverrides(); err != nil {
+		logger.Fatal("Failed to load settings", zap.Error(err))
+	}
+	canary := service.NewCanary(s3Svc, logger, cfg.Canary)
+	canaryHandler := handler.NewCanaryHandler(canary)
 
 	// Create router
 	r := chi.NewRouter()
@@ -61,8 +119,12 @@ func main() {
 	// Middleware (all before routes)
 	r.Use(chimiddleware.RequestID)
 	r.Use(chimiddleware.RealIP)
+	if cfg.Metrics.Enabled {
+		r.Use(mw.Metrics)
+	}
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
+	r.Use(mw.Gzip(cfg.Server.GzipMinSize))
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
@@ -76,33 +138,104 @@ func main() {
 	r.Get("/", h.GetIndex)
 	r.Get("/login", handler.GetLogin)
 	r.Get("/signup", handler.GetSignup)
-	r.Get("/health", h.HealthCheck)
+	r.Get("/health", h.HealthReady)
+	r.Get("/health/live", h.HealthCheck)
+	r.Get("/health/ready", h.HealthReady)
+	if cfg.Metrics.Enabled {
+		if cfg.Metrics.RequireAuth {
+			r.With(mw.MetricsAccess(tokenManager, cfg.Metrics.ScrapeToken)).Handle("/metrics", metrics.Handler())
+		} else {
+			r.Handle("/metrics", metrics.Handler())
+		}
+	}
+
+	// Share links work without an account
+	r.With(mw.RateLimit(rateLimits.Policy("share-public"))).Get("/s/{token}", h.DownloadShare)
+
+	// Upload inboxes let partners send files without an account
+	r.With(mw.RateLimit(rateLimits.Policy("inbox-public"))).Post("/inbox/{token}/upload", h.InboxUpload)
 
 	// Auth Routes (public)
 	r.Route("/api/auth", func(r chi.Router) {
-		r.Post("/login", authHandler.LoginHandler)
-		r.Post("/signup", authHandler.SignupHandler)
-		r.Post("/logout", authHandler.LogoutHandler)
+		// Credential endpoints are limited strictly, while refresh tolerates many tabs at once
+		r.Group(func(r chi.Router) {
+			r.Use(mw.RateLimit(rateLimits.Policy("auth-strict")))
+			r.Post("/login", authHandler.LoginHandler)
+			r.Post("/signup", authHandler.SignupHandler)
+			r.Post("/forgot-password", authHandler.ForgotPasswordHandler)
+			r.Post("/reset-password", authHandler.ResetPasswordHandler)
+			r.Get("/verify", authHandler.VerifyEmailHandler)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(mw.RateLimit(rateLimits.Policy("refresh-burst")))
+			r.Post("/logout", authHandler.LogoutHandler)
+			r.Post("/refresh", authHandler.RefreshHandler)
+		})
 	})
 
 	// Protected Routes (require authentication)
 	r.Group(func(r chi.Router) {
 		r.Use(mw.AuthMiddleware(tokenManager))
+		r.Use(mw.CSRF(tokenManager))
 		r.Get("/dashboard", handler.GetDashboard)
+		r.Get("/api/auth/csrf", authHandler.CSRFTokenHandler)
+		r.Post("/api/auth/change-password", authHandler.ChangePasswordHandler)
 
 		// API Routes (require authentication)
 		r.Route("/api", func(r chi.Router) {
-			r.Get("/files", h.ListFiles)
-			r.Post("/upload", h.UploadFile)
-			r.Get("/download", h.DownloadFile)
-			r.Delete("/files", h.DeleteFile)
+			r.Use(mw.RateLimit(rateLimits.Policy("api-default")))
+			r.Get("/me", h.GetMe)
+			r.Get("/limits", h.GetLimits)
+			r.Get("/tokens", authHandler.ListAPITokensHandler)
+			r.Post("/tokens", authHandler.CreateAPITokenHandler)
+			r.Delete("/tokens/{id}", authHandler.DeleteAPITokenHandler)
+			r.Get("/events", h.Events)
+			r.With(mw.RequireRole(auth.RoleAdmin)).Get("/buckets", h.ListBuckets)
+			fileRoutes(r, h, rateLimits)
+			r.Delete("/shares/{token}", h.RevokeShare)
+			r.Get("/trash", h.ListTrash)
+			r.Post("/trash/restore", h.RestoreTrash)
+			r.With(mw.RequireRole(auth.RoleAdmin)).Delete("/trash", h.EmptyTrash)
+
+			// Moving file content in or out can be held back until the email is verified
+			r.Group(func(r chi.Router) {
+				if cfg.Auth.RequireVerifiedEmail {
+					r.Use(mw.RequireVerifiedEmail(database))
+				}
+				transferRoutes(r, h, rateLimits)
+			})
 		})
 
 		// Admin Routes (require admin role)
 		r.Route("/api/admin", func(r chi.Router) {
 			r.Use(mw.RequireRole(auth.RoleAdmin))
 			r.Get("/users", adminHandler.GetUsers)
+			r.Get("/users/{id}/quota", h.GetUserQuota)
+			r.Put("/users/{id}/quota", h.UpdateUserQuota)
+			r.Put("/users/{id}/role", adminHandler.UpdateUserRole)
 			r.Delete("/users/{id}", adminHandler.DeleteUser)
+			r.Get("/approvals", approvalHandler.ListApprovals)
+			r.Post("/approvals/{id}/approve", approvalHandler.ApproveAction)
+			r.Delete("/approvals/{id}", approvalHandler.CancelAction)
+			r.Get("/legal-holds", legalHoldHandler.ListHolds)
+			r.Post("/legal-holds", legalHoldHandler.PlaceHold)
+			r.Delete("/legal-holds/{id}", legalHoldHandler.ReleaseHold)
+			r.Get("/stats", h.AdminStats)
+			r.Get("/canary", canaryHandler.Status)
+			r.Get("/s3/selftest", h.S3SelfTest)
+			r.Get("/replication", h.ReplicationStatus)
+			r.Post("/inboxes", h.CreateInbox)
+			r.Post("/import-bucket", h.ImportBucket)
+			r.Get("/import-bucket/{id}", h.GetImportJob)
+			r.Post("/cleanup/multipart", h.CleanupMultipart)
+			r.Get("/lifecycle", h.GetLifecycle)
+			r.Put("/lifecycle", h.PutLifecycle)
+			r.Get("/settings", settingsHandler.ListSettings)
+			r.Put("/settings", settingsHandler.UpdateSettings)
+			r.Put("/settings/{key}", settingsHandler.UpdateSetting)
+			r.Delete("/settings/{key}", settingsHandler.ResetSetting)
+			r.Get("/pipeline", h.GetPipeline)
+			r.Put("/pipeline/{hook}", h.UpdatePipelineHook)
 		})
 	})
 
@@ -110,24 +243,159 @@ func main() {
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 	logger.Info("Starting server", zap.String("address", addr), zap.String("s3_endpoint", cfg.S3.Endpoint), zap.String("bucket", cfg.S3.Bucket))
 
+	conns := &connCounter{}
 	server := &http.Server{
-		Addr:    addr,
-		Handler: r,
+		Addr:      addr,
+		Handler:   r,
+		ConnState: conns.track,
+	}
+	if cfg.Server.TLSEnabled() {
+		server.TLSConfig = &tls.Config{MinVersion: cfg.Server.MinTLSVersion()}
+	}
+
+	var redirectServer *http.Server
+	if cfg.Server.HTTPRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:    cfg.Server.HTTPRedirectAddr,
+			Handler: httpsRedirect(cfg.Server.Port),
+		}
 	}
 
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go purgeRevokedTokens(jobsCtx, database, logger)
+	go loginLimiter.Run(jobsCtx, time.Minute)
+	go rateLimits.Run(jobsCtx, time.Minute)
+	go canary.Run(jobsCtx)
+	go h.RunTrashPurge(jobsCtx)
+	go h.RunDirectUploadSweep(jobsCtx)
+	go h.RunRecordBackfill(jobsCtx)
+	go h.RunBucketImports(jobsCtx)
+	go s3Svc.RunMultipartJanitor(jobsCtx, cfg.S3.MultipartCleanupInterval)
+	go s3Svc.RunReplicator(jobsCtx)
+	go h.RunLifecycleSweep(jobsCtx, cfg.S3.LifecycleSweepInterval)
+	go backfillCategories(database, logger)
+
 	// Graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			logger.Info("Serving HTTPS", zap.String("min_tls_version", cfg.Server.TLSMinVersion))
+			err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server error", zap.Error(err))
 		}
 	}()
 
+	if redirectServer != nil {
+		go func() {
+			logger.Info("Redirecting HTTP to HTTPS", zap.String("address", redirectServer.Addr))
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatal("Redirect server error", zap.Error(err))
+			}
+		}()
+	}
+
 	<-sigChan
-	logger.Info("Shutting down server...")
-	if err := server.Close(); err != nil {
+	logger.Info("Shutting down server...", zap.Duration("timeout", cfg.Server.ShutdownTimeout))
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelShutdown()
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			redirectServer.Close()
+		}
+	}
+	shutdownServer(shutdownCtx, server, conns, logger)
+	// The deferred job cancellation, database close and logger sync run only after this point
+}
+
+// connCounter tracks open connections so shutdown can report how many it drained
+type connCounter struct {
+	open atomic.Int64
+}
+
+// track is installed as http.Server.ConnState
+func (c *connCounter) track(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		c.open.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		c.open.Add(-1)
+	}
+}
+
+// shutdownServer lets in-flight requests finish until ctx expires, then closes whatever is left
+func shutdownServer(ctx context.Context, server *http.Server, conns *connCounter, logger *zap.Logger) {
+	open := conns.open.Load()
+	err := server.Shutdown(ctx)
+	if err == nil {
+		logger.Info("Server drained", zap.Int64("connections", open))
+		return
+	}
+
+	remaining := conns.open.Load()
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Warn("Shutdown timeout hit, closing remaining connections",
+			zap.Int64("drained", open-remaining), zap.Int64("remaining", remaining))
+	} else {
 		logger.Error("Server shutdown error", zap.Error(err))
 	}
+	if err := server.Close(); err != nil {
+		logger.Error("Server close error", zap.Error(err))
+	}
+}
+
+// httpsRedirect sends every request to the same host and path on the HTTPS port
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// backfillCategories categorizes file records stored before categories were tracked
+func backfillCategories(database *db.Database, logger *zap.Logger) {
+	updated, err := database.BackfillCategories(service.Categorize)
+	if err != nil {
+		logger.Error("failed to backfill file categories", zap.Error(err))
+		return
+	}
+	if updated > 0 {
+		logger.Info("backfilled file categories", zap.Int("count", updated))
+	}
 }
+
+// purgeRevokedTokens periodically removes revocation entries for tokens that have expired
+func purgeRevokedTokens(ctx context.Context, database *db.Database, logger *zap.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := database.PurgeExpiredRevocations()
+			if err != nil {
+				logger.Error("failed to purge revoked tokens", zap.Error(err))
+				continue
+			}
+			if purged > 0 {
+				logger.Info("purged expired token revocations", zap.Int64("count", purged))
+			}
+		}
+	}
+}
\ No newline at end of file
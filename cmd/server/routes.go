@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/go-chi/chi/v5"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/handler"
+	mw "s3-test-app/internal/middleware"
+	"s3-test-app/internal/ratelimit"
+)
+
+// fileRoutes registers the /files endpoints of the API, each behind the permission of
+// what it does to a file. The handlers check again, so a route registered without one
+// still can't be misused.
+func fileRoutes(r chi.Router, h *handler.Handler, rateLimits *ratelimit.Registry) {
+	canView := mw.RequirePermission(auth.Permission{CanView: true})
+	canUpload := mw.RequirePermission(auth.Permission{CanUpload: true})
+	canDelete := mw.RequirePermission(auth.Permission{CanDelete: true})
+	// Renaming and moving write the new key and delete the old one
+	canReplace := mw.RequirePermission(auth.Permission{CanUpload: true, CanDelete: true})
+	admin := mw.RequireRole(auth.RoleAdmin)
+
+	r.With(canView).Get("/files", h.ListFiles)
+	r.With(canView).Get("/files/stat", h.StatFile)
+	r.With(canView).Get("/files/by-hash", h.FilesByHash)
+	r.With(canView).Get("/files/search", h.SearchFiles)
+	r.With(admin, mw.RateLimit(rateLimits.Policy("download-heavy"))).Get("/files/export", h.ExportFiles)
+	r.With(canView).Get("/files/tags", h.GetTags)
+	r.With(canUpload).Put("/files/tags", h.SetTags)
+	r.With(canView).Get("/files/versions", h.ListVersions)
+	r.With(canUpload).Post("/files/versions/restore", h.RestoreVersion)
+	r.With(canReplace).Post("/files/rename", h.RenameFile)
+	r.With(canReplace).Post("/files/move", h.MoveFiles)
+	r.With(canDelete).Delete("/files", h.DeleteFile)
+	r.With(canDelete).Post("/files/batch-delete", h.BatchDelete)
+	r.Post("/files/share", h.CreateShare)
+	r.With(admin).Delete("/files/prefix", h.DeletePrefix)
+	r.With(admin).Post("/files/lock", h.LockFile)
+}
+
+// transferRoutes registers the endpoints that move file content in or out
+func transferRoutes(r chi.Router, h *handler.Handler, rateLimits *ratelimit.Registry) {
+	r.Group(func(r chi.Router) {
+		r.Use(mw.RequirePermission(auth.Permission{CanUpload: true}))
+		r.Post("/upload", h.UploadFile)
+		r.Post("/upload/presign-post", h.PresignPost)
+		r.Post("/upload/confirm", h.ConfirmUpload)
+		r.Post("/upload/presign", h.PresignUpload)
+		r.Post("/upload/complete", h.ConfirmUpload)
+	})
+	r.With(mw.RateLimit(rateLimits.Policy("download-heavy"))).Get("/download", h.DownloadFile)
+	r.With(mw.RateLimit(rateLimits.Policy("download-heavy"))).Post("/files/download-zip", h.DownloadZip)
+	r.With(mw.RateLimit(rateLimits.Policy("download-heavy"))).Get("/files/zip", h.DownloadZip)
+}
\ No newline at end of file
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/handler"
+	"s3-test-app/internal/ratelimit"
+	"s3-test-app/internal/service"
+)
+
+const testBucket = "test-bucket"
+
+// newTestRouter serves the file and transfer routes over a fake bucket. Requests are
+// authenticated as the user of the role named in the X-Test-Role header.
+func newTestRouter(t *testing.T) (http.Handler, *fakes3.Server) {
+	t.Helper()
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+	s3Svc, err := service.NewS3Service(&config.S3Config{
+		Endpoint:         fake.URL,
+		Region:           "us-east-1",
+		Bucket:           testBucket,
+		AccessKey:        "test",
+		SecretKey:        "test",
+		MaxAttempts:      1,
+		RetryMode:        config.RetryModeStandard,
+		OperationTimeout: 10 * time.Second,
+		DialTimeout:      time.Second,
+		MaxConcurrentOps: 8,
+		ConcurrencyWait:  time.Second,
+		ListConcurrency:  2,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	h := handler.NewHandler(s3Svc, database, zap.NewNop(), config.KeyPolicyConfig{}, config.UploadTypeConfig{}, 1<<20, config.TrashConfig{Retention: time.Hour})
+	unlimited := config.RateLimitSpec{Rate: 1000, Per: time.Second, Burst: 1000, Key: config.RateLimitByUser}
+	rateLimits := ratelimit.NewRegistry(map[string]config.RateLimitSpec{"download-heavy": unlimited})
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := auth.Role(r.Header.Get("X-Test-Role"))
+			user := &auth.User{ID: string(role) + "-id", Name: string(role), Email: string(role) + "@example.com", Role: role}
+			next.ServeHTTP(w, r.WithContext(auth.SetUserInContext(r.Context(), user)))
+		})
+	})
+	r.Route("/api", func(r chi.Router) {
+		fileRoutes(r, h, rateLimits)
+		transferRoutes(r, h, rateLimits)
+	})
+	return r, fake
+}
+
+// multipartBody is a one-file upload form
+func multipartBody(t *testing.T) (string, []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("report"))
+	form.Close()
+	return form.FormDataContentType(), body.Bytes()
+}
+
+func TestFileRoutesRequireRolePermissions(t *testing.T) {
+	view := auth.Permission{CanView: true}
+	upload := auth.Permission{CanUpload: true}
+	del := auth.Permission{CanDelete: true}
+	replace := auth.Permission{CanUpload: true, CanDelete: true}
+	admin := auth.PermissionMap[auth.RoleAdmin]
+
+	// key is a file in the caller's own folder, which every role may reach once permitted
+	key := "users/ROLE-id/report.txt"
+	formType, form := multipartBody(t)
+	endpoints := []struct {
+		method string
+		path   string
+		body   string
+		need   auth.Permission
+	}{
+		{http.MethodGet, "/api/files", "", view},
+		{http.MethodGet, "/api/files/stat?key=" + key, "", view},
+		{http.MethodGet, "/api/files/by-hash?sha256=" + strings.Repeat("a", 64), "", view},
+		{http.MethodGet, "/api/files/search?q=report", "", view},
+		{http.MethodGet, "/api/files/tags?key=" + key, "", view},
+		{http.MethodPut, "/api/files/tags?key=" + key, `{"tags": {"project": "apollo"}}`, upload},
+		{http.MethodGet, "/api/files/versions?key=" + key, "", view},
+		{http.MethodPost, "/api/files/versions/restore", `{"key": "` + key + `", "version_id": "v1"}`, upload},
+		{http.MethodPost, "/api/files/rename", `{"from": "` + key + `", "to": "users/ROLE-id/renamed.txt"}`, replace},
+		{http.MethodPost, "/api/files/move", `{"key": "` + key + `", "destination": "users/ROLE-id/archive/"}`, replace},
+		{http.MethodDelete, "/api/files?key=" + key, "", del},
+		{http.MethodPost, "/api/files/batch-delete", `{"keys": ["` + key + `"]}`, del},
+		{http.MethodDelete, "/api/files/prefix?prefix=users/ROLE-id/", "", admin},
+		{http.MethodPost, "/api/files/lock", `{"key": "` + key + `"}`, admin},
+		{http.MethodGet, "/api/files/export", "", admin},
+		{http.MethodPost, "/api/upload", "", upload},
+		{http.MethodPost, "/api/upload/presign", `{"filename": "report.txt", "size": 6}`, upload},
+		{http.MethodPost, "/api/upload/confirm", `{"key": "` + key + `"}`, upload},
+		{http.MethodGet, "/api/download?key=" + key, "", view},
+	}
+
+	for _, role := range []auth.Role{auth.RoleAdmin, auth.RoleUploader, auth.RoleViewer} {
+		user := &auth.User{Role: role}
+		for _, endpoint := range endpoints {
+			router, fake := newTestRouter(t)
+			own := strings.ReplaceAll(key, "ROLE", string(role))
+			fake.Put(testBucket, own, []byte("report"))
+
+			body := []byte(strings.ReplaceAll(endpoint.body, "ROLE", string(role)))
+			contentType := "application/json"
+			if endpoint.path == "/api/upload" {
+				contentType, body = formType, form
+			}
+			req := httptest.NewRequest(endpoint.method, strings.ReplaceAll(endpoint.path, "ROLE", string(role)), bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set("X-Test-Role", string(role))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			name := string(role) + " " + endpoint.method + " " + endpoint.path
+			if user.HasPermission(endpoint.need) {
+				if rec.Code == http.StatusForbidden || rec.Code == http.StatusUnauthorized {
+					t.Errorf("%s: status = %d, want it let through: %s", name, rec.Code, rec.Body.String())
+				}
+				continue
+			}
+			// Refused by the route itself, before the handler's own check
+			if rec.Code != http.StatusForbidden || !strings.HasPrefix(rec.Body.String(), "Forbidden") {
+				t.Errorf("%s: status = %d %q, want the route to answer 403", name, rec.Code, rec.Body.String())
+			}
+			if object := fake.Get(testBucket, own); object == nil || string(object.Data) != "report" {
+				t.Errorf("%s: file was changed by a refused request", name)
+			}
+		}
+	}
+}
\ No newline at end of file
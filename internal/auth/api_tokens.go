@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// APITokenPrefix starts every personal access token, which tells them apart from JWTs
+const APITokenPrefix = "s3pat_"
+
+// apiTokenSize is the number of random bytes in a personal access token
+const apiTokenSize = 32
+
+// ErrInvalidAPIToken is returned for a personal access token that was never issued,
+// was revoked or has expired
+var ErrInvalidAPIToken = errors.New("invalid API token")
+
+// APITokenContextKey marks a request that authenticated with a personal access token
+const APITokenContextKey ContextKey = "api_token"
+
+// APITokenStore resolves personal access tokens to the user they belong to, with the
+// user's current role
+type APITokenStore interface {
+	LookupAPIToken(token string) (*User, error)
+}
+
+// NewAPIToken returns a new personal access token
+func NewAPIToken() (string, error) {
+	token, err := NewRandomToken(apiTokenSize)
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + token, nil
+}
+
+// IsAPIToken reports whether token is a personal access token rather than a JWT
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// SetAPITokenStore sets the store personal access tokens are looked up in
+func (m *TokenManager) SetAPITokenStore(store APITokenStore) {
+	m.apiTokens = store
+}
+
+// ValidateAPIToken returns the user a personal access token belongs to
+func (m *TokenManager) ValidateAPIToken(token string) (*User, error) {
+	if m.apiTokens == nil {
+		return nil, fmt.Errorf("API tokens are not configured")
+	}
+	return m.apiTokens.LookupAPIToken(token)
+}
+
+// SetAPITokenAuthInContext records that the request authenticated with a personal access token
+func SetAPITokenAuthInContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, APITokenContextKey, true)
+}
+
+// IsAPITokenAuth reports whether the request authenticated with a personal access token
+// rather than a session
+func IsAPITokenAuth(ctx context.Context) bool {
+	viaToken, _ := ctx.Value(APITokenContextKey).(bool)
+	return viaToken
+}
\ No newline at end of file
@@ -0,0 +1,42 @@
+package auth
+
+import "testing"
+
+func TestCSRFTokenSurvivesKeyRotation(t *testing.T) {
+	m := NewTokenManager("")
+	if err := m.SetSigningKeys([]SigningKey{{ID: "old", Secret: "old-secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	issued := m.CSRFToken("session")
+
+	if err := m.SetSigningKeys([]SigningKey{{ID: "new", Secret: "new-secret"}, {ID: "old", Secret: "old-secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !m.ValidCSRFToken("session", issued) {
+		t.Error("token from the previous key was refused while that key is still configured")
+	}
+	if m.CSRFToken("session") == issued {
+		t.Error("new tokens are still derived with the old key")
+	}
+
+	if err := m.SetSigningKeys([]SigningKey{{ID: "new", Secret: "new-secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if m.ValidCSRFToken("session", issued) {
+		t.Error("token accepted after its key was removed")
+	}
+}
+
+func TestCSRFTokenIsBoundToSession(t *testing.T) {
+	m := NewTokenManager("test-secret")
+
+	if !m.ValidCSRFToken("session", m.CSRFToken("session")) {
+		t.Error("a session's own token was refused")
+	}
+	if m.ValidCSRFToken("session", m.CSRFToken("other")) {
+		t.Error("another session's token was accepted")
+	}
+	if m.ValidCSRFToken("session", "") {
+		t.Error("an empty token was accepted")
+	}
+}
\ No newline at end of file
@@ -5,19 +5,101 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
-// TokenManager handles token operations using simple HMAC-based tokens
+// legacyTokenVersion prefixes the tokens issued before the switch to JWT, which were
+// version.claims.signature with an HMAC over the version and the JSON claims
+const legacyTokenVersion = "v1"
+
+// ErrUnsupportedTokenVersion is returned for tokens of a format that is no longer
+// accepted; the holder has to log in again
+var ErrUnsupportedTokenVersion = errors.New("unsupported token version")
+
+// issuedAtPrecision is how finely the iat claim is recorded. jwt.NumericDate is cut to
+// jwt.TimePrecision, whole seconds by default, which is too coarse to tell a token issued
+// right after a revocation cutoff from one issued just before it; the package variable is
+// left alone since it would change every other jwt user in the binary.
+const issuedAtPrecision = time.Microsecond
+
+// issuedAt is the iat claim as seconds with a microsecond fraction
+type issuedAt struct {
+	time.Time
+}
+
+// MarshalJSON writes the time as seconds since the epoch, with six decimals
+func (t issuedAt) MarshalJSON() ([]byte, error) {
+	truncated := t.Truncate(issuedAtPrecision)
+	return []byte(fmt.Sprintf("%d.%06d", truncated.Unix(), truncated.Nanosecond()/int(issuedAtPrecision))), nil
+}
+
+// UnmarshalJSON reads seconds since the epoch. Decimal seconds are parsed digit by digit,
+// since a float64 loses microseconds at current epoch values.
+func (t *issuedAt) UnmarshalJSON(b []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(b, &number); err != nil {
+		return fmt.Errorf("could not parse iat: %w", err)
+	}
+
+	whole, frac, _ := strings.Cut(number.String(), ".")
+	seconds, err := strconv.ParseInt(whole, 10, 64)
+	var nanos int64
+	if err == nil && frac != "" && len(frac) <= 9 {
+		nanos, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+	}
+	if err != nil || len(frac) > 9 {
+		// Exponent notation or excess digits, which no token issued here uses
+		f, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("could not parse iat: %w", err)
+		}
+		sec, fraction := math.Modf(f)
+		seconds, nanos = int64(sec), int64(fraction*1e9)
+	}
+	t.Time = time.Unix(seconds, nanos).Truncate(issuedAtPrecision)
+	return nil
+}
+
+// TokenManager issues and validates HS256-signed JWTs
 type TokenManager struct {
-	secret string
+	// keys[0] signs new tokens; every key validates them, so a rotated-out secret keeps
+	// its tokens working until it is dropped
+	keys         []SigningKey
+	users        UserStore
+	revocations  RevocationStore
+	refreshGrace time.Duration
+	// cookieSecure sets the Secure attribute of the auth cookie
+	cookieSecure bool
+	// acceptLegacy lets tokens in the pre-JWT format through until they expire
+	acceptLegacy bool
+	// apiTokens resolves personal access tokens; see ValidateAPIToken
+	apiTokens APITokenStore
+}
+
+// SigningKey is a secret tokens are signed with. A non-empty ID is put in the kid
+// header of the tokens the key signs, so a token names the key that verifies it.
+type SigningKey struct {
+	ID     string
+	Secret string
+}
+
+// UserStore looks up the current state of a user when refreshing tokens
+type UserStore interface {
+	LookupUser(id string) (*User, error)
 }
 
-// Claims represents token claims
+// Claims represents token claims. In a JWT, ID, UserID, ExpiresAt and IssuedAt are the
+// registered jti, sub, exp and iat claims.
 type Claims struct {
+	ID        string    `json:"jti"`
 	UserID    string    `json:"user_id"`
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
@@ -26,63 +108,312 @@ type Claims struct {
 	IssuedAt  time.Time `json:"iat"`
 }
 
-// NewTokenManager creates a new token manager
+// jwtClaims is the JWT payload of a token. IssuedAt shadows the iat of RegisteredClaims
+// to keep it finer than whole seconds.
+type jwtClaims struct {
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     Role      `json:"role"`
+	IssuedAt *issuedAt `json:"iat,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewTokenManager creates a new token manager that signs with a single key
 func NewTokenManager(secret string) *TokenManager {
 	return &TokenManager{
-		secret: secret,
+		keys:         []SigningKey{{Secret: secret}},
+		cookieSecure: true,
 	}
 }
 
+// RevocationStore persists the IDs of tokens that were revoked before expiry,
+// and per-user cutoffs that revoke every token issued earlier. RevokeToken reports
+// whether it revoked the token, false if it already was; it must decide that
+// atomically, as it is what lets only one of two concurrent refreshes win.
+type RevocationStore interface {
+	RevokeToken(id string, expiresAt time.Time) (bool, error)
+	IsTokenRevoked(id string) (bool, error)
+	RevokeUserTokens(userID string, before time.Time) error
+	UserTokensRevokedBefore(userID string) (time.Time, error)
+}
+
+// SetRevocationStore sets the store consulted for revoked tokens
+func (m *TokenManager) SetRevocationStore(store RevocationStore) {
+	m.revocations = store
+}
+
+// SetUserStore sets the store used to re-read user details on refresh
+func (m *TokenManager) SetUserStore(store UserStore) {
+	m.users = store
+}
+
+// SetRefreshGrace sets how long after expiry a token may still be refreshed
+func (m *TokenManager) SetRefreshGrace(grace time.Duration) {
+	m.refreshGrace = grace
+}
+
+// SetCookieSecure sets whether the auth cookie is only sent over HTTPS. It is on by
+// default; browsers drop Secure cookies set over plain HTTP except on localhost.
+func (m *TokenManager) SetCookieSecure(secure bool) {
+	m.cookieSecure = secure
+}
+
+// SetSigningKeys replaces the signing keys. The first signs new tokens and the others
+// only validate, which lets a secret be rotated without logging everyone out: add the
+// new key in front, then remove the old one once its tokens have expired. Key IDs must
+// be unique; at most one key may go without one.
+func (m *TokenManager) SetSigningKeys(keys []SigningKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("at least one signing key is required")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.Secret == "" {
+			return fmt.Errorf("signing key %q has no secret", key.ID)
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("signing key ID %q is used more than once", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	m.keys = append([]SigningKey(nil), keys...)
+	return nil
+}
+
+// SetAcceptLegacyTokens sets whether tokens issued before the switch to JWT are still
+// accepted, so sessions survive a rollout. Once those tokens have expired it can be off.
+func (m *TokenManager) SetAcceptLegacyTokens(accept bool) {
+	m.acceptLegacy = accept
+}
+
 // GenerateToken generates a token for a user
 func (m *TokenManager) GenerateToken(user *User, expirationTime time.Duration) (string, error) {
-	claims := &Claims{
-		UserID:    user.ID,
-		Email:     user.Email,
-		Name:      user.Name,
-		Role:      user.Role,
-		ExpiresAt: time.Now().Add(expirationTime),
-		IssuedAt:  time.Now(),
+	tokenID, err := NewRandomToken(16)
+	if err != nil {
+		return "", err
 	}
 
-	claimsJSON, err := json.Marshal(claims)
+	key := m.keys[0]
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
+		Email:    user.Email,
+		Name:     user.Name,
+		Role:     user.Role,
+		IssuedAt: &issuedAt{now.Truncate(issuedAtPrecision)},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(expirationTime)),
+		},
+	})
+
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	signed, err := token.SignedString([]byte(key.Secret))
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal claims: %w", err)
+		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
+	return signed, nil
+}
 
-	// Create signature
-	h := hmac.New(sha256.New, []byte(m.secret))
-	h.Write(claimsJSON)
-	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+// verificationKey returns the secret that verifies token: the key its kid header names,
+// or for a token without one, issued before keys had IDs, every key in turn
+func (m *TokenManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(m.keys))}
+		for _, key := range m.keys {
+			set.Keys = append(set.Keys, []byte(key.Secret))
+		}
+		return set, nil
+	}
 
-	// Encode claims
-	encodedClaims := base64.StdEncoding.EncodeToString(claimsJSON)
+	for _, key := range m.keys {
+		if key.ID != "" && key.ID == kid {
+			return []byte(key.Secret), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
 
-	// Return token in format: claims.signature
-	return encodedClaims + "." + signature, nil
+// legacySignature returns the signature of the claims of a pre-JWT token
+func legacySignature(secret, version string, claimsJSON []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(version + "."))
+	h.Write(claimsJSON)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
 // ValidateToken validates a token and returns claims
 func (m *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := m.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check expiration
+	if time.Now().After(claims.ExpiresAt) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	if err := m.checkRevoked(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// RevokeToken invalidates a token until its natural expiry
+func (m *TokenManager) RevokeToken(tokenString string) error {
+	if m.revocations == nil {
+		return fmt.Errorf("token revocation is not configured")
+	}
+
+	claims, err := m.parseToken(tokenString)
+	if err != nil {
+		return err
+	}
+	if claims.ID == "" {
+		return fmt.Errorf("token has no ID")
+	}
+
+	_, err = m.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Add(m.refreshGrace))
+	return err
+}
+
+// RevokeUserTokens invalidates every token issued to a user up to now
+func (m *TokenManager) RevokeUserTokens(userID string) error {
+	if m.revocations == nil {
+		return fmt.Errorf("token revocation is not configured")
+	}
+
+	return m.revocations.RevokeUserTokens(userID, time.Now())
+}
+
+// checkRevoked rejects tokens whose ID is on the revocation list or that
+// were issued before the user's revocation cutoff
+func (m *TokenManager) checkRevoked(claims *Claims) error {
+	if m.revocations == nil {
+		return nil
+	}
+
+	if claims.ID != "" {
+		revoked, err := m.revocations.IsTokenRevoked(claims.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return fmt.Errorf("token revoked")
+		}
+	}
+
+	before, err := m.revocations.UserTokensRevokedBefore(claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	// iat is cut to issuedAtPrecision, so a token issued just after the cutoff can carry
+	// an earlier time than it; only tokens from before the cutoff's own step are revoked
+	if claims.IssuedAt.Before(before.Truncate(issuedAtPrecision)) {
+		return fmt.Errorf("token revoked")
+	}
+
+	return nil
+}
+
+// RefreshToken issues a new token for a valid or recently expired token.
+// The user's current details are re-read so role changes take effect.
+func (m *TokenManager) RefreshToken(old string) (string, error) {
+	claims, err := m.parseToken(old)
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(claims.ExpiresAt.Add(m.refreshGrace)) {
+		return "", fmt.Errorf("token expired beyond refresh grace period")
+	}
+
+	if err := m.checkRevoked(claims); err != nil {
+		return "", err
+	}
+
+	user := claims.ToUser()
+	if m.users != nil {
+		user, err = m.users.LookupUser(claims.UserID)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up user: %w", err)
+		}
+	}
+
+	// The old token must not be usable for another refresh. Revoking it is what claims
+	// the refresh, so of two racing with the same token only one gets a new one.
+	if m.revocations != nil && claims.ID != "" {
+		revoked, err := m.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Add(m.refreshGrace))
+		if err != nil {
+			return "", fmt.Errorf("failed to revoke old token: %w", err)
+		}
+		if !revoked {
+			return "", fmt.Errorf("token revoked")
+		}
+	}
+
+	return m.GenerateToken(user, claims.ExpiresAt.Sub(claims.IssuedAt))
+}
+
+// parseToken verifies the signature and decodes the claims without checking expiry
+func (m *TokenManager) parseToken(tokenString string) (*Claims, error) {
 	parts := strings.Split(tokenString, ".")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid token format")
+	if len(parts) == 2 {
+		// Tokens from before versioning were just claims.signature
+		return nil, ErrUnsupportedTokenVersion
+	}
+	if len(parts) == 3 && parts[0] == legacyTokenVersion {
+		if !m.acceptLegacy {
+			return nil, ErrUnsupportedTokenVersion
+		}
+		return m.parseLegacyToken(parts[0], parts[1], parts[2])
 	}
 
-	encodedClaims := parts[0]
-	signature := parts[1]
+	// Expiry is left to the callers, which allow different amounts of it
+	parsed := &jwtClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, parsed, m.verificationKey, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if parsed.Subject == "" || parsed.ExpiresAt == nil || parsed.IssuedAt == nil {
+		return nil, fmt.Errorf("invalid token: missing sub, exp or iat claim")
+	}
+
+	return &Claims{
+		ID:        parsed.ID,
+		UserID:    parsed.Subject,
+		Email:     parsed.Email,
+		Name:      parsed.Name,
+		Role:      parsed.Role,
+		ExpiresAt: parsed.ExpiresAt.Time,
+		IssuedAt:  parsed.IssuedAt.Time,
+	}, nil
+}
 
+// parseLegacyToken verifies and decodes a token in the pre-JWT format
+func (m *TokenManager) parseLegacyToken(version, encodedClaims, signature string) (*Claims, error) {
 	// Decode claims
 	claimsJSON, err := base64.StdEncoding.DecodeString(encodedClaims)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode claims: %w", err)
 	}
 
-	// Verify signature
-	h := hmac.New(sha256.New, []byte(m.secret))
-	h.Write(claimsJSON)
-	expectedSignature := base64.StdEncoding.EncodeToString(h.Sum(nil))
-
-	if !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
+	// Verify signature; legacy tokens name no key, so any of them may have signed it
+	valid := false
+	for _, key := range m.keys {
+		if hmac.Equal([]byte(signature), []byte(legacySignature(key.Secret, version, claimsJSON))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
 		return nil, fmt.Errorf("invalid token signature")
 	}
 
@@ -92,11 +423,6 @@ func (m *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("failed to parse claims: %w", err)
 	}
 
-	// Check expiration
-	if time.Now().After(claims.ExpiresAt) {
-		return nil, fmt.Errorf("token expired")
-	}
-
 	return claims, nil
 }
 
@@ -110,14 +436,39 @@ func (c *Claims) ToUser() *User {
 	}
 }
 
-// SetTokenCookie creates an HTTP-only cookie with the token
-func SetTokenCookie(w http.ResponseWriter, token string, expirationTime time.Duration) {
+// ErrNoToken is returned when a request carries no token at all
+var ErrNoToken = errors.New("no token provided")
+
+// TokenFromRequest extracts the token from the auth cookie or the Authorization header
+func TokenFromRequest(r *http.Request) (string, error) {
+	// First, try to get token from cookie (for HTML page requests)
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return cookie.Value, nil
+	}
+
+	// Fall back to Authorization header (for API requests from JS)
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", ErrNoToken
+	}
+
+	// Extract token from "Bearer <token>" format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", fmt.Errorf("invalid authorization header")
+	}
+
+	return parts[1], nil
+}
+
+// SetTokenCookie creates an HTTP-only cookie with the token that expires along with it
+func (m *TokenManager) SetTokenCookie(w http.ResponseWriter, token string, expirationTime time.Duration) {
 	cookie := &http.Cookie{
 		Name:     "auth_token",
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
+		Secure:   m.cookieSecure,
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(expirationTime.Seconds()),
 	}
@@ -125,13 +476,13 @@ func SetTokenCookie(w http.ResponseWriter, token string, expirationTime time.Dur
 }
 
 // ClearTokenCookie removes the auth token cookie
-func ClearTokenCookie(w http.ResponseWriter) {
+func (m *TokenManager) ClearTokenCookie(w http.ResponseWriter) {
 	cookie := &http.Cookie{
 		Name:     "auth_token",
 		Value:    "",
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   false,
+		Secure:   m.cookieSecure,
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   -1,
 	}
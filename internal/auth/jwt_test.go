@@ -0,0 +1,511 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memoryStore keeps revocations and users in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	cutoffs map[string]time.Time
+	users   map[string]*User
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		revoked: make(map[string]time.Time),
+		cutoffs: make(map[string]time.Time),
+		users:   make(map[string]*User),
+	}
+}
+
+func (s *memoryStore) RevokeToken(id string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.revoked[id]; ok {
+		return false, nil
+	}
+	s.revoked[id] = expiresAt
+	return true, nil
+}
+
+func (s *memoryStore) IsTokenRevoked(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+func (s *memoryStore) RevokeUserTokens(userID string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cutoffs[userID] = before
+	return nil
+}
+
+func (s *memoryStore) UserTokensRevokedBefore(userID string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cutoffs[userID], nil
+}
+
+func (s *memoryStore) LookupUser(id string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	copied := *user
+	return &copied, nil
+}
+
+var testUser = &User{ID: "user-1", Name: "alice", Email: "alice@example.com", Role: RoleUploader}
+
+// newTestManager creates a token manager backed by a memory store holding testUser
+func newTestManager() (*TokenManager, *memoryStore) {
+	store := newMemoryStore()
+	store.users[testUser.ID] = testUser
+	m := NewTokenManager("test-secret")
+	m.SetRevocationStore(store)
+	m.SetUserStore(store)
+	m.SetRefreshGrace(time.Hour)
+	return m, store
+}
+
+func TestRefreshTokenRereadsRole(t *testing.T) {
+	m, store := newTestManager()
+	old, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	store.users[testUser.ID] = &User{ID: testUser.ID, Name: testUser.Name, Email: testUser.Email, Role: RoleAdmin}
+	fresh, err := m.RefreshToken(old)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	claims, err := m.ValidateToken(fresh)
+	if err != nil {
+		t.Fatalf("refreshed token is invalid: %v", err)
+	}
+	if claims.Role != RoleAdmin {
+		t.Errorf("refreshed role = %s, want admin", claims.Role)
+	}
+
+	if _, err := m.ValidateToken(old); err == nil {
+		t.Error("refreshed-away token is still valid")
+	}
+	if _, err := m.RefreshToken(old); err == nil {
+		t.Error("token was refreshed twice")
+	}
+}
+
+func TestRefreshTokenGraceWindow(t *testing.T) {
+	m, _ := newTestManager()
+
+	recent, err := m.GenerateToken(testUser, -30*time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := m.RefreshToken(recent); err != nil {
+		t.Errorf("token expired within the grace window was not refreshed: %v", err)
+	}
+
+	stale, err := m.GenerateToken(testUser, -2*time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := m.RefreshToken(stale); err == nil {
+		t.Error("token expired beyond the grace window was refreshed")
+	}
+}
+
+func TestRefreshTokenConcurrentRefreshesHaveOneWinner(t *testing.T) {
+	m, _ := newTestManager()
+	old, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	const attempts = 16
+	var wg sync.WaitGroup
+	results := make(chan error, attempts)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.RefreshToken(old)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want exactly 1", succeeded)
+	}
+}
+
+func TestValidateTokenExpiry(t *testing.T) {
+	m, _ := newTestManager()
+
+	valid, err := m.GenerateToken(testUser, time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := m.ValidateToken(valid); err != nil {
+		t.Errorf("unexpired token rejected: %v", err)
+	}
+
+	expired, err := m.GenerateToken(testUser, -time.Second)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := m.ValidateToken(expired); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("ValidateToken of an expired token = %v, want an expiry error", err)
+	}
+}
+
+func TestValidateTokenRejectsTampering(t *testing.T) {
+	m, _ := newTestManager()
+	token, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	header, payload, signature := splitToken(t, token)
+
+	// The same claims with the role raised, under the original signature
+	var claims map[string]any
+	decodeSegment(t, payload, &claims)
+	claims["role"] = string(RoleAdmin)
+	promoted := header + "." + encodeSegment(t, claims) + "." + signature
+
+	flipped := []byte(signature)
+	if flipped[0] == 'A' {
+		flipped[0] = 'B'
+	} else {
+		flipped[0] = 'A'
+	}
+
+	other := NewTokenManager("another-secret")
+	foreign, err := other.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	for name, tampered := range map[string]string{
+		"payload":      promoted,
+		"signature":    header + "." + payload + "." + string(flipped),
+		"no signature": header + "." + payload + ".",
+		"other secret": foreign,
+	} {
+		if _, err := m.ValidateToken(tampered); err == nil {
+			t.Errorf("%s: tampered token accepted", name)
+		}
+	}
+}
+
+func TestValidateTokenRejectsOtherAlgorithms(t *testing.T) {
+	m, _ := newTestManager()
+	claims := jwtClaims{
+		Role:     RoleAdmin,
+		IssuedAt: &issuedAt{time.Now()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "forged",
+			Subject:   testUser.ID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	for _, tc := range []struct {
+		method jwt.SigningMethod
+		key    any
+	}{
+		{jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType},
+		{jwt.SigningMethodHS384, []byte("test-secret")},
+		{jwt.SigningMethodHS512, []byte("test-secret")},
+	} {
+		token, err := jwt.NewWithClaims(tc.method, claims).SignedString(tc.key)
+		if err != nil {
+			t.Fatalf("%s: SignedString: %v", tc.method.Alg(), err)
+		}
+		if _, err := m.ValidateToken(token); err == nil {
+			t.Errorf("token signed with %s accepted", tc.method.Alg())
+		}
+	}
+}
+
+func TestIssuedAtKeepsMicroseconds(t *testing.T) {
+	m, _ := newTestManager()
+	before := time.Now().Truncate(time.Microsecond)
+	token, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	claims, err := m.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.IssuedAt.Before(before) || claims.IssuedAt.After(time.Now()) {
+		t.Errorf("IssuedAt = %v, want the time the token was issued (after %v)", claims.IssuedAt, before)
+	}
+	if jwt.TimePrecision != time.Second {
+		t.Errorf("jwt.TimePrecision = %v, want the library default left alone", jwt.TimePrecision)
+	}
+}
+
+func TestIssuedAtUnmarshal(t *testing.T) {
+	for _, tc := range []struct {
+		json string
+		want time.Time
+	}{
+		{"1712345678", time.Unix(1712345678, 0)},
+		{"1712345678.123456", time.Unix(1712345678, 123456000)},
+		{"1712345678.5", time.Unix(1712345678, 500000000)},
+		{"1712345678.123456789", time.Unix(1712345678, 123456000)},
+		{"1.712345678e9", time.Unix(1712345678, 0)},
+	} {
+		var got issuedAt
+		if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+			t.Errorf("%s: %v", tc.json, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.json, got.Time, tc.want)
+		}
+	}
+
+	var got issuedAt
+	if err := json.Unmarshal([]byte(`"soon"`), &got); err == nil {
+		t.Error("a string iat was accepted")
+	}
+}
+
+func TestRevokeUserTokensCutsOffEarlierTokensOnly(t *testing.T) {
+	m, store := newTestManager()
+	for range 20 {
+		earlier, err := m.GenerateToken(testUser, time.Hour)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		if err := m.RevokeUserTokens(testUser.ID); err != nil {
+			t.Fatalf("RevokeUserTokens: %v", err)
+		}
+		later, err := m.GenerateToken(testUser, time.Hour)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+
+		if _, err := m.ValidateToken(later); err != nil {
+			t.Fatalf("token issued right after the cutoff rejected: %v", err)
+		}
+		if _, err := m.ValidateToken(earlier); err == nil {
+			// Only a token from the cutoff's own microsecond can't be told apart from a later one
+			claims, _ := m.parseToken(earlier)
+			if !claims.IssuedAt.Equal(store.cutoffs[testUser.ID].Truncate(issuedAtPrecision)) {
+				t.Fatal("token issued before the cutoff is still valid")
+			}
+		}
+	}
+}
+
+// splitToken returns the three segments of a JWT
+func splitToken(t *testing.T, token string) (header, payload, signature string) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d segments, want 3", len(parts))
+	}
+	return parts[0], parts[1], parts[2]
+}
+
+// decodeSegment decodes a base64url JSON segment into v
+func decodeSegment(t *testing.T, segment string, v any) {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		t.Fatalf("failed to decode segment: %v", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatalf("failed to parse segment: %v", err)
+	}
+}
+
+// encodeSegment encodes v as a base64url JSON segment
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to encode segment: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// tokenKID returns the kid header of a JWT, or "" without one
+func tokenKID(t *testing.T, token string) string {
+	t.Helper()
+	header, _, _ := splitToken(t, token)
+	var fields map[string]any
+	decodeSegment(t, header, &fields)
+	kid, _ := fields["kid"].(string)
+	return kid
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	m, _ := newTestManager()
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k1", Secret: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	old, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if kid := tokenKID(t, old); kid != "k1" {
+		t.Fatalf("kid = %q, want k1", kid)
+	}
+
+	// A new key in front signs from now on; the old one still validates its tokens
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k2", Secret: "two"}, {ID: "k1", Secret: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(old); err != nil {
+		t.Errorf("token of the previous key rejected during rotation: %v", err)
+	}
+	fresh, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if kid := tokenKID(t, fresh); kid != "k2" {
+		t.Errorf("new token kid = %q, want k2", kid)
+	}
+	if _, err := m.ValidateToken(fresh); err != nil {
+		t.Errorf("token of the new key rejected: %v", err)
+	}
+
+	// Refreshing an old token moves it to the new key
+	refreshed, err := m.RefreshToken(old)
+	if err != nil {
+		t.Fatalf("RefreshToken of a previous key's token: %v", err)
+	}
+	if kid := tokenKID(t, refreshed); kid != "k2" {
+		t.Errorf("refreshed token kid = %q, want k2", kid)
+	}
+
+	// Once the old key is dropped its tokens stop working
+	other, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k1", Secret: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(other); err == nil {
+		t.Error("token of a removed key still validates")
+	}
+}
+
+func TestSigningKeyWithoutIDValidatesKidlessTokens(t *testing.T) {
+	m, _ := newTestManager()
+	// Issued from AUTH_SECRET before key IDs were configured
+	kidless, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if kid := tokenKID(t, kidless); kid != "" {
+		t.Fatalf("kid = %q, want none", kid)
+	}
+
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k1", Secret: "one"}, {Secret: "test-secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(kidless); err != nil {
+		t.Errorf("kid-less token rejected while its secret is configured: %v", err)
+	}
+
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k1", Secret: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ValidateToken(kidless); err == nil {
+		t.Error("kid-less token still validates after its secret was removed")
+	}
+}
+
+func TestTokenKIDPinsTheVerificationKey(t *testing.T) {
+	m, _ := newTestManager()
+	if err := m.SetSigningKeys([]SigningKey{{ID: "k2", Secret: "two"}, {ID: "k1", Secret: "one"}}); err != nil {
+		t.Fatal(err)
+	}
+	claims := jwtClaims{
+		IssuedAt: &issuedAt{time.Now()},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "pinned",
+			Subject:   testUser.ID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	for _, tc := range []struct {
+		kid    string
+		secret string
+	}{
+		{"k1", "two"},
+		{"k9", "two"},
+		{"", "one"},
+	} {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		if tc.kid != "" {
+			token.Header["kid"] = tc.kid
+		}
+		signed, err := token.SignedString([]byte(tc.secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = m.ValidateToken(signed)
+		// Kid-less tokens may match any key, but a kid pins the one it names
+		if tc.kid == "" && err != nil {
+			t.Errorf("kid-less token signed with a listed key rejected: %v", err)
+		}
+		if tc.kid != "" && err == nil {
+			t.Errorf("kid %q signed with secret %q accepted", tc.kid, tc.secret)
+		}
+	}
+}
+
+func TestSetSigningKeysRejectsInvalidSets(t *testing.T) {
+	m, _ := newTestManager()
+	for name, keys := range map[string][]SigningKey{
+		"empty":          nil,
+		"no secret":      {{ID: "k1"}},
+		"duplicate ID":   {{ID: "k1", Secret: "one"}, {ID: "k1", Secret: "two"}},
+		"two without ID": {{Secret: "one"}, {Secret: "two"}},
+	} {
+		if err := m.SetSigningKeys(keys); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// A rejected set leaves the keys as they were
+	token, err := m.GenerateToken(testUser, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if kid := tokenKID(t, token); kid != "" {
+		t.Errorf("kid = %q after rejected key sets, want the original kid-less key", kid)
+	}
+}
\ No newline at end of file
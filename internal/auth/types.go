@@ -49,6 +49,12 @@ var PermissionMap = map[Role]Permission{
 	},
 }
 
+// Valid reports whether r is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := PermissionMap[r]
+	return ok
+}
+
 // ContextKey for storing user in context
 type ContextKey string
 
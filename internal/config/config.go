@@ -1,8 +1,16 @@
 package config
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds the application configuration
@@ -12,12 +20,59 @@ type Config struct {
 	Log      LogConfig
 	Database DatabaseConfig
 	Auth     AuthConfig
+	Approval ApprovalConfig
+	Keys     KeyPolicyConfig
+	Uploads  UploadTypeConfig
+	Canary   CanaryConfig
+	Trash    TrashConfig
+	Metrics  MetricsConfig
+	Mail     MailConfig
+
+	// RateLimits holds the named rate limit policies, keyed by policy name
+	RateLimits map[string]RateLimitSpec
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Port string
-	Host string
+	Port          string
+	Host          string
+	BaseURL       string
+	MaxUploadSize int64
+	// UserQuota is the default number of bytes each user may store; 0 means unlimited.
+	// Admins can override it per user
+	UserQuota int64
+	// ShutdownTimeout bounds how long in-flight requests may run after a shutdown signal
+	ShutdownTimeout time.Duration
+	// HealthCheckTimeout bounds each dependency check of the readiness probe
+	HealthCheckTimeout time.Duration
+	// GzipMinSize is the smallest JSON response compressed for clients that accept gzip
+	GzipMinSize int64
+
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSMinVersion is the oldest TLS version accepted: 1.0, 1.1, 1.2 or 1.3
+	TLSMinVersion string
+	// HTTPRedirectAddr, when set with TLS, serves plain HTTP redirects to HTTPS on this address
+	HTTPRedirectAddr string
+}
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values to their protocol versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// MinTLSVersion returns the configured minimum TLS version as a crypto/tls constant
+func (c ServerConfig) MinTLSVersion() uint16 {
+	return tlsVersions[c.TLSMinVersion]
 }
 
 // S3Config holds S3/MinIO configuration
@@ -27,8 +82,151 @@ type S3Config struct {
 	Bucket    string
 	AccessKey string
 	SecretKey string
+	// SSE is the server-side encryption applied to stored objects: "none", "AES256" or "aws:kms"
+	SSE string
+	// SSEKMSKeyID selects the KMS key when SSE is "aws:kms"; empty uses the bucket's default key
+	SSEKMSKeyID string
+	// Buckets names the extra buckets the API can address besides Bucket; see BucketRegistry
+	Buckets string
+	// AutoCreateBucket creates every configured bucket at startup when it doesn't exist
+	AutoCreateBucket bool
+	// VerifyPermissions runs the put, head, get and delete self-test on every bucket at startup
+	VerifyPermissions bool
+	// UsageCacheTTL is how long a bucket usage scan is reused; 0 scans on every request
+	UsageCacheTTL time.Duration
+
+	// MaxAttempts is how many times a failing S3 request is tried, counting the first attempt
+	MaxAttempts int
+	// RetryMode is the SDK retry strategy: "standard" or "adaptive"
+	RetryMode string
+	// OperationTimeout bounds each S3 call; 0 disables it
+	OperationTimeout time.Duration
+	// DialTimeout bounds opening a connection to the endpoint
+	DialTimeout time.Duration
+	// MaxConcurrentOps caps object uploads and downloads running against the backend at once; 0 disables the cap
+	MaxConcurrentOps int
+	// ConcurrencyWait is how long an operation waits for a free slot before failing as busy
+	ConcurrencyWait time.Duration
+	// SlowOperationThreshold is how long an S3 call may take before it is logged as slow; 0 disables it
+	SlowOperationThreshold time.Duration
+
+	// ReadCacheSize is the memory given to caching small objects; 0 disables the cache
+	ReadCacheSize int64
+	// ReadCacheMaxObject is the largest object the read cache keeps
+	ReadCacheMaxObject int64
+	// ReadCacheTTL is how long a cached object is served before its ETag is checked again
+	ReadCacheTTL time.Duration
+
+	// ListConcurrency is how many folders are listed at once when walking a whole bucket
+	ListConcurrency int
+
+	// MultipartMaxAge is how old an incomplete multipart upload may get before it is aborted
+	MultipartMaxAge time.Duration
+	// MultipartCleanupInterval is how often stale multipart uploads are looked for; 0 disables it
+	MultipartCleanupInterval time.Duration
+
+	// LifecycleSweepInterval is how often emulated lifecycle rules are enforced, for
+	// backends without lifecycle support; 0 disables it
+	LifecycleSweepInterval time.Duration
+
+	// Secondary is the endpoint changes to the primary bucket are mirrored to
+	Secondary S3SecondaryConfig
+}
+
+// S3SecondaryConfig holds the replication target. Replication is on when Endpoint is
+// set; Region, Bucket and the credentials default to the primary's.
+type S3SecondaryConfig struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// RetryInterval is how often the replication queue is checked for entries due a retry
+	RetryInterval time.Duration
+}
+
+// Enabled reports whether uploads and deletes are replicated to the secondary endpoint
+func (c S3SecondaryConfig) Enabled() bool {
+	return c.Endpoint != ""
 }
 
+// NamedBucket is a bucket the API can address by Name through the bucket parameter
+type NamedBucket struct {
+	Name   string `json:"name"`
+	Bucket string `json:"bucket"`
+}
+
+// bucketNamePattern restricts registry names to characters that are safe in query strings
+var bucketNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// BucketRegistry returns every bucket the API can address, the primary first under its own
+// name. Buckets is either a comma-separated list of name=bucket pairs, where a bare bucket
+// is named after itself, or a JSON array of bucket names and {"name", "bucket"} objects.
+func (c S3Config) BucketRegistry() ([]NamedBucket, error) {
+	var extra []NamedBucket
+	value := strings.TrimSpace(c.Buckets)
+	if strings.HasPrefix(value, "[") {
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(value), &items); err != nil {
+			return nil, fmt.Errorf("S3_BUCKETS is not a valid JSON array: %w", err)
+		}
+		for _, item := range items {
+			var entry NamedBucket
+			if err := json.Unmarshal(item, &entry.Bucket); err != nil {
+				if err := json.Unmarshal(item, &entry); err != nil {
+					return nil, fmt.Errorf("S3_BUCKETS entries must be bucket names or {\"name\", \"bucket\"} objects")
+				}
+			}
+			extra = append(extra, entry)
+		}
+	} else if value != "" {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			name, bucket, ok := strings.Cut(item, "=")
+			if !ok {
+				bucket = name
+			}
+			extra = append(extra, NamedBucket{Name: strings.TrimSpace(name), Bucket: strings.TrimSpace(bucket)})
+		}
+	}
+
+	registry := []NamedBucket{{Name: c.Bucket, Bucket: c.Bucket}}
+	seen := map[string]string{c.Bucket: c.Bucket}
+	for _, entry := range extra {
+		if entry.Name == "" {
+			entry.Name = entry.Bucket
+		}
+		if entry.Bucket == "" || !bucketNamePattern.MatchString(entry.Name) {
+			return nil, fmt.Errorf("S3_BUCKETS entry %q needs a bucket and a name of letters, digits, '.', '_' or '-'", entry.Name)
+		}
+		if bucket, ok := seen[entry.Name]; ok {
+			// Listing the primary again is harmless, naming two buckets the same is not
+			if bucket == entry.Bucket {
+				continue
+			}
+			return nil, fmt.Errorf("S3_BUCKETS names %q more than once", entry.Name)
+		}
+		seen[entry.Name] = entry.Bucket
+		registry = append(registry, entry)
+	}
+	return registry, nil
+}
+
+// S3 client retry modes accepted in S3Config.RetryMode
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
+// Server-side encryption modes accepted in S3Config.SSE
+const (
+	SSENone   = "none"
+	SSEAES256 = "AES256"
+	SSEKMS    = "aws:kms"
+)
+
 // LogConfig holds logging configuration
 type LogConfig struct {
 	Level string
@@ -41,23 +239,257 @@ type DatabaseConfig struct {
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Secret      string
-	SignupKey   string
+	Secret           string
+	SignupKey        string
+	PasswordResetTTL time.Duration
+	RefreshGrace     time.Duration
+	LoginMaxAttempts int
+	LoginWindow      time.Duration
+
+	// TokenTTL is how long issued tokens, and the auth cookie carrying them, are valid
+	TokenTTL time.Duration
+	// CookieSecure marks the auth cookie Secure; turn it off only for local HTTP
+	CookieSecure bool
+	// AcceptLegacyTokens keeps accepting tokens issued before the switch to JWT
+	AcceptLegacyTokens bool
+
+	// SigningKeys is a JSON array of {"id", "secret"} token signing keys, the one that
+	// signs new tokens first; see SigningKeyRing
+	SigningKeys string
+
+	EmailVerificationTTL time.Duration
+	RequireVerifiedEmail bool
+}
+
+// SigningKey is a token signing secret configured in AUTH_SIGNING_KEYS
+type SigningKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// SigningKeyRing returns the token signing keys, the one that signs new tokens first.
+// Without SigningKeys, Secret is the only key. With them, a Secret that isn't among them
+// is kept last, without ID, so tokens it signed before the keys were configured still
+// validate; unset AUTH_SECRET once they have expired.
+func (c AuthConfig) SigningKeyRing() ([]SigningKey, error) {
+	value := strings.TrimSpace(c.SigningKeys)
+	if value == "" {
+		return []SigningKey{{Secret: c.Secret}}, nil
+	}
+
+	var keys []SigningKey
+	if err := json.Unmarshal([]byte(value), &keys); err != nil {
+		return nil, fmt.Errorf("AUTH_SIGNING_KEYS is not a valid JSON array of {\"id\", \"secret\"} objects: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("AUTH_SIGNING_KEYS must list at least one key")
+	}
+
+	seen := make(map[string]bool, len(keys))
+	secrets := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || key.Secret == "" {
+			return nil, fmt.Errorf("every AUTH_SIGNING_KEYS entry needs an id and a secret")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("AUTH_SIGNING_KEYS lists key %q more than once", key.ID)
+		}
+		seen[key.ID] = true
+		secrets[key.Secret] = true
+	}
+
+	if c.Secret != "" && !secrets[c.Secret] {
+		keys = append(keys, SigningKey{Secret: c.Secret})
+	}
+	return keys, nil
+}
+
+// ApprovableActions are the admin actions APPROVAL_REQUIRED_ACTIONS can put behind a
+// second admin's approval
+var ApprovableActions = []string{"delete_admin", "demote_admin", "place_legal_hold", "release_legal_hold", "delete_prefix", "empty_trash"}
+
+// ApprovalConfig holds two-person approval configuration
+type ApprovalConfig struct {
+	Actions  []string
+	TTL      time.Duration
+	Disabled bool
+}
+
+// KeyPolicyConfig holds the object key normalization policy applied at upload
+type KeyPolicyConfig struct {
+	LowercaseExtensions bool
+	NFC                 bool
+	CollapseWhitespace  bool
+	WarnNearDuplicates  bool
+}
+
+// UploadTypeConfig restricts which files can be uploaded. Types are media types or
+// wildcards such as "image/*", extensions include the dot; an empty allow list allows
+// everything that isn't denied
+type UploadTypeConfig struct {
+	AllowedTypes      []string
+	DeniedTypes       []string
+	AllowedExtensions []string
+	DeniedExtensions  []string
+}
+
+// TrashConfig holds how long deleted files are kept before being purged
+type TrashConfig struct {
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+// MetricsConfig controls the Prometheus /metrics endpoint
+type MetricsConfig struct {
+	Enabled bool
+	// RequireAuth serves /metrics only to admins and to scrapers sending ScrapeToken
+	RequireAuth bool
+	// ScrapeToken is a bearer token that may read /metrics without a user account
+	ScrapeToken string
+}
+
+// MailConfig controls how outgoing email is delivered
+type MailConfig struct {
+	// LogDelivery logs outgoing messages, without their bodies, instead of sending
+	// them; for local development only
+	LogDelivery bool
+}
+
+// CanaryConfig holds the storage canary configuration
+type CanaryConfig struct {
+	Interval   time.Duration
+	Size       int
+	Seed       int64
+	AlertAfter int
+}
+
+// RateLimitSpec describes one rate limit policy: Rate requests per Per, with
+// bursts of up to Burst, counted per client IP or per user
+type RateLimitSpec struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+	Key   string
+}
+
+// Rate limit keys
+const (
+	RateLimitByIP   = "ip"
+	RateLimitByUser = "user"
+)
+
+// String formats the spec the way ParseRateLimitSpec reads it
+func (s RateLimitSpec) String() string {
+	// Drop the zero units time.Duration prints, so 1m0s reads as 1m
+	per := s.Per.String()
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return fmt.Sprintf("%d/%s,burst=%d,key=%s", s.Rate, per, s.Burst, s.Key)
+}
+
+// ParseRateLimitSpec parses a spec such as "120/1m,burst=40,key=user".
+// Burst defaults to the rate and key to "ip".
+func ParseRateLimitSpec(value string) (RateLimitSpec, error) {
+	parts := strings.Split(value, ",")
+
+	rate, per, ok := strings.Cut(strings.TrimSpace(parts[0]), "/")
+	if !ok {
+		return RateLimitSpec{}, fmt.Errorf("rate limit %q must start with <requests>/<duration>", value)
+	}
+
+	spec := RateLimitSpec{Key: RateLimitByIP}
+	var err error
+	if spec.Rate, err = strconv.Atoi(rate); err != nil || spec.Rate <= 0 {
+		return RateLimitSpec{}, fmt.Errorf("rate limit %q has an invalid request count", value)
+	}
+	if spec.Per, err = time.ParseDuration(per); err != nil || spec.Per <= 0 {
+		return RateLimitSpec{}, fmt.Errorf("rate limit %q has an invalid duration", value)
+	}
+	spec.Burst = spec.Rate
+
+	for _, part := range parts[1:] {
+		name, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "burst":
+			if spec.Burst, err = strconv.Atoi(v); err != nil || spec.Burst <= 0 {
+				return RateLimitSpec{}, fmt.Errorf("rate limit %q has an invalid burst", value)
+			}
+		case "key":
+			if v != RateLimitByIP && v != RateLimitByUser {
+				return RateLimitSpec{}, fmt.Errorf("rate limit %q key must be %q or %q", value, RateLimitByIP, RateLimitByUser)
+			}
+			spec.Key = v
+		default:
+			return RateLimitSpec{}, fmt.Errorf("rate limit %q has unknown option %q", value, name)
+		}
+	}
+
+	return spec, nil
 }
 
 // NewConfig creates a new configuration from environment variables
 func NewConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "8080"),
-			Host: getEnv("HOST", "0.0.0.0"),
+			Port:               getEnv("PORT", "8080"),
+			Host:               getEnv("HOST", "0.0.0.0"),
+			BaseURL:            strings.TrimRight(getEnv("BASE_URL", "http://localhost:8080"), "/"),
+			MaxUploadSize:      getEnvSize("MAX_UPLOAD_SIZE", 500<<20),
+			UserQuota:          getEnvSize("USER_QUOTA", 0),
+			ShutdownTimeout:    getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			HealthCheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			GzipMinSize:        getEnvSize("GZIP_MIN_SIZE", 1<<10),
+
+			TLSCertFile:      getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:       getEnv("TLS_KEY_FILE", ""),
+			TLSMinVersion:    getEnv("TLS_MIN_VERSION", "1.2"),
+			HTTPRedirectAddr: getEnv("HTTP_REDIRECT_ADDR", ""),
 		},
 		S3: S3Config{
-			Endpoint:  getEnv("S3_ENDPOINT", ""),
-			Region:    getEnv("S3_REGION", ""),
-			Bucket:    getEnv("S3_BUCKET", ""),
-			AccessKey: getEnv("S3_ACCESS_KEY", ""),
-			SecretKey: getEnv("S3_SECRET_KEY", ""),
+			Endpoint:         getEnv("S3_ENDPOINT", ""),
+			Region:           getEnv("S3_REGION", ""),
+			Bucket:           getEnv("S3_BUCKET", ""),
+			AccessKey:        getEnv("S3_ACCESS_KEY", ""),
+			SecretKey:        getEnv("S3_SECRET_KEY", ""),
+			SSE:              getEnv("S3_SSE", SSENone),
+			SSEKMSKeyID:      getEnv("S3_SSE_KMS_KEY_ID", ""),
+			Buckets:          getEnv("S3_BUCKETS", ""),
+			AutoCreateBucket: getEnvBool("S3_AUTO_CREATE_BUCKET", false),
+			UsageCacheTTL:    getEnvDuration("S3_USAGE_CACHE_TTL", 5*time.Minute),
+			MaxAttempts:      getEnvInt("S3_MAX_ATTEMPTS", 3),
+			RetryMode:        getEnv("S3_RETRY_MODE", RetryModeStandard),
+			OperationTimeout: getEnvDuration("S3_OPERATION_TIMEOUT", 10*time.Minute),
+			DialTimeout:      getEnvDuration("S3_DIAL_TIMEOUT", 10*time.Second),
+			MaxConcurrentOps: getEnvInt("S3_MAX_CONCURRENT_OPS", 64),
+			ConcurrencyWait:  getEnvDuration("S3_CONCURRENCY_WAIT", 5*time.Second),
+
+			VerifyPermissions: getEnvBool("S3_VERIFY_PERMISSIONS", false),
+
+			SlowOperationThreshold: getEnvDuration("S3_SLOW_OPERATION_THRESHOLD", 10*time.Second),
+
+			ReadCacheSize:      getEnvSize("S3_READ_CACHE_SIZE", 0),
+			ReadCacheMaxObject: getEnvSize("S3_READ_CACHE_MAX_OBJECT", 1<<20),
+			ReadCacheTTL:       getEnvDuration("S3_READ_CACHE_TTL", time.Minute),
+
+			ListConcurrency: getEnvInt("S3_LIST_CONCURRENCY", 8),
+
+			MultipartMaxAge:          getEnvDuration("S3_MULTIPART_MAX_AGE", 24*time.Hour),
+			MultipartCleanupInterval: getEnvDuration("S3_MULTIPART_CLEANUP_INTERVAL", time.Hour),
+
+			LifecycleSweepInterval: getEnvDuration("S3_LIFECYCLE_SWEEP_INTERVAL", time.Hour),
+
+			Secondary: S3SecondaryConfig{
+				Endpoint:      getEnv("S3_SECONDARY_ENDPOINT", ""),
+				Region:        getEnv("S3_SECONDARY_REGION", ""),
+				Bucket:        getEnv("S3_SECONDARY_BUCKET", ""),
+				AccessKey:     getEnv("S3_SECONDARY_ACCESS_KEY", ""),
+				SecretKey:     getEnv("S3_SECONDARY_SECRET_KEY", ""),
+				RetryInterval: getEnvDuration("S3_SECONDARY_RETRY_INTERVAL", 30*time.Second),
+			},
 		},
 		Log: LogConfig{
 			Level: getEnv("LOG_LEVEL", "info"),
@@ -68,12 +500,94 @@ func NewConfig() *Config {
 		Auth: AuthConfig{
 			Secret:    getEnv("AUTH_SECRET", ""),
 			SignupKey: getEnv("SIGNUP_KEY", ""),
+
+			PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+			RefreshGrace:     getEnvDuration("AUTH_REFRESH_GRACE", time.Hour),
+			LoginMaxAttempts: getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+			LoginWindow:      getEnvDuration("LOGIN_RATE_WINDOW", 15*time.Minute),
+
+			TokenTTL:     getEnvDuration("AUTH_TOKEN_TTL", 24*time.Hour),
+			CookieSecure: getEnvBool("AUTH_COOKIE_SECURE", true),
+
+			AcceptLegacyTokens: getEnvBool("AUTH_ACCEPT_LEGACY_TOKENS", true),
+
+			SigningKeys: getEnv("AUTH_SIGNING_KEYS", ""),
+
+			EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+			RequireVerifiedEmail: getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+		},
+		Approval: ApprovalConfig{
+			Actions:  getEnvList("APPROVAL_REQUIRED_ACTIONS", []string{"delete_admin", "demote_admin", "place_legal_hold", "release_legal_hold", "delete_prefix", "empty_trash"}),
+			TTL:      getEnvDuration("APPROVAL_TTL", 24*time.Hour),
+			Disabled: getEnvBool("APPROVAL_DISABLED", false),
+		},
+		Keys: KeyPolicyConfig{
+			LowercaseExtensions: getEnvBool("KEY_LOWERCASE_EXTENSIONS", false),
+			NFC:                 getEnvBool("KEY_NORMALIZE_NFC", false),
+			CollapseWhitespace:  getEnvBool("KEY_COLLAPSE_WHITESPACE", false),
+			WarnNearDuplicates:  getEnvBool("KEY_WARN_NEAR_DUPLICATES", false),
+		},
+		Uploads: UploadTypeConfig{
+			AllowedTypes:      getEnvList("UPLOAD_ALLOWED_TYPES", nil),
+			DeniedTypes:       getEnvList("UPLOAD_DENIED_TYPES", []string{"text/html", "application/xhtml+xml"}),
+			AllowedExtensions: getEnvList("UPLOAD_ALLOWED_EXTENSIONS", nil),
+			DeniedExtensions:  getEnvList("UPLOAD_DENIED_EXTENSIONS", []string{".exe", ".dll", ".msi", ".bat", ".cmd", ".scr", ".ps1", ".html", ".htm", ".xhtml"}),
+		},
+		RateLimits: map[string]RateLimitSpec{
+			"auth-strict":    getEnvRateLimit("RATE_LIMIT_AUTH_STRICT", RateLimitSpec{Rate: 10, Per: time.Minute, Burst: 10, Key: RateLimitByIP}),
+			"refresh-burst":  getEnvRateLimit("RATE_LIMIT_REFRESH_BURST", RateLimitSpec{Rate: 30, Per: time.Minute, Burst: 60, Key: RateLimitByIP}),
+			"api-default":    getEnvRateLimit("RATE_LIMIT_API_DEFAULT", RateLimitSpec{Rate: 300, Per: time.Minute, Burst: 100, Key: RateLimitByUser}),
+			"download-heavy": getEnvRateLimit("RATE_LIMIT_DOWNLOAD_HEAVY", RateLimitSpec{Rate: 60, Per: time.Minute, Burst: 20, Key: RateLimitByUser}),
+			"share-public":   getEnvRateLimit("RATE_LIMIT_SHARE_PUBLIC", RateLimitSpec{Rate: 30, Per: time.Minute, Burst: 10, Key: RateLimitByIP}),
+			"inbox-public":   getEnvRateLimit("RATE_LIMIT_INBOX_PUBLIC", RateLimitSpec{Rate: 10, Per: time.Minute, Burst: 5, Key: RateLimitByIP}),
+		},
+		Canary: CanaryConfig{
+			Interval:   getEnvDuration("CANARY_INTERVAL", 5*time.Minute),
+			Size:       getEnvInt("CANARY_SIZE", 4096),
+			Seed:       int64(getEnvInt("CANARY_SEED", 1)),
+			AlertAfter: getEnvInt("CANARY_ALERT_AFTER", 3),
+		},
+		Trash: TrashConfig{
+			Retention:     getEnvDuration("TRASH_RETENTION", 7*24*time.Hour),
+			PurgeInterval: getEnvDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		},
+		Metrics: MetricsConfig{
+			Enabled:     getEnvBool("METRICS_ENABLED", true),
+			RequireAuth: getEnvBool("METRICS_REQUIRE_AUTH", false),
+			ScrapeToken: getEnv("METRICS_SCRAPE_TOKEN", ""),
+		},
+		Mail: MailConfig{
+			LogDelivery: getEnvBool("MAIL_LOG_DELIVERY", false),
 		},
 	}
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
+	if c.Server.MaxUploadSize <= 0 {
+		return fmt.Errorf("MAX_UPLOAD_SIZE must be positive")
+	}
+	if c.Server.UserQuota < 0 {
+		return fmt.Errorf("USER_QUOTA must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.Server.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_TIMEOUT must be positive")
+	}
+	if c.Server.GzipMinSize < 0 {
+		return fmt.Errorf("GZIP_MIN_SIZE must not be negative")
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, ok := tlsVersions[c.Server.TLSMinVersion]; !ok {
+		return fmt.Errorf("TLS_MIN_VERSION must be 1.0, 1.1, 1.2 or 1.3")
+	}
+	if c.Server.HTTPRedirectAddr != "" && !c.Server.TLSEnabled() {
+		return fmt.Errorf("HTTP_REDIRECT_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
 	if c.S3.Endpoint == "" {
 		return fmt.Errorf("S3_ENDPOINT is required")
 	}
@@ -89,12 +603,124 @@ func (c *Config) Validate() error {
 	if c.S3.SecretKey == "" {
 		return fmt.Errorf("S3_SECRET_KEY is required")
 	}
-	if c.Auth.Secret == "" {
-		return fmt.Errorf("AUTH_SECRET is required")
+	if _, err := c.S3.BucketRegistry(); err != nil {
+		return err
+	}
+	if c.S3.SSE != SSENone && c.S3.SSE != SSEAES256 && c.S3.SSE != SSEKMS {
+		return fmt.Errorf("S3_SSE must be %q, %q or %q", SSENone, SSEAES256, SSEKMS)
+	}
+	if c.S3.SSEKMSKeyID != "" && c.S3.SSE != SSEKMS {
+		return fmt.Errorf("S3_SSE_KMS_KEY_ID requires S3_SSE=%s", SSEKMS)
+	}
+	if c.S3.UsageCacheTTL < 0 {
+		return fmt.Errorf("S3_USAGE_CACHE_TTL must not be negative")
+	}
+	if c.S3.MaxAttempts < 1 {
+		return fmt.Errorf("S3_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.S3.RetryMode != RetryModeStandard && c.S3.RetryMode != RetryModeAdaptive {
+		return fmt.Errorf("S3_RETRY_MODE must be %q or %q", RetryModeStandard, RetryModeAdaptive)
+	}
+	if c.S3.OperationTimeout < 0 {
+		return fmt.Errorf("S3_OPERATION_TIMEOUT must not be negative")
+	}
+	if c.S3.DialTimeout <= 0 {
+		return fmt.Errorf("S3_DIAL_TIMEOUT must be positive")
+	}
+	if c.S3.MaxConcurrentOps < 0 {
+		return fmt.Errorf("S3_MAX_CONCURRENT_OPS must not be negative")
+	}
+	if c.S3.ConcurrencyWait < 0 {
+		return fmt.Errorf("S3_CONCURRENCY_WAIT must not be negative")
+	}
+	if c.S3.SlowOperationThreshold < 0 {
+		return fmt.Errorf("S3_SLOW_OPERATION_THRESHOLD must not be negative")
+	}
+	if c.S3.ReadCacheSize < 0 {
+		return fmt.Errorf("S3_READ_CACHE_SIZE must not be negative")
+	}
+	if c.S3.ReadCacheSize > 0 && c.S3.ReadCacheMaxObject <= 0 {
+		return fmt.Errorf("S3_READ_CACHE_MAX_OBJECT must be positive")
+	}
+	if c.S3.ReadCacheSize > 0 && c.S3.ReadCacheTTL <= 0 {
+		return fmt.Errorf("S3_READ_CACHE_TTL must be positive")
+	}
+	if c.S3.ListConcurrency < 1 {
+		return fmt.Errorf("S3_LIST_CONCURRENCY must be at least 1")
+	}
+	if c.S3.MultipartMaxAge <= 0 {
+		return fmt.Errorf("S3_MULTIPART_MAX_AGE must be positive")
+	}
+	if c.S3.MultipartCleanupInterval < 0 {
+		return fmt.Errorf("S3_MULTIPART_CLEANUP_INTERVAL must not be negative")
+	}
+	if c.S3.LifecycleSweepInterval < 0 {
+		return fmt.Errorf("S3_LIFECYCLE_SWEEP_INTERVAL must not be negative")
+	}
+	if c.S3.Secondary.Enabled() {
+		if c.S3.Secondary.RetryInterval <= 0 {
+			return fmt.Errorf("S3_SECONDARY_RETRY_INTERVAL must be positive")
+		}
+		if (c.S3.Secondary.AccessKey == "") != (c.S3.Secondary.SecretKey == "") {
+			return fmt.Errorf("S3_SECONDARY_ACCESS_KEY and S3_SECONDARY_SECRET_KEY must be set together")
+		}
+		// Mirroring the bucket onto itself would delete what it just wrote
+		if c.S3.Secondary.Endpoint == c.S3.Endpoint && (c.S3.Secondary.Bucket == "" || c.S3.Secondary.Bucket == c.S3.Bucket) {
+			return fmt.Errorf("S3_SECONDARY_ENDPOINT and S3_SECONDARY_BUCKET must not name the primary bucket")
+		}
+	}
+	if c.Auth.Secret == "" && c.Auth.SigningKeys == "" {
+		return fmt.Errorf("AUTH_SECRET or AUTH_SIGNING_KEYS is required")
+	}
+	if _, err := c.Auth.SigningKeyRing(); err != nil {
+		return err
 	}
 	if c.Auth.SignupKey == "" {
 		return fmt.Errorf("SIGNUP_KEY is required")
 	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		return fmt.Errorf("PASSWORD_RESET_TTL must be positive")
+	}
+	if c.Auth.RefreshGrace < 0 {
+		return fmt.Errorf("AUTH_REFRESH_GRACE must not be negative")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		return fmt.Errorf("AUTH_TOKEN_TTL must be positive")
+	}
+	if c.Auth.LoginMaxAttempts <= 0 || c.Auth.LoginWindow <= 0 {
+		return fmt.Errorf("LOGIN_MAX_ATTEMPTS and LOGIN_RATE_WINDOW must be positive")
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		return fmt.Errorf("EMAIL_VERIFICATION_TTL must be positive")
+	}
+	for _, t := range append(append([]string{}, c.Uploads.AllowedTypes...), c.Uploads.DeniedTypes...) {
+		if !strings.Contains(t, "/") {
+			return fmt.Errorf("UPLOAD_ALLOWED_TYPES and UPLOAD_DENIED_TYPES entries must be media types such as image/png or image/*, got %q", t)
+		}
+	}
+	for _, ext := range append(append([]string{}, c.Uploads.AllowedExtensions...), c.Uploads.DeniedExtensions...) {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("UPLOAD_ALLOWED_EXTENSIONS and UPLOAD_DENIED_EXTENSIONS entries must start with a dot, got %q", ext)
+		}
+	}
+	if c.Approval.TTL <= 0 {
+		return fmt.Errorf("APPROVAL_TTL must be positive")
+	}
+	// A misspelled action would silently go without approval
+	for _, action := range c.Approval.Actions {
+		if !slices.Contains(ApprovableActions, action) {
+			return fmt.Errorf("APPROVAL_REQUIRED_ACTIONS names unknown action %q; known actions are %s", action, strings.Join(ApprovableActions, ", "))
+		}
+	}
+	if c.Canary.Interval < 0 {
+		return fmt.Errorf("CANARY_INTERVAL must not be negative")
+	}
+	if c.Canary.Size <= 0 || c.Canary.AlertAfter <= 0 {
+		return fmt.Errorf("CANARY_SIZE and CANARY_ALERT_AFTER must be positive")
+	}
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		return fmt.Errorf("TRASH_RETENTION and TRASH_PURGE_INTERVAL must be positive")
+	}
 	return nil
 }
 
@@ -103,4 +729,96 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+// getEnvSize reads a byte size such as "1048576", "512KB", "512MB" or "2GB" (1KB = 1024 bytes)
+func getEnvSize(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := ParseSize(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// ParseSize parses a byte size with an optional B, KB, MB, GB or TB suffix
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", value)
+	}
+	return n * multiplier, nil
+}
+
+func getEnvRateLimit(key string, defaultValue RateLimitSpec) RateLimitSpec {
+	if value := os.Getenv(key); value != "" {
+		if spec, err := ParseRateLimitSpec(value); err == nil {
+			return spec
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
\ No newline at end of file
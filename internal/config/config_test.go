@@ -0,0 +1,172 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns the configuration NewConfig builds from the required settings alone
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+	for key, value := range map[string]string{
+		"S3_ENDPOINT":   "http://localhost:9000",
+		"S3_REGION":     "us-east-1",
+		"S3_BUCKET":     "documents",
+		"S3_ACCESS_KEY": "access",
+		"S3_SECRET_KEY": "secret",
+		"AUTH_SECRET":   "auth-secret",
+		"SIGNUP_KEY":    "signup-key",
+	} {
+		t.Setenv(key, value)
+	}
+	cfg := NewConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("required settings alone don't validate: %v", err)
+	}
+	return cfg
+}
+
+func TestValidateRejectsUnknownApprovalActions(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Approval.Actions = []string{"delete_admin", "delete_prefx"}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "delete_prefx") {
+		t.Errorf("Validate() = %v, want an error naming the unknown action", err)
+	}
+}
+
+func TestValidateAcceptsEveryApprovableAction(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Approval.Actions = ApprovableActions
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"512B", 512},
+		{"1KB", 1 << 10},
+		{"500MB", 500 << 20},
+		{"2 gb", 2 << 30},
+		{"1TB", 1 << 40},
+	} {
+		got, err := ParseSize(tc.value)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", tc.value, got, err, tc.want)
+		}
+	}
+
+	for _, value := range []string{"", "MB", "-1MB", "1.5GB", "lots", "9999999999TB"} {
+		if _, err := ParseSize(value); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", value)
+		}
+	}
+}
+
+func TestParseRateLimitSpec(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  RateLimitSpec
+	}{
+		{"10/1m", RateLimitSpec{Rate: 10, Per: time.Minute, Burst: 10, Key: RateLimitByIP}},
+		{"5/1s,burst=20", RateLimitSpec{Rate: 5, Per: time.Second, Burst: 20, Key: RateLimitByIP}},
+		{"100/1h, burst=10, key=user", RateLimitSpec{Rate: 100, Per: time.Hour, Burst: 10, Key: RateLimitByUser}},
+	} {
+		got, err := ParseRateLimitSpec(tc.value)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseRateLimitSpec(%q) = %+v, %v, want %+v", tc.value, got, err, tc.want)
+		}
+		if again, err := ParseRateLimitSpec(got.String()); err != nil || again != got {
+			t.Errorf("%q does not round-trip through String: %+v, %v", got.String(), again, err)
+		}
+	}
+
+	for _, value := range []string{"", "10", "0/1m", "10/0s", "x/1m", "10/soon", "10/1m,burst=0", "10/1m,key=session", "10/1m,window=5"} {
+		if _, err := ParseRateLimitSpec(value); err == nil {
+			t.Errorf("ParseRateLimitSpec(%q) succeeded", value)
+		}
+	}
+}
+
+func TestUserQuota(t *testing.T) {
+	if cfg := validConfig(t); cfg.Server.UserQuota != 0 {
+		t.Errorf("default UserQuota = %d, want 0 (unlimited)", cfg.Server.UserQuota)
+	}
+
+	t.Setenv("USER_QUOTA", "2GB")
+	cfg := NewConfig()
+	if cfg.Server.UserQuota != 2<<30 {
+		t.Errorf("UserQuota = %d, want %d", cfg.Server.UserQuota, int64(2<<30))
+	}
+
+	cfg.Server.UserQuota = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "USER_QUOTA") {
+		t.Errorf("Validate() = %v, want an error naming USER_QUOTA", err)
+	}
+}
+
+func TestSigningKeyRing(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		secret string
+		keys   string
+		want   []SigningKey
+	}{
+		{"secret only", "base", "", []SigningKey{{Secret: "base"}}},
+		{"unlisted secret kept last", "base", `[{"id": "k2", "secret": "two"}, {"id": "k1", "secret": "one"}]`,
+			[]SigningKey{{ID: "k2", Secret: "two"}, {ID: "k1", Secret: "one"}, {Secret: "base"}}},
+		{"listed secret not repeated", "one", `[{"id": "k2", "secret": "two"}, {"id": "k1", "secret": "one"}]`,
+			[]SigningKey{{ID: "k2", Secret: "two"}, {ID: "k1", Secret: "one"}}},
+		{"keys without secret", "", `[{"id": "k1", "secret": "one"}]`, []SigningKey{{ID: "k1", Secret: "one"}}},
+	} {
+		got, err := AuthConfig{Secret: tc.secret, SigningKeys: tc.keys}.SigningKeyRing()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, keys := range []string{
+		`{"id": "k1", "secret": "one"}`,
+		`[]`,
+		`[{"id": "", "secret": "one"}]`,
+		`[{"id": "k1", "secret": ""}]`,
+		`[{"id": "k1", "secret": "one"}, {"id": "k1", "secret": "two"}]`,
+	} {
+		if _, err := (AuthConfig{Secret: "base", SigningKeys: keys}).SigningKeyRing(); err == nil {
+			t.Errorf("%s: accepted", keys)
+		}
+	}
+}
+
+func TestValidateSigningKeys(t *testing.T) {
+	cfg := validConfig(t)
+	cfg.Auth.Secret = ""
+	cfg.Auth.SigningKeys = `[{"id": "k1", "secret": "one"}]`
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("signing keys without AUTH_SECRET: Validate() = %v", err)
+	}
+
+	cfg.Auth.SigningKeys = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("neither AUTH_SECRET nor AUTH_SIGNING_KEYS: accepted")
+	}
+
+	cfg.Auth.SigningKeys = `[{"id": "k1"}]`
+	if err := cfg.Validate(); err == nil {
+		t.Error("key without secret: accepted")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+// ErrAPITokenNotFound is returned for an API token ID the user doesn't have
+var ErrAPITokenNotFound = errors.New("API token not found")
+
+// apiTokenTouchInterval is how stale last_used_at may get before a use updates it,
+// so busy scripts don't write on every request
+const apiTokenTouchInterval = time.Minute
+
+// APIToken is a personal access token a user issued for scripts. Only a hash of the
+// token itself is stored
+type APIToken struct {
+	ID         string
+	UserID     string
+	Name       string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// CreateAPIToken stores a personal access token under token
+func (d *Database) CreateAPIToken(token string, apiToken *APIToken) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var expiresAt interface{}
+	if apiToken.ExpiresAt != nil {
+		expiresAt = apiToken.ExpiresAt.UTC()
+	}
+
+	_, err := d.conn.Exec(
+		`INSERT INTO api_tokens (id, token_hash, user_id, name, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		apiToken.ID, hashToken(token), apiToken.UserID, apiToken.Name, apiToken.CreatedAt.UTC(), expiresAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return nil
+}
+
+// ListAPITokens returns the personal access tokens of a user, newest first
+func (d *Database) ListAPITokens(userID string) ([]*APIToken, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT id, user_id, name, created_at, expires_at, last_used_at FROM api_tokens WHERE user_id = ? ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		var token APIToken
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&token.ID, &token.UserID, &token.Name, &token.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		if expiresAt.Valid {
+			token.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			token.LastUsedAt = &lastUsedAt.Time
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes a personal access token of a user
+func (d *Database) DeleteAPIToken(id, userID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+// DeleteAPITokensByUser revokes all of a user's personal access tokens
+func (d *Database) DeleteAPITokensByUser(userID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.conn.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete API tokens: %w", err)
+	}
+
+	return nil
+}
+
+// LookupAPIToken returns the user a valid personal access token belongs to, with the
+// user's current role, and records the use
+func (d *Database) LookupAPIToken(token string) (*auth.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	var id string
+	var user auth.User
+	err := d.conn.QueryRow(
+		`SELECT t.id, u.id, u.username, u.email, u.role FROM api_tokens t JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND (t.expires_at IS NULL OR t.expires_at > ?)`,
+		hashToken(token), now,
+	).Scan(&id, &user.ID, &user.Name, &user.Email, &user.Role)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, auth.ErrInvalidAPIToken
+		}
+		return nil, fmt.Errorf("failed to look up API token: %w", err)
+	}
+
+	if _, err := d.conn.Exec(
+		`UPDATE api_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`,
+		now, id, now.Add(-apiTokenTouchInterval),
+	); err != nil {
+		return nil, fmt.Errorf("failed to record API token use: %w", err)
+	}
+
+	return &user, nil
+}
\ No newline at end of file
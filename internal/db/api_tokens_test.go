@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+// createTestAPIToken stores a fresh personal access token for userID and returns it
+func createTestAPIToken(t *testing.T, database *Database, id, userID string, expiresAt *time.Time) string {
+	t.Helper()
+	token, err := auth.NewAPIToken()
+	if err != nil {
+		t.Fatalf("NewAPIToken: %v", err)
+	}
+	apiToken := &APIToken{ID: id, UserID: userID, Name: id, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	if err := database.CreateAPIToken(token, apiToken); err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	return token
+}
+
+func TestAPITokenIsStoredHashed(t *testing.T) {
+	database := newTestDatabase(t)
+	if err := database.CreateUser("alice-id", "alice", "alice@example.com", "password", auth.RoleUploader); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	token := createTestAPIToken(t, database, "token-1", "alice-id", nil)
+
+	var stored string
+	if err := database.conn.QueryRow(`SELECT token_hash FROM api_tokens WHERE id = ?`, "token-1").Scan(&stored); err != nil {
+		t.Fatalf("reading token_hash: %v", err)
+	}
+	if stored == token || stored != hashToken(token) {
+		t.Errorf("token_hash = %q, want the hash of the token", stored)
+	}
+
+	user, err := database.LookupAPIToken(token)
+	if err != nil {
+		t.Fatalf("LookupAPIToken: %v", err)
+	}
+	if user.ID != "alice-id" || user.Role != auth.RoleUploader {
+		t.Errorf("LookupAPIToken = %+v, want alice as uploader", user)
+	}
+
+	// Knowing the stored hash is no use as a token
+	if _, err := database.LookupAPIToken(stored); !errors.Is(err, auth.ErrInvalidAPIToken) {
+		t.Errorf("lookup by hash: err = %v, want ErrInvalidAPIToken", err)
+	}
+}
+
+func TestLookupAPITokenRejectsExpired(t *testing.T) {
+	database := newTestDatabase(t)
+	if err := database.CreateUser("alice-id", "alice", "alice@example.com", "password", auth.RoleUploader); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	token := createTestAPIToken(t, database, "token-1", "alice-id", &expired)
+
+	if _, err := database.LookupAPIToken(token); !errors.Is(err, auth.ErrInvalidAPIToken) {
+		t.Errorf("err = %v, want ErrInvalidAPIToken", err)
+	}
+}
+
+func TestDeleteAPITokensByUser(t *testing.T) {
+	database := newTestDatabase(t)
+	for _, name := range []string{"alice", "bob"} {
+		if err := database.CreateUser(name+"-id", name, name+"@example.com", "password", auth.RoleUploader); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	first := createTestAPIToken(t, database, "alice-1", "alice-id", nil)
+	second := createTestAPIToken(t, database, "alice-2", "alice-id", nil)
+	other := createTestAPIToken(t, database, "bob-1", "bob-id", nil)
+
+	if err := database.DeleteAPITokensByUser("alice-id"); err != nil {
+		t.Fatalf("DeleteAPITokensByUser: %v", err)
+	}
+
+	for _, token := range []string{first, second} {
+		if _, err := database.LookupAPIToken(token); !errors.Is(err, auth.ErrInvalidAPIToken) {
+			t.Errorf("alice's token: err = %v, want ErrInvalidAPIToken", err)
+		}
+	}
+	if _, err := database.LookupAPIToken(other); err != nil {
+		t.Errorf("bob's token: %v", err)
+	}
+
+	// A user without tokens is not an error
+	if err := database.DeleteAPITokensByUser("alice-id"); err != nil {
+		t.Errorf("second DeleteAPITokensByUser: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Pending action statuses
+const (
+	ActionPending   = "pending"
+	ActionApproved  = "approved"
+	ActionExecuted  = "executed"
+	ActionFailed    = "failed"
+	ActionCancelled = "cancelled"
+	ActionExpired   = "expired"
+)
+
+// PendingAction is a sensitive admin action waiting for a second approval
+type PendingAction struct {
+	ID          string
+	Action      string
+	Target      string
+	Payload     string
+	RequestedBy string
+	Status      string
+	ApprovedBy  string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// AuditEntry is a single record in the audit log
+type AuditEntry struct {
+	Actor    string
+	Approver string
+	Action   string
+	Target   string
+	Details  string
+}
+
+// CreatePendingAction stores a new pending action
+func (d *Database) CreatePendingAction(action *PendingAction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO pending_actions (id, action, target, payload, requested_by, status, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		action.ID, action.Action, action.Target, action.Payload, action.RequestedBy, ActionPending, action.ExpiresAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create pending action: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingAction retrieves a pending action by ID
+func (d *Database) GetPendingAction(id string) (*PendingAction, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var action PendingAction
+	var approvedBy sql.NullString
+	err := d.conn.QueryRow(
+		`SELECT id, action, target, payload, requested_by, status, approved_by, created_at, expires_at FROM pending_actions WHERE id = ?`,
+		id,
+	).Scan(&action.ID, &action.Action, &action.Target, &action.Payload, &action.RequestedBy, &action.Status, &approvedBy, &action.CreatedAt, &action.ExpiresAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("pending action not found")
+		}
+		return nil, fmt.Errorf("failed to get pending action: %w", err)
+	}
+	action.ApprovedBy = approvedBy.String
+
+	return &action, nil
+}
+
+// ListPendingActions returns all actions still awaiting approval
+func (d *Database) ListPendingActions() ([]*PendingAction, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT id, action, target, payload, requested_by, status, created_at, expires_at FROM pending_actions WHERE status = ? ORDER BY created_at DESC`,
+		ActionPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending actions: %w", err)
+	}
+	defer rows.Close()
+
+	var actions []*PendingAction
+	for rows.Next() {
+		var action PendingAction
+		if err := rows.Scan(&action.ID, &action.Action, &action.Target, &action.Payload, &action.RequestedBy, &action.Status, &action.CreatedAt, &action.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending action: %w", err)
+		}
+		actions = append(actions, &action)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending actions: %w", err)
+	}
+
+	return actions, nil
+}
+
+// TransitionPendingAction moves an action from one status to another.
+// It fails if the action is no longer in the expected status, so an action
+// can only be approved or cancelled once.
+func (d *Database) TransitionPendingAction(id, from, to, approvedBy string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE pending_actions SET status = ?, approved_by = COALESCE(NULLIF(?, ''), approved_by), resolved_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`,
+		to, approvedBy, id, from,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update pending action: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("pending action is no longer %s", from)
+	}
+
+	return nil
+}
+
+// RecordAudit appends an entry to the audit log
+func (d *Database) RecordAudit(entry AuditEntry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO audit_log (actor, approver, action, target, details) VALUES (?, ?, ?, ?, ?)`,
+		entry.Actor, entry.Approver, entry.Action, entry.Target, entry.Details,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
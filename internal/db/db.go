@@ -1,15 +1,22 @@
 package db
 
 import (
+	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"s3-test-app/internal/auth"
 )
 
+// ErrLastAdmin is returned when a role change would leave no admin account
+var ErrLastAdmin = errors.New("cannot demote the last admin")
+
 // Database handles all database operations
 type Database struct {
 	conn *sql.DB
@@ -23,6 +30,34 @@ type User struct {
 	Email    string
 	Password string // hashed
 	Role     auth.Role
+
+	EmailVerified bool
+	// CreatedAt and UpdatedAt are zero for rows that never had them set
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// LastLoginAt is zero until the user first logs in
+	LastLoginAt time.Time
+}
+
+// userColumns are the columns scanUser reads, in order
+const userColumns = `id, username, email, password, role, email_verified, created_at, updated_at, last_login_at`
+
+// rowScanner is the Scan method shared by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a row selected with userColumns. Missing timestamps are left zero.
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	var createdAt, updatedAt, lastLoginAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.EmailVerified, &createdAt, &updatedAt, &lastLoginAt); err != nil {
+		return nil, err
+	}
+	user.CreatedAt = createdAt.Time
+	user.UpdatedAt = updatedAt.Time
+	user.LastLoginAt = lastLoginAt.Time
+	return &user, nil
 }
 
 // New creates a new database connection
@@ -46,6 +81,18 @@ func New(dbPath string) (*Database, error) {
 	return db, nil
 }
 
+// Ping runs a trivial query to confirm the database file is readable
+func (d *Database) Ping(ctx context.Context) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var one int
+	if err := d.conn.QueryRowContext(ctx, `SELECT 1`).Scan(&one); err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	return nil
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	return d.conn.Close()
@@ -60,31 +107,351 @@ func (d *Database) initSchema() error {
 		email TEXT UNIQUE NOT NULL,
 		password TEXT NOT NULL,
 		role TEXT NOT NULL,
+		email_verified BOOLEAN NOT NULL DEFAULT 0,
+		tokens_revoked_before DATETIME,
+		quota_bytes INTEGER,
+		last_login_at DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
 	CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+	CREATE TABLE IF NOT EXISTS password_resets (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets(user_id);
+
+	CREATE TABLE IF NOT EXISTS email_verifications (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_email_verifications_user_id ON email_verifications(user_id);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		id TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+	CREATE TABLE IF NOT EXISTS pending_actions (
+		id TEXT PRIMARY KEY,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		requested_by TEXT NOT NULL,
+		status TEXT NOT NULL,
+		approved_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		resolved_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_pending_actions_status ON pending_actions(status);
+
+	CREATE TABLE IF NOT EXISTS legal_holds (
+		id TEXT PRIMARY KEY,
+		scope TEXT NOT NULL,
+		target TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		placed_by TEXT NOT NULL,
+		placed_at DATETIME NOT NULL,
+		released_by TEXT,
+		released_at DATETIME,
+		release_reason TEXT
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_legal_holds_active ON legal_holds(scope, target) WHERE released_at IS NULL;
+
+	CREATE TABLE IF NOT EXISTS files (
+		key TEXT PRIMARY KEY,
+		owner_id TEXT NOT NULL,
+		original_name TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		content_type TEXT NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		sha256 TEXT NOT NULL DEFAULT '',
+		uploaded_at DATETIME NOT NULL,
+		locked BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_files_owner_id ON files(owner_id);
+	CREATE INDEX IF NOT EXISTS idx_files_uploaded_at ON files(uploaded_at);
+
+	CREATE TABLE IF NOT EXISTS trash (
+		key TEXT PRIMARY KEY,
+		original_key TEXT NOT NULL,
+		owner_id TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		deleted_by TEXT NOT NULL,
+		deleted_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_trash_deleted_at ON trash(deleted_at);
+
+	CREATE TABLE IF NOT EXISTS shares (
+		token_hash TEXT PRIMARY KEY,
+		key TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		max_downloads INTEGER NOT NULL DEFAULT 0,
+		download_count INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_shares_expires_at ON shares(expires_at);
+
+	CREATE TABLE IF NOT EXISTS inboxes (
+		id TEXT PRIMARY KEY,
+		token_hash TEXT UNIQUE NOT NULL,
+		prefix TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		max_files INTEGER NOT NULL,
+		max_bytes INTEGER NOT NULL,
+		file_count INTEGER NOT NULL DEFAULT 0,
+		byte_count INTEGER NOT NULL DEFAULT 0,
+		consumed_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS replication_queue (
+		key TEXT PRIMARY KEY,
+		op TEXT NOT NULL,
+		seq INTEGER NOT NULL DEFAULT 1,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		last_attempt_at DATETIME,
+		queued_at DATETIME NOT NULL,
+		next_attempt_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_replication_queue_next_attempt_at ON replication_queue(next_attempt_at);
+
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id TEXT PRIMARY KEY,
+		token_hash TEXT UNIQUE NOT NULL,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+
+	CREATE TABLE IF NOT EXISTS direct_uploads (
+		key TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		allow_empty BOOLEAN NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_direct_uploads_expires_at ON direct_uploads(expires_at);
+
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		id TEXT PRIMARY KEY,
+		prefix TEXT NOT NULL,
+		owner_id TEXT NOT NULL,
+		dry_run BOOLEAN NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		cursor TEXT NOT NULL DEFAULT '',
+		scanned INTEGER NOT NULL DEFAULT 0,
+		imported INTEGER NOT NULL DEFAULT 0,
+		skipped INTEGER NOT NULL DEFAULT 0,
+		conflicts INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		started_by TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+
+	CREATE TABLE IF NOT EXISTS import_conflicts (
+		job_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		recorded_size INTEGER NOT NULL,
+		object_size INTEGER NOT NULL,
+		PRIMARY KEY (job_id, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_by TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS settings_version (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		version INTEGER NOT NULL
+	);
+
+	INSERT OR IGNORE INTO settings_version (id, version) VALUES (1, 0);
+
+	CREATE TABLE IF NOT EXISTS lifecycle_rules (
+		bucket TEXT NOT NULL,
+		id TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		expiration_days INTEGER NOT NULL,
+		updated_by TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (bucket, id)
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		approver TEXT,
+		action TEXT NOT NULL,
+		target TEXT,
+		details TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	if _, err := d.conn.Exec(schema); err != nil {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
 
+	return d.migrate()
+}
+
+// migrate upgrades databases created before columns were added to existing tables
+func (d *Database) migrate() error {
+	hasColumn, err := d.hasColumn("users", "email_verified")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		// Accounts that predate verification are treated as verified so they aren't locked out
+		if _, err := d.conn.Exec(`ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("failed to add email_verified column: %w", err)
+		}
+		if _, err := d.conn.Exec(`UPDATE users SET email_verified = 1`); err != nil {
+			return fmt.Errorf("failed to mark existing users verified: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("users", "tokens_revoked_before")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		if _, err := d.conn.Exec(`ALTER TABLE users ADD COLUMN tokens_revoked_before DATETIME`); err != nil {
+			return fmt.Errorf("failed to add tokens_revoked_before column: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("users", "quota_bytes")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		if _, err := d.conn.Exec(`ALTER TABLE users ADD COLUMN quota_bytes INTEGER`); err != nil {
+			return fmt.Errorf("failed to add quota_bytes column: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("users", "last_login_at")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		if _, err := d.conn.Exec(`ALTER TABLE users ADD COLUMN last_login_at DATETIME`); err != nil {
+			return fmt.Errorf("failed to add last_login_at column: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("files", "category")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		// Existing rows are categorized by the backfill job started at boot
+		if _, err := d.conn.Exec(`ALTER TABLE files ADD COLUMN category TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add category column: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("files", "sha256")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		// Files stored earlier have no known hash and are never matched as duplicates
+		if _, err := d.conn.Exec(`ALTER TABLE files ADD COLUMN sha256 TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("failed to add sha256 column: %w", err)
+		}
+	}
+
+	hasColumn, err = d.hasColumn("files", "locked")
+	if err != nil {
+		return err
+	}
+	if !hasColumn {
+		if _, err := d.conn.Exec(`ALTER TABLE files ADD COLUMN locked BOOLEAN NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("failed to add locked column: %w", err)
+		}
+	}
+
+	// Created after the migration so older databases already have the column
+	if _, err := d.conn.Exec(`CREATE INDEX IF NOT EXISTS idx_files_sha256 ON files(sha256)`); err != nil {
+		return fmt.Errorf("failed to create sha256 index: %w", err)
+	}
+
 	return nil
 }
 
+// hasColumn reports whether table has a column with the given name
+func (d *Database) hasColumn(table, column string) (bool, error) {
+	rows, err := d.conn.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan column info: %w", err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
 // GetUserByUsername retrieves a user by username
 func (d *Database) GetUserByUsername(username string) (*User, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var user User
-	err := d.conn.QueryRow(
-		`SELECT id, username, email, password, role FROM users WHERE username = ?`,
+	user, err := scanUser(d.conn.QueryRow(
+		`SELECT `+userColumns+` FROM users WHERE username = ?`,
 		username,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+	))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -93,7 +460,7 @@ func (d *Database) GetUserByUsername(username string) (*User, error) {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetUserByID retrieves a user by ID
@@ -101,11 +468,10 @@ func (d *Database) GetUserByID(id string) (*User, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var user User
-	err := d.conn.QueryRow(
-		`SELECT id, username, email, password, role FROM users WHERE id = ?`,
+	user, err := scanUser(d.conn.QueryRow(
+		`SELECT `+userColumns+` FROM users WHERE id = ?`,
 		id,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+	))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -114,7 +480,22 @@ func (d *Database) GetUserByID(id string) (*User, error) {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+// LookupUser returns the current authentication details of a user
+func (d *Database) LookupUser(id string) (*auth.User, error) {
+	user, err := d.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.User{
+		ID:    user.ID,
+		Name:  user.Username,
+		Email: user.Email,
+		Role:  user.Role,
+	}, nil
 }
 
 // GetUserByEmail retrieves a user by email
@@ -122,11 +503,10 @@ func (d *Database) GetUserByEmail(email string) (*User, error) {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	var user User
-	err := d.conn.QueryRow(
-		`SELECT id, username, email, password, role FROM users WHERE email = ?`,
+	user, err := scanUser(d.conn.QueryRow(
+		`SELECT `+userColumns+` FROM users WHERE email = ?`,
 		email,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+	))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -135,7 +515,7 @@ func (d *Database) GetUserByEmail(email string) (*User, error) {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetAllUsers retrieves all users
@@ -144,7 +524,7 @@ func (d *Database) GetAllUsers() ([]*User, error) {
 	defer d.mu.RUnlock()
 
 	rows, err := d.conn.Query(
-		`SELECT id, username, email, password, role FROM users ORDER BY created_at DESC`,
+		`SELECT ` + userColumns + ` FROM users ORDER BY created_at DESC`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %w", err)
@@ -153,11 +533,11 @@ func (d *Database) GetAllUsers() ([]*User, error) {
 
 	var users []*User
 	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -206,6 +586,11 @@ func (d *Database) DeleteUser(id string) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	// Tokens of a deleted user resolve to nobody anyway; this only tidies them up
+	if _, err := d.conn.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete user's API tokens: %w", err)
+	}
+
 	result, err := d.conn.Exec(`DELETE FROM users WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
@@ -223,11 +608,30 @@ func (d *Database) DeleteUser(id string) error {
 	return nil
 }
 
-// UpdateUserRole updates a user's role
+// UpdateUserRole updates a user's role. Demoting the only remaining admin fails with ErrLastAdmin.
 func (d *Database) UpdateUserRole(id string, role auth.Role) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	var current auth.Role
+	err := d.conn.QueryRow(`SELECT role FROM users WHERE id = ?`, id).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if current == auth.RoleAdmin && role != auth.RoleAdmin {
+		var admins int
+		if err := d.conn.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, auth.RoleAdmin).Scan(&admins); err != nil {
+			return fmt.Errorf("failed to count admins: %w", err)
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
 	result, err := d.conn.Exec(
 		`UPDATE users SET role = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		role, id,
@@ -249,9 +653,108 @@ func (d *Database) UpdateUserRole(id string, role auth.Role) error {
 	return nil
 }
 
-// VerifyPassword checks if the provided password matches the user's password
+// GetUserQuota returns the storage quota override of a user, or nil if the default applies
+func (d *Database) GetUserQuota(id string) (*int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var quota sql.NullInt64
+	err := d.conn.QueryRow(`SELECT quota_bytes FROM users WHERE id = ?`, id).Scan(&quota)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user quota: %w", err)
+	}
+
+	if !quota.Valid {
+		return nil, nil
+	}
+	return &quota.Int64, nil
+}
+
+// SetUserQuota overrides a user's storage quota; nil returns the user to the default
+func (d *Database) SetUserQuota(id string, quota *int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE users SET quota_bytes = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		quota, id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update user quota: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// UpdateUserPassword replaces a user's password
+func (d *Database) UpdateUserPassword(id, newPassword string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		hashPassword(newPassword), id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// TouchLastLogin records a successful login of a user at the current time
+func (d *Database) TouchLastLogin(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE users SET last_login_at = ? WHERE id = ?`,
+		time.Now().UTC(), id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// VerifyPassword checks if the provided password matches the user's password. The hashes
+// are compared in constant time so the comparison doesn't leak how much of them matched.
 func VerifyPassword(hashedPassword, plainPassword string) bool {
-	return hashPassword(plainPassword) == hashedPassword
+	return subtle.ConstantTimeCompare([]byte(hashPassword(plainPassword)), []byte(hashedPassword)) == 1
 }
 
 // hashPassword hashes a password using SHA256
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDirectUploadNotFound is returned for a key with no pending direct upload by the user
+var ErrDirectUploadNotFound = errors.New("direct upload not found")
+
+// DirectUpload is a presigned upload that was issued but not yet confirmed. Size is what
+// the client declared when asking for the URL, which the uploaded object must match
+type DirectUpload struct {
+	Key        string
+	UserID     string
+	Filename   string
+	Size       int64
+	AllowEmpty bool
+	ExpiresAt  time.Time
+}
+
+// SaveDirectUpload records a pending direct upload, replacing any earlier one for its key
+func (d *Database) SaveDirectUpload(upload *DirectUpload) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT OR REPLACE INTO direct_uploads (key, user_id, filename, size, allow_empty, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		upload.Key, upload.UserID, upload.Filename, upload.Size, upload.AllowEmpty, upload.ExpiresAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save direct upload: %w", err)
+	}
+
+	return nil
+}
+
+// TakeDirectUpload removes and returns the pending direct upload for key if it was issued to userID
+func (d *Database) TakeDirectUpload(key, userID string) (*DirectUpload, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var upload DirectUpload
+	err := d.conn.QueryRow(
+		`SELECT key, user_id, filename, size, allow_empty, expires_at FROM direct_uploads WHERE key = ? AND user_id = ?`,
+		key, userID,
+	).Scan(&upload.Key, &upload.UserID, &upload.Filename, &upload.Size, &upload.AllowEmpty, &upload.ExpiresAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDirectUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to get direct upload: %w", err)
+	}
+
+	if _, err := d.conn.Exec(`DELETE FROM direct_uploads WHERE key = ?`, key); err != nil {
+		return nil, fmt.Errorf("failed to delete direct upload: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// ListDirectUploadsBefore returns the pending direct uploads that expired before cutoff
+func (d *Database) ListDirectUploadsBefore(cutoff time.Time) ([]*DirectUpload, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT key, user_id, filename, size, allow_empty, expires_at FROM direct_uploads WHERE expires_at < ? ORDER BY expires_at`,
+		cutoff.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query direct uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := make([]*DirectUpload, 0)
+	for rows.Next() {
+		var upload DirectUpload
+		if err := rows.Scan(&upload.Key, &upload.UserID, &upload.Filename, &upload.Size, &upload.AllowEmpty, &upload.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan direct upload: %w", err)
+		}
+		uploads = append(uploads, &upload)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating direct uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// ClaimExpiredDirectUpload removes the pending direct upload for key if it still expired
+// before cutoff, reporting whether it did. An upload that was confirmed or issued again in
+// the meantime is left alone
+func (d *Database) ClaimExpiredDirectUpload(key string, cutoff time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(`DELETE FROM direct_uploads WHERE key = ? AND expires_at < ?`, key, cutoff.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim direct upload: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
\ No newline at end of file
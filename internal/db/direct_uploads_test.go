@@ -0,0 +1,61 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTakeDirectUploadIsOwnerOnlyAndSingleUse(t *testing.T) {
+	database := newTestDatabase(t)
+	upload := &DirectUpload{Key: "users/alice-id/file.txt", UserID: "alice-id", Filename: "file.txt", Size: 5, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := database.SaveDirectUpload(upload); err != nil {
+		t.Fatalf("SaveDirectUpload: %v", err)
+	}
+
+	if _, err := database.TakeDirectUpload(upload.Key, "bob-id"); !errors.Is(err, ErrDirectUploadNotFound) {
+		t.Errorf("take by another user: err = %v, want ErrDirectUploadNotFound", err)
+	}
+	taken, err := database.TakeDirectUpload(upload.Key, "alice-id")
+	if err != nil {
+		t.Fatalf("TakeDirectUpload: %v", err)
+	}
+	if taken.Size != 5 || taken.Filename != "file.txt" {
+		t.Errorf("taken = %+v, want size 5 and file.txt", taken)
+	}
+	if _, err := database.TakeDirectUpload(upload.Key, "alice-id"); !errors.Is(err, ErrDirectUploadNotFound) {
+		t.Errorf("second take: err = %v, want ErrDirectUploadNotFound", err)
+	}
+}
+
+func TestClaimExpiredDirectUploadSkipsRenewed(t *testing.T) {
+	database := newTestDatabase(t)
+	now := time.Now()
+	expired := &DirectUpload{Key: "expired", UserID: "alice-id", ExpiresAt: now.Add(-time.Hour)}
+	live := &DirectUpload{Key: "live", UserID: "alice-id", ExpiresAt: now.Add(time.Hour)}
+	for _, upload := range []*DirectUpload{expired, live} {
+		if err := database.SaveDirectUpload(upload); err != nil {
+			t.Fatalf("SaveDirectUpload: %v", err)
+		}
+	}
+
+	listed, err := database.ListDirectUploadsBefore(now)
+	if err != nil {
+		t.Fatalf("ListDirectUploadsBefore: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Key != "expired" {
+		t.Fatalf("listed = %+v, want only the expired upload", listed)
+	}
+
+	// Issued again after the listing, so the sweep must leave it alone
+	expired.ExpiresAt = now.Add(time.Hour)
+	if err := database.SaveDirectUpload(expired); err != nil {
+		t.Fatalf("SaveDirectUpload: %v", err)
+	}
+	if claimed, err := database.ClaimExpiredDirectUpload("expired", now); err != nil || claimed {
+		t.Errorf("claim of renewed upload = %v, %v; want false", claimed, err)
+	}
+	if _, err := database.TakeDirectUpload("expired", "alice-id"); err != nil {
+		t.Errorf("renewed upload is gone: %v", err)
+	}
+}
\ No newline at end of file
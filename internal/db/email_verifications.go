@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreateEmailVerification stores a single-use email verification token for a user
+func (d *Database) CreateEmailVerification(token, userID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO email_verifications (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		hashToken(token), userID, expiresAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create email verification: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeEmailVerification validates a verification token, marks the owning user's
+// email verified and returns the user ID
+func (d *Database) ConsumeEmailVerification(token string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		`SELECT user_id, expires_at, used_at FROM email_verifications WHERE token_hash = ?`,
+		hashToken(token),
+	).Scan(&userID, &expiresAt, &usedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("invalid verification token")
+		}
+		return "", fmt.Errorf("failed to get email verification: %w", err)
+	}
+
+	if usedAt.Valid {
+		return "", fmt.Errorf("verification token already used")
+	}
+	if time.Now().After(expiresAt) {
+		return "", fmt.Errorf("verification token expired")
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE email_verifications SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		time.Now().UTC(), userID,
+	); err != nil {
+		return "", fmt.Errorf("failed to mark verification token used: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE users SET email_verified = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		userID,
+	); err != nil {
+		return "", fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit email verification: %w", err)
+	}
+
+	return userID, nil
+}
+
+// IsEmailVerified reports whether a user has confirmed their email address
+func (d *Database) IsEmailVerified(userID string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var verified bool
+	err := d.conn.QueryRow(`SELECT email_verified FROM users WHERE id = ?`, userID).Scan(&verified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("user not found")
+		}
+		return false, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return verified, nil
+}
\ No newline at end of file
@@ -0,0 +1,461 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FileRecord is the metadata kept for a stored object. S3 stays the source of truth for the bytes.
+type FileRecord struct {
+	Key          string
+	OwnerID      string
+	OriginalName string
+	Size         int64
+	ContentType  string
+	Category     string
+	SHA256       string
+	UploadedAt   time.Time
+	// Locked protects the object from being overwritten, renamed, moved or deleted
+	Locked bool
+}
+
+// SaveFileRecord stores the metadata of an uploaded object, replacing any record for the
+// same key. A lock on the key is kept.
+func (d *Database) SaveFileRecord(file *FileRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO files (key, owner_id, original_name, size, content_type, category, sha256, uploaded_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET owner_id = excluded.owner_id, original_name = excluded.original_name, size = excluded.size,
+			content_type = excluded.content_type, category = excluded.category, sha256 = excluded.sha256, uploaded_at = excluded.uploaded_at`,
+		file.Key, file.OwnerID, file.OriginalName, file.Size, file.ContentType, file.Category, file.SHA256, file.UploadedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	return nil
+}
+
+// BackfillFileRecord stores metadata for an object that has none, leaving existing records alone
+func (d *Database) BackfillFileRecord(file *FileRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT OR IGNORE INTO files (key, owner_id, original_name, size, content_type, category, sha256, uploaded_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		file.Key, file.OwnerID, file.OriginalName, file.Size, file.ContentType, file.Category, file.SHA256, file.UploadedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to backfill file record: %w", err)
+	}
+
+	return nil
+}
+
+// GetFileRecord returns the metadata for key, or nil if there is none
+func (d *Database) GetFileRecord(key string) (*FileRecord, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var file FileRecord
+	err := d.conn.QueryRow(
+		`SELECT key, owner_id, original_name, size, content_type, category, sha256, uploaded_at, locked FROM files WHERE key = ?`,
+		key,
+	).Scan(&file.Key, &file.OwnerID, &file.OriginalName, &file.Size, &file.ContentType, &file.Category, &file.SHA256, &file.UploadedAt, &file.Locked)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get file record: %w", err)
+	}
+
+	return &file, nil
+}
+
+// FileRecordsByKeys returns the metadata of the given keys, indexed by key. Keys without a record are absent.
+func (d *Database) FileRecordsByKeys(keys []string) (map[string]*FileRecord, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	records := make(map[string]*FileRecord, len(keys))
+
+	// Stay well below SQLite's bound parameter limit
+	const chunk = 500
+	for start := 0; start < len(keys); start += chunk {
+		batch := keys[start:min(start+chunk, len(keys))]
+
+		args := make([]interface{}, len(batch))
+		for i, key := range batch {
+			args[i] = key
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+
+		rows, err := d.conn.Query(
+			`SELECT key, owner_id, original_name, size, content_type, category, sha256, uploaded_at, locked FROM files WHERE key IN (`+placeholders+`)`,
+			args...,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query file records: %w", err)
+		}
+
+		for rows.Next() {
+			var file FileRecord
+			if err := rows.Scan(&file.Key, &file.OwnerID, &file.OriginalName, &file.Size, &file.ContentType, &file.Category, &file.SHA256, &file.UploadedAt, &file.Locked); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan file record: %w", err)
+			}
+			records[file.Key] = &file
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating file records: %w", err)
+		}
+	}
+
+	return records, nil
+}
+
+// FileSearch filters a search over file records. Zero values leave a filter out
+type FileSearch struct {
+	// Query is matched case-insensitively anywhere in the original name or the key
+	Query string
+	// KeyPrefix restricts results to keys starting with it
+	KeyPrefix string
+	OwnerID   string
+	MinSize   int64
+	MaxSize   int64
+	// ContentType is an exact media type or a wildcard such as "image/*"
+	ContentType string
+	// UploadedFrom is inclusive and UploadedBefore exclusive
+	UploadedFrom   time.Time
+	UploadedBefore time.Time
+	Limit          int
+	Offset         int
+}
+
+// SearchFileRecords returns one page of the records matching search, newest first, along
+// with the total number of matches. Files in the trash are never included
+func (d *Database) SearchFileRecords(search FileSearch) ([]*FileRecord, int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	where := []string{
+		`(instr(lower(original_name), lower(?)) > 0 OR instr(lower(key), lower(?)) > 0)`,
+		`key NOT IN (SELECT key FROM trash)`,
+	}
+	args := []interface{}{search.Query, search.Query}
+
+	if search.KeyPrefix != "" {
+		// substr counts characters, not bytes
+		where = append(where, `substr(key, 1, ?) = ?`)
+		args = append(args, utf8.RuneCountInString(search.KeyPrefix), search.KeyPrefix)
+	}
+	if search.OwnerID != "" {
+		where = append(where, `owner_id = ?`)
+		args = append(args, search.OwnerID)
+	}
+	if search.MinSize > 0 {
+		where = append(where, `size >= ?`)
+		args = append(args, search.MinSize)
+	}
+	if search.MaxSize > 0 {
+		where = append(where, `size <= ?`)
+		args = append(args, search.MaxSize)
+	}
+	if major, ok := strings.CutSuffix(search.ContentType, "/*"); ok {
+		where = append(where, `lower(content_type) LIKE ?`)
+		args = append(args, strings.ToLower(major)+"/%")
+	} else if search.ContentType != "" {
+		// Stored types may carry parameters such as a charset
+		where = append(where, `(lower(content_type) = ? OR lower(content_type) LIKE ?)`)
+		args = append(args, strings.ToLower(search.ContentType), strings.ToLower(search.ContentType)+";%")
+	}
+	if !search.UploadedFrom.IsZero() {
+		where = append(where, `uploaded_at >= ?`)
+		args = append(args, search.UploadedFrom.UTC())
+	}
+	if !search.UploadedBefore.IsZero() {
+		where = append(where, `uploaded_at < ?`)
+		args = append(args, search.UploadedBefore.UTC())
+	}
+	condition := strings.Join(where, " AND ")
+
+	var total int
+	if err := d.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE `+condition, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count file records: %w", err)
+	}
+
+	rows, err := d.conn.Query(
+		`SELECT key, owner_id, original_name, size, content_type, category, sha256, uploaded_at, locked FROM files WHERE `+condition+` ORDER BY uploaded_at DESC, key LIMIT ? OFFSET ?`,
+		append(args, search.Limit, search.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search file records: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*FileRecord, 0)
+	for rows.Next() {
+		var file FileRecord
+		if err := rows.Scan(&file.Key, &file.OwnerID, &file.OriginalName, &file.Size, &file.ContentType, &file.Category, &file.SHA256, &file.UploadedAt, &file.Locked); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan file record: %w", err)
+		}
+		records = append(records, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating file records: %w", err)
+	}
+
+	return records, total, nil
+}
+
+// FileRecordsByHash returns the records of every file whose content has the given SHA-256, oldest first
+func (d *Database) FileRecordsByHash(sha256 string) ([]*FileRecord, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT key, owner_id, original_name, size, content_type, category, sha256, uploaded_at, locked FROM files WHERE sha256 = ? ORDER BY uploaded_at`,
+		sha256,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file records: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*FileRecord, 0)
+	for rows.Next() {
+		var file FileRecord
+		if err := rows.Scan(&file.Key, &file.OwnerID, &file.OriginalName, &file.Size, &file.ContentType, &file.Category, &file.SHA256, &file.UploadedAt, &file.Locked); err != nil {
+			return nil, fmt.Errorf("failed to scan file record: %w", err)
+		}
+		records = append(records, &file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file records: %w", err)
+	}
+
+	return records, nil
+}
+
+// BackfillCategories sets the category of every record that has none, using categorize,
+// and returns how many records were updated
+func (d *Database) BackfillCategories(categorize func(contentType, name string) string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rows, err := d.conn.Query(`SELECT key, content_type, original_name FROM files WHERE category = ''`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query uncategorized files: %w", err)
+	}
+
+	type pending struct{ key, category string }
+	updates := make([]pending, 0)
+	for rows.Next() {
+		var key, contentType, name string
+		if err := rows.Scan(&key, &contentType, &name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan file record: %w", err)
+		}
+		updates = append(updates, pending{key, categorize(contentType, name)})
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error iterating file records: %w", err)
+	}
+
+	for _, u := range updates {
+		if _, err := d.conn.Exec(`UPDATE files SET category = ? WHERE key = ?`, u.category, u.key); err != nil {
+			return 0, fmt.Errorf("failed to update file category: %w", err)
+		}
+	}
+
+	return len(updates), nil
+}
+
+// RenameFileRecord moves the metadata of from to the key to. A non-empty ownerID
+// also transfers the file to that user.
+func (d *Database) RenameFileRecord(from, to, ownerID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// An overwritten destination loses its old metadata
+	if _, err := tx.Exec(`DELETE FROM files WHERE key = ?`, to); err != nil {
+		return fmt.Errorf("failed to rename file record: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE files SET key = ?, owner_id = CASE WHEN ? = '' THEN owner_id ELSE ? END WHERE key = ?`,
+		to, ownerID, ownerID, from,
+	); err != nil {
+		return fmt.Errorf("failed to rename file record: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteFileRecords removes the metadata of the given keys
+func (d *Database) DeleteFileRecords(keys ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, key := range keys {
+		if _, err := d.conn.Exec(`DELETE FROM files WHERE key = ?`, key); err != nil {
+			return fmt.Errorf("failed to delete file record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteFileRecordsByPrefix removes the metadata of every key starting with prefix
+func (d *Database) DeleteFileRecordsByPrefix(prefix string) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// substr counts characters, not bytes
+	result, err := d.conn.Exec(`DELETE FROM files WHERE substr(key, 1, ?) = ?`, utf8.RuneCountInString(prefix), prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete file records: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// SumFileSizesByOwner returns the bytes recorded for ownerID's files. Files in the trash
+// don't count, so deleting a file frees its space right away
+func (d *Database) SumFileSizesByOwner(ownerID string) (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var total int64
+	err := d.conn.QueryRow(
+		`SELECT COALESCE(SUM(size), 0) FROM files WHERE owner_id = ? AND key NOT IN (SELECT key FROM trash)`,
+		ownerID,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum file sizes: %w", err)
+	}
+
+	return total, nil
+}
+
+// OwnerUsage is the total size and count of the files recorded for one owner
+type OwnerUsage struct {
+	OwnerID string
+	Bytes   int64
+	Files   int64
+}
+
+// UsageByOwner sums the recorded file sizes per owner, largest first
+func (d *Database) UsageByOwner() ([]OwnerUsage, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(`SELECT owner_id, COALESCE(SUM(size), 0), COUNT(*) FROM files GROUP BY owner_id ORDER BY SUM(size) DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make([]OwnerUsage, 0)
+	for rows.Next() {
+		var u OwnerUsage
+		if err := rows.Scan(&u.OwnerID, &u.Bytes, &u.Files); err != nil {
+			return nil, fmt.Errorf("failed to scan file usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// SetFileLocked locks or unlocks the object stored under key
+func (d *Database) SetFileLocked(key string, locked bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(`UPDATE files SET locked = ? WHERE key = ?`, locked, key)
+	if err != nil {
+		return fmt.Errorf("failed to set file lock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("file record not found")
+	}
+
+	return nil
+}
+
+// IsFileLocked reports whether the object stored under key is locked
+func (d *Database) IsFileLocked(key string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var locked bool
+	err := d.conn.QueryRow(`SELECT locked FROM files WHERE key = ?`, key).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check file lock: %w", err)
+	}
+
+	return locked, nil
+}
+
+// LockedFileUnder returns a locked key under prefix, or "" if there is none
+func (d *Database) LockedFileUnder(prefix string) (string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var key string
+	// substr counts characters, not bytes
+	err := d.conn.QueryRow(
+		`SELECT key FROM files WHERE locked = 1 AND substr(key, 1, ?) = ? ORDER BY key LIMIT 1`,
+		utf8.RuneCountInString(prefix), prefix,
+	).Scan(&key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to check file locks: %w", err)
+	}
+
+	return key, nil
+}
\ No newline at end of file
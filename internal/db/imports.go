@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Import job statuses
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+var (
+	// ErrImportJobNotFound is returned for an import job ID that was never issued
+	ErrImportJobNotFound = errors.New("import job not found")
+	// ErrImportRunning is returned when an import is started while another one is running
+	ErrImportRunning = errors.New("an import is already running")
+)
+
+// ImportJob walks the bucket under Prefix and records the objects that have no metadata
+// yet as owned by OwnerID. Cursor is the last key whose page was fully processed, so a job
+// interrupted by a restart continues after it
+type ImportJob struct {
+	ID         string
+	Prefix     string
+	OwnerID    string
+	DryRun     bool
+	Status     string
+	Cursor     string
+	Scanned    int
+	Imported   int
+	Skipped    int
+	Conflicts  int
+	Error      string
+	StartedBy  string
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// ImportConflict is an object an import left alone because its metadata records a different size
+type ImportConflict struct {
+	Key          string
+	RecordedSize int64
+	ObjectSize   int64
+}
+
+// importJobColumns are the columns scanImportJob reads, in order
+const importJobColumns = `id, prefix, owner_id, dry_run, status, cursor, scanned, imported, skipped, conflicts, error, started_by, started_at, updated_at, finished_at`
+
+// scanImportJob reads a row selected with importJobColumns
+func scanImportJob(row interface{ Scan(...interface{}) error }) (*ImportJob, error) {
+	var job ImportJob
+	var finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.Prefix, &job.OwnerID, &job.DryRun, &job.Status, &job.Cursor, &job.Scanned, &job.Imported,
+		&job.Skipped, &job.Conflicts, &job.Error, &job.StartedBy, &job.StartedAt, &job.UpdatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// CreateImportJob stores a new running import job. Only one import may run at a time;
+// it fails with ErrImportRunning while another is running.
+func (d *Database) CreateImportJob(job *ImportJob) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	result, err := d.conn.Exec(
+		`INSERT INTO import_jobs (id, prefix, owner_id, dry_run, status, cursor, started_by, started_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM import_jobs WHERE status = ?)`,
+		job.ID, job.Prefix, job.OwnerID, job.DryRun, ImportRunning, job.Cursor, job.StartedBy, now, now, ImportRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrImportRunning
+	}
+
+	job.Status = ImportRunning
+	job.StartedAt = now
+	job.UpdatedAt = now
+	return nil
+}
+
+// GetImportJob retrieves an import job by ID
+func (d *Database) GetImportJob(id string) (*ImportJob, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	job, err := scanImportJob(d.conn.QueryRow(`SELECT `+importJobColumns+` FROM import_jobs WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+
+	return job, nil
+}
+
+// RunningImportJobs returns the import jobs that haven't finished, oldest first
+func (d *Database) RunningImportJobs() ([]*ImportJob, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(`SELECT `+importJobColumns+` FROM import_jobs WHERE status = ? ORDER BY started_at`, ImportRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*ImportJob
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// SaveImportProgress stores the cursor and counters of job together with the conflicts
+// found since the last save, in one transaction, so a resumed job neither skips nor
+// double-counts a page
+func (d *Database) SaveImportProgress(job *ImportJob, conflicts []ImportConflict) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, conflict := range conflicts {
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO import_conflicts (job_id, key, recorded_size, object_size) VALUES (?, ?, ?, ?)`,
+			job.ID, conflict.Key, conflict.RecordedSize, conflict.ObjectSize,
+		); err != nil {
+			return fmt.Errorf("failed to save import conflict: %w", err)
+		}
+	}
+
+	job.UpdatedAt = time.Now().UTC()
+	if _, err := tx.Exec(
+		`UPDATE import_jobs SET cursor = ?, scanned = ?, imported = ?, skipped = ?, conflicts = ?, updated_at = ? WHERE id = ?`,
+		job.Cursor, job.Scanned, job.Imported, job.Skipped, job.Conflicts, job.UpdatedAt, job.ID,
+	); err != nil {
+		return fmt.Errorf("failed to save import progress: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// FinishImportJob marks a running import job completed or failed. errMsg is kept for a failed job.
+func (d *Database) FinishImportJob(job *ImportJob, status, errMsg string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	_, err := d.conn.Exec(
+		`UPDATE import_jobs SET status = ?, error = ?, updated_at = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		status, errMsg, now, now, job.ID, ImportRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish import job: %w", err)
+	}
+
+	job.Status = status
+	job.Error = errMsg
+	job.UpdatedAt = now
+	job.FinishedAt = &now
+	return nil
+}
+
+// ListImportConflicts returns the conflicts an import job reported, by key
+func (d *Database) ListImportConflicts(jobID string) ([]ImportConflict, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(`SELECT key, recorded_size, object_size FROM import_conflicts WHERE job_id = ? ORDER BY key`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := make([]ImportConflict, 0)
+	for rows.Next() {
+		var conflict ImportConflict
+		if err := rows.Scan(&conflict.Key, &conflict.RecordedSize, &conflict.ObjectSize); err != nil {
+			return nil, fmt.Errorf("failed to scan import conflict: %w", err)
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import conflicts: %w", err)
+	}
+
+	return conflicts, nil
+}
\ No newline at end of file
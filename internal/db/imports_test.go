@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCreateImportJobAllowsOneRunningImport(t *testing.T) {
+	database := newTestDatabase(t)
+	first := &ImportJob{ID: "first", OwnerID: "system", StartedBy: "admin-id"}
+	if err := database.CreateImportJob(first); err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+	if err := database.CreateImportJob(&ImportJob{ID: "second", OwnerID: "system", StartedBy: "admin-id"}); !errors.Is(err, ErrImportRunning) {
+		t.Fatalf("second import while one runs: err = %v, want ErrImportRunning", err)
+	}
+
+	if err := database.FinishImportJob(first, ImportCompleted, ""); err != nil {
+		t.Fatalf("FinishImportJob: %v", err)
+	}
+	if err := database.CreateImportJob(&ImportJob{ID: "second", OwnerID: "system", StartedBy: "admin-id"}); err != nil {
+		t.Fatalf("import after the first finished: %v", err)
+	}
+
+	running, err := database.RunningImportJobs()
+	if err != nil {
+		t.Fatalf("RunningImportJobs: %v", err)
+	}
+	if len(running) != 1 || running[0].ID != "second" {
+		t.Errorf("running = %+v, want only the second import", running)
+	}
+}
+
+func TestSaveImportProgressKeepsConflictsOnce(t *testing.T) {
+	database := newTestDatabase(t)
+	job := &ImportJob{ID: "job", Prefix: "legacy/", OwnerID: "system", StartedBy: "admin-id"}
+	if err := database.CreateImportJob(job); err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+
+	conflict := ImportConflict{Key: "legacy/a.txt", RecordedSize: 4, ObjectSize: 9}
+	job.Cursor, job.Scanned, job.Conflicts = "legacy/a.txt", 1, 1
+	if err := database.SaveImportProgress(job, []ImportConflict{conflict}); err != nil {
+		t.Fatalf("SaveImportProgress: %v", err)
+	}
+	// A page processed again after a restart reports the same conflict
+	if err := database.SaveImportProgress(job, []ImportConflict{conflict}); err != nil {
+		t.Fatalf("SaveImportProgress again: %v", err)
+	}
+
+	saved, err := database.GetImportJob("job")
+	if err != nil {
+		t.Fatalf("GetImportJob: %v", err)
+	}
+	if saved.Cursor != "legacy/a.txt" || saved.Scanned != 1 || saved.Conflicts != 1 || saved.Status != ImportRunning {
+		t.Errorf("saved = %+v, want the cursor and counters stored", saved)
+	}
+	conflicts, err := database.ListImportConflicts("job")
+	if err != nil {
+		t.Fatalf("ListImportConflicts: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != conflict {
+		t.Errorf("conflicts = %+v, want %+v once", conflicts, conflict)
+	}
+
+	if _, err := database.GetImportJob("missing"); !errors.Is(err, ErrImportJobNotFound) {
+		t.Errorf("unknown job: err = %v, want ErrImportJobNotFound", err)
+	}
+}
\ No newline at end of file
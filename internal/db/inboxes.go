@@ -0,0 +1,145 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInboxNotFound is returned for an inbox token that was never issued
+	ErrInboxNotFound = errors.New("inbox not found")
+	// ErrInboxGone is returned for an inbox that expired or was used up
+	ErrInboxGone = errors.New("inbox is no longer available")
+	// ErrInboxTooLarge is returned for a file bigger than what is left of an inbox's byte allowance
+	ErrInboxTooLarge = errors.New("file exceeds the space left in the inbox")
+)
+
+// Inbox lets anyone holding its token upload files under Prefix until it expires or its
+// file count or byte allowance is used up. Only a hash of its token is stored
+type Inbox struct {
+	ID         string
+	Prefix     string
+	CreatedBy  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	MaxFiles   int
+	MaxBytes   int64
+	FileCount  int
+	ByteCount  int64
+	ConsumedAt *time.Time
+}
+
+// Available reports whether the inbox still accepts uploads at now
+func (i *Inbox) Available(now time.Time) bool {
+	return i.ConsumedAt == nil && now.Before(i.ExpiresAt) && i.FileCount < i.MaxFiles && i.ByteCount < i.MaxBytes
+}
+
+// OwnerID is the owner recorded for files uploaded through the inbox
+func (i *Inbox) OwnerID() string {
+	return "inbox:" + i.ID
+}
+
+// CreateInbox stores an inbox under token
+func (d *Database) CreateInbox(token string, inbox *Inbox) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO inboxes (id, token_hash, prefix, created_by, created_at, expires_at, max_files, max_bytes, file_count, byte_count) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 0, 0)`,
+		inbox.ID, hashToken(token), inbox.Prefix, inbox.CreatedBy, inbox.CreatedAt.UTC(), inbox.ExpiresAt.UTC(), inbox.MaxFiles, inbox.MaxBytes,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create inbox: %w", err)
+	}
+
+	return nil
+}
+
+// GetInbox returns the inbox issued under token
+func (d *Database) GetInbox(token string) (*Inbox, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.getInbox(token)
+}
+
+// getInbox reads an inbox; the caller holds the lock
+func (d *Database) getInbox(token string) (*Inbox, error) {
+	var inbox Inbox
+	var consumedAt sql.NullTime
+	err := d.conn.QueryRow(
+		`SELECT id, prefix, created_by, created_at, expires_at, max_files, max_bytes, file_count, byte_count, consumed_at FROM inboxes WHERE token_hash = ?`,
+		hashToken(token),
+	).Scan(&inbox.ID, &inbox.Prefix, &inbox.CreatedBy, &inbox.CreatedAt, &inbox.ExpiresAt, &inbox.MaxFiles, &inbox.MaxBytes, &inbox.FileCount, &inbox.ByteCount, &consumedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInboxNotFound
+		}
+		return nil, fmt.Errorf("failed to get inbox: %w", err)
+	}
+
+	if consumedAt.Valid {
+		inbox.ConsumedAt = &consumedAt.Time
+	}
+	return &inbox, nil
+}
+
+// ReserveInboxUpload counts one file of size bytes against the inbox issued under token,
+// marking the inbox consumed when this uses up its files or bytes. The check and the update
+// happen in one statement, so concurrent uploads can't overrun the limits
+func (d *Database) ReserveInboxUpload(token string, size int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	result, err := d.conn.Exec(
+		`UPDATE inboxes SET file_count = file_count + 1, byte_count = byte_count + ?,
+			consumed_at = CASE WHEN file_count + 1 >= max_files OR byte_count + ? >= max_bytes THEN ? END
+		WHERE token_hash = ? AND consumed_at IS NULL AND expires_at > ? AND file_count < max_files AND byte_count + ? <= max_bytes`,
+		size, size, now, hashToken(token), now, size,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reserve inbox upload: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		inbox, err := d.getInbox(token)
+		if err != nil {
+			return err
+		}
+		if !inbox.Available(now) {
+			return ErrInboxGone
+		}
+		return ErrInboxTooLarge
+	}
+
+	return nil
+}
+
+// ReleaseInboxUpload gives back a reservation made by ReserveInboxUpload for a file that
+// couldn't be stored, reopening the inbox if that reservation consumed it
+func (d *Database) ReleaseInboxUpload(token string, size int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`UPDATE inboxes SET file_count = MAX(file_count - 1, 0), byte_count = MAX(byte_count - ?, 0),
+			consumed_at = CASE WHEN file_count - 1 >= max_files OR byte_count - ? >= max_bytes THEN consumed_at END
+		WHERE token_hash = ?`,
+		size, size, hashToken(token),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release inbox upload: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
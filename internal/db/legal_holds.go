@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Legal hold scopes
+const (
+	HoldScopeUser   = "user"
+	HoldScopePrefix = "prefix"
+)
+
+// LegalHold freezes a user's account or every object under a key prefix
+type LegalHold struct {
+	ID       string
+	Scope    string
+	Target   string
+	Reason   string
+	PlacedBy string
+	PlacedAt time.Time
+}
+
+// CreateLegalHold places a new hold. Only one active hold may exist per scope and target.
+func (d *Database) CreateLegalHold(hold *LegalHold) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO legal_holds (id, scope, target, reason, placed_by, placed_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		hold.ID, hold.Scope, hold.Target, hold.Reason, hold.PlacedBy, hold.PlacedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create legal hold: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseLegalHold lifts an active hold
+func (d *Database) ReleaseLegalHold(id, releasedBy, reason string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE legal_holds SET released_by = ?, released_at = ?, release_reason = ? WHERE id = ? AND released_at IS NULL`,
+		releasedBy, time.Now().UTC(), reason, id,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to release legal hold: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("legal hold not found")
+	}
+
+	return nil
+}
+
+// GetLegalHold retrieves an active hold by ID
+func (d *Database) GetLegalHold(id string) (*LegalHold, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var hold LegalHold
+	err := d.conn.QueryRow(
+		`SELECT id, scope, target, reason, placed_by, placed_at FROM legal_holds WHERE id = ? AND released_at IS NULL`,
+		id,
+	).Scan(&hold.ID, &hold.Scope, &hold.Target, &hold.Reason, &hold.PlacedBy, &hold.PlacedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("legal hold not found")
+		}
+		return nil, fmt.Errorf("failed to get legal hold: %w", err)
+	}
+
+	return &hold, nil
+}
+
+// ListLegalHolds returns every active hold
+func (d *Database) ListLegalHolds() ([]*LegalHold, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT id, scope, target, reason, placed_by, placed_at FROM legal_holds WHERE released_at IS NULL ORDER BY placed_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query legal holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := make([]*LegalHold, 0)
+	for rows.Next() {
+		var hold LegalHold
+		if err := rows.Scan(&hold.ID, &hold.Scope, &hold.Target, &hold.Reason, &hold.PlacedBy, &hold.PlacedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan legal hold: %w", err)
+		}
+		holds = append(holds, &hold)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating legal holds: %w", err)
+	}
+
+	return holds, nil
+}
+
+// FindLegalHold returns the active hold on scope and target, or nil if there is none
+func (d *Database) FindLegalHold(scope, target string) (*LegalHold, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var hold LegalHold
+	err := d.conn.QueryRow(
+		`SELECT id, scope, target, reason, placed_by, placed_at FROM legal_holds WHERE scope = ? AND target = ? AND released_at IS NULL`,
+		scope, target,
+	).Scan(&hold.ID, &hold.Scope, &hold.Target, &hold.Reason, &hold.PlacedBy, &hold.PlacedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get legal hold: %w", err)
+	}
+
+	return &hold, nil
+}
\ No newline at end of file
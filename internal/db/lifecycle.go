@@ -0,0 +1,80 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// LifecycleRule is an expiration rule the app enforces itself, for buckets whose
+// backend has no lifecycle support
+type LifecycleRule struct {
+	Bucket         string
+	ID             string
+	Prefix         string
+	ExpirationDays int32
+	UpdatedBy      string
+	UpdatedAt      time.Time
+}
+
+// ListLifecycleRules returns the emulated lifecycle rules of bucket, or of every bucket when bucket is empty
+func (d *Database) ListLifecycleRules(bucket string) ([]*LifecycleRule, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT bucket, id, prefix, expiration_days, updated_by, updated_at FROM lifecycle_rules WHERE ? = '' OR bucket = ? ORDER BY bucket, prefix`,
+		bucket, bucket,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query lifecycle rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*LifecycleRule, 0)
+	for rows.Next() {
+		var rule LifecycleRule
+		if err := rows.Scan(&rule.Bucket, &rule.ID, &rule.Prefix, &rule.ExpirationDays, &rule.UpdatedBy, &rule.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lifecycle rule: %w", err)
+		}
+		rules = append(rules, &rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating lifecycle rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// ReplaceLifecycleRules swaps the emulated lifecycle rules of bucket for rules in one
+// transaction. An empty rules removes them all.
+func (d *Database) ReplaceLifecycleRules(bucket string, rules []LifecycleRule, updatedBy string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM lifecycle_rules WHERE bucket = ?`, bucket); err != nil {
+		return fmt.Errorf("failed to delete lifecycle rules: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, rule := range rules {
+		if _, err := tx.Exec(
+			`INSERT INTO lifecycle_rules (bucket, id, prefix, expiration_days, updated_by, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			bucket, rule.ID, rule.Prefix, rule.ExpirationDays, updatedBy, now,
+		); err != nil {
+			return fmt.Errorf("failed to save lifecycle rule: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreatePasswordReset stores a single-use password reset token for a user
+func (d *Database) CreatePasswordReset(token, userID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO password_resets (token_hash, user_id, expires_at) VALUES (?, ?, ?)`,
+		hashToken(token), userID, expiresAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumePasswordReset validates a reset token, marks it used and returns the owning user ID.
+// Every other outstanding token for the same user is invalidated as well.
+func (d *Database) ConsumePasswordReset(token string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(
+		`SELECT user_id, expires_at, used_at FROM password_resets WHERE token_hash = ?`,
+		hashToken(token),
+	).Scan(&userID, &expiresAt, &usedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("invalid reset token")
+		}
+		return "", fmt.Errorf("failed to get password reset: %w", err)
+	}
+
+	if usedAt.Valid {
+		return "", fmt.Errorf("reset token already used")
+	}
+	if time.Now().After(expiresAt) {
+		return "", fmt.Errorf("reset token expired")
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		time.Now().UTC(), userID,
+	); err != nil {
+		return "", fmt.Errorf("failed to mark reset token used: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit password reset: %w", err)
+	}
+
+	return userID, nil
+}
+
+// hashToken hashes an opaque token so only its digest is stored at rest
+func hashToken(token string) string {
+	return hashPassword(token)
+}
\ No newline at end of file
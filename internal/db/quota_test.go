@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+func TestSumFileSizesByOwnerSkipsTrash(t *testing.T) {
+	database := newTestDatabase(t)
+	for _, record := range []*FileRecord{
+		{Key: "users/a/one.txt", OwnerID: "a", Size: 3, UploadedAt: time.Now()},
+		{Key: "users/a/two.txt", OwnerID: "a", Size: 4, UploadedAt: time.Now()},
+		{Key: "users/b/other.txt", OwnerID: "b", Size: 100, UploadedAt: time.Now()},
+	} {
+		if err := database.SaveFileRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if used, err := database.SumFileSizesByOwner("a"); err != nil || used != 7 {
+		t.Fatalf("SumFileSizesByOwner = %d, %v, want 7", used, err)
+	}
+
+	if err := database.AddTrashEntry(&TrashEntry{Key: "users/a/two.txt", OriginalKey: "users/a/two.txt", OwnerID: "a", Size: 4, DeletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if used, err := database.SumFileSizesByOwner("a"); err != nil || used != 3 {
+		t.Errorf("after trashing: SumFileSizesByOwner = %d, %v, want 3", used, err)
+	}
+	if used, err := database.SumFileSizesByOwner("nobody"); err != nil || used != 0 {
+		t.Errorf("unknown owner: SumFileSizesByOwner = %d, %v, want 0", used, err)
+	}
+}
+
+func TestUserQuotaOverride(t *testing.T) {
+	database := newTestDatabase(t)
+	if err := database.CreateUser("alice-id", "alice", "alice@example.com", "password", auth.RoleUploader); err != nil {
+		t.Fatal(err)
+	}
+
+	if quota, err := database.GetUserQuota("alice-id"); err != nil || quota != nil {
+		t.Fatalf("new user: GetUserQuota = %v, %v, want no override", quota, err)
+	}
+
+	for _, want := range []int64{10, 0} {
+		if err := database.SetUserQuota("alice-id", &want); err != nil {
+			t.Fatal(err)
+		}
+		if quota, err := database.GetUserQuota("alice-id"); err != nil || quota == nil || *quota != want {
+			t.Errorf("GetUserQuota = %v, %v, want %d", quota, err, want)
+		}
+	}
+
+	if err := database.SetUserQuota("alice-id", nil); err != nil {
+		t.Fatal(err)
+	}
+	if quota, err := database.GetUserQuota("alice-id"); err != nil || quota != nil {
+		t.Errorf("after clearing: GetUserQuota = %v, %v, want no override", quota, err)
+	}
+
+	if _, err := database.GetUserQuota("nobody"); err == nil {
+		t.Error("GetUserQuota succeeded for an unknown user")
+	}
+	if err := database.SetUserQuota("nobody", nil); err == nil {
+		t.Error("SetUserQuota succeeded for an unknown user")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"s3-test-app/internal/service"
+)
+
+// ReplicationQueueStats summarizes the replication queue
+type ReplicationQueueStats struct {
+	// Pending is how many keys wait to be copied to the secondary, Retrying how many of
+	// them failed at least once
+	Pending  int
+	Retrying int
+	// OldestQueuedAt is when the longest-waiting key was queued, nil with an empty queue
+	OldestQueuedAt *time.Time
+	// LastError is the most recent failure of a key still in the queue
+	LastError     string
+	LastErrorKey  string
+	LastAttemptAt *time.Time
+}
+
+// EnqueueReplication queues key to be brought up to date on the secondary. A key already
+// queued is merged into one entry that is due at once, with the latest op.
+func (d *Database) EnqueueReplication(key, op string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now().UTC()
+	_, err := d.conn.Exec(
+		`INSERT INTO replication_queue (key, op, queued_at, next_attempt_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET op = excluded.op, seq = seq + 1, attempts = 0, last_error = '', next_attempt_at = excluded.next_attempt_at`,
+		key, op, now, now,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to queue replication: %w", err)
+	}
+
+	return nil
+}
+
+// DueReplications returns up to limit queued keys due at now, longest due first
+func (d *Database) DueReplications(now time.Time, limit int) ([]service.ReplicationTask, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(
+		`SELECT key, op, seq, attempts FROM replication_queue WHERE next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?`,
+		now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication queue: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []service.ReplicationTask
+	for rows.Next() {
+		var task service.ReplicationTask
+		if err := rows.Scan(&task.Key, &task.Op, &task.Seq, &task.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan replication task: %w", err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// CompleteReplication removes a replicated task from the queue. A key queued again
+// while it was being copied stays, since the copy may predate the newer change.
+func (d *Database) CompleteReplication(task service.ReplicationTask) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(`DELETE FROM replication_queue WHERE key = ? AND seq = ?`, task.Key, task.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to complete replication: %w", err)
+	}
+
+	return nil
+}
+
+// RetryReplication records a failed attempt of task and makes it due again at retryAt
+func (d *Database) RetryReplication(task service.ReplicationTask, lastErr string, retryAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`UPDATE replication_queue SET attempts = attempts + 1, last_error = ?, last_attempt_at = ?, next_attempt_at = ? WHERE key = ? AND seq = ?`,
+		lastErr, time.Now().UTC(), retryAt.UTC(), task.Key, task.Seq,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule replication: %w", err)
+	}
+
+	return nil
+}
+
+// ReplicationQueueStats returns the depth of the replication queue and its latest failure
+func (d *Database) ReplicationQueueStats() (*ReplicationQueueStats, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var stats ReplicationQueueStats
+	err := d.conn.QueryRow(
+		`SELECT COUNT(*), COUNT(CASE WHEN attempts > 0 THEN 1 END) FROM replication_queue`,
+	).Scan(&stats.Pending, &stats.Retrying)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count replication queue: %w", err)
+	}
+	if stats.Pending == 0 {
+		return &stats, nil
+	}
+
+	var oldest time.Time
+	err = d.conn.QueryRow(`SELECT queued_at FROM replication_queue ORDER BY queued_at LIMIT 1`).Scan(&oldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query replication queue: %w", err)
+	}
+	stats.OldestQueuedAt = &oldest
+
+	var attemptAt time.Time
+	err = d.conn.QueryRow(
+		`SELECT key, last_error, last_attempt_at FROM replication_queue WHERE attempts > 0 ORDER BY last_attempt_at DESC LIMIT 1`,
+	).Scan(&stats.LastErrorKey, &stats.LastError, &attemptAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query replication queue: %w", err)
+	}
+	if err == nil {
+		stats.LastAttemptAt = &attemptAt
+	}
+
+	return &stats, nil
+}
\ No newline at end of file
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RevokeToken records a token ID as revoked until it expires. It reports whether this
+// call revoked it, which is false when it already was.
+func (d *Database) RevokeToken(id string, expiresAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`INSERT OR IGNORE INTO revoked_tokens (id, expires_at) VALUES (?, ?)`,
+		id, expiresAt.UTC(),
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// IsTokenRevoked reports whether a token ID has been revoked
+func (d *Database) IsTokenRevoked(id string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var count int
+	err := d.conn.QueryRow(`SELECT COUNT(*) FROM revoked_tokens WHERE id = ?`, id).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// RevokeUserTokens revokes every token issued to a user before the given time
+func (d *Database) RevokeUserTokens(userID string, before time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE users SET tokens_revoked_before = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		before.UTC(), userID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// UserTokensRevokedBefore returns the cutoff before which a user's tokens are invalid,
+// or the zero time if none was set
+func (d *Database) UserTokensRevokedBefore(userID string) (time.Time, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var before sql.NullTime
+	err := d.conn.QueryRow(`SELECT tokens_revoked_before FROM users WHERE id = ?`, userID).Scan(&before)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to check user token revocation: %w", err)
+	}
+
+	return before.Time, nil
+}
+
+// PurgeExpiredRevocations deletes revocation entries whose tokens have expired anyway
+func (d *Database) PurgeExpiredRevocations() (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(`DELETE FROM revoked_tokens WHERE expires_at < ?`, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revoked tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
\ No newline at end of file
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestDatabase opens a fresh database in a temporary directory
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	database, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestRevokeTokenReportsFirstRevocationOnly(t *testing.T) {
+	database := newTestDatabase(t)
+	expiresAt := time.Now().Add(time.Hour)
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := 0
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			revoked, err := database.RevokeToken("token-1", expiresAt)
+			if err != nil {
+				t.Errorf("RevokeToken: %v", err)
+				return
+			}
+			if revoked {
+				mu.Lock()
+				won++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if won != 1 {
+		t.Errorf("%d revocations reported success, want 1", won)
+	}
+	if revoked, err := database.IsTokenRevoked("token-1"); err != nil || !revoked {
+		t.Errorf("IsTokenRevoked = %v, %v; want true", revoked, err)
+	}
+}
+
+func TestPurgeExpiredRevocations(t *testing.T) {
+	database := newTestDatabase(t)
+	if _, err := database.RevokeToken("expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := database.RevokeToken("live", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	purged, err := database.PurgeExpiredRevocations()
+	if err != nil {
+		t.Fatalf("PurgeExpiredRevocations: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("purged %d entries, want 1", purged)
+	}
+	if revoked, _ := database.IsTokenRevoked("live"); !revoked {
+		t.Error("unexpired revocation was purged")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSettingsConflict is returned when settings changed since the version an edit was based on
+var ErrSettingsConflict = errors.New("settings were changed by someone else")
+
+// SettingChange is one change in a settings transaction. A nil Value removes the stored setting.
+type SettingChange struct {
+	Key   string
+	Value *string
+}
+
+// Setting is a runtime configuration value changed by an admin
+type Setting struct {
+	Key       string
+	Value     string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// GetSetting returns the stored value for key, or nil if it has not been set
+func (d *Database) GetSetting(key string) (*Setting, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var setting Setting
+	err := d.conn.QueryRow(
+		`SELECT key, value, updated_by, updated_at FROM settings WHERE key = ?`,
+		key,
+	).Scan(&setting.Key, &setting.Value, &setting.UpdatedBy, &setting.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get setting: %w", err)
+	}
+
+	return &setting, nil
+}
+
+// ListSettings returns every stored setting
+func (d *Database) ListSettings() ([]*Setting, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(`SELECT key, value, updated_by, updated_at FROM settings ORDER BY key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make([]*Setting, 0)
+	for rows.Next() {
+		var setting Setting
+		if err := rows.Scan(&setting.Key, &setting.Value, &setting.UpdatedBy, &setting.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		settings = append(settings, &setting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating settings: %w", err)
+	}
+
+	return settings, nil
+}
+
+// SettingsVersion returns the version of the settings, bumped by every change
+func (d *Database) SettingsVersion() (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var version int64
+	if err := d.conn.QueryRow(`SELECT version FROM settings_version WHERE id = 1`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get settings version: %w", err)
+	}
+
+	return version, nil
+}
+
+// SetSetting stores value for key, replacing any previous value
+func (d *Database) SetSetting(key, value, updatedBy string) error {
+	_, err := d.updateSettings(-1, []SettingChange{{Key: key, Value: &value}}, updatedBy)
+	return err
+}
+
+// DeleteSetting removes the stored value for key
+func (d *Database) DeleteSetting(key string) error {
+	_, err := d.updateSettings(-1, []SettingChange{{Key: key}}, "")
+	return err
+}
+
+// ApplySettings applies every change in one transaction if the settings are still at
+// version expected, and returns the new version. Otherwise nothing changes and
+// ErrSettingsConflict is returned.
+func (d *Database) ApplySettings(expected int64, changes []SettingChange, updatedBy string) (int64, error) {
+	return d.updateSettings(expected, changes, updatedBy)
+}
+
+// updateSettings applies changes and bumps the version in one transaction.
+// A negative expected version skips the version check.
+func (d *Database) updateSettings(expected int64, changes []SettingChange, updatedBy string) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	tx, err := d.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var version int64
+	if err := tx.QueryRow(`SELECT version FROM settings_version WHERE id = 1`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get settings version: %w", err)
+	}
+	if expected >= 0 && version != expected {
+		return version, ErrSettingsConflict
+	}
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		if change.Value == nil {
+			result, err := tx.Exec(`DELETE FROM settings WHERE key = ?`, change.Key)
+			if err != nil {
+				return 0, fmt.Errorf("failed to delete setting: %w", err)
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return 0, fmt.Errorf("failed to get rows affected: %w", err)
+			}
+			// Resetting a setting that isn't stored is only an error on its own
+			if rowsAffected == 0 && expected < 0 {
+				return 0, fmt.Errorf("setting not found")
+			}
+			continue
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO settings (key, value, updated_by, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_by = excluded.updated_by, updated_at = excluded.updated_at`,
+			change.Key, *change.Value, updatedBy, now,
+		); err != nil {
+			return 0, fmt.Errorf("failed to save setting: %w", err)
+		}
+	}
+
+	version++
+	if _, err := tx.Exec(`UPDATE settings_version SET version = ? WHERE id = 1`, version); err != nil {
+		return 0, fmt.Errorf("failed to bump settings version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return version, nil
+}
\ No newline at end of file
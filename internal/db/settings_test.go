@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApplySettingsChecksVersion(t *testing.T) {
+	database := newTestDatabase(t)
+	value := "10/1m"
+
+	version, err := database.ApplySettings(0, []SettingChange{{Key: "ratelimit.api-default", Value: &value}}, "admin-id")
+	if err != nil || version != 1 {
+		t.Fatalf("ApplySettings = %d, %v, want version 1", version, err)
+	}
+
+	other := "20/1m"
+	version, err = database.ApplySettings(0, []SettingChange{{Key: "ratelimit.api-default", Value: &other}}, "other-id")
+	if !errors.Is(err, ErrSettingsConflict) || version != 1 {
+		t.Fatalf("stale ApplySettings = %d, %v, want ErrSettingsConflict at version 1", version, err)
+	}
+
+	settings, err := database.ListSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 1 || settings[0].Value != value || settings[0].UpdatedBy != "admin-id" {
+		t.Errorf("settings = %+v, want only the first change", settings)
+	}
+}
+
+func TestApplySettingsRollsBackOnFailure(t *testing.T) {
+	database := newTestDatabase(t)
+	value := "10/1m"
+
+	// Resetting a setting that was never stored fails the whole single-key edit
+	_, err := database.ApplySettings(-1, []SettingChange{
+		{Key: "ratelimit.api-default", Value: &value},
+		{Key: "ratelimit.download-heavy"},
+	}, "admin-id")
+	if err == nil {
+		t.Fatal("ApplySettings succeeded")
+	}
+
+	settings, err := database.ListSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings) != 0 {
+		t.Errorf("settings = %+v, want the failed transaction rolled back", settings)
+	}
+	if version, err := database.SettingsVersion(); err != nil || version != 0 {
+		t.Errorf("SettingsVersion = %d, %v, want 0", version, err)
+	}
+}
\ No newline at end of file
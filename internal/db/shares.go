@@ -0,0 +1,127 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrShareNotFound is returned for a share token that was never issued or was revoked
+	ErrShareNotFound = errors.New("share not found")
+	// ErrShareGone is returned for a share that expired or used up its downloads
+	ErrShareGone = errors.New("share is no longer available")
+)
+
+// Share is a public download link for one object. Only a hash of its token is stored
+type Share struct {
+	Key           string
+	CreatedBy     string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	MaxDownloads  int
+	DownloadCount int
+}
+
+// Available reports whether the share can still be downloaded at now
+func (s *Share) Available(now time.Time) bool {
+	if now.After(s.ExpiresAt) {
+		return false
+	}
+	return s.MaxDownloads == 0 || s.DownloadCount < s.MaxDownloads
+}
+
+// CreateShare stores a share under token. A MaxDownloads of zero means no limit
+func (d *Database) CreateShare(token string, share *Share) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO shares (token_hash, key, created_by, created_at, expires_at, max_downloads, download_count) VALUES (?, ?, ?, ?, ?, ?, 0)`,
+		hashToken(token), share.Key, share.CreatedBy, share.CreatedAt.UTC(), share.ExpiresAt.UTC(), share.MaxDownloads,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create share: %w", err)
+	}
+
+	return nil
+}
+
+// GetShare returns the share issued under token
+func (d *Database) GetShare(token string) (*Share, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var share Share
+	err := d.conn.QueryRow(
+		`SELECT key, created_by, created_at, expires_at, max_downloads, download_count FROM shares WHERE token_hash = ?`,
+		hashToken(token),
+	).Scan(&share.Key, &share.CreatedBy, &share.CreatedAt, &share.ExpiresAt, &share.MaxDownloads, &share.DownloadCount)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+
+	return &share, nil
+}
+
+// ClaimShareDownload counts one download against the share issued under token. The check
+// and the increment happen in one statement, so concurrent downloads can't overrun the limit
+func (d *Database) ClaimShareDownload(token string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(
+		`UPDATE shares SET download_count = download_count + 1
+		WHERE token_hash = ? AND expires_at > ? AND (max_downloads = 0 OR download_count < max_downloads)`,
+		hashToken(token), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to claim share download: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		if err := d.conn.QueryRow(`SELECT EXISTS(SELECT 1 FROM shares WHERE token_hash = ?)`, hashToken(token)).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to get share: %w", err)
+		}
+		if !exists {
+			return ErrShareNotFound
+		}
+		return ErrShareGone
+	}
+
+	return nil
+}
+
+// DeleteShare revokes the share issued under token
+func (d *Database) DeleteShare(token string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result, err := d.conn.Exec(`DELETE FROM shares WHERE token_hash = ?`, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrShareNotFound
+	}
+
+	return nil
+}
\ No newline at end of file
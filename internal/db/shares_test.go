@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createTestShare stores a share of key under token
+func createTestShare(t *testing.T, database *Database, token string, expiresAt time.Time, maxDownloads int) {
+	t.Helper()
+	share := &Share{Key: "users/alice-id/file.txt", CreatedBy: "alice-id", CreatedAt: time.Now(), ExpiresAt: expiresAt, MaxDownloads: maxDownloads}
+	if err := database.CreateShare(token, share); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+}
+
+func TestClaimShareDownloadNeverExceedsLimit(t *testing.T) {
+	database := newTestDatabase(t)
+	const limit = 3
+	createTestShare(t, database, "token", time.Now().Add(time.Hour), limit)
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := database.ClaimShareDownload("token")
+			if err != nil && !errors.Is(err, ErrShareGone) {
+				t.Errorf("ClaimShareDownload: %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed != limit {
+		t.Errorf("%d downloads claimed, want %d", claimed, limit)
+	}
+	share, err := database.GetShare("token")
+	if err != nil {
+		t.Fatalf("GetShare: %v", err)
+	}
+	if share.DownloadCount != limit {
+		t.Errorf("download_count = %d, want %d", share.DownloadCount, limit)
+	}
+}
+
+func TestClaimShareDownload(t *testing.T) {
+	database := newTestDatabase(t)
+	createTestShare(t, database, "unlimited", time.Now().Add(time.Hour), 0)
+	createTestShare(t, database, "expired", time.Now().Add(-time.Minute), 0)
+
+	for range 5 {
+		if err := database.ClaimShareDownload("unlimited"); err != nil {
+			t.Fatalf("unlimited share: %v", err)
+		}
+	}
+	if err := database.ClaimShareDownload("expired"); !errors.Is(err, ErrShareGone) {
+		t.Errorf("expired share: err = %v, want ErrShareGone", err)
+	}
+	if err := database.ClaimShareDownload("unknown"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("unknown share: err = %v, want ErrShareNotFound", err)
+	}
+
+	if err := database.DeleteShare("unlimited"); err != nil {
+		t.Fatalf("DeleteShare: %v", err)
+	}
+	if err := database.ClaimShareDownload("unlimited"); !errors.Is(err, ErrShareNotFound) {
+		t.Errorf("revoked share: err = %v, want ErrShareNotFound", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TrashEntry records a soft-deleted object that can still be restored
+type TrashEntry struct {
+	Key         string
+	OriginalKey string
+	OwnerID     string
+	Size        int64
+	DeletedBy   string
+	DeletedAt   time.Time
+}
+
+// AddTrashEntry records an object moved into the trash
+func (d *Database) AddTrashEntry(entry *TrashEntry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.conn.Exec(
+		`INSERT INTO trash (key, original_key, owner_id, size, deleted_by, deleted_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.Key, entry.OriginalKey, entry.OwnerID, entry.Size, entry.DeletedBy, entry.DeletedAt.UTC(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to add trash entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetTrashEntry returns the trash entry stored under key, or nil if there is none
+func (d *Database) GetTrashEntry(key string) (*TrashEntry, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var entry TrashEntry
+	err := d.conn.QueryRow(
+		`SELECT key, original_key, owner_id, size, deleted_by, deleted_at FROM trash WHERE key = ?`,
+		key,
+	).Scan(&entry.Key, &entry.OriginalKey, &entry.OwnerID, &entry.Size, &entry.DeletedBy, &entry.DeletedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get trash entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// ListTrash returns the trash entries owned by ownerID, or every entry when ownerID is empty, newest first
+func (d *Database) ListTrash(ownerID string) ([]*TrashEntry, error) {
+	return d.queryTrash(
+		`SELECT key, original_key, owner_id, size, deleted_by, deleted_at FROM trash WHERE ? = '' OR owner_id = ? ORDER BY deleted_at DESC`,
+		ownerID, ownerID,
+	)
+}
+
+// ListTrashBefore returns the trash entries deleted before cutoff
+func (d *Database) ListTrashBefore(cutoff time.Time) ([]*TrashEntry, error) {
+	return d.queryTrash(
+		`SELECT key, original_key, owner_id, size, deleted_by, deleted_at FROM trash WHERE deleted_at < ? ORDER BY deleted_at`,
+		cutoff.UTC(),
+	)
+}
+
+// queryTrash runs a trash query and scans every row
+func (d *Database) queryTrash(query string, args ...interface{}) ([]*TrashEntry, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rows, err := d.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*TrashEntry, 0)
+	for rows.Next() {
+		var entry TrashEntry
+		if err := rows.Scan(&entry.Key, &entry.OriginalKey, &entry.OwnerID, &entry.Size, &entry.DeletedBy, &entry.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trash: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DeleteTrashEntries removes the trash entries stored under the given keys
+func (d *Database) DeleteTrashEntries(keys ...string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, key := range keys {
+		if _, err := d.conn.Exec(`DELETE FROM trash WHERE key = ?`, key); err != nil {
+			return fmt.Errorf("failed to delete trash entry: %w", err)
+		}
+	}
+
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+func TestTouchLastLogin(t *testing.T) {
+	database := newTestDatabase(t)
+	if err := database.CreateUser("alice-id", "alice", "alice@example.com", "password", auth.RoleUploader); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := database.GetUserByID("alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.LastLoginAt.IsZero() {
+		t.Fatalf("new user: LastLoginAt = %v, want zero", user.LastLoginAt)
+	}
+
+	var previous time.Time
+	for range 2 {
+		before := time.Now()
+		if err := database.TouchLastLogin("alice-id"); err != nil {
+			t.Fatalf("TouchLastLogin: %v", err)
+		}
+		user, err := database.GetUserByID("alice-id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.LastLoginAt.Before(before) || user.LastLoginAt.After(time.Now()) {
+			t.Errorf("LastLoginAt = %v, want the time of the login", user.LastLoginAt)
+		}
+		if !user.LastLoginAt.After(previous) {
+			t.Errorf("LastLoginAt = %v, did not move forward from %v", user.LastLoginAt, previous)
+		}
+		previous = user.LastLoginAt
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := database.TouchLastLogin("nobody"); err == nil {
+		t.Error("TouchLastLogin of an unknown user succeeded")
+	}
+}
+
+func TestMigrationAddsLastLoginColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The users table as it was before last logins were recorded
+	if _, err := conn.Exec(`
+		CREATE TABLE users (
+			id TEXT PRIMARY KEY,
+			username TEXT UNIQUE NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO users (id, username, email, password, role) VALUES ('alice-id', 'alice', 'alice@example.com', 'hash', 'uploader');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	database, err := New(path)
+	if err != nil {
+		t.Fatalf("New on an old database: %v", err)
+	}
+	defer database.Close()
+
+	user, err := database.GetUserByID("alice-id")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !user.LastLoginAt.IsZero() {
+		t.Errorf("migrated user: LastLoginAt = %v, want zero", user.LastLoginAt)
+	}
+	if err := database.TouchLastLogin("alice-id"); err != nil {
+		t.Errorf("TouchLastLogin after migration: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File activity actions
+const (
+	ActionUploaded = "uploaded"
+	ActionDeleted  = "deleted"
+	ActionRenamed  = "renamed"
+)
+
+// Event describes one change to a stored file
+type Event struct {
+	Action string `json:"action"`
+	// Key is the affected file, or the prefix ending in "/" when a whole prefix was deleted
+	Key string `json:"key"`
+	// From is the previous key of a renamed file
+	From      string    `json:"from,omitempty"`
+	Bucket    string    `json:"bucket,omitempty"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Hub fans published events out to every subscriber. Publishing never blocks: a
+// subscriber whose buffer is full misses the event and has it counted as dropped
+type Hub struct {
+	mu         sync.RWMutex
+	subs       map[*Subscription]struct{}
+	bufferSize int
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	dropped atomic.Int64
+}
+
+// NewHub creates a hub that buffers up to bufferSize events per subscriber
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		subs:       make(map[*Subscription]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+// Subscribe registers a new subscriber; it must be released with Unsubscribe
+func (h *Hub) Subscribe() *Subscription {
+	ch := make(chan Event, h.bufferSize)
+	sub := &Subscription{C: ch, ch: ch}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+// Unsubscribe removes sub from the hub and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish delivers e to every subscriber that has room for it
+func (h *Hub) Publish(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// TakeDropped returns how many events sub missed since the last call and resets the count
+func (s *Subscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
\ No newline at end of file
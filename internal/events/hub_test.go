@@ -0,0 +1,48 @@
+package events
+
+import "testing"
+
+func TestHubDeliversToEverySubscriber(t *testing.T) {
+	hub := NewHub(4)
+	a, b := hub.Subscribe(), hub.Subscribe()
+	defer hub.Unsubscribe(a)
+	defer hub.Unsubscribe(b)
+
+	hub.Publish(Event{Action: ActionUploaded, Key: "users/a/notes.txt"})
+	for _, sub := range []*Subscription{a, b} {
+		if e := <-sub.C; e.Key != "users/a/notes.txt" {
+			t.Errorf("received %+v", e)
+		}
+	}
+}
+
+func TestHubDropsEventsForFullSubscribers(t *testing.T) {
+	hub := NewHub(2)
+	sub := hub.Subscribe()
+	defer hub.Unsubscribe(sub)
+
+	for range 5 {
+		hub.Publish(Event{Action: ActionUploaded})
+	}
+	if n := len(sub.C); n != 2 {
+		t.Errorf("buffered %d events, want 2", n)
+	}
+	if dropped := sub.TakeDropped(); dropped != 3 {
+		t.Errorf("dropped = %d, want 3", dropped)
+	}
+	if dropped := sub.TakeDropped(); dropped != 0 {
+		t.Errorf("dropped after taking = %d, want 0", dropped)
+	}
+}
+
+func TestHubUnsubscribeClosesChannel(t *testing.T) {
+	hub := NewHub(1)
+	sub := hub.Subscribe()
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(sub)
+
+	hub.Publish(Event{Action: ActionDeleted})
+	if _, ok := <-sub.C; ok {
+		t.Error("event delivered after unsubscribing")
+	}
+}
\ No newline at end of file
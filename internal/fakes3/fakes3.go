@@ -0,0 +1,539 @@
+// Package fakes3 is an in-memory stand-in for the S3 API, covering the calls the service
+// makes for objects, listings, tags and buckets, for tests that exercise S3Service end to end.
+package fakes3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is how many keys a listing returns per page unless the request asks for fewer
+const DefaultPageSize = 1000
+
+// Object is a stored object
+type Object struct {
+	Data            []byte
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+	Tags            map[string]string
+	ETag            string
+	LastModified    time.Time
+}
+
+// Server serves the S3 API from memory. Buckets must be created, through the API or
+// CreateBucket, before objects can be stored in them.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*Object
+	// pageSize caps the keys per listing page
+	pageSize int
+	// intercept, when set, may answer a request before the fake does
+	intercept func(w http.ResponseWriter, r *http.Request) bool
+	// requests counts requests by operation, such as "PutObject"
+	requests map[string]int
+}
+
+// New starts a fake S3 server; it is closed when the test ends
+func New(t interface{ Cleanup(func()) }) *Server {
+	s := &Server{
+		buckets:  make(map[string]map[string]*Object),
+		pageSize: DefaultPageSize,
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// CreateBucket adds an empty bucket
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[name] == nil {
+		s.buckets[name] = make(map[string]*Object)
+	}
+}
+
+// SetPageSize sets how many keys a listing page holds at most
+func (s *Server) SetPageSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = size
+}
+
+// Intercept installs fn to see every request first; when it returns true the request
+// is considered answered. A nil fn removes the interceptor.
+func (s *Server) Intercept(fn func(w http.ResponseWriter, r *http.Request) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intercept = fn
+}
+
+// Put stores an object directly, bypassing the API
+func (s *Server) Put(bucket, key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(bucket, key, &Object{Data: data})
+}
+
+// Get returns a stored object, or nil
+func (s *Server) Get(bucket, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buckets[bucket][key]
+}
+
+// Keys lists the keys stored in bucket, sorted
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Requests returns how many requests for operation, such as "ListObjectsV2", were served
+func (s *Server) Requests(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[operation]
+}
+
+// store saves obj, filling in its ETag and modification time; s.mu must be held
+func (s *Server) store(bucket, key string, obj *Object) {
+	sum := md5.Sum(obj.Data)
+	obj.ETag = `"` + hex.EncodeToString(sum[:]) + `"`
+	obj.LastModified = time.Now().UTC().Truncate(time.Second)
+	if obj.ContentType == "" {
+		obj.ContentType = "binary/octet-stream"
+	}
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]*Object)
+	}
+	s.buckets[bucket][key] = obj
+}
+
+// WriteError answers with an S3 error document
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message><RequestId>fake</RequestId></Error>`, code, code)
+}
+
+// operation names the S3 API call a request makes
+func operation(r *http.Request, key string) string {
+	q := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		return "HeadBucket"
+	case key == "" && r.Method == http.MethodPut:
+		return "CreateBucket"
+	case key == "" && r.Method == http.MethodPost && q.Has("delete"):
+		return "DeleteObjects"
+	case key == "" && r.Method == http.MethodGet && q.Get("list-type") == "2":
+		return "ListObjectsV2"
+	case key == "":
+		return "Bucket" + r.Method
+	case q.Has("tagging") && r.Method == http.MethodPut:
+		return "PutObjectTagging"
+	case q.Has("tagging") && r.Method == http.MethodGet:
+		return "GetObjectTagging"
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		return "CopyObject"
+	case r.Method == http.MethodPut:
+		return "PutObject"
+	case r.Method == http.MethodGet:
+		return "GetObject"
+	case r.Method == http.MethodHead:
+		return "HeadObject"
+	case r.Method == http.MethodDelete:
+		return "DeleteObject"
+	}
+	return "Object" + r.Method
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	s.requests[operation(r, key)]++
+	intercept := s.intercept
+	s.mu.Unlock()
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, exists := s.buckets[bucket]
+	if key == "" {
+		s.serveBucket(w, r, bucket, exists)
+		return
+	}
+	if !exists {
+		WriteError(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch operation(r, key) {
+	case "PutObject":
+		body, err := readBody(r)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		obj := &Object{
+			Data:            body,
+			ContentType:     r.Header.Get("Content-Type"),
+			ContentEncoding: r.Header.Get("Content-Encoding"),
+			Metadata:        metadataOf(r.Header),
+		}
+		// aws-chunked only describes the transfer; it isn't stored with the object
+		obj.ContentEncoding = strings.TrimPrefix(strings.TrimPrefix(obj.ContentEncoding, "aws-chunked"), ",")
+		if tagging := r.Header.Get("X-Amz-Tagging"); tagging != "" {
+			values, _ := url.ParseQuery(tagging)
+			obj.Tags = make(map[string]string, len(values))
+			for k := range values {
+				obj.Tags[k] = values.Get(k)
+			}
+		}
+		s.store(bucket, key, obj)
+		w.Header().Set("ETag", obj.ETag)
+		w.WriteHeader(http.StatusOK)
+
+	case "CopyObject":
+		source, err := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+		srcBucket, srcKey, _ := strings.Cut(source, "/")
+		src := s.buckets[srcBucket][srcKey]
+		if src == nil {
+			WriteError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		obj := *src
+		obj.Data = bytes.Clone(src.Data)
+		if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+			obj.Metadata = metadataOf(r.Header)
+			obj.ContentType = r.Header.Get("Content-Type")
+		}
+		s.store(bucket, key, &obj)
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprintf(w, `<CopyObjectResult><ETag>%s</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+			xmlEscape(obj.ETag), obj.LastModified.Format(time.RFC3339))
+
+	case "GetObject", "HeadObject":
+		obj := objects[key]
+		if obj == nil {
+			WriteError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		writeObjectHeaders(w, obj)
+		data := obj.Data
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, end, ok := parseRange(rng, int64(len(data)))
+			if !ok {
+				WriteError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case "DeleteObject":
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	case "GetObjectTagging":
+		obj := objects[key]
+		if obj == nil {
+			WriteError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		var buf strings.Builder
+		buf.WriteString(`<Tagging><TagSet>`)
+		for _, k := range sortedKeys(obj.Tags) {
+			fmt.Fprintf(&buf, `<Tag><Key>%s</Key><Value>%s</Value></Tag>`, xmlEscape(k), xmlEscape(obj.Tags[k]))
+		}
+		buf.WriteString(`</TagSet></Tagging>`)
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, buf.String())
+
+	case "PutObjectTagging":
+		obj := objects[key]
+		if obj == nil {
+			WriteError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		var tagging struct {
+			Tags []struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			} `xml:"TagSet>Tag"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&tagging); err != nil {
+			WriteError(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		obj.Tags = make(map[string]string, len(tagging.Tags))
+		for _, tag := range tagging.Tags {
+			obj.Tags[tag.Key] = tag.Value
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		WriteError(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// serveBucket answers requests addressed to a bucket rather than an object; s.mu must be held
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, exists bool) {
+	op := operation(r, "")
+	if op == "CreateBucket" {
+		if exists {
+			WriteError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou")
+			return
+		}
+		s.buckets[bucket] = make(map[string]*Object)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if !exists {
+		WriteError(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch op {
+	case "HeadBucket":
+		w.WriteHeader(http.StatusOK)
+	case "ListObjectsV2":
+		s.listObjects(w, r, bucket)
+	case "DeleteObjects":
+		var request struct {
+			Objects []struct {
+				Key string `xml:"Key"`
+			} `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			WriteError(w, r, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var buf strings.Builder
+		buf.WriteString(`<DeleteResult>`)
+		for _, obj := range request.Objects {
+			delete(s.buckets[bucket], obj.Key)
+			fmt.Fprintf(&buf, `<Deleted><Key>%s</Key></Deleted>`, xmlEscape(obj.Key))
+		}
+		buf.WriteString(`</DeleteResult>`)
+		w.Header().Set("Content-Type", "application/xml")
+		io.WriteString(w, buf.String())
+	default:
+		WriteError(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// listObjects answers ListObjectsV2, paging by the key to start after; s.mu must be held
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	after := q.Get("continuation-token")
+	if after == "" {
+		after = q.Get("start-after")
+	}
+	limit := s.pageSize
+	if maxKeys, err := strconv.Atoi(q.Get("max-keys")); err == nil && maxKeys > 0 && maxKeys < limit {
+		limit = maxKeys
+	}
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var contents, folders strings.Builder
+	seenFolders := make(map[string]bool)
+	count := 0
+	truncated := false
+	last := ""
+	for _, key := range keys {
+		if key <= after {
+			continue
+		}
+		folder := ""
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				folder = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if folder != "" && seenFolders[folder] {
+			continue
+		}
+		if count == limit {
+			truncated = true
+			break
+		}
+		count++
+		if folder != "" {
+			seenFolders[folder] = true
+			fmt.Fprintf(&folders, `<CommonPrefixes><Prefix>%s</Prefix></CommonPrefixes>`, xmlEscape(folder))
+			// Everything under the folder is covered by it
+			last = folder + "\U0010FFFF"
+			continue
+		}
+		obj := s.buckets[bucket][key]
+		fmt.Fprintf(&contents, `<Contents><Key>%s</Key><LastModified>%s</LastModified><ETag>%s</ETag><Size>%d</Size><StorageClass>STANDARD</StorageClass></Contents>`,
+			xmlEscape(key), obj.LastModified.Format(time.RFC3339), xmlEscape(obj.ETag), len(obj.Data))
+		last = key
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount><MaxKeys>%d</MaxKeys><IsTruncated>%t</IsTruncated>`,
+		xmlEscape(bucket), xmlEscape(prefix), count, limit, truncated)
+	if truncated {
+		fmt.Fprintf(w, `<NextContinuationToken>%s</NextContinuationToken>`, xmlEscape(last))
+	}
+	io.WriteString(w, contents.String())
+	io.WriteString(w, folders.String())
+	io.WriteString(w, `</ListBucketResult>`)
+}
+
+// readBody returns the object data of a PutObject request, decoding the aws-chunked
+// framing the SDK uses to send trailing checksums
+func readBody(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeField, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeField, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad chunk size %q", sizeField)
+		}
+		if size == 0 {
+			// Trailers follow; they are not checked
+			io.Copy(io.Discard, reader)
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// metadataOf collects the x-amz-meta-* headers of a request
+func metadataOf(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-meta-") && len(values) > 0 {
+			metadata[strings.TrimPrefix(lower, "x-amz-meta-")] = values[0]
+		}
+	}
+	return metadata
+}
+
+// writeObjectHeaders sets the headers GetObject and HeadObject return for obj
+func writeObjectHeaders(w http.ResponseWriter, obj *Object) {
+	w.Header().Set("ETag", obj.ETag)
+	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if obj.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", obj.ContentEncoding)
+	}
+	for k, v := range obj.Metadata {
+		w.Header().Set("X-Amz-Meta-"+k, v)
+	}
+	if len(obj.Tags) > 0 {
+		w.Header().Set("X-Amz-Tagging-Count", strconv.Itoa(len(obj.Tags)))
+	}
+}
+
+// parseRange reads a single "bytes=start-end" range
+func parseRange(header string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	from, to, _ := strings.Cut(spec, "-")
+	if from == "" {
+		n, err := strconv.ParseInt(to, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		return max(size-n, 0), size - 1, size > 0
+	}
+	start, err := strconv.ParseInt(from, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if to != "" {
+		if end, err = strconv.ParseInt(to, 10, 64); err != nil || end < start {
+			return 0, 0, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end, true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func xmlEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
\ No newline at end of file
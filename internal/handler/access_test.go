@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+func TestCanAccessKey(t *testing.T) {
+	alice := &auth.User{ID: "alice-id", Role: auth.RoleUploader}
+	admin := &auth.User{ID: "admin-id", Role: auth.RoleAdmin}
+	own := service.UserPrefix(alice.ID)
+
+	for _, tc := range []struct {
+		user *auth.User
+		key  string
+		want bool
+	}{
+		{alice, own + "report.pdf", true},
+		{alice, own + "nested/report.pdf", true},
+		{alice, "users/bob-id/report.pdf", false},
+		{alice, "report.pdf", false},
+		{alice, own + "../bob-id/report.pdf", false},
+		{alice, own + "./report.pdf", false},
+		{alice, own + "nested/../../bob-id/report.pdf", false},
+		{alice, own + "..\\bob-id\\report.pdf", false},
+		{alice, own + "report\x00.pdf", false},
+		{admin, "users/bob-id/report.pdf", true},
+		{admin, "legacy.pdf", true},
+		{admin, "users/bob-id/../alice-id/report.pdf", false},
+	} {
+		if got := canAccessKey(tc.user, tc.key); got != tc.want {
+			t.Errorf("canAccessKey(%s, %q) = %v, want %v", tc.user.ID, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestOtherUsersFilesAreForbidden(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	bob := createTestUser(t, database, "bob", auth.RoleUploader)
+	key := service.UserPrefix(bob.ID) + "1712345-private.txt"
+	fake.Put(testBucket, key, []byte("private"))
+	query := "?key=" + url.QueryEscape(key)
+
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		request *http.Request
+	}{
+		{"stat", h.StatFile, httptest.NewRequest(http.MethodGet, "/api/files/stat"+query, nil)},
+		{"download", h.DownloadFile, httptest.NewRequest(http.MethodGet, "/api/download"+query, nil)},
+		{"tags", h.GetTags, httptest.NewRequest(http.MethodGet, "/api/files/tags"+query, nil)},
+		{"versions", h.ListVersions, httptest.NewRequest(http.MethodGet, "/api/files/versions"+query, nil)},
+		{"list", h.ListFiles, httptest.NewRequest(http.MethodGet, "/api/files?prefix="+url.QueryEscape(service.UserPrefix(bob.ID)), nil)},
+		{"zip", h.DownloadZip, jsonRequest(t, http.MethodPost, "/api/files/download-zip", DownloadZipRequest{Keys: []string{key}, Strict: true})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler(rec, asUser(tc.request, alice))
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// The owner still gets through
+	if rec := statFile(h, bob, key); rec.Code != http.StatusOK {
+		t.Errorf("owner stat status = %d, want 200", rec.Code)
+	}
+}
+
+func TestWriteHandlersCheckPermissionsThemselves(t *testing.T) {
+	endpoints := []struct {
+		name string
+		need auth.Permission
+		call func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder
+	}{
+		{"upload", auth.Permission{CanUpload: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return uploadFile(h, user, uploadRequest(t, nil, testFile{name: "report.txt", content: []byte("new")}))
+		}},
+		{"set tags", auth.Permission{CanUpload: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return setTags(t, h, user, key, map[string]string{"project": "apollo"})
+		}},
+		{"restore version", auth.Permission{CanUpload: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.RestoreVersion(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/versions/restore", RestoreVersionRequest{Key: key, VersionID: "v1"}), user))
+			return rec
+		}},
+		{"confirm upload", auth.Permission{CanUpload: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/confirm", ConfirmUploadRequest{Key: key}), user))
+			return rec
+		}},
+		{"rename", auth.Permission{CanUpload: true, CanDelete: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return renameFile(t, h, user, RenameRequest{From: key, To: key + ".renamed"})
+		}},
+		{"move", auth.Permission{CanUpload: true, CanDelete: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.MoveFiles(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/move", MoveRequest{Key: key, Destination: service.UserPrefix(user.ID) + "archive/"}), user))
+			return rec
+		}},
+		{"delete", auth.Permission{CanDelete: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(key), nil), user))
+			return rec
+		}},
+		{"batch delete", auth.Permission{CanDelete: true}, func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.BatchDelete(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/batch-delete", BatchDeleteRequest{Keys: []string{key}}), user))
+			return rec
+		}},
+	}
+
+	for _, role := range []auth.Role{auth.RoleUploader, auth.RoleViewer} {
+		for _, endpoint := range endpoints {
+			h, database, fake := newTestHandler(t)
+			user := createTestUser(t, database, string(role), role)
+			key := service.UserPrefix(user.ID) + "report.txt"
+			fake.Put(testBucket, key, []byte("report"))
+
+			rec := endpoint.call(t, h, user, key)
+			name := string(role) + " " + endpoint.name
+			if user.HasPermission(endpoint.need) {
+				if rec.Code == http.StatusForbidden {
+					t.Errorf("%s: status = 403, want it allowed: %s", name, rec.Body.String())
+				}
+				continue
+			}
+			if rec.Code != http.StatusForbidden || decodeResponse(t, rec).Error == "" {
+				t.Errorf("%s: status = %d, want a 403 explaining the refusal: %s", name, rec.Code, rec.Body.String())
+			}
+			if keys := fake.Keys(testBucket); len(keys) != 1 || string(fake.Get(testBucket, key).Data) != "report" {
+				t.Errorf("%s: bucket changed to %v by a refused request", name, keys)
+			}
+		}
+	}
+}
\ No newline at end of file
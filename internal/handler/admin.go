@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 	"s3-test-app/internal/auth"
 	"s3-test-app/internal/db"
@@ -11,25 +16,103 @@ import (
 
 // AdminHandler handles admin operations
 type AdminHandler struct {
-	database *db.Database
-	logger   *zap.Logger
+	tokenManager *auth.TokenManager
+	database     *db.Database
+	logger       *zap.Logger
+	approvals    *ApprovalHandler
+}
+
+// deleteUserPayload is the stored payload of a deferred admin deletion
+type deleteUserPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// demoteAdminPayload is the stored payload of a deferred admin demotion
+type demoteAdminPayload struct {
+	UserID string    `json:"user_id"`
+	Role   auth.Role `json:"role"`
+}
+
+// UserData is a user as exposed to admins, without the password hash
+type UserData struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// LastLoginAt is omitted for users who never logged in
+	LastLoginAt time.Time `json:"last_login_at,omitzero"`
+}
+
+// userData converts a database user to its admin view
+func userData(user *db.User) UserData {
+	return UserData{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      string(user.Role),
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+
+		LastLoginAt: user.LastLoginAt,
+	}
+}
+
+// UpdateRoleRequest is the body of a role change
+type UpdateRoleRequest struct {
+	Role auth.Role `json:"role"`
+}
+
+// UsersData is the payload of the user listing endpoint
+type UsersData struct {
+	Users []UserData `json:"users"`
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(database *db.Database, logger *zap.Logger) *AdminHandler {
-	return &AdminHandler{
-		database: database,
-		logger:   logger,
+func NewAdminHandler(tokenManager *auth.TokenManager, database *db.Database, logger *zap.Logger, approvals *ApprovalHandler) *AdminHandler {
+	h := &AdminHandler{
+		tokenManager: tokenManager,
+		database:     database,
+		logger:       logger,
+		approvals:    approvals,
 	}
+
+	approvals.Register("delete_admin", func(ctx context.Context, payload json.RawMessage) error {
+		var p deleteUserPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		// A hold may have been placed while the deletion waited for approval
+		if hold, err := database.FindLegalHold(db.HoldScopeUser, p.UserID); err != nil || hold != nil {
+			return fmt.Errorf("user is under legal hold")
+		}
+		return database.DeleteUser(p.UserID)
+	})
+
+	approvals.Register("demote_admin", func(ctx context.Context, payload json.RawMessage) error {
+		var p demoteAdminPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		if !p.Role.Valid() {
+			return fmt.Errorf("invalid role %q", p.Role)
+		}
+		if err := database.UpdateUserRole(p.UserID, p.Role); err != nil {
+			return err
+		}
+		h.revokeSessions(p.UserID)
+		return nil
+	})
+
+	return h
 }
 
 // GetUsers returns all users (admin only)
 func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	user := auth.GetUserFromContext(r.Context())
 	if user == nil || user.Role != auth.RoleAdmin {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusForbidden, Response{
 			Success: false,
 			Error:   "unauthorized",
 		})
@@ -40,9 +123,7 @@ func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	dbUsers, err := h.database.GetAllUsers()
 	if err != nil {
 		h.logger.Error("failed to get users", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusInternalServerError, Response{
 			Success: false,
 			Error:   "failed to retrieve users",
 		})
@@ -50,21 +131,15 @@ func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert database users to response format (exclude password hash)
-	users := make([]map[string]interface{}, len(dbUsers))
+	users := make([]UserData, len(dbUsers))
 	for i, dbUser := range dbUsers {
-		users[i] = map[string]interface{}{
-			"id":       dbUser.ID,
-			"username": dbUser.Username,
-			"email":    dbUser.Email,
-			"role":     string(dbUser.Role),
-		}
+		users[i] = userData(dbUser)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{
+	respondJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"users": users,
+		Data: UsersData{
+			Users: users,
 		},
 	})
 }
@@ -73,9 +148,7 @@ func (h *AdminHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	user := auth.GetUserFromContext(r.Context())
 	if user == nil || user.Role != auth.RoleAdmin {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusForbidden, Response{
 			Success: false,
 			Error:   "unauthorized",
 		})
@@ -83,9 +156,7 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != http.MethodDelete {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusMethodNotAllowed, Response{
 			Success: false,
 			Error:   "method not allowed",
 		})
@@ -96,21 +167,60 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	// Prevent deleting self
 	if userId == user.ID {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   "cannot delete yourself",
 		})
 		return
 	}
 
+	// Deleting another admin is a sensitive action that may need a second approval
+	target, err := h.database.GetUserByID(userId)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	hold, err := h.database.FindLegalHold(db.HoldScopeUser, userId)
+	if err != nil {
+		h.logger.Error("failed to check legal hold", zap.String("user_id", userId), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check legal holds",
+		})
+		return
+	}
+	if hold != nil {
+		h.logger.Warn("user deletion blocked by legal hold", zap.String("admin", user.ID), zap.String("user_id", userId))
+		respondJSON(w, http.StatusLocked, Response{
+			Success: false,
+			Error:   "user is under legal hold",
+			Code:    CodeLegalHold,
+		})
+		return
+	}
+
+	if target.Role == auth.RoleAdmin && h.approvals.Required("delete_admin") {
+		pending, err := h.approvals.Submit(user, "delete_admin", userId, deleteUserPayload{UserID: userId})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
 	// Delete user from database
 	if err := h.database.DeleteUser(userId); err != nil {
 		h.logger.Warn("failed to delete user", zap.String("user_id", userId), zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusNotFound, Response{
 			Success: false,
 			Error:   "user not found",
 		})
@@ -118,12 +228,116 @@ func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.Info("user deleted", zap.String("admin", user.ID), zap.String("deleted_user", userId))
+	h.approvals.Audit(user, "delete_user", userId, "")
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{
+	respondJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"message": "user deleted",
+		Data: MessageData{
+			Message: "user deleted",
 		},
 	})
+}
+
+// UpdateUserRole changes a user's role (admin only). The last remaining admin
+// cannot be demoted, so the instance always keeps someone who can manage it.
+// Demoting an admin may need a second admin's approval, and the user's existing
+// sessions end once the role has changed.
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || user.Role != auth.RoleAdmin {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	userId := chi.URLParam(r, "id")
+
+	var req UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	if !req.Role.Valid() {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "role must be one of admin, uploader, viewer",
+		})
+		return
+	}
+
+	target, err := h.database.GetUserByID(userId)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	if target.Role == auth.RoleAdmin && req.Role != auth.RoleAdmin && h.approvals.Required("demote_admin") {
+		pending, err := h.approvals.Submit(user, "demote_admin", userId, demoteAdminPayload{UserID: userId, Role: req.Role})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
+	if err := h.database.UpdateUserRole(userId, req.Role); err != nil {
+		if errors.Is(err, db.ErrLastAdmin) {
+			respondJSON(w, http.StatusConflict, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		h.logger.Warn("failed to update user role", zap.String("user_id", userId), zap.Error(err))
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	h.logger.Info("user role updated",
+		zap.String("admin", user.ID),
+		zap.String("user_id", userId),
+		zap.String("from", string(target.Role)),
+		zap.String("to", string(req.Role)))
+	h.approvals.Audit(user, "update_role", userId, fmt.Sprintf("%s -> %s", target.Role, req.Role))
+
+	// Tokens issued under the old role must not outlive it
+	if target.Role != req.Role {
+		h.revokeSessions(userId)
+	}
+
+	// Read the user back for the new updated_at
+	updated, err := h.database.GetUserByID(userId)
+	if err != nil {
+		updated = target
+		updated.Role = req.Role
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    userData(updated),
+	})
+}
+
+// revokeSessions ends every session of a user whose role changed
+func (h *AdminHandler) revokeSessions(userID string) {
+	if err := h.tokenManager.RevokeUserTokens(userID); err != nil {
+		h.logger.Error("failed to revoke sessions after role change", zap.String("user_id", userID), zap.Error(err))
+	}
 }
\ No newline at end of file
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+)
+
+// newTestAdminHandler creates an admin handler whose approvals require actions
+func newTestAdminHandler(database *db.Database, tokenManager *auth.TokenManager, actions ...string) (*AdminHandler, *ApprovalHandler) {
+	approvals := newTestApprovals(database, actions...)
+	return NewAdminHandler(tokenManager, database, zap.NewNop(), approvals), approvals
+}
+
+// updateRole has admin change the role of userID
+func updateRole(t *testing.T, h *AdminHandler, admin *auth.User, userID string, role auth.Role) *httptest.ResponseRecorder {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", userID)
+	r := jsonRequest(t, http.MethodPut, "/api/admin/users/"+userID+"/role", UpdateRoleRequest{Role: role})
+	r = asUser(r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)), admin)
+	rec := httptest.NewRecorder()
+	h.UpdateUserRole(rec, r)
+	return rec
+}
+
+// storedRole returns the role the database holds for userID
+func storedRole(t *testing.T, database *db.Database, userID string) auth.Role {
+	t.Helper()
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		t.Fatalf("GetUserByID(%s): %v", userID, err)
+	}
+	return user.Role
+}
+
+// sessionFor issues a token for user and returns it
+func sessionFor(t *testing.T, tokenManager *auth.TokenManager, user *auth.User) string {
+	t.Helper()
+	token, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAdminHandler(database, tokenManager)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	session := sessionFor(t, tokenManager, user)
+
+	rec := updateRole(t, h, admin, user.ID, auth.RoleViewer)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data UserData
+	decodeData(t, rec, &data)
+	if data.ID != user.ID || data.Role != string(auth.RoleViewer) {
+		t.Errorf("user = %+v, want %s as a viewer", data, user.ID)
+	}
+	if role := storedRole(t, database, user.ID); role != auth.RoleViewer {
+		t.Errorf("stored role = %s, want viewer", role)
+	}
+	if _, err := tokenManager.ValidateToken(session); err == nil {
+		t.Error("session issued under the old role is still valid")
+	}
+}
+
+func TestUpdateUserRoleKeepsSessionsWhenUnchanged(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAdminHandler(database, tokenManager)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	session := sessionFor(t, tokenManager, user)
+
+	if rec := updateRole(t, h, admin, user.ID, auth.RoleUploader); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := tokenManager.ValidateToken(session); err != nil {
+		t.Errorf("session ended although the role didn't change: %v", err)
+	}
+}
+
+func TestUpdateUserRoleRejectsInvalidRequests(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAdminHandler(database, newTestTokenManager(database))
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	if rec := updateRole(t, h, admin, user.ID, "superuser"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid role: status = %d, want 400", rec.Code)
+	}
+	if rec := updateRole(t, h, admin, "nobody", auth.RoleViewer); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", rec.Code)
+	}
+	if rec := updateRole(t, h, user, user.ID, auth.RoleAdmin); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin caller: status = %d, want 403", rec.Code)
+	}
+	if role := storedRole(t, database, user.ID); role != auth.RoleUploader {
+		t.Errorf("stored role = %s, want it unchanged", role)
+	}
+}
+
+func TestUpdateUserRoleKeepsLastAdmin(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAdminHandler(database, tokenManager)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	session := sessionFor(t, tokenManager, admin)
+
+	if rec := updateRole(t, h, admin, admin.ID, auth.RoleViewer); rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+	if role := storedRole(t, database, admin.ID); role != auth.RoleAdmin {
+		t.Errorf("stored role = %s, want admin", role)
+	}
+	if _, err := tokenManager.ValidateToken(session); err != nil {
+		t.Errorf("refused demotion ended the admin's session: %v", err)
+	}
+
+	// With a second admin the former last one can step down
+	createTestUser(t, database, "second", auth.RoleAdmin)
+	if rec := updateRole(t, h, admin, admin.ID, auth.RoleViewer); rec.Code != http.StatusOK {
+		t.Errorf("with a second admin: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminDemotionNeedsApproval(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, approvals := newTestAdminHandler(database, tokenManager, "demote_admin")
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	second := createTestUser(t, database, "second", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	session := sessionFor(t, tokenManager, second)
+
+	// Promotions and changes between other roles apply right away
+	if rec := updateRole(t, h, admin, user.ID, auth.RoleViewer); rec.Code != http.StatusOK {
+		t.Fatalf("non-admin change: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := updateRole(t, h, admin, second.ID, auth.RoleUploader)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	var pending PendingData
+	decodeData(t, rec, &pending)
+	if role := storedRole(t, database, second.ID); role != auth.RoleAdmin {
+		t.Fatalf("role changed to %s before approval", role)
+	}
+	if _, err := tokenManager.ValidateToken(session); err != nil {
+		t.Fatalf("session ended before approval: %v", err)
+	}
+
+	if rec := approve(approvals, admin, pending.PendingID); rec.Code != http.StatusForbidden {
+		t.Errorf("self-approval: status = %d, want 403", rec.Code)
+	}
+	third := createTestUser(t, database, "third", auth.RoleAdmin)
+	if rec := approve(approvals, third, pending.PendingID); rec.Code != http.StatusOK {
+		t.Fatalf("approval: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if role := storedRole(t, database, second.ID); role != auth.RoleUploader {
+		t.Errorf("role after approval = %s, want uploader", role)
+	}
+	if _, err := tokenManager.ValidateToken(session); err == nil {
+		t.Error("session issued as admin is still valid after the approved demotion")
+	}
+}
\ No newline at end of file
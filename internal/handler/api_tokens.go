@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+)
+
+// apiTokenMaxNameLength caps the label a user gives an API token
+const apiTokenMaxNameLength = 100
+
+// CreateAPITokenRequest asks for a personal access token. ExpiresIn is a duration such
+// as "720h"; without it the token lives until it is revoked.
+type CreateAPITokenRequest struct {
+	Name      string `json:"name"`
+	ExpiresIn string `json:"expires_in"`
+}
+
+// APITokenData describes a personal access token. Token is only set in the response
+// that creates it and can never be read back.
+type APITokenData struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Token      string `json:"token,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+}
+
+// APITokensData is the payload of the API token listing endpoint
+type APITokensData struct {
+	Tokens []APITokenData `json:"tokens"`
+}
+
+// CreateAPITokenHandler issues a personal access token that authenticates as the caller
+// in the Authorization header, for scripts that shouldn't log in with a password
+func (h *AuthHandler) CreateAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	// A leaked token must not be able to mint itself successors
+	if auth.IsAPITokenAuth(r.Context()) {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "API tokens cannot be used to create API tokens",
+		})
+		return
+	}
+
+	var req CreateAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+	if len(req.Name) > apiTokenMaxNameLength {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("name must be at most %d characters", apiTokenMaxNameLength),
+		})
+		return
+	}
+
+	now := time.Now()
+	apiToken := &db.APIToken{
+		UserID:    user.ID,
+		Name:      req.Name,
+		CreatedAt: now,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "expires_in must be a positive duration",
+			})
+			return
+		}
+		expiresAt := now.Add(d)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	id, err := auth.NewRandomToken(12)
+	if err != nil {
+		h.logger.Error("failed to generate API token ID", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create API token",
+		})
+		return
+	}
+	apiToken.ID = id
+
+	token, err := auth.NewAPIToken()
+	if err != nil {
+		h.logger.Error("failed to generate API token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create API token",
+		})
+		return
+	}
+
+	if err := h.database.CreateAPIToken(token, apiToken); err != nil {
+		h.logger.Error("failed to store API token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create API token",
+		})
+		return
+	}
+
+	h.logger.Info("API token created", zap.String("user", user.Name), zap.String("id", apiToken.ID), zap.String("name", apiToken.Name))
+
+	data := apiTokenData(apiToken)
+	data.Token = token
+	respondJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ListAPITokensHandler lists the caller's personal access tokens, without the tokens themselves
+func (h *AuthHandler) ListAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	tokens, err := h.database.ListAPITokens(user.ID)
+	if err != nil {
+		h.logger.Error("failed to list API tokens", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to retrieve API tokens",
+		})
+		return
+	}
+
+	items := make([]APITokenData, 0, len(tokens))
+	for _, token := range tokens {
+		items = append(items, apiTokenData(token))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: APITokensData{
+			Tokens: items,
+		},
+	})
+}
+
+// DeleteAPITokenHandler revokes one of the caller's personal access tokens
+func (h *AuthHandler) DeleteAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if auth.IsAPITokenAuth(r.Context()) {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "API tokens cannot be used to revoke API tokens",
+		})
+		return
+	}
+	id := chi.URLParam(r, "id")
+
+	if err := h.database.DeleteAPIToken(id, user.ID); err != nil {
+		if errors.Is(err, db.ErrAPITokenNotFound) {
+			respondJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "API token not found",
+			})
+			return
+		}
+		h.logger.Error("failed to revoke API token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to revoke API token",
+		})
+		return
+	}
+
+	h.logger.Info("API token revoked", zap.String("user", user.Name), zap.String("id", id))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "API token revoked",
+		},
+	})
+}
+
+// apiTokenData converts a stored API token to its API representation
+func apiTokenData(token *db.APIToken) APITokenData {
+	data := APITokenData{
+		ID:        token.ID,
+		Name:      token.Name,
+		CreatedAt: token.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if token.ExpiresAt != nil {
+		data.ExpiresAt = token.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if token.LastUsedAt != nil {
+		data.LastUsedAt = token.LastUsedAt.UTC().Format(time.RFC3339)
+	}
+	return data
+}
\ No newline at end of file
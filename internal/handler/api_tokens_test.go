@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	mw "s3-test-app/internal/middleware"
+)
+
+// createAPIToken issues a personal access token for user through the handler
+func createAPIToken(t *testing.T, h *AuthHandler, user *auth.User, name string) APITokenData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.CreateAPITokenHandler(rec, asUser(jsonRequest(t, http.MethodPost, "/api/tokens", CreateAPITokenRequest{Name: name}), user))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	var data APITokenData
+	decodeData(t, rec, &data)
+	return data
+}
+
+// listAPITokens returns user's personal access tokens
+func listAPITokens(t *testing.T, h *AuthHandler, user *auth.User) []APITokenData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ListAPITokensHandler(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/tokens", nil), user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data APITokensData
+	decodeData(t, rec, &data)
+	return data.Tokens
+}
+
+// deleteAPIToken revokes the token with id as user and returns the status
+func deleteAPIToken(h *AuthHandler, user *auth.User, id string) int {
+	r := httptest.NewRequest(http.MethodDelete, "/api/tokens/"+id, nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	r = asUser(r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)), user)
+	rec := httptest.NewRecorder()
+	h.DeleteAPITokenHandler(rec, r)
+	return rec.Code
+}
+
+func TestAPITokenListAndDelete(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	bob := createTestUser(t, database, "bob", auth.RoleUploader)
+
+	first := createAPIToken(t, h, alice, "ci")
+	second := createAPIToken(t, h, alice, "backup")
+	if !auth.IsAPIToken(first.Token) {
+		t.Fatalf("created token %q lacks the %s prefix", first.Token, auth.APITokenPrefix)
+	}
+
+	tokens := listAPITokens(t, h, alice)
+	if len(tokens) != 2 {
+		t.Fatalf("listed %d tokens, want 2", len(tokens))
+	}
+	for _, token := range tokens {
+		if token.Token != "" {
+			t.Errorf("listing exposes token %s", token.ID)
+		}
+	}
+	if got := listAPITokens(t, h, bob); len(got) != 0 {
+		t.Errorf("bob lists %d tokens, want 0", len(got))
+	}
+
+	// Someone else's token looks like one that doesn't exist
+	if code := deleteAPIToken(h, bob, first.ID); code != http.StatusNotFound {
+		t.Errorf("delete by another user: status = %d, want 404", code)
+	}
+	if code := deleteAPIToken(h, alice, first.ID); code != http.StatusOK {
+		t.Fatalf("delete status = %d, want 200", code)
+	}
+	if code := deleteAPIToken(h, alice, first.ID); code != http.StatusNotFound {
+		t.Errorf("second delete: status = %d, want 404", code)
+	}
+
+	tokens = listAPITokens(t, h, alice)
+	if len(tokens) != 1 || tokens[0].ID != second.ID {
+		t.Errorf("tokens after delete = %+v, want only %s", tokens, second.ID)
+	}
+	if _, err := database.LookupAPIToken(first.Token); err == nil {
+		t.Error("deleted token still authenticates")
+	}
+}
+
+func TestAPITokenCannotManageTokens(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	existing := createAPIToken(t, h, user, "ci")
+
+	r := chi.NewRouter()
+	r.Use(mw.AuthMiddleware(tokenManager))
+	r.Get("/api/tokens", h.ListAPITokensHandler)
+	r.Post("/api/tokens", h.CreateAPITokenHandler)
+	r.Delete("/api/tokens/{id}", h.DeleteAPITokenHandler)
+
+	call := func(method, target string) int {
+		req := jsonRequest(t, method, target, CreateAPITokenRequest{Name: "successor"})
+		req.Header.Set("Authorization", "Bearer "+existing.Token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := call(http.MethodGet, "/api/tokens"); code != http.StatusOK {
+		t.Errorf("list: status = %d, want 200", code)
+	}
+	if code := call(http.MethodPost, "/api/tokens"); code != http.StatusForbidden {
+		t.Errorf("create: status = %d, want 403", code)
+	}
+	if code := call(http.MethodDelete, "/api/tokens/"+existing.ID); code != http.StatusForbidden {
+		t.Errorf("delete: status = %d, want 403", code)
+	}
+	if tokens := listAPITokens(t, h, user); len(tokens) != 1 {
+		t.Errorf("%d tokens after refused calls, want 1", len(tokens))
+	}
+}
+
+func TestPasswordResetRevokesAPITokens(t *testing.T) {
+	database := newTestDatabase(t)
+	h, mailer := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	apiToken := createAPIToken(t, h, user, "ci")
+
+	rec := httptest.NewRecorder()
+	h.ForgotPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/forgot-password", ForgotPasswordRequest{Email: user.Email}))
+	token := resetTokenFrom(t, mailer.last(t).Body)
+
+	rec = httptest.NewRecorder()
+	h.ResetPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/reset-password", ResetPasswordRequest{Token: token, NewPassword: "new-password"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset-password status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := database.LookupAPIToken(apiToken.Token); err == nil {
+		t.Error("API token issued before the reset still authenticates")
+	}
+}
+
+func TestPasswordChangeRevokesAPITokens(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	apiToken := createAPIToken(t, h, user, "ci")
+
+	// Keeping other sessions does not keep API tokens
+	rec := httptest.NewRecorder()
+	req := ChangePasswordRequest{CurrentPassword: "password", NewPassword: "new-password", KeepOtherSessions: true}
+	h.ChangePasswordHandler(rec, asUser(jsonRequest(t, http.MethodPost, "/api/auth/change-password", req), user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change-password status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := database.LookupAPIToken(apiToken.Token); err == nil {
+		t.Error("API token issued before the change still authenticates")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,331 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+)
+
+// ActionExecutor carries out an approved action from its stored payload
+type ActionExecutor func(ctx context.Context, payload json.RawMessage) error
+
+// ApprovalHandler implements the two-person approval workflow for sensitive admin actions
+type ApprovalHandler struct {
+	database  *db.Database
+	logger    *zap.Logger
+	cfg       *config.ApprovalConfig
+	executors map[string]ActionExecutor
+}
+
+// PendingData is the payload returned when an action was deferred for approval
+type PendingData struct {
+	Message   string `json:"message"`
+	PendingID string `json:"pending_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// ApprovalData describes an action awaiting approval
+type ApprovalData struct {
+	ID          string `json:"id"`
+	Action      string `json:"action"`
+	Target      string `json:"target"`
+	RequestedBy string `json:"requested_by"`
+	CreatedAt   string `json:"created_at"`
+	ExpiresAt   string `json:"expires_at"`
+	Expired     bool   `json:"expired"`
+}
+
+// ApprovalsData is the payload of the approval listing endpoint
+type ApprovalsData struct {
+	Approvals []ApprovalData `json:"approvals"`
+}
+
+// NewApprovalHandler creates a new approval handler
+func NewApprovalHandler(database *db.Database, logger *zap.Logger, cfg *config.ApprovalConfig) *ApprovalHandler {
+	if cfg.Disabled {
+		logger.Warn("TWO-PERSON APPROVAL IS DISABLED: sensitive admin actions will execute with a single admin's consent",
+			zap.Strings("actions", cfg.Actions))
+	}
+
+	return &ApprovalHandler{
+		database:  database,
+		logger:    logger,
+		cfg:       cfg,
+		executors: make(map[string]ActionExecutor),
+	}
+}
+
+// Register sets the executor used once an action is approved
+func (h *ApprovalHandler) Register(action string, executor ActionExecutor) {
+	h.executors[action] = executor
+}
+
+// Required reports whether action needs a second admin's approval
+func (h *ApprovalHandler) Required(action string) bool {
+	if h.cfg.Disabled {
+		return false
+	}
+	for _, a := range h.cfg.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Submit records a pending action on behalf of the requesting admin
+func (h *ApprovalHandler) Submit(requester *auth.User, action, target string, payload interface{}) (*db.PendingAction, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	id, err := auth.NewRandomToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := &db.PendingAction{
+		ID:          id,
+		Action:      action,
+		Target:      target,
+		Payload:     string(payloadJSON),
+		RequestedBy: requester.ID,
+		Status:      db.ActionPending,
+		CreatedAt:   time.Now(),
+		ExpiresAt:   time.Now().Add(h.cfg.TTL),
+	}
+	if err := h.database.CreatePendingAction(pending); err != nil {
+		return nil, err
+	}
+
+	h.audit(db.AuditEntry{
+		Actor:   requester.ID,
+		Action:  action + ".requested",
+		Target:  target,
+		Details: pending.ID,
+	})
+
+	return pending, nil
+}
+
+// Audit records an action that executed without needing approval
+func (h *ApprovalHandler) Audit(actor *auth.User, action, target, details string) {
+	h.audit(db.AuditEntry{
+		Actor:   actor.ID,
+		Action:  action,
+		Target:  target,
+		Details: details,
+	})
+}
+
+// WritePending answers a request whose action was deferred for approval
+func (h *ApprovalHandler) WritePending(w http.ResponseWriter, pending *db.PendingAction) {
+	respondJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Data: PendingData{
+			Message:   "action requires approval by a second admin",
+			PendingID: pending.ID,
+			ExpiresAt: pending.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// ListApprovals returns all actions awaiting approval
+func (h *ApprovalHandler) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.database.ListPendingActions()
+	if err != nil {
+		h.logger.Error("failed to list pending actions", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to retrieve pending actions",
+		})
+		return
+	}
+
+	items := make([]ApprovalData, 0, len(actions))
+	for _, action := range actions {
+		items = append(items, ApprovalData{
+			ID:          action.ID,
+			Action:      action.Action,
+			Target:      action.Target,
+			RequestedBy: action.RequestedBy,
+			CreatedAt:   action.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:   action.ExpiresAt.Format(time.RFC3339),
+			Expired:     time.Now().After(action.ExpiresAt),
+		})
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: ApprovalsData{
+			Approvals: items,
+		},
+	})
+}
+
+// ApproveAction lets a second admin approve and execute a pending action
+func (h *ApprovalHandler) ApproveAction(w http.ResponseWriter, r *http.Request) {
+	approver := auth.GetUserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	action, ok := h.loadPending(w, id)
+	if !ok {
+		return
+	}
+
+	if action.RequestedBy == approver.ID {
+		h.logger.Warn("self-approval rejected", zap.String("pending_id", id), zap.String("admin", approver.ID))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "an action must be approved by a different admin",
+		})
+		return
+	}
+
+	executor, ok := h.executors[action.Action]
+	if !ok {
+		h.logger.Error("no executor registered for action", zap.String("action", action.Action))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "unknown action",
+		})
+		return
+	}
+
+	if err := h.database.TransitionPendingAction(id, db.ActionPending, db.ActionApproved, approver.ID); err != nil {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   "action is no longer pending",
+		})
+		return
+	}
+
+	entry := db.AuditEntry{
+		Actor:    action.RequestedBy,
+		Approver: approver.ID,
+		Action:   action.Action,
+		Target:   action.Target,
+		Details:  action.ID,
+	}
+
+	if err := executor(r.Context(), json.RawMessage(action.Payload)); err != nil {
+		h.logger.Error("approved action failed", zap.String("pending_id", id), zap.String("action", action.Action), zap.Error(err))
+		if err := h.database.TransitionPendingAction(id, db.ActionApproved, db.ActionFailed, ""); err != nil {
+			h.logger.Error("failed to mark action failed", zap.String("pending_id", id), zap.Error(err))
+		}
+		entry.Action += ".failed"
+		h.audit(entry)
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "action failed: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.database.TransitionPendingAction(id, db.ActionApproved, db.ActionExecuted, ""); err != nil {
+		h.logger.Error("failed to mark action executed", zap.String("pending_id", id), zap.Error(err))
+	}
+	h.audit(entry)
+
+	h.logger.Info("approved action executed",
+		zap.String("pending_id", id),
+		zap.String("action", action.Action),
+		zap.String("requested_by", action.RequestedBy),
+		zap.String("approved_by", approver.ID),
+	)
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "action approved and executed",
+		},
+	})
+}
+
+// CancelAction withdraws a pending action
+func (h *ApprovalHandler) CancelAction(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	action, ok := h.loadPending(w, id)
+	if !ok {
+		return
+	}
+
+	if err := h.database.TransitionPendingAction(id, db.ActionPending, db.ActionCancelled, ""); err != nil {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   "action is no longer pending",
+		})
+		return
+	}
+
+	h.audit(db.AuditEntry{
+		Actor:   admin.ID,
+		Action:  action.Action + ".cancelled",
+		Target:  action.Target,
+		Details: action.ID,
+	})
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "action cancelled",
+		},
+	})
+}
+
+// loadPending fetches a pending action and writes the error response if it can't be acted on
+func (h *ApprovalHandler) loadPending(w http.ResponseWriter, id string) (*db.PendingAction, bool) {
+	action, err := h.database.GetPendingAction(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "pending action not found",
+		})
+		return nil, false
+	}
+
+	if action.Status != db.ActionPending {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   "action is already " + action.Status,
+		})
+		return nil, false
+	}
+
+	if time.Now().After(action.ExpiresAt) {
+		if err := h.database.TransitionPendingAction(id, db.ActionPending, db.ActionExpired, ""); err != nil {
+			h.logger.Error("failed to expire pending action", zap.String("pending_id", id), zap.Error(err))
+		}
+		respondJSON(w, http.StatusGone, Response{
+			Success: false,
+			Error:   "pending action has expired",
+		})
+		return nil, false
+	}
+
+	return action, true
+}
+
+// audit writes an entry to the audit log, logging rather than failing on error
+func (h *ApprovalHandler) audit(entry db.AuditEntry) {
+	if err := h.database.RecordAudit(entry); err != nil {
+		h.logger.Error("failed to record audit entry", zap.String("action", entry.Action), zap.Error(err))
+	}
+	h.logger.Info("audit",
+		zap.String("actor", entry.Actor),
+		zap.String("approver", entry.Approver),
+		zap.String("action", entry.Action),
+		zap.String("target", entry.Target),
+	)
+}
\ No newline at end of file
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+)
+
+// newTestApprovals creates an approval handler requiring approval for actions
+func newTestApprovals(database *db.Database, actions ...string) *ApprovalHandler {
+	return NewApprovalHandler(database, zap.NewNop(), &config.ApprovalConfig{
+		Actions: actions,
+		TTL:     time.Hour,
+	})
+}
+
+// approve has admin approve the pending action id
+func approve(approvals *ApprovalHandler, admin *auth.User, id string) *httptest.ResponseRecorder {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/approvals/"+id+"/approve", nil)
+	r = asUser(r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)), admin)
+	rec := httptest.NewRecorder()
+	approvals.ApproveAction(rec, r)
+	return rec
+}
+
+func TestDeletePrefixWaitsForSecondAdmin(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	approvals := newTestApprovals(database, "delete_prefix")
+	h.SetApprovals(approvals)
+	first := createTestUser(t, database, "first", auth.RoleAdmin)
+	second := createTestUser(t, database, "second", auth.RoleAdmin)
+	fake.Put(testBucket, "reports/a.txt", []byte("a"))
+	fake.Put(testBucket, "reports/b.txt", []byte("b"))
+
+	rec := httptest.NewRecorder()
+	h.DeletePrefix(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files/prefix?prefix=reports/", nil), first))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	var pending PendingData
+	decodeData(t, rec, &pending)
+	if keys := fake.Keys(testBucket); len(keys) != 2 {
+		t.Fatalf("objects deleted before approval: %v left", keys)
+	}
+
+	if rec := approve(approvals, first, pending.PendingID); rec.Code != http.StatusForbidden {
+		t.Errorf("self-approval status = %d, want 403", rec.Code)
+	}
+	if rec := approve(approvals, second, pending.PendingID); rec.Code != http.StatusOK {
+		t.Fatalf("approval status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("objects left after approved delete: %v", keys)
+	}
+}
+
+func TestDeletePrefixWithoutApprovalRunsAtOnce(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	h.SetApprovals(newTestApprovals(database))
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "reports/a.txt", []byte("a"))
+
+	rec := httptest.NewRecorder()
+	h.DeletePrefix(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files/prefix?prefix=reports/", nil), admin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("objects left: %v", keys)
+	}
+}
+
+func TestEmptyTrashWaitsForSecondAdmin(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	approvals := newTestApprovals(database, "empty_trash")
+	h.SetApprovals(approvals)
+	first := createTestUser(t, database, "first", auth.RoleAdmin)
+	second := createTestUser(t, database, "second", auth.RoleAdmin)
+
+	rec := httptest.NewRecorder()
+	h.EmptyTrash(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/trash", nil), first))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	var pending PendingData
+	decodeData(t, rec, &pending)
+
+	if rec := approve(approvals, second, pending.PendingID); rec.Code != http.StatusOK {
+		t.Errorf("approval status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
\ No newline at end of file
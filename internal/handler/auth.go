@@ -2,7 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,6 +14,8 @@ import (
 	"s3-test-app/internal/auth"
 	"s3-test-app/internal/config"
 	"s3-test-app/internal/db"
+	"s3-test-app/internal/mail"
+	"s3-test-app/internal/ratelimit"
 )
 
 // User credentials for login
@@ -38,13 +44,33 @@ type SignupResponse struct {
 	Error   string `json:"error,omitempty"`
 }
 
+// ForgotPasswordRequest starts a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest completes a password reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePasswordRequest rotates the logged-in user's password
+type ChangePasswordRequest struct {
+	CurrentPassword   string `json:"current_password"`
+	NewPassword       string `json:"new_password"`
+	KeepOtherSessions bool   `json:"keep_other_sessions"`
+}
+
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(tokenManager *auth.TokenManager, database *db.Database, logger *zap.Logger, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(tokenManager *auth.TokenManager, database *db.Database, logger *zap.Logger, cfg *config.Config, loginLimiter *ratelimit.Limiter, mailer mail.EmailSender) *AuthHandler {
 	return &AuthHandler{
 		tokenManager: tokenManager,
 		database:     database,
 		logger:       logger,
 		cfg:          cfg,
+		loginLimiter: loginLimiter,
+		mailer:       mailer,
 	}
 }
 
@@ -54,14 +80,14 @@ type AuthHandler struct {
 	database     *db.Database
 	logger       *zap.Logger
 	cfg          *config.Config
+	loginLimiter *ratelimit.Limiter
+	mailer       mail.EmailSender
 }
 
 // LoginHandler handles user login
 func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(LoginResponse{
+		respondJSON(w, http.StatusMethodNotAllowed, LoginResponse{
 			Success: false,
 			Error:   "method not allowed",
 		})
@@ -70,22 +96,30 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(LoginResponse{
+		respondJSON(w, http.StatusBadRequest, LoginResponse{
 			Success: false,
 			Error:   "invalid request",
 		})
 		return
 	}
 
+	// Throttle attempts per username and client IP
+	limitKey := strings.ToLower(req.Username) + "|" + clientIP(r)
+	if allowed, retryAfter := h.loginLimiter.Allow(limitKey); !allowed {
+		h.logger.Warn("login rate limited", zap.String("username", req.Username), zap.String("ip", clientIP(r)))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondJSON(w, http.StatusTooManyRequests, LoginResponse{
+			Success: false,
+			Error:   "too many login attempts, try again later",
+		})
+		return
+	}
+
 	// Get user from database
 	dbUser, err := h.database.GetUserByUsername(req.Username)
 	if err != nil {
 		h.logger.Warn("login failed - user not found", zap.String("username", req.Username))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(LoginResponse{
+		respondJSON(w, http.StatusUnauthorized, LoginResponse{
 			Success: false,
 			Error:   "invalid username or password",
 		})
@@ -95,9 +129,7 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Verify password
 	if !db.VerifyPassword(dbUser.Password, req.Password) {
 		h.logger.Warn("login failed - invalid password", zap.String("username", req.Username))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(LoginResponse{
+		respondJSON(w, http.StatusUnauthorized, LoginResponse{
 			Success: false,
 			Error:   "invalid username or password",
 		})
@@ -113,25 +145,29 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate token
-	token, err := h.tokenManager.GenerateToken(user, 24*time.Hour)
+	token, err := h.tokenManager.GenerateToken(user, h.cfg.Auth.TokenTTL)
 	if err != nil {
 		h.logger.Error("failed to generate token", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(LoginResponse{
+		respondJSON(w, http.StatusInternalServerError, LoginResponse{
 			Success: false,
 			Error:   "failed to generate token",
 		})
 		return
 	}
 
+	h.loginLimiter.Reset(limitKey)
+
+	// A failed update only loses the timestamp, so it doesn't fail the login
+	if err := h.database.TouchLastLogin(dbUser.ID); err != nil {
+		h.logger.Error("failed to record last login", zap.String("user_id", dbUser.ID), zap.Error(err))
+	}
+
 	h.logger.Info("user logged in", zap.String("username", req.Username), zap.String("role", string(user.Role)))
 
 	// Set auth token cookie
-	auth.SetTokenCookie(w, token, 24*time.Hour)
+	h.tokenManager.SetTokenCookie(w, token, h.cfg.Auth.TokenTTL)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{
+	respondJSON(w, http.StatusOK, LoginResponse{
 		Success: true,
 		Token:   token,
 	})
@@ -140,32 +176,90 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 // LogoutHandler handles user logout
 func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "method not allowed",
+		respondJSON(w, http.StatusMethodNotAllowed, Response{
+			Success: false,
+			Error:   "method not allowed",
 		})
 		return
 	}
 
+	// Revoke the presented token so it can't be replayed until expiry
+	if token, err := auth.TokenFromRequest(r); err == nil {
+		if err := h.tokenManager.RevokeToken(token); err != nil {
+			h.logger.Warn("failed to revoke token on logout", zap.Error(err))
+		}
+	}
+
 	// Clear auth token cookie
-	auth.ClearTokenCookie(w)
+	h.tokenManager.ClearTokenCookie(w)
 
 	h.logger.Info("user logged out")
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+	})
+}
+
+// CSRFData is the payload of the CSRF token endpoint
+type CSRFData struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// CSRFTokenHandler returns the CSRF token that state-changing requests made with the
+// auth cookie must send in the X-CSRF-Token header
+func (h *AuthHandler) CSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.TokenFromRequest(r)
+	if err != nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: CSRFData{
+			CSRFToken: h.tokenManager.CSRFToken(token),
+		},
+	})
+}
+
+// RefreshHandler exchanges a valid or recently expired token for a fresh one
+func (h *AuthHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	oldToken, err := auth.TokenFromRequest(r)
+	if err != nil {
+		respondJSON(w, http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	token, err := h.tokenManager.RefreshToken(oldToken)
+	if err != nil {
+		h.logger.Warn("token refresh rejected", zap.Error(err))
+		h.tokenManager.ClearTokenCookie(w)
+		respondJSON(w, http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Error:   "token cannot be refreshed",
+		})
+		return
+	}
+
+	// Set auth token cookie
+	h.tokenManager.SetTokenCookie(w, token, h.cfg.Auth.TokenTTL)
+
+	respondJSON(w, http.StatusOK, LoginResponse{
+		Success: true,
+		Token:   token,
 	})
 }
 
 // SignupHandler handles user registration
 func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusMethodNotAllowed, SignupResponse{
 			Success: false,
 			Error:   "method not allowed",
 		})
@@ -174,9 +268,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 
 	var req SignupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusBadRequest, SignupResponse{
 			Success: false,
 			Error:   "invalid request",
 		})
@@ -186,9 +278,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate signup key
 	if req.SignupKey != h.cfg.Auth.SignupKey {
 		h.logger.Warn("signup failed - invalid signup key", zap.String("username", req.Username))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusUnauthorized, SignupResponse{
 			Success: false,
 			Error:   "invalid signup key",
 		})
@@ -201,9 +291,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	req.Password = strings.TrimSpace(req.Password)
 
 	if req.Username == "" || req.Email == "" || req.Password == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusBadRequest, SignupResponse{
 			Success: false,
 			Error:   "username, email and password are required",
 		})
@@ -211,9 +299,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(req.Password) < 6 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusBadRequest, SignupResponse{
 			Success: false,
 			Error:   "password must be at least 6 characters",
 		})
@@ -223,9 +309,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if user already exists
 	if _, err := h.database.GetUserByUsername(req.Username); err == nil {
 		h.logger.Warn("signup failed - user already exists", zap.String("username", req.Username))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusConflict, SignupResponse{
 			Success: false,
 			Error:   "username already exists",
 		})
@@ -235,9 +319,7 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	// Check if email already exists
 	if _, err := h.database.GetUserByEmail(req.Email); err == nil {
 		h.logger.Warn("signup failed - email already exists", zap.String("email", req.Email))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusConflict, SignupResponse{
 			Success: false,
 			Error:   "email already exists",
 		})
@@ -263,30 +345,31 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	// Create user
 	if err := h.database.CreateUser(userID, req.Username, req.Email, req.Password, role); err != nil {
 		h.logger.Error("failed to create user", zap.String("username", req.Username), zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusInternalServerError, SignupResponse{
 			Success: false,
 			Error:   "failed to create user",
 		})
 		return
 	}
 
+	if err := h.sendVerification(r, userID, req.Email); err != nil {
+		// The account exists either way; the user can ask an admin to resend
+		h.logger.Error("failed to send verification email", zap.String("user_id", userID), zap.Error(err))
+	}
+
 	// Create user object
 	user := &auth.User{
 		ID:    userID,
 		Name:  req.Username,
 		Email: req.Email,
-		Role:  auth.RoleUploader,
+		Role:  role,
 	}
 
 	// Generate token
-	token, err := h.tokenManager.GenerateToken(user, 24*time.Hour)
+	token, err := h.tokenManager.GenerateToken(user, h.cfg.Auth.TokenTTL)
 	if err != nil {
 		h.logger.Error("failed to generate token", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(SignupResponse{
+		respondJSON(w, http.StatusInternalServerError, SignupResponse{
 			Success: false,
 			Error:   "failed to generate token",
 		})
@@ -296,11 +379,288 @@ func (h *AuthHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("user registered", zap.String("username", req.Username), zap.String("email", req.Email))
 
 	// Set auth token cookie
-	auth.SetTokenCookie(w, token, 24*time.Hour)
+	h.tokenManager.SetTokenCookie(w, token, h.cfg.Auth.TokenTTL)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(SignupResponse{
+	respondJSON(w, http.StatusOK, SignupResponse{
 		Success: true,
 		Token:   token,
 	})
+}
+
+// ForgotPasswordHandler issues a password reset token for the given email
+func (h *AuthHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	// Always answer the same way so the endpoint can't be used to probe for accounts
+	genericResponse := Response{
+		Success: true,
+		Data: MessageData{
+			Message: "if the email is registered, a password reset link has been sent",
+		},
+	}
+
+	req.Email = strings.TrimSpace(req.Email)
+	dbUser, err := h.database.GetUserByEmail(req.Email)
+	if req.Email == "" || err != nil {
+		h.logger.Info("password reset requested for unknown email")
+		respondJSON(w, http.StatusOK, genericResponse)
+		return
+	}
+
+	token, err := auth.NewRandomToken(32)
+	if err != nil {
+		h.logger.Error("failed to generate reset token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to process request",
+		})
+		return
+	}
+
+	expiresAt := time.Now().Add(h.cfg.Auth.PasswordResetTTL)
+	if err := h.database.CreatePasswordReset(token, dbUser.ID, expiresAt); err != nil {
+		h.logger.Error("failed to store reset token", zap.String("user_id", dbUser.ID), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to process request",
+		})
+		return
+	}
+
+	if err := h.mailer.Send(r.Context(), mail.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your password",
+		Body: "Use this token to reset your password: " + token + "\n\n" +
+			"It expires at " + expiresAt.Format(time.RFC1123) + ". If you didn't ask for a reset, ignore this email.",
+	}); err != nil {
+		h.logger.Error("failed to send reset email", zap.String("user_id", dbUser.ID), zap.Error(err))
+	}
+
+	h.logger.Info("password reset requested", zap.String("user_id", dbUser.ID), zap.Time("expires_at", expiresAt))
+
+	respondJSON(w, http.StatusOK, genericResponse)
+}
+
+// ResetPasswordHandler sets a new password using a valid reset token
+func (h *AuthHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	req.NewPassword = strings.TrimSpace(req.NewPassword)
+
+	if req.Token == "" || req.NewPassword == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "token and new_password are required",
+		})
+		return
+	}
+
+	if len(req.NewPassword) < 6 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "password must be at least 6 characters",
+		})
+		return
+	}
+
+	userID, err := h.database.ConsumePasswordReset(req.Token)
+	if err != nil {
+		h.logger.Warn("password reset failed", zap.Error(err))
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid or expired reset token",
+		})
+		return
+	}
+
+	if err := h.database.UpdateUserPassword(userID, req.NewPassword); err != nil {
+		h.logger.Error("failed to update password", zap.String("user_id", userID), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to reset password",
+		})
+		return
+	}
+
+	// Whoever triggered the reset may be locking out someone holding a stolen session
+	if err := h.tokenManager.RevokeUserTokens(userID); err != nil {
+		h.logger.Error("failed to revoke sessions after password reset", zap.String("user_id", userID), zap.Error(err))
+	}
+	if err := h.database.DeleteAPITokensByUser(userID); err != nil {
+		h.logger.Error("failed to revoke API tokens after password reset", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	h.logger.Info("password reset completed", zap.String("user_id", userID))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "password has been reset",
+		},
+	})
+}
+
+// ChangePasswordHandler lets a logged-in user replace their password.
+// Other sessions are signed out unless keep_other_sessions is set.
+func (h *AuthHandler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	req.NewPassword = strings.TrimSpace(req.NewPassword)
+
+	dbUser, err := h.database.GetUserByID(user.ID)
+	if err != nil {
+		h.logger.Error("failed to load user for password change", zap.String("user_id", user.ID), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to change password",
+		})
+		return
+	}
+
+	if !db.VerifyPassword(dbUser.Password, req.CurrentPassword) {
+		h.logger.Warn("password change failed - wrong current password", zap.String("user_id", user.ID))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "current password is incorrect",
+		})
+		return
+	}
+
+	if len(req.NewPassword) < 6 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "new password must be at least 6 characters",
+		})
+		return
+	}
+
+	if err := h.database.UpdateUserPassword(user.ID, req.NewPassword); err != nil {
+		h.logger.Error("failed to update password", zap.String("user_id", user.ID), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to change password",
+		})
+		return
+	}
+
+	if !req.KeepOtherSessions {
+		// This revokes the caller's token too, so a fresh one is issued below
+		if err := h.tokenManager.RevokeUserTokens(user.ID); err != nil {
+			h.logger.Error("failed to revoke sessions after password change", zap.String("user_id", user.ID), zap.Error(err))
+		} else {
+			token, err := h.tokenManager.GenerateToken(user, h.cfg.Auth.TokenTTL)
+			if err != nil {
+				h.logger.Error("failed to generate token", zap.Error(err))
+				h.tokenManager.ClearTokenCookie(w)
+			} else {
+				h.tokenManager.SetTokenCookie(w, token, h.cfg.Auth.TokenTTL)
+			}
+		}
+	}
+
+	// API tokens outlive any session, so they go regardless of keep_other_sessions
+	if err := h.database.DeleteAPITokensByUser(user.ID); err != nil {
+		h.logger.Error("failed to revoke API tokens after password change", zap.String("user_id", user.ID), zap.Error(err))
+	}
+
+	h.logger.Info("password changed", zap.String("user_id", user.ID), zap.Bool("other_sessions_revoked", !req.KeepOtherSessions))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "password changed",
+		},
+	})
+}
+
+// VerifyEmailHandler marks a user's email as verified using the token from the verification link
+func (h *AuthHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSpace(r.URL.Query().Get("token"))
+	if token == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "token parameter required",
+		})
+		return
+	}
+
+	userID, err := h.database.ConsumeEmailVerification(token)
+	if err != nil {
+		h.logger.Warn("email verification failed", zap.Error(err))
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid or expired verification token",
+		})
+		return
+	}
+
+	h.logger.Info("email verified", zap.String("user_id", userID))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "email verified",
+		},
+	})
+}
+
+// sendVerification issues a verification token and mails the link to the user
+func (h *AuthHandler) sendVerification(r *http.Request, userID, email string) error {
+	token, err := auth.NewRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(h.cfg.Auth.EmailVerificationTTL)
+	if err := h.database.CreateEmailVerification(token, userID, expiresAt); err != nil {
+		return err
+	}
+
+	link := h.cfg.Server.BaseURL + "/api/auth/verify?token=" + url.QueryEscape(token)
+	return h.mailer.Send(r.Context(), mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: "Confirm your email address by opening this link: " + link + "\n\n" +
+			"It expires at " + expiresAt.Format(time.RFC1123) + ".",
+	})
+}
+
+// clientIP returns the request's remote IP without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
\ No newline at end of file
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"s3-test-app/internal/auth"
+	mw "s3-test-app/internal/middleware"
+	"s3-test-app/internal/ratelimit"
+)
+
+// resetTokenFrom extracts the token from a password reset email
+func resetTokenFrom(t *testing.T, body string) string {
+	t.Helper()
+	const marker = "reset your password: "
+	start := strings.Index(body, marker)
+	if start < 0 {
+		t.Fatalf("no reset token in email: %q", body)
+	}
+	token, _, _ := strings.Cut(body[start+len(marker):], "\n")
+	return strings.TrimSpace(token)
+}
+
+func TestPasswordResetRevokesSessions(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, mailer := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	session, err := tokenManager.GenerateToken(user, h.cfg.Auth.TokenTTL)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ForgotPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/forgot-password", ForgotPasswordRequest{Email: user.Email}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("forgot-password status = %d, want 200", rec.Code)
+	}
+	token := resetTokenFrom(t, mailer.last(t).Body)
+
+	rec = httptest.NewRecorder()
+	h.ResetPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/reset-password", ResetPasswordRequest{Token: token, NewPassword: "new-password"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset-password status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := tokenManager.ValidateToken(session); err == nil {
+		t.Error("session issued before the reset is still valid")
+	}
+
+	// The token is single-use
+	rec = httptest.NewRecorder()
+	h.ResetPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/reset-password", ResetPasswordRequest{Token: token, NewPassword: "other-password"}))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("second reset status = %d, want 400", rec.Code)
+	}
+}
+
+func TestForgotPasswordAnswersUnknownEmailsAlike(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	known := httptest.NewRecorder()
+	h.ForgotPasswordHandler(known, jsonRequest(t, http.MethodPost, "/api/auth/forgot-password", ForgotPasswordRequest{Email: user.Email}))
+	unknown := httptest.NewRecorder()
+	h.ForgotPasswordHandler(unknown, jsonRequest(t, http.MethodPost, "/api/auth/forgot-password", ForgotPasswordRequest{Email: "nobody@example.com"}))
+
+	if known.Code != unknown.Code || known.Body.String() != unknown.Body.String() {
+		t.Errorf("responses differ: %d %s vs %d %s", known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+}
+
+func TestForgotPasswordDoesNotLogToken(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	database := newTestDatabase(t)
+	h, mailer := newTestAuthHandler(database, newTestTokenManager(database), zap.New(core))
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := httptest.NewRecorder()
+	h.ForgotPasswordHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/forgot-password", ForgotPasswordRequest{Email: user.Email}))
+	token := resetTokenFrom(t, mailer.last(t).Body)
+
+	for _, entry := range logs.All() {
+		if strings.Contains(entry.Message, token) {
+			t.Errorf("log message %q contains the reset token", entry.Message)
+		}
+		for key, value := range entry.ContextMap() {
+			if s, ok := value.(string); ok && strings.Contains(s, token) {
+				t.Errorf("log field %s of %q contains the reset token", key, entry.Message)
+			}
+		}
+	}
+}
+
+func TestLoggedOutTokenIsRejectedOnNextRequest(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	tokenManager := newTestTokenManager(database)
+	authHandler, _ := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	r := chi.NewRouter()
+	r.Post("/api/auth/logout", authHandler.LogoutHandler)
+	r.With(mw.AuthMiddleware(tokenManager)).Get("/api/me", h.GetMe)
+
+	token, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	call := func(method, target string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := call(http.MethodGet, "/api/me"); code != http.StatusOK {
+		t.Fatalf("before logout: status = %d, want 200", code)
+	}
+	if code := call(http.MethodPost, "/api/auth/logout"); code != http.StatusOK {
+		t.Fatalf("logout status = %d, want 200", code)
+	}
+	if code := call(http.MethodGet, "/api/me"); code != http.StatusUnauthorized {
+		t.Errorf("after logout: status = %d, want 401", code)
+	}
+
+	// Other sessions of the same user stay valid
+	other, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	token = other
+	if code := call(http.MethodGet, "/api/me"); code != http.StatusOK {
+		t.Errorf("other session: status = %d, want 200", code)
+	}
+}
+
+func TestCSRFTokenHandlerIssuesTokenForCookieSession(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	authHandler, _ := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	r := chi.NewRouter()
+	r.Get("/api/auth/csrf", authHandler.CSRFTokenHandler)
+	r.With(mw.CSRF(tokenManager)).Post("/api/files/rename", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	token, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	cookie := &http.Cookie{Name: "auth_token", Value: token}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/csrf", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("csrf status = %d, want 200", rec.Code)
+	}
+	var data CSRFData
+	decodeData(t, rec, &data)
+
+	write := func(csrf string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/files/rename", nil)
+		req.AddCookie(cookie)
+		if csrf != "" {
+			req.Header.Set(auth.CSRFHeader, csrf)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := write(data.CSRFToken); code != http.StatusOK {
+		t.Errorf("write with issued token: status = %d, want 200", code)
+	}
+	if code := write(""); code != http.StatusForbidden {
+		t.Errorf("write without token: status = %d, want 403", code)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/csrf", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("csrf without session: status = %d, want 401", rec.Code)
+	}
+}
+
+// login posts the credentials of username to the login handler
+func login(t *testing.T, h *AuthHandler, username, password string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.LoginHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/login", LoginRequest{Username: username, Password: password}))
+	return rec.Code
+}
+
+func TestLoginRecordsLastLogin(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	authHandler, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	adminHandler, _ := newTestAdminHandler(database, newTestTokenManager(database))
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	lastLogin := func() (time.Time, bool) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.GetMe(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/me", nil), alice))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GetMe status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var me struct {
+			LastLoginAt *time.Time `json:"last_login_at"`
+		}
+		decodeData(t, rec, &me)
+		if me.LastLoginAt == nil {
+			return time.Time{}, false
+		}
+		return *me.LastLoginAt, true
+	}
+
+	if at, ok := lastLogin(); ok {
+		t.Fatalf("before any login: last_login_at = %v, want it omitted", at)
+	}
+	if code := login(t, authHandler, "alice", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status = %d, want 401", code)
+	}
+	if at, ok := lastLogin(); ok {
+		t.Fatalf("after a failed login: last_login_at = %v, want it omitted", at)
+	}
+
+	before := time.Now()
+	if code := login(t, authHandler, "alice", "password"); code != http.StatusOK {
+		t.Fatalf("login status = %d, want 200", code)
+	}
+	first, ok := lastLogin()
+	if !ok || first.Before(before) || first.After(time.Now()) {
+		t.Fatalf("after login: last_login_at = %v (present %t), want the time of the login", first, ok)
+	}
+
+	time.Sleep(time.Millisecond)
+	if code := login(t, authHandler, "alice", "password"); code != http.StatusOK {
+		t.Fatalf("second login status = %d, want 200", code)
+	}
+	if second, _ := lastLogin(); !second.After(first) {
+		t.Errorf("second login: last_login_at = %v, did not move forward from %v", second, first)
+	}
+
+	// The admin user list shows it too, and leaves it out for users who never logged in
+	rec := httptest.NewRecorder()
+	adminHandler.GetUsers(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/admin/users", nil), admin))
+	var list struct {
+		Users []struct {
+			ID          string     `json:"id"`
+			LastLoginAt *time.Time `json:"last_login_at"`
+		} `json:"users"`
+	}
+	decodeData(t, rec, &list)
+	for _, user := range list.Users {
+		switch user.ID {
+		case alice.ID:
+			if user.LastLoginAt == nil {
+				t.Error("user list: alice has no last_login_at")
+			}
+		case admin.ID:
+			if user.LastLoginAt != nil {
+				t.Errorf("user list: admin last_login_at = %v, want it omitted", user.LastLoginAt)
+			}
+		}
+	}
+}
+
+// changePassword posts req to the change-password handler as user
+func changePassword(t *testing.T, h *AuthHandler, user *auth.User, req ChangePasswordRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ChangePasswordHandler(rec, asUser(jsonRequest(t, http.MethodPost, "/api/auth/change-password", req), user))
+	return rec
+}
+
+func TestChangePasswordRejectsWrongCurrentPassword(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := changePassword(t, h, user, ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "new-password"})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", rec.Code, rec.Body.String())
+	}
+
+	if code := login(t, h, "alice", "password"); code != http.StatusOK {
+		t.Errorf("old password after refused change: status = %d, want 200", code)
+	}
+	if code := login(t, h, "alice", "new-password"); code != http.StatusUnauthorized {
+		t.Errorf("new password after refused change: status = %d, want 401", code)
+	}
+}
+
+func TestChangePasswordEnforcesMinimumLength(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, tc := range []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{"too short", "12345", http.StatusBadRequest},
+		{"short once trimmed", "  12345  ", http.StatusBadRequest},
+		{"empty", "", http.StatusBadRequest},
+		// Last, since it changes the password the other cases confirm with
+		{"minimum length", "123456", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := changePassword(t, h, user, ChangePasswordRequest{CurrentPassword: "password", NewPassword: tc.password})
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+			if tc.want != http.StatusOK {
+				if code := login(t, h, "alice", "password"); code != http.StatusOK {
+					t.Errorf("password changed despite the refusal: login status = %d", code)
+				}
+				return
+			}
+			if code := login(t, h, "alice", tc.password); code != http.StatusOK {
+				t.Errorf("login with the new password: status = %d, want 200", code)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRevokesOldSessions(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	session, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	rec := changePassword(t, h, user, ChangePasswordRequest{CurrentPassword: "password", NewPassword: "new-password"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := tokenManager.ValidateToken(session); err == nil {
+		t.Error("session issued before the change is still valid")
+	}
+
+	// The caller gets a fresh session in place of the revoked one
+	var fresh string
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "auth_token" {
+			fresh = cookie.Value
+		}
+	}
+	if fresh == "" {
+		t.Fatal("no new session cookie was set")
+	}
+	if _, err := tokenManager.ValidateToken(fresh); err != nil {
+		t.Errorf("new session is invalid: %v", err)
+	}
+
+	if code := login(t, h, "alice", "password"); code != http.StatusUnauthorized {
+		t.Errorf("old password: status = %d, want 401", code)
+	}
+	if code := login(t, h, "alice", "new-password"); code != http.StatusOK {
+		t.Errorf("new password: status = %d, want 200", code)
+	}
+}
+
+func TestChangePasswordCanKeepOtherSessions(t *testing.T) {
+	database := newTestDatabase(t)
+	tokenManager := newTestTokenManager(database)
+	h, _ := newTestAuthHandler(database, tokenManager, zap.NewNop())
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	session, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	rec := changePassword(t, h, user, ChangePasswordRequest{CurrentPassword: "password", NewPassword: "new-password", KeepOtherSessions: true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := tokenManager.ValidateToken(session); err != nil {
+		t.Errorf("session was revoked despite keep_other_sessions: %v", err)
+	}
+}
+
+func TestLoginLockoutResetsOnSuccess(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	h.loginLimiter = ratelimit.New(3, time.Minute)
+	createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for range 2 {
+		if code := login(t, h, "alice", "wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: status = %d, want 401", code)
+		}
+	}
+	// A success wipes the failures, so three more attempts are allowed
+	if code := login(t, h, "alice", "password"); code != http.StatusOK {
+		t.Fatalf("right password: status = %d, want 200", code)
+	}
+	for i := range 3 {
+		if code := login(t, h, "alice", "wrong"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d after success: status = %d, want 401", i+1, code)
+		}
+	}
+
+	// Locked out, even with the right password
+	rec := httptest.NewRecorder()
+	h.LoginHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/login", LoginRequest{Username: "alice", Password: "password"}))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header on a locked out login")
+	}
+}
\ No newline at end of file
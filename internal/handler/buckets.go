@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+)
+
+// BucketInfo describes one configured bucket
+type BucketInfo struct {
+	Name        string `json:"name"`
+	Bucket      string `json:"bucket"`
+	Primary     bool   `json:"primary"`
+	ObjectCount int64  `json:"object_count"`
+	TotalBytes  int64  `json:"total_bytes"`
+	ComputedAt  string `json:"computed_at,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BucketsData is the payload of the bucket list endpoint
+type BucketsData struct {
+	Buckets []BucketInfo `json:"buckets"`
+}
+
+// ListBuckets lists the buckets the API can address with their object counts (admin only).
+// Counts come from the same cached scan as the bucket statistics; a bucket that can't be
+// scanned is still listed, with the error instead of counts.
+func (h *Handler) ListBuckets(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || user.Role != auth.RoleAdmin {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	names := h.s3Service.BucketNames()
+	data := BucketsData{Buckets: make([]BucketInfo, 0, len(names))}
+	for _, name := range names {
+		storage, err := h.s3Service.ForBucket(name)
+		if err != nil {
+			continue
+		}
+		info := BucketInfo{
+			Name:    name,
+			Bucket:  storage.Bucket(),
+			Primary: storage == h.s3Service,
+		}
+		usage, err := storage.Usage(r.Context(), "")
+		if err != nil {
+			h.logger.Error("failed to count bucket objects", zap.String("bucket", name), zap.Error(err))
+			_, info.Error = storageStatus(err, "failed to count objects")
+		} else {
+			info.ObjectCount = usage.Objects
+			info.TotalBytes = usage.Bytes
+			info.ComputedAt = usage.ComputedAt.Format(time.RFC3339)
+		}
+		data.Buckets = append(data.Buckets, info)
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
\ No newline at end of file
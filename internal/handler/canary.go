@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"s3-test-app/internal/service"
+)
+
+// CanaryHandler reports the storage canary's results
+type CanaryHandler struct {
+	canary *service.Canary
+}
+
+// NewCanaryHandler creates a new canary handler
+func NewCanaryHandler(canary *service.Canary) *CanaryHandler {
+	return &CanaryHandler{
+		canary: canary,
+	}
+}
+
+// Status returns the latest canary results. It answers 503 while the canary is alerting.
+func (h *CanaryHandler) Status(w http.ResponseWriter, r *http.Request) {
+	status := h.canary.Status()
+
+	code := http.StatusOK
+	if status.Alerting {
+		code = http.StatusServiceUnavailable
+	}
+
+	respondJSON(w, code, Response{
+		Success: !status.Alerting,
+		Data:    status,
+	})
+}
\ No newline at end of file
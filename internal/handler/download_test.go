@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+func downloadFile(h *Handler, user *auth.User, key string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/download?key="+url.QueryEscape(key), nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.DownloadFile(rec, asUser(r, user))
+	return rec
+}
+
+func TestDownloadSetsValidators(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleViewer)
+	key := service.UserPrefix(user.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, key, []byte("notes"))
+
+	rec := downloadFile(h, user, key, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if etag := rec.Header().Get("ETag"); etag != fake.Get(testBucket, key).ETag {
+		t.Errorf("ETag = %q, want the stored object's", etag)
+	}
+	if _, err := http.ParseTime(rec.Header().Get("Last-Modified")); err != nil {
+		t.Errorf("Last-Modified = %q: %v", rec.Header().Get("Last-Modified"), err)
+	}
+	if rec.Body.String() != "notes" {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestDownloadConditionalRequests(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleViewer)
+	key := service.UserPrefix(user.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, key, []byte("notes"))
+	etag := fake.Get(testBucket, key).ETag
+	hourAgo := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	inAnHour := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"matching etag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"weak matching etag", http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified},
+		{"etag in list", http.Header{"If-None-Match": {`"other", ` + etag}}, http.StatusNotModified},
+		{"wildcard", http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		{"stale etag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"unchanged since", http.Header{"If-Modified-Since": {inAnHour}}, http.StatusNotModified},
+		{"changed since", http.Header{"If-Modified-Since": {hourAgo}}, http.StatusOK},
+		{"etag wins over date", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {inAnHour}}, http.StatusOK},
+		{"unparseable date", http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := downloadFile(h, user, key, tc.header)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 carried a body: %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDownloadMissingFileIs404(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleViewer)
+
+	if rec := downloadFile(h, user, service.UserPrefix(user.ID)+"missing.txt", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`},
+		{`say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+		{`a\b.txt`, `attachment; filename="a_b.txt"; filename*=UTF-8''a%5Cb.txt`},
+		{"a; b=c.txt", `attachment; filename="a; b=c.txt"; filename*=UTF-8''a%3B%20b%3Dc.txt`},
+		{"evil\r\nSet-Cookie: x=y.txt", `attachment; filename="evilSet-Cookie: x=y.txt"; filename*=UTF-8''evilSet-Cookie%3A%20x%3Dy.txt`},
+		{"café.txt", `attachment; filename="cafe.txt"; filename*=UTF-8''caf%C3%A9.txt`},
+		{"보고서.pdf", `attachment; filename="___.pdf"; filename*=UTF-8''%EB%B3%B4%EA%B3%A0%EC%84%9C.pdf`},
+		{"\r\n", `attachment; filename="download"; filename*=UTF-8''download`},
+	} {
+		if got := contentDisposition("attachment", tc.name); got != tc.want {
+			t.Errorf("contentDisposition(%q) =\n  %s\nwant\n  %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDownloadUsesOriginalName(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleViewer)
+	named := service.UserPrefix(user.ID) + "1712345-report.pdf"
+	unnamed := service.UserPrefix(user.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, named, []byte("%PDF"))
+	fake.Put(testBucket, unnamed, []byte("notes"))
+	if err := database.SaveFileRecord(&db.FileRecord{Key: named, OwnerID: user.ID, OriginalName: "분기 보고서.pdf", UploadedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{
+		named:   "분기 보고서.pdf",
+		unnamed: "notes.txt",
+	} {
+		rec := downloadFile(h, user, key, nil)
+		_, params, err := mime.ParseMediaType(rec.Header().Get("Content-Disposition"))
+		if err != nil {
+			t.Fatalf("%s: Content-Disposition %q doesn't parse: %v", key, rec.Header().Get("Content-Disposition"), err)
+		}
+		if params["filename"] != want {
+			t.Errorf("%s: filename = %q, want %q", key, params["filename"], want)
+		}
+	}
+}
+
+func TestContentDispositionRoundTrips(t *testing.T) {
+	for _, name := range []string{
+		"résumé (final).pdf",
+		`say "hi".txt`,
+		`a\b.txt`,
+		"분기 보고서.pdf",
+		"100% done.txt",
+	} {
+		header := contentDisposition("attachment", name)
+		disposition, params, err := mime.ParseMediaType(header)
+		if err != nil {
+			t.Errorf("%q: %s doesn't parse: %v", name, header, err)
+			continue
+		}
+		if disposition != "attachment" || params["filename"] != name {
+			t.Errorf("%q: parsed as %s with filename %q", name, disposition, params["filename"])
+		}
+	}
+
+	// Clients without RFC 5987 support see the ASCII fallback, with accents stripped
+	if got := contentDisposition("inline", "résumé (final).pdf"); !strings.HasPrefix(got, `inline; filename="resume (final).pdf"; filename*=`) {
+		t.Errorf("fallback = %s", got)
+	}
+}
+
+func TestDownloadZipContentDisposition(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleViewer)
+	key := service.UserPrefix(user.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, key, []byte("notes"))
+
+	rec := httptest.NewRecorder()
+	h.DownloadZip(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/download-zip", DownloadZipRequest{Keys: []string{key}}), user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	header := rec.Header().Get("Content-Disposition")
+	disposition, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		t.Fatalf("Content-Disposition %q doesn't parse: %v", header, err)
+	}
+	if disposition != "attachment" || !strings.HasPrefix(params["filename"], "files-") || !strings.HasSuffix(params["filename"], ".zip") {
+		t.Errorf("Content-Disposition = %q, want an attachment named files-<unix>.zip", header)
+	}
+	if !strings.Contains(header, "filename*=UTF-8''files-") {
+		t.Errorf("Content-Disposition = %q, want a filename* parameter", header)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/events"
+	"s3-test-app/internal/service"
+)
+
+const (
+	// eventBufferSize is how many events a slow event stream may fall behind before
+	// further events are dropped for it
+	eventBufferSize = 64
+	// eventKeepAlive is how often an idle event stream sends a comment so proxies
+	// keep the connection open and a vanished client is noticed
+	eventKeepAlive = 30 * time.Second
+)
+
+// Events streams file activity the user can see as Server-Sent Events. Each message's
+// data is a JSON events.Event; when the stream fell behind, an "overflow" event tells
+// the client to reload instead of relying on the messages it received
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	rc := http.NewResponseController(w)
+
+	// Subscribe before the stream opens, so a client that saw it open misses nothing
+	sub := h.events.Subscribe()
+	defer h.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if err := rc.Flush(); err != nil {
+		h.logger.Error("event stream not supported", zap.Error(err))
+		return
+	}
+
+	h.logger.Debug("event stream opened", zap.String("user", user.Name))
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			h.logger.Debug("event stream closed", zap.String("user", user.Name))
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e := <-sub.C:
+			if dropped := sub.TakeDropped(); dropped > 0 {
+				if _, err := fmt.Fprintf(w, "event: overflow\ndata: {\"dropped\":%d}\n\n", dropped); err != nil {
+					return
+				}
+			}
+			if !canAccessKey(user, e.Key) && (e.From == "" || !canAccessKey(user, e.From)) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				h.logger.Error("failed to encode event", zap.Error(err))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// publish announces a change to key in storage made by user
+func (h *Handler) publish(action string, user *auth.User, storage *service.S3Service, key, from string) {
+	h.events.Publish(events.Event{
+		Action:    action,
+		Key:       key,
+		From:      from,
+		Bucket:    storage.Name(),
+		Actor:     user.Name,
+		Timestamp: time.Now().UTC(),
+	})
+}
\ No newline at end of file
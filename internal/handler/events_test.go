@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/events"
+)
+
+// eventStream is a client reading the live event stream as one user
+type eventStream struct {
+	t      *testing.T
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+// openEventStream connects user to h's event stream through a real server
+func openEventStream(t *testing.T, h *Handler, user *auth.User) *eventStream {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Events(w, asUser(r, user))
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	stream := &eventStream{t: t, resp: resp, reader: bufio.NewReader(resp.Body)}
+	if name, data := stream.next(); name != "" || data != "" {
+		t.Fatalf("stream opened with event %q %q, want the retry preamble", name, data)
+	}
+	return stream
+}
+
+// next reads the next message and returns its event name and data
+func (s *eventStream) next() (name, data string) {
+	s.t.Helper()
+	done := make(chan struct{})
+	timer := time.AfterFunc(5*time.Second, func() {
+		s.resp.Body.Close()
+		close(done)
+	})
+	defer timer.Stop()
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			select {
+			case <-done:
+				s.t.Fatal("no event within 5s")
+			default:
+				s.t.Fatalf("read event stream: %v", err)
+			}
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// nextEvent reads the next file event
+func (s *eventStream) nextEvent() events.Event {
+	s.t.Helper()
+	name, data := s.next()
+	if name != "" {
+		s.t.Fatalf("got %q event %s, want a file event", name, data)
+	}
+	var e events.Event
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		s.t.Fatalf("event %q: %v", data, err)
+	}
+	return e
+}
+
+func TestEventStreamDeliversOwnActivity(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	bob := createTestUser(t, database, "bob", auth.RoleUploader)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	aliceStream := openEventStream(t, h, alice)
+	bobStream := openEventStream(t, h, bob)
+	adminStream := openEventStream(t, h, admin)
+
+	upload := func(user *auth.User, name string) string {
+		rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: name, content: []byte("notes")}))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("upload status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var data UploadData
+		decodeData(t, rec, &data)
+		return data.Key
+	}
+	aliceKey := upload(alice, "alice.txt")
+	bobKey := upload(bob, "bob.txt")
+
+	if e := aliceStream.nextEvent(); e.Action != events.ActionUploaded || e.Key != aliceKey || e.Actor != alice.Name || e.Bucket != testBucket {
+		t.Errorf("alice got %+v, want her upload of %s", e, aliceKey)
+	}
+	// Bob's first event is his own upload: alice's was filtered out
+	if e := bobStream.nextEvent(); e.Key != bobKey {
+		t.Errorf("bob got %+v, want his upload of %s", e, bobKey)
+	}
+	for _, want := range []string{aliceKey, bobKey} {
+		if e := adminStream.nextEvent(); e.Key != want {
+			t.Errorf("admin got %+v, want the upload of %s", e, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+aliceKey, nil), admin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if e := aliceStream.nextEvent(); e.Action != events.ActionDeleted || e.Key != aliceKey || e.Actor != admin.Name {
+		t.Errorf("alice got %+v, want the admin's delete of %s", e, aliceKey)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// exportBatchSize is how many objects are collected before their metadata is looked up
+// and their rows are written
+const exportBatchSize = 500
+
+// exportColumns is the header row of a CSV inventory
+var exportColumns = []string{"key", "size", "last_modified", "content_type", "owner", "sha256"}
+
+// ExportRow is one object of the inventory export. Content type and checksum are only
+// known for files with metadata records.
+type ExportRow struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	SHA256       string    `json:"sha256,omitempty"`
+}
+
+// exportEncoder writes inventory rows in one output format
+type exportEncoder interface {
+	Write(row ExportRow) error
+	Flush() error
+}
+
+// csvExportEncoder writes rows as CSV under a header row
+type csvExportEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVExportEncoder(w io.Writer) (*csvExportEncoder, error) {
+	e := &csvExportEncoder{w: csv.NewWriter(w)}
+	return e, e.w.Write(exportColumns)
+}
+
+func (e *csvExportEncoder) Write(row ExportRow) error {
+	return e.w.Write([]string{
+		row.Key,
+		strconv.FormatInt(row.Size, 10),
+		row.LastModified.UTC().Format(time.RFC3339),
+		row.ContentType,
+		row.Owner,
+		row.SHA256,
+	})
+}
+
+func (e *csvExportEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonExportEncoder writes each row as a JSON object on its own line
+type ndjsonExportEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExportEncoder) Write(row ExportRow) error {
+	return e.enc.Encode(row)
+}
+
+func (e *ndjsonExportEncoder) Flush() error {
+	return nil
+}
+
+// ExportFiles streams the inventory of every object in the bucket as CSV or NDJSON
+// (admin only). Folders are listed in parallel and rows are written as pages arrive,
+// so the export never holds the whole bucket in memory; rows are not sorted by key.
+func (h *Handler) ExportFiles(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	rc := http.NewResponseController(w)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "format must be csv or ndjson",
+		})
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// File metadata is only kept for the primary bucket
+	primary := storage == h.s3Service
+
+	// Nothing is sent until the first page has been listed, so a failing bucket
+	// still gets a proper error response
+	var enc exportEncoder
+	start := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", contentDisposition("attachment",
+			fmt.Sprintf("inventory-%s-%s.%s", storage.Name(), time.Now().UTC().Format("2006-01-02"), format)))
+		w.WriteHeader(http.StatusOK)
+		if format == "ndjson" {
+			enc = &ndjsonExportEncoder{enc: json.NewEncoder(w)}
+			return nil
+		}
+		var err error
+		enc, err = newCSVExportEncoder(w)
+		return err
+	}
+
+	rows := 0
+	batch := make([]service.File, 0, exportBatchSize)
+	writeBatch := func() error {
+		if enc == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		var records map[string]*db.FileRecord
+		if primary && len(batch) > 0 {
+			keys := make([]string, len(batch))
+			for i, file := range batch {
+				keys[i] = file.Key
+			}
+			var err error
+			if records, err = h.database.FileRecordsByKeys(keys); err != nil {
+				// Rows are still written, just without the metadata columns
+				h.logger.Warn("failed to load file metadata for export", zap.Error(err))
+			}
+		}
+
+		for _, file := range batch {
+			row := ExportRow{
+				Key:          file.Key,
+				Size:         file.Size,
+				LastModified: file.LastModified,
+				Owner:        service.UserFromKey(file.Key),
+			}
+			if record := records[file.Key]; record != nil {
+				row.ContentType = record.ContentType
+				row.SHA256 = record.SHA256
+				if record.OwnerID != "" {
+					row.Owner = record.OwnerID
+				}
+			}
+			if err := enc.Write(row); err != nil {
+				return err
+			}
+		}
+		rows += len(batch)
+		batch = batch[:0]
+
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && err != http.ErrNotSupported {
+			return err
+		}
+		return nil
+	}
+
+	var writeErr error
+	err = storage.ListParallel(r.Context(), service.ParallelListOptions{}, func(files []service.File) error {
+		batch = append(batch, files...)
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		writeErr = writeBatch()
+		return writeErr
+	})
+	if err == nil {
+		writeErr = writeBatch()
+	}
+
+	if writeErr == nil && err != nil && enc == nil {
+		h.logger.Error("failed to list files for export", zap.String("bucket", storage.Name()), zap.Error(err))
+		respondStorageError(w, err, "failed to export files")
+		return
+	}
+	if writeErr != nil || err != nil {
+		// Headers are already sent, so the only way to signal failure is a cut-off export
+		h.logger.Error("file export aborted", zap.String("bucket", storage.Name()), zap.Int("rows", rows), zap.NamedError("list_error", err), zap.NamedError("write_error", writeErr))
+		return
+	}
+
+	h.logger.Info("file inventory exported", zap.String("admin", user.Name), zap.String("bucket", storage.Name()), zap.String("format", format), zap.Int("rows", rows))
+}
\ No newline at end of file
@@ -1,13 +1,30 @@
 package handler
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"go.uber.org/zap"
+	"golang.org/x/text/unicode/norm"
 	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/events"
+	"s3-test-app/internal/metrics"
 	"s3-test-app/internal/service"
 	"s3-test-app/templates"
 )
@@ -15,15 +32,96 @@ import (
 // Handler holds HTTP handlers
 type Handler struct {
 	s3Service *service.S3Service
+	database  *db.Database
 	logger    *zap.Logger
+	keyPolicy config.KeyPolicyConfig
+	// uploadTypes decides which kinds of file may be uploaded
+	uploadTypes config.UploadTypeConfig
+
+	// maxUploadSize caps the size of a single uploaded file
+	maxUploadSize int64
+	trash         config.TrashConfig
+	// healthCheckTimeout bounds each dependency check of the readiness probe
+	healthCheckTimeout time.Duration
+	// defaultQuota is the storage quota of users without an override; 0 means unlimited
+	defaultQuota int64
+	// baseURL is the public address share links are built on
+	baseURL string
+	// events carries file activity to the live event stream
+	events *events.Hub
+	// backfills carries listed objects without metadata to RunRecordBackfill
+	backfills chan []service.File
+	// imports carries the IDs of newly started bucket imports to RunBucketImports
+	imports chan string
+	// approvals defers the bulk deletions an admin asks for when they need a second admin
+	approvals *ApprovalHandler
+	// pipeline holds the checks a proxied upload passes before it is stored
+	pipeline *uploadPipeline
 }
 
+// backfillQueueSize is how many listings' worth of objects without metadata may wait for
+// RunRecordBackfill
+const backfillQueueSize = 16
+
 // NewHandler creates a new Handler
-func NewHandler(s3Service *service.S3Service, logger *zap.Logger) *Handler {
-	return &Handler{
-		s3Service: s3Service,
-		logger:    logger,
+func NewHandler(s3Service *service.S3Service, database *db.Database, logger *zap.Logger, keyPolicy config.KeyPolicyConfig, uploadTypes config.UploadTypeConfig, maxUploadSize int64, trash config.TrashConfig) *Handler {
+	h := &Handler{
+		s3Service:     s3Service,
+		database:      database,
+		logger:        logger,
+		keyPolicy:     keyPolicy,
+		uploadTypes:   uploadTypes,
+		maxUploadSize: maxUploadSize,
+		trash:         trash,
+		events:        events.NewHub(eventBufferSize),
+		backfills:     make(chan []service.File, backfillQueueSize),
+		imports:       make(chan string, 1),
+		pipeline:      newUploadPipeline(logger),
+
+		healthCheckTimeout: defaultHealthCheckTimeout,
 	}
+	h.registerUploadHooks()
+	return h
+}
+
+// SetApprovals makes prefix deletes and emptying the trash wait for a second admin when
+// the approval configuration lists delete_prefix or empty_trash
+func (h *Handler) SetApprovals(approvals *ApprovalHandler) {
+	h.approvals = approvals
+
+	approvals.Register("delete_prefix", func(ctx context.Context, payload json.RawMessage) error {
+		var p deletePrefixPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		// Holds and locks may have been placed while the delete waited for approval
+		held, err := h.heldPrefixes()
+		if err != nil {
+			return err
+		}
+		for _, prefix := range held {
+			if strings.HasPrefix(prefix, p.Prefix) || strings.HasPrefix(p.Prefix, prefix) {
+				return fmt.Errorf("prefix overlaps a legal hold on %s", prefix)
+			}
+		}
+		locked, err := h.database.LockedFileUnder(p.Prefix)
+		if err != nil {
+			return err
+		}
+		if locked != "" {
+			return fmt.Errorf("file %q is locked", locked)
+		}
+		_, err = h.deletePrefix(ctx, &auth.User{Name: p.RequestedBy}, p.Prefix)
+		return err
+	})
+	approvals.Register("empty_trash", func(ctx context.Context, payload json.RawMessage) error {
+		var p emptyTrashPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		_, _, err := h.emptyTrash(ctx, &auth.User{Name: p.RequestedBy})
+		return err
+	})
 }
 
 // Response is a generic API response
@@ -31,14 +129,127 @@ type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+}
+
+// MessageData is the payload of responses that only carry a human-readable message
+type MessageData struct {
+	Message string `json:"message"`
+}
+
+// HealthResponse is returned by the liveness endpoints
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// FileEntry is a listed file together with its stored metadata and legal hold state
+type FileEntry struct {
+	service.File
+	OriginalName string `json:"original_name"`
+	ContentType  string `json:"content_type,omitempty"`
+	Category     string `json:"category"`
+	OwnerID      string `json:"owner_id,omitempty"`
+	LegalHold    bool   `json:"legal_hold,omitempty"`
+	Locked       bool   `json:"locked,omitempty"`
+}
+
+// ListFilesData is the payload of the file listing endpoint
+type ListFilesData struct {
+	Files   []FileEntry `json:"files"`
+	Folders []string    `json:"folders"`
+	Count   int         `json:"count"`
+	Prefix  string      `json:"prefix"`
+	Bucket  string      `json:"bucket"`
 }
 
-// HealthCheck handles the health check endpoint
+// DuplicateWarning flags existing keys that an upload may be confused with
+type DuplicateWarning struct {
+	Warning        string   `json:"warning,omitempty"`
+	NearDuplicates []string `json:"near_duplicates,omitempty"`
+}
+
+// UploadData is the payload of the upload endpoint
+type UploadData struct {
+	Key          string `json:"key"`
+	Filename     string `json:"filename"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	StorageClass string `json:"storage_class,omitempty"`
+	SHA256       string `json:"sha256"`
+	MD5          string `json:"md5"`
+	Deduplicated bool   `json:"deduplicated,omitempty"`
+	Bucket       string `json:"bucket,omitempty"`
+	// ContentEncoding is "gzip" when the file was stored compressed; Size is then the
+	// stored size and OriginalSize the size of the file as uploaded
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	OriginalSize    int64  `json:"original_size,omitempty"`
+	DuplicateWarning
+}
+
+// UploadResult is the outcome of one file in a multi-file upload
+type UploadResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+	UploadData
+}
+
+// UploadBatchData is the payload of an upload that carried several files
+type UploadBatchData struct {
+	Files    []UploadResult `json:"files"`
+	Uploaded int            `json:"uploaded"`
+	Failed   int            `json:"failed"`
+}
+
+// RenameData is the payload of the rename endpoint
+type RenameData struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LimitsData is the payload of the limits endpoint
+type LimitsData struct {
+	MaxUploadSize int64 `json:"max_upload_size"`
+	// Quota is the caller's storage quota, 0 when unlimited, and QuotaUsed how much of it is taken
+	Quota     int64 `json:"quota"`
+	QuotaUsed int64 `json:"quota_used"`
+}
+
+// DeletePrefixData is the payload of the recursive delete endpoint
+type DeletePrefixData struct {
+	Prefix  string `json:"prefix"`
+	Deleted int    `json:"deleted"`
+}
+
+// Machine-readable error codes for failures clients are expected to handle
+const (
+	CodeNoFilePart    = "NO_FILE_PART"
+	CodeEmptyFile     = "EMPTY_FILE"
+	CodeTruncatedBody = "TRUNCATED_BODY"
+	CodeLegalHold     = "LEGAL_HOLD"
+	CodeTooLarge      = "FILE_TOO_LARGE"
+	CodeChecksum      = "CHECKSUM_MISMATCH"
+	CodeFileType      = "UNSUPPORTED_FILE_TYPE"
+	CodeQuotaExceeded = "QUOTA_EXCEEDED"
+	CodeStorageBusy   = "STORAGE_BUSY"
+	CodeDestExists    = "DESTINATION_EXISTS"
+	CodeInboxGone     = "INBOX_GONE"
+	CodeLocked        = "FILE_LOCKED"
+)
+
+// storageBusyRetryAfter is the Retry-After, in seconds, sent when storage had no free
+// operation slot; the request already waited for one, so a short pause is enough
+const storageBusyRetryAfter = "2"
+
+// multipartOverhead is the room left in an upload body for form fields and part headers
+const multipartOverhead = 1 << 20
+
+// HealthCheck reports that the process is up, without checking dependencies
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
+	respondJSON(w, http.StatusOK, HealthResponse{
+		Status:    "healthy",
+		Timestamp: time.Now().Format(time.RFC3339),
 	})
 }
 
@@ -79,25 +290,147 @@ func (h *Handler) GetIndex(w http.ResponseWriter, r *http.Request) {
 // ListFiles handles the list files endpoint
 func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	category := r.URL.Query().Get("category")
+
+	if delimiter != "" && delimiter != "/" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "delimiter must be \"/\"",
+		})
+		return
+	}
+
+	if category != "" && !slices.Contains(service.Categories, category) {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("category must be one of %s", strings.Join(service.Categories, ", ")),
+		})
+		return
+	}
+
+	if err := validatePrefix(prefix); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 
-	files, err := h.s3Service.ListFiles(ctx)
+	order, err := parseFileSort(r.URL.Query())
 	if err != nil {
-		h.logger.Error("failed to list files", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{
+	// Non-admins only see the files they own in their own folder; admins see everything
+	// or filter by user
+	user := auth.GetUserFromContext(ctx)
+	scope := ""
+	if user.Role != auth.RoleAdmin {
+		scope = service.UserPrefix(user.ID)
+	} else if userID := r.URL.Query().Get("user"); userID != "" {
+		scope = service.UserPrefix(userID)
+	}
+	if scope != "" {
+		if prefix == "" {
+			prefix = scope
+		} else if !strings.HasPrefix(prefix, scope) {
+			respondJSON(w, http.StatusForbidden, Response{
+				Success: false,
+				Error:   "access denied to files outside your folder",
+			})
+			return
+		}
+	}
+
+	listing, err := storage.ListFiles(ctx, service.ListOptions{
+		Prefix:    prefix,
+		Delimiter: delimiter,
+	})
+	if err != nil {
+		h.logger.Error("failed to list files", zap.Error(err))
+		respondStorageError(w, err, "failed to list files")
+		return
+	}
+
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+	}
+
+	// File metadata is only kept for the primary bucket
+	primary := storage == h.s3Service
+	var records map[string]*db.FileRecord
+	if primary {
+		keys := make([]string, len(listing.Files))
+		for i, file := range listing.Files {
+			keys[i] = file.Key
+		}
+		records, err = h.database.FileRecordsByKeys(keys)
+		if err != nil {
+			h.logger.Error("failed to load file metadata", zap.Error(err))
+		}
+	}
+
+	var missing []service.File
+	files := make([]FileEntry, 0, len(listing.Files))
+	for _, file := range listing.Files {
+		record := records[file.Key]
+		if record == nil {
+			record = recordFromListing(file)
+			if primary {
+				missing = append(missing, file)
+			}
+		}
+		// A non-admin's folder may hold files someone else put there, such as inbox uploads
+		if user.Role != auth.RoleAdmin && record.OwnerID != user.ID {
+			continue
+		}
+		// Rows the startup backfill hasn't reached yet are categorized on the fly
+		fileCategory := record.Category
+		if fileCategory == "" {
+			fileCategory = service.Categorize(record.ContentType, record.OriginalName)
+		}
+		if category != "" && fileCategory != category {
+			continue
+		}
+		files = append(files, FileEntry{
+			File:         file,
+			OriginalName: record.OriginalName,
+			ContentType:  record.ContentType,
+			Category:     fileCategory,
+			OwnerID:      record.OwnerID,
+			LegalHold:    heldPrefixOf(held, file.Key) != "",
+			Locked:       primary && record.Locked,
+		})
+	}
+	order.apply(files, listing.Folders)
+	if len(missing) > 0 {
+		h.queueBackfill(missing)
+	}
+
+	respondJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"files": files,
-			"count": len(files),
+		Data: ListFilesData{
+			Files:   files,
+			Folders: listing.Folders,
+			Count:   len(files),
+			Prefix:  prefix,
+			Bucket:  storage.Name(),
 		},
 	})
 }
@@ -105,9 +438,7 @@ func (h *Handler) ListFiles(w http.ResponseWriter, r *http.Request) {
 // UploadFile handles the file upload endpoint
 func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusMethodNotAllowed, Response{
 			Success: false,
 			Error:   "method not allowed",
 		})
@@ -117,9 +448,7 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	// Check upload permission
 	user := auth.GetUserFromContext(r.Context())
 	if user == nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusUnauthorized, Response{
 			Success: false,
 			Error:   "unauthorized",
 		})
@@ -129,76 +458,509 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	perm := auth.PermissionMap[user.Role]
 	if !perm.CanUpload {
 		h.logger.Warn("upload attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusForbidden, Response{
 			Success: false,
 			Error:   "insufficient permissions to upload files",
 		})
 		return
 	}
 
-	ctx := r.Context()
+	// Refuse oversized bodies outright instead of spooling them to disk
+	if r.ContentLength > h.maxUploadSize+multipartOverhead {
+		respondJSON(w, http.StatusRequestEntityTooLarge, Response{
+			Success: false,
+			Error:   fmt.Sprintf("upload exceeds the maximum size of %d bytes", h.maxUploadSize),
+			Code:    CodeTooLarge,
+		})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize+multipartOverhead)
+
+	metrics.UploadsInFlight.Inc()
+	defer metrics.UploadsInFlight.Dec()
+
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.logger.Warn("upload body too large", zap.String("user", user.Name), zap.Int64("limit", h.maxUploadSize))
+			respondJSON(w, http.StatusRequestEntityTooLarge, Response{
+				Success: false,
+				Error:   fmt.Sprintf("upload exceeds the maximum size of %d bytes", h.maxUploadSize),
+				Code:    CodeTooLarge,
+			})
+			return
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			h.logger.Warn("upload body ended early", zap.String("user", user.Name), zap.Int64("content_length", r.ContentLength))
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "request body ended before the declared length",
+				Code:    CodeTruncatedBody,
+			})
+			return
+		}
 		h.logger.Error("failed to parse multipart form", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Response{
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   "failed to parse form",
 		})
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		h.logger.Error("failed to get form file", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Response{
+	// Files arrive as repeated "file" parts or, from multi-select forms, as "files[]"
+	batched := len(r.MultipartForm.File["files[]"]) > 0
+	headers := append(r.MultipartForm.File["file"], r.MultipartForm.File["files[]"]...)
+	if len(headers) == 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
-			Error:   "file not provided",
+			Error:   "no file part in form",
+			Code:    CodeNoFilePart,
 		})
 		return
 	}
-	defer file.Close()
 
-	// Read file content
-	buf := make([]byte, header.Size)
-	if _, err := file.Read(buf); err != nil {
-		h.logger.Error("failed to read file", zap.Error(err))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{
+	// Empty files are almost always a broken pipeline, so they must be asked for explicitly
+	allowEmpty, _ := strconv.ParseBool(r.FormValue("allow_empty"))
+
+	// With dedupe, a file identical to one already in the uploader's folder isn't stored again
+	dedupe, _ := strconv.ParseBool(r.FormValue("dedupe"))
+
+	// Tags arrive as a JSON object and apply to every file in the request
+	var tags map[string]string
+	if raw := r.FormValue("tags"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tags); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "tags must be a JSON object of string values",
+			})
+			return
+		}
+		if err := service.ValidateTags(tags); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	// Custom metadata arrives as a JSON object and is stored as x-amz-meta-* on every file
+	var metadata map[string]string
+	if raw := r.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "metadata must be a JSON object of string values",
+			})
+			return
+		}
+		if err := service.ValidateMetadata(metadata); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	// Compression is opt-in and only applied to files it helps; see service.Compressible
+	compress := r.FormValue("compress")
+	if compress != "" && compress != service.ContentEncodingGzip {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("unsupported compression %q; only gzip is available", compress),
+		})
+		return
+	}
+
+	storageClass := strings.ToUpper(r.FormValue("storage_class"))
+	if storageClass == "" {
+		storageClass = service.DefaultStorageClass
+	}
+	if !service.ValidStorageClass(storageClass) {
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
-			Error:   "failed to read file",
+			Error:   fmt.Sprintf("unknown storage class %q", storageClass),
 		})
 		return
 	}
 
-	// Create unique key
-	key := fmt.Sprintf("%d-%s", time.Now().Unix(), header.Filename)
+	// A client-supplied digest is checked against what the server received
+	expectedSHA256 := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Content-SHA256")))
+	if expectedSHA256 != "" {
+		if len(headers) > 1 {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "X-Content-SHA256 applies to single-file uploads only",
+			})
+			return
+		}
+		if _, err := hex.DecodeString(expectedSHA256); err != nil || len(expectedSHA256) != sha256.Size*2 {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "X-Content-SHA256 must be a hex-encoded SHA-256 digest",
+			})
+			return
+		}
+	}
 
-	// Upload to S3
-	if err := h.s3Service.UploadFile(ctx, key, buf); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{
+	// Usage is only counted from the primary bucket's records, so other buckets would
+	// be storage past the quota
+	if storage != h.s3Service && user.Role != auth.RoleAdmin {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to upload to other buckets",
+		})
+		return
+	}
+
+	upload := uploadParams{
+		storage:        storage,
+		storageClass:   storageClass,
+		tags:           tags,
+		metadata:       metadata,
+		allowEmpty:     allowEmpty,
+		dedupe:         dedupe,
+		compress:       compress != "",
+		expectedSHA256: expectedSHA256,
+	}
+
+	// A single file keeps the original response shape unless the client asked for a batch
+	if len(headers) == 1 && !batched {
+		data, err := h.storeUpload(r, user, headers[0], headers[0].Filename, upload)
+		if err != nil {
+			var uerr *uploadError
+			errors.As(err, &uerr)
+			if uerr.code == CodeStorageBusy {
+				w.Header().Set("Retry-After", storageBusyRetryAfter)
+			}
+			respondJSON(w, uerr.status, Response{
+				Success: false,
+				Error:   uerr.message,
+				Code:    uerr.code,
+			})
+			return
+		}
+
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data:    data,
+		})
+		return
+	}
+
+	// Several files are stored independently so one failure doesn't abort the rest
+	batch := UploadBatchData{
+		Files: make([]UploadResult, 0, len(headers)),
+	}
+	used := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		name := uniqueName(used, header.Filename)
+		data, err := h.storeUpload(r, user, header, name, upload)
+		if err != nil {
+			var uerr *uploadError
+			errors.As(err, &uerr)
+			batch.Failed++
+			batch.Files = append(batch.Files, UploadResult{
+				Success:    false,
+				Error:      uerr.message,
+				Code:       uerr.code,
+				UploadData: UploadData{Filename: header.Filename, Size: header.Size},
+			})
+			continue
+		}
+		batch.Uploaded++
+		batch.Files = append(batch.Files, UploadResult{
+			Success:    true,
+			UploadData: data,
+		})
+	}
+
+	h.logger.Info("batch upload finished", zap.String("user", user.Name), zap.Int("uploaded", batch.Uploaded), zap.Int("failed", batch.Failed))
+
+	// Per-file failures are reported in the payload rather than through the status
+	respondJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"key":      key,
-			"filename": header.Filename,
-			"size":     header.Size,
-		},
+		Data:    batch,
+	})
+}
+
+// uploadError is a per-file upload failure with the status it maps to
+type uploadError struct {
+	status  int
+	message string
+	code    string
+}
+
+// Error returns the message reported to the client
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+// storageStatus maps a storage error to the status and message reported to the client.
+// Anything other than a missing object or a refusal by the backend gets fallback, so raw
+// SDK error text never leaves the server
+func storageStatus(err error, fallback string) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, "file not found"
+	case errors.Is(err, service.ErrAccessDenied):
+		return http.StatusForbidden, "access denied by storage"
+	case errors.Is(err, service.ErrVersioningUnsupported):
+		return http.StatusNotImplemented, service.ErrVersioningUnsupported.Error()
+	case errors.Is(err, service.ErrBusy):
+		return http.StatusServiceUnavailable, service.ErrBusy.Error()
+	}
+	return http.StatusInternalServerError, fallback
+}
+
+// setRetryAfter tells the client when to retry a request refused because storage was busy
+func setRetryAfter(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrBusy) {
+		w.Header().Set("Retry-After", storageBusyRetryAfter)
+	}
+}
+
+// bucketFor resolves the bucket query parameter to the storage of that bucket.
+// Without the parameter the primary bucket is used.
+func (h *Handler) bucketFor(r *http.Request) (*service.S3Service, error) {
+	return h.s3Service.ForBucket(r.URL.Query().Get("bucket"))
+}
+
+// respondStorageError writes the JSON error response for a failed storage call
+func respondStorageError(w http.ResponseWriter, err error, fallback string) {
+	setRetryAfter(w, err)
+	status, message := storageStatus(err, fallback)
+	respondJSON(w, status, Response{
+		Success: false,
+		Error:   message,
+	})
+}
+
+// uploadParams holds the options of an upload request that apply to every file in it
+type uploadParams struct {
+	// storage is the bucket the files go to
+	storage      *service.S3Service
+	storageClass string
+	tags         map[string]string
+	metadata     map[string]string
+	allowEmpty   bool
+	dedupe       bool
+	// compress stores compressible files gzip-compressed
+	compress bool
+	// expectedSHA256 is the hex digest the client says the file has, if any
+	expectedSHA256 string
+	// prefix is the folder the files are stored in; empty means the uploader's folder
+	prefix string
+	// skipQuota is set for uploads bounded by limits of their own, such as an inbox's
+	skipQuota bool
+}
+
+// storeUpload validates and stores one uploaded file part under a key derived from name
+func (h *Handler) storeUpload(r *http.Request, user *auth.User, header *multipart.FileHeader, name string, params uploadParams) (UploadData, error) {
+	ctx := r.Context()
+
+	if header.Size > h.maxUploadSize {
+		return UploadData{}, &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the maximum upload size of %d bytes", h.maxUploadSize), CodeTooLarge}
+	}
+
+	if header.Size == 0 && !params.allowEmpty {
+		h.logger.Warn("rejected empty upload", zap.String("user", user.Name), zap.String("filename", header.Filename))
+		return UploadData{}, &uploadError{http.StatusBadRequest, "file is empty; pass allow_empty=true to upload it anyway", CodeEmptyFile}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.logger.Error("failed to open form file", zap.Error(err))
+		return UploadData{}, &uploadError{http.StatusBadRequest, "file not provided", ""}
+	}
+	defer file.Close()
+
+	// Hash the part in one streaming pass, keeping only its head for sniffing,
+	// then rewind it so the upload streams from the same spooled copy
+	sha := sha256.New()
+	md := md5.New()
+	hasher := io.MultiWriter(sha, md)
+	head := make([]byte, 512)
+	n, err := io.ReadFull(io.TeeReader(file, hasher), head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		h.logger.Error("failed to read file", zap.Error(err))
+		return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to read file", ""}
+	}
+	head = head[:n]
+	if _, err := io.Copy(hasher, file); err != nil {
+		h.logger.Error("failed to read file", zap.Error(err))
+		return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to read file", ""}
+	}
+	shaSum, mdSum := sha.Sum(nil), md.Sum(nil)
+	checksum := hex.EncodeToString(shaSum)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		h.logger.Error("failed to rewind file", zap.Error(err))
+		return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to read file", ""}
+	}
+
+	if uerr := h.runUploadHooks(ctx, &uploadCheck{
+		user:           user,
+		name:           name,
+		contentType:    header.Header.Get("Content-Type"),
+		size:           header.Size,
+		head:           head,
+		sha256:         checksum,
+		expectedSHA256: params.expectedSHA256,
+	}); uerr != nil {
+		return UploadData{}, uerr
+	}
+
+	// Only the primary bucket has the file records deduplication looks in
+	primary := params.storage == h.s3Service
+	if params.dedupe && primary {
+		if existing := h.findDuplicate(ctx, user, checksum, header.Size); existing != nil {
+			h.logger.Info("upload deduplicated", zap.String("user", user.Name), zap.String("key", existing.Key))
+			return UploadData{
+				Key:          existing.Key,
+				Filename:     existing.OriginalName,
+				Size:         existing.Size,
+				ContentType:  existing.ContentType,
+				SHA256:       checksum,
+				MD5:          hex.EncodeToString(mdSum),
+				Deduplicated: true,
+				Bucket:       params.storage.Name(),
+			}, nil
+		}
+	}
+
+	// Even where usage isn't recorded, no single upload may take its owner past the quota
+	if !params.skipQuota {
+		if uerr := h.checkQuota(user.ID, header.Size); uerr != nil {
+			return UploadData{}, uerr
+		}
+	}
+
+	// Create unique key in the uploader's folder unless the upload names another
+	folder := params.prefix
+	if folder == "" {
+		folder = service.UserPrefix(user.ID)
+	}
+	filename := service.NormalizeFilename(name, h.keyPolicy)
+	key := fmt.Sprintf("%s%d-%s", folder, time.Now().Unix(), filename)
+
+	// Two uploads of the same name within a second share a key, so the second overwrites
+	if primary {
+		locked, err := h.database.IsFileLocked(key)
+		if err != nil {
+			h.logger.Error("failed to check file lock", zap.String("key", key), zap.Error(err))
+			return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to check file lock", ""}
+		}
+		if locked {
+			return UploadData{}, &uploadError{http.StatusLocked, fmt.Sprintf("file %q is locked", key), CodeLocked}
+		}
+	}
+
+	// Prefer the type the client declared, otherwise sniff it from the content.
+	// Browsers and curl declare octet-stream for anything they don't recognise,
+	// which says nothing about the file, so it is sniffed too.
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(head)
+	}
+
+	metadata := make(map[string]string, len(params.metadata)+1)
+	for k, v := range params.metadata {
+		metadata[k] = v
+	}
+	metadata[sha256MetadataKey] = checksum
+
+	// A compressed file is spooled to disk first, since the digests and length S3 wants
+	// are those of the compressed bytes
+	var body io.Reader = file
+	storedSize, storedMD5, storedSHA := header.Size, mdSum, shaSum
+	contentEncoding := ""
+	if params.compress && service.Compressible(contentType, name) {
+		spool, err := service.SpoolGzip(file)
+		if err != nil {
+			h.logger.Error("failed to compress upload", zap.String("filename", name), zap.Error(err))
+			return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to compress file", ""}
+		}
+		defer spool.Close()
+
+		body = spool
+		storedSize, storedMD5, storedSHA = spool.Size, spool.MD5, spool.SHA256
+		contentEncoding = service.ContentEncodingGzip
+		metadata[service.OriginalSizeMetadataKey] = strconv.FormatInt(header.Size, 10)
+	}
+
+	// Upload to S3 with both digests, so a body corrupted on the way is rejected rather than stored
+	err = params.storage.UploadFile(ctx, key, body, service.UploadOptions{
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+		StorageClass:    params.storageClass,
+		Metadata:        metadata,
+		Tags:            params.tags,
+		ContentMD5:      base64.StdEncoding.EncodeToString(storedMD5),
+		ChecksumSHA256:  base64.StdEncoding.EncodeToString(storedSHA),
+	})
+	if errors.Is(err, service.ErrChecksumMismatch) {
+		return UploadData{}, &uploadError{http.StatusUnprocessableEntity, "storage rejected the file because it was corrupted in transit", CodeChecksum}
+	}
+	if errors.Is(err, service.ErrBusy) {
+		return UploadData{}, &uploadError{http.StatusServiceUnavailable, err.Error(), CodeStorageBusy}
+	}
+	if err != nil {
+		status, message := storageStatus(err, "failed to upload file")
+		return UploadData{}, &uploadError{status, message, ""}
+	}
+
+	// Confirm the stored object holds every byte we received
+	info, err := params.storage.StatFile(ctx, key)
+	if err != nil || info.Size != storedSize {
+		h.logger.Error("stored object does not match upload", zap.String("key", key), zap.Int64("expected", storedSize), zap.Error(err))
+		if err := params.storage.DeleteFile(ctx, key); err != nil {
+			h.logger.Error("failed to remove mismatched upload", zap.String("key", key), zap.Error(err))
+		}
+		return UploadData{}, &uploadError{http.StatusInternalServerError, "stored object size does not match the uploaded file", CodeTruncatedBody}
+	}
+
+	data := UploadData{
+		Key:          key,
+		Filename:     filename,
+		Size:         info.Size,
+		ContentType:  contentType,
+		StorageClass: params.storageClass,
+		SHA256:       checksum,
+		MD5:          hex.EncodeToString(mdSum),
+		Bucket:       params.storage.Name(),
+	}
+	if contentEncoding != "" {
+		data.ContentEncoding = contentEncoding
+		data.OriginalSize = header.Size
+		h.logger.Info("upload stored compressed", zap.String("key", key), zap.Int64("size", header.Size), zap.Int64("stored", info.Size))
+	}
+	h.publish(events.ActionUploaded, user, params.storage, key, "")
+	if !primary {
+		return data, nil
+	}
+
+	h.saveRecord(&db.FileRecord{
+		Key:          key,
+		OwnerID:      user.ID,
+		OriginalName: header.Filename,
+		Size:         info.Size,
+		ContentType:  contentType,
+		Category:     service.Categorize(contentType, header.Filename),
+		SHA256:       checksum,
+		UploadedAt:   time.Now(),
 	})
+	data.DuplicateWarning = h.nearDuplicates(r, key)
+	return data, nil
 }
 
 // DownloadFile handles the file download endpoint
@@ -206,88 +968,1305 @@ func (h *Handler) DownloadFile(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	key := r.URL.Query().Get("key")
 
-	if key == "" {
-		http.Error(w, "key parameter required", http.StatusBadRequest)
+	if err := validateKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	data, err := h.s3Service.GetFile(ctx, key)
+	if !canAccessKey(auth.GetUserFromContext(ctx), key) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	storage, err := h.bucketFor(r)
 	if err != nil {
-		h.logger.Error("failed to download file", zap.String("key", key), zap.Error(err))
-		http.Error(w, "file not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", key))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
-	w.Write(data)
-}
+	// Without versionId the latest version is served
+	versionID := r.URL.Query().Get("versionId")
 
-// DeleteFile handles the file delete endpoint
-func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(Response{
-			Success: false,
-			Error:   "method not allowed",
-		})
+	// Check the object's validators first so cached copies don't cost a transfer
+	info, err := storage.StatFileVersion(ctx, key, versionID)
+	if err != nil {
+		status, message := storageStatus(err, "failed to download file")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("failed to stat file for download", zap.String("key", key), zap.Error(err))
+		}
+		http.Error(w, message, status)
 		return
 	}
 
-	// Check delete permission
-	user := auth.GetUserFromContext(r.Context())
-	if user == nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(Response{
-			Success: false,
-			Error:   "unauthorized",
-		})
-		return
+	// Compressed objects go out as stored to clients that accept gzip and are decompressed
+	// on the fly for the rest. The decompressed body has no byte ranges and only a weak
+	// ETag, since the stored ETag describes the compressed bytes.
+	etag := info.ETag
+	decode := false
+	if info.ContentEncoding != "" {
+		w.Header().Add("Vary", "Accept-Encoding")
+		decode = info.ContentEncoding != service.ContentEncodingGzip || !acceptsGzip(r)
+		if decode && etag != "" && !strings.HasPrefix(etag, "W/") {
+			etag = "W/" + etag
+		}
 	}
 
-	perm := auth.PermissionMap[user.Role]
-	if !perm.CanDelete {
-		h.logger.Warn("delete attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(Response{
-			Success: false,
-			Error:   "insufficient permissions to delete files",
-		})
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !info.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, info.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	ctx := r.Context()
-	key := r.URL.Query().Get("key")
+	// A stale If-Range means the client's partial copy is outdated, so it gets the whole object
+	rangeHeader := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != info.ETag &&
+		ifRange != info.LastModified.UTC().Format(http.TimeFormat) {
+		rangeHeader = ""
+	}
+	if decode {
+		rangeHeader = ""
+	}
 
-	if key == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(Response{
-			Success: false,
-			Error:   "key parameter required",
-		})
+	start, end, partial, err := parseRange(rangeHeader, info.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 		return
 	}
 
-	if err := h.s3Service.DeleteFile(ctx, key); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{
-			Success: false,
-			Error:   err.Error(),
-		})
+	byteRange := ""
+	if partial {
+		byteRange = fmt.Sprintf("bytes=%d-%d", start, end)
+	}
+
+	obj, err := storage.StreamFileVersion(ctx, key, versionID, byteRange)
+	if err != nil {
+		h.logger.Error("failed to download file", zap.String("key", key), zap.Error(err))
+		setRetryAfter(w, err)
+		status, message := storageStatus(err, "failed to download file")
+		http.Error(w, message, status)
 		return
 	}
+	defer obj.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{
-		Success: true,
-		Data: map[string]interface{}{
-			"message": "file deleted successfully",
-		},
-	})
+	body := io.Reader(obj.Body)
+	if decode {
+		decoded, err := obj.Decoded()
+		if err != nil {
+			h.logger.Error("failed to decompress file for download", zap.String("key", key), zap.Error(err))
+			http.Error(w, "failed to download file", http.StatusInternalServerError)
+			return
+		}
+		defer decoded.Close()
+		body = decoded
+	}
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "attachment"
+	if inline, _ := strconv.ParseBool(r.URL.Query().Get("inline")); inline && isPreviewable(contentType) {
+		disposition = "inline"
+	}
+
+	// Prefer the name the file was uploaded with over the stored key;
+	// only the primary bucket has file records to take it from
+	name := service.OriginalName(key)
+	if storage == h.s3Service {
+		if record, err := h.database.GetFileRecord(key); err == nil && record != nil && record.OriginalName != "" {
+			name = record.OriginalName
+		}
+	}
+
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, name))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	for k, v := range info.Metadata {
+		w.Header().Set("X-File-Meta-"+k, v)
+	}
+	switch {
+	case !decode:
+		if obj.ContentEncoding != "" {
+			w.Header().Set("Content-Encoding", obj.ContentEncoding)
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	case info.OriginalSize > 0:
+		w.Header().Set("Accept-Ranges", "none")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", info.OriginalSize))
+	default:
+		w.Header().Set("Accept-Ranges", "none")
+	}
+
+	status := http.StatusOK
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	if _, err := io.Copy(w, body); err != nil {
+		h.logger.Warn("download interrupted", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// parseRange resolves a single-range "bytes=" Range header against an object of the given size.
+// It reports partial=false when the whole object should be sent, which includes an absent
+// header and multi-range requests, and an error when the range is malformed or unsatisfiable.
+func parseRange(header string, size int64) (start, end int64, partial bool, err error) {
+	if header == "" {
+		return 0, 0, false, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, false, fmt.Errorf("unsupported range unit")
+	}
+	if strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false, fmt.Errorf("malformed range")
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false, fmt.Errorf("range not satisfiable")
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false, fmt.Errorf("malformed range")
+	}
+	if start >= size {
+		return 0, 0, false, fmt.Errorf("range not satisfiable")
+	}
+
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, fmt.Errorf("malformed range")
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return start, end, true, nil
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since against an object's validators.
+// If-Modified-Since is ignored when If-None-Match is present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have one-second resolution
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// contentDisposition builds a Content-Disposition header for name following RFC 6266.
+// Control characters are dropped; the plain filename parameter is a quoted ASCII
+// fallback with accents stripped, and the exact name always follows as an RFC 5987
+// filename* parameter, which clients that understand it prefer.
+func contentDisposition(disposition, name string) string {
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name))
+	if name == "" {
+		name = "download"
+	}
+
+	// Each character becomes its unaccented ASCII form, é as e, or one underscore
+	var fallback strings.Builder
+	for _, r := range name {
+		ascii := strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, norm.NFD.String(string(r)))
+		if ascii == "" || strings.ContainsFunc(ascii, func(r rune) bool { return r > unicode.MaxASCII || r == '"' || r == '\\' }) {
+			fallback.WriteByte('_')
+			continue
+		}
+		fallback.WriteString(ascii)
+	}
+
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, fallback.String(), encodeRFC5987(name))
+}
+
+// encodeRFC5987 percent-encodes every byte of s outside the RFC 5987 attr-char set
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// isPreviewable reports whether a content type is safe to render inline in the browser.
+// HTML and SVG are excluded because they can run script on our origin.
+func isPreviewable(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "video/"):
+		return true
+	case mediaType == "application/pdf", mediaType == "text/plain":
+		return true
+	}
+	return false
+}
+
+// GetLimits returns the upload limits so clients can check files before sending them
+func (h *Handler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	data := LimitsData{
+		MaxUploadSize: h.maxUploadSize,
+	}
+	if quota, err := h.userQuotaData(auth.GetUserFromContext(r.Context()).ID); err == nil {
+		data.Quota = quota.Quota
+		data.QuotaUsed = quota.Used
+	} else {
+		h.logger.Warn("failed to get storage quota for limits", zap.Error(err))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// StatFile handles the file metadata endpoint
+func (h *Handler) StatFile(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, auth.GetUserFromContext(r.Context()), key) {
+		return
+	}
+
+	info, err := h.s3Service.StatFile(r.Context(), key)
+	if err != nil {
+		respondStorageError(w, err, "failed to stat file")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    info,
+	})
+}
+
+// sha256MetadataKey is the object metadata entry holding the SHA-256 of the content
+const sha256MetadataKey = "sha256"
+
+// ByHashData is the payload of the lookup by hash endpoint
+type ByHashData struct {
+	SHA256 string      `json:"sha256"`
+	Files  []FileEntry `json:"files"`
+}
+
+// FilesByHash looks up the files the user can access whose content has the given SHA-256
+func (h *Handler) FilesByHash(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	checksum := strings.ToLower(r.URL.Query().Get("sha256"))
+
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "sha256 must be 64 hex characters",
+		})
+		return
+	}
+
+	records, err := h.database.FileRecordsByHash(checksum)
+	if err != nil {
+		h.logger.Error("failed to look up files by hash", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to look up files",
+		})
+		return
+	}
+
+	files := make([]FileEntry, 0, len(records))
+	for _, record := range records {
+		if strings.HasPrefix(record.Key, service.TrashPrefix) || !canAccessKey(user, record.Key) {
+			continue
+		}
+		files = append(files, FileEntry{
+			File: service.File{
+				Key:          record.Key,
+				Size:         record.Size,
+				LastModified: record.UploadedAt,
+			},
+			OriginalName: record.OriginalName,
+			ContentType:  record.ContentType,
+			Category:     record.Category,
+			OwnerID:      record.OwnerID,
+		})
+	}
+
+	if len(files) == 0 {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "no file with that hash",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: ByHashData{
+			SHA256: checksum,
+			Files:  files,
+		},
+	})
+}
+
+// findDuplicate returns a file in the user's folder with the given content, or nil.
+// Records whose object is gone or has changed size are skipped.
+func (h *Handler) findDuplicate(ctx context.Context, user *auth.User, checksum string, size int64) *db.FileRecord {
+	records, err := h.database.FileRecordsByHash(checksum)
+	if err != nil {
+		h.logger.Error("failed to look up duplicates", zap.Error(err))
+		return nil
+	}
+
+	for _, record := range records {
+		if !strings.HasPrefix(record.Key, service.UserPrefix(user.ID)) || record.Size != size {
+			continue
+		}
+		if info, err := h.s3Service.StatFile(ctx, record.Key); err != nil || info.Size != size {
+			continue
+		}
+		return record
+	}
+	return nil
+}
+
+// TagsRequest is the request body of the set tags endpoint
+type TagsRequest struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// TagsData is the payload of the tag endpoints
+type TagsData struct {
+	Key  string            `json:"key"`
+	Tags map[string]string `json:"tags"`
+}
+
+// GetTags returns the tags of a file
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, auth.GetUserFromContext(r.Context()), key) {
+		return
+	}
+
+	tags, err := h.s3Service.GetObjectTags(r.Context(), key)
+	if err != nil {
+		respondStorageError(w, err, "failed to get tags")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: TagsData{
+			Key:  key,
+			Tags: tags,
+		},
+	})
+}
+
+// SetTags replaces the tags of a file
+func (h *Handler) SetTags(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !auth.PermissionMap[user.Role].CanUpload {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to tag files",
+		})
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, key) {
+		return
+	}
+
+	var req TagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+	if req.Tags == nil {
+		req.Tags = map[string]string{}
+	}
+
+	if err := service.ValidateTags(req.Tags); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err := h.s3Service.SetObjectTags(r.Context(), key, req.Tags)
+	if err != nil {
+		respondStorageError(w, err, "failed to set tags")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: TagsData{
+			Key:  key,
+			Tags: req.Tags,
+		},
+	})
+}
+
+// RenameRequest moves an object to a new key
+type RenameRequest struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// RenameFile handles the file rename endpoint (copy followed by delete)
+func (h *Handler) RenameFile(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	// Renaming both writes the new key and removes the old one
+	if !user.HasPermission(auth.Permission{CanUpload: true, CanDelete: true}) {
+		h.logger.Warn("rename attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to rename files",
+		})
+		return
+	}
+
+	var req RenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	for _, key := range []string{req.From, req.To} {
+		if err := validateKey(key); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.From == req.To || strings.HasSuffix(req.To, "/") {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "valid, distinct from and to keys are required",
+		})
+		return
+	}
+
+	ctx := r.Context()
+
+	if h.rejectForeign(w, user, req.From) || h.rejectForeign(w, user, req.To) {
+		return
+	}
+
+	if h.rejectHeld(w, req.From) || h.rejectHeld(w, req.To) {
+		return
+	}
+
+	if h.rejectLocked(w, req.From) || h.rejectLocked(w, req.To) {
+		return
+	}
+
+	if !req.Overwrite {
+		if _, err := h.s3Service.StatFile(ctx, req.To); err == nil {
+			respondJSON(w, http.StatusConflict, Response{
+				Success: false,
+				Error:   "destination key already exists",
+			})
+			return
+		} else if !errors.Is(err, service.ErrNotFound) {
+			status, message := storageStatus(err, "failed to check destination")
+			respondJSON(w, status, Response{
+				Success: false,
+				Error:   message,
+			})
+			return
+		}
+	}
+
+	// The destination's folder decides who owns the file from now on
+	if err := h.s3Service.RenameFile(ctx, req.From, req.To); err != nil {
+		status, message := storageStatus(err, "failed to copy file")
+		if errors.Is(err, service.ErrRenameIncomplete) {
+			message = service.ErrRenameIncomplete.Error()
+		}
+		respondJSON(w, status, Response{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	if err := h.database.RenameFileRecord(req.From, req.To, service.UserFromKey(req.To)); err != nil {
+		h.logger.Error("failed to move file metadata", zap.String("from", req.From), zap.String("to", req.To), zap.Error(err))
+	}
+
+	h.logger.Info("file renamed", zap.String("user", user.Name), zap.String("from", req.From), zap.String("to", req.To))
+	h.publish(events.ActionRenamed, user, h.s3Service, req.To, req.From)
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: RenameData{
+			From: req.From,
+			To:   req.To,
+		},
+	})
+}
+
+// DeleteFile handles the file delete endpoint
+func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		respondJSON(w, http.StatusMethodNotAllowed, Response{
+			Success: false,
+			Error:   "method not allowed",
+		})
+		return
+	}
+
+	// Check delete permission
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	perm := auth.PermissionMap[user.Role]
+	if !perm.CanDelete {
+		h.logger.Warn("delete attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to delete files",
+		})
+		return
+	}
+
+	ctx := r.Context()
+	key := r.URL.Query().Get("key")
+
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, key) {
+		return
+	}
+
+	if h.rejectHeld(w, key) {
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Locks are kept with the metadata, which only covers the primary bucket
+	if storage == h.s3Service && h.rejectLocked(w, key) {
+		return
+	}
+
+	// Deleting a single version is permanent by nature, so it bypasses the trash
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		if err := storage.DeleteFileVersion(ctx, key, versionID); err != nil {
+			h.logger.Warn("failed to delete file version", zap.String("key", key), zap.String("version_id", versionID), zap.Error(err))
+			respondStorageError(w, err, "failed to delete file version")
+			return
+		}
+		h.logger.Info("file version deleted", zap.String("user", user.Name), zap.String("bucket", storage.Name()), zap.String("key", key), zap.String("version_id", versionID))
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data: MessageData{
+				Message: "file version deleted",
+			},
+		})
+		return
+	}
+
+	// The trash lives in the primary bucket, so files elsewhere are deleted outright
+	if storage != h.s3Service {
+		if _, err := storage.StatFile(ctx, key); err != nil {
+			respondStorageError(w, err, "failed to delete file")
+			return
+		}
+		if err := storage.DeleteFile(ctx, key); err != nil {
+			h.logger.Error("failed to delete file", zap.String("bucket", storage.Name()), zap.String("key", key), zap.Error(err))
+			respondStorageError(w, err, "failed to delete file")
+			return
+		}
+		h.logger.Info("file deleted", zap.String("user", user.Name), zap.String("bucket", storage.Name()), zap.String("key", key))
+		h.publish(events.ActionDeleted, user, storage, key, "")
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data: MessageData{
+				Message: "file deleted",
+			},
+		})
+		return
+	}
+
+	if err := h.trashFile(ctx, user, key); err != nil {
+		h.logger.Warn("failed to move file to trash", zap.String("key", key), zap.Error(err))
+		respondStorageError(w, err, "failed to delete file")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "file moved to trash",
+		},
+	})
+}
+
+// maxBatchDeleteKeys caps how many keys one batch delete request may name
+const maxBatchDeleteKeys = 10000
+
+// BatchDeleteRequest is the request body of the batch delete endpoint
+type BatchDeleteRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// BatchDeleteFailure is a key that could not be deleted and why
+type BatchDeleteFailure struct {
+	Key   string `json:"key"`
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// BatchDeleteData is the payload of the batch delete endpoint
+type BatchDeleteData struct {
+	Deleted []string             `json:"deleted"`
+	Failed  []BatchDeleteFailure `json:"failed"`
+}
+
+// BatchDelete deletes several files in one request and reports the outcome per key
+func (h *Handler) BatchDelete(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	perm := auth.PermissionMap[user.Role]
+	if !perm.CanDelete {
+		h.logger.Warn("batch delete attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to delete files",
+		})
+		return
+	}
+
+	var req BatchDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	if len(req.Keys) == 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "keys are required",
+		})
+		return
+	}
+	if len(req.Keys) > maxBatchDeleteKeys {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("at most %d keys can be deleted at once", maxBatchDeleteKeys),
+		})
+		return
+	}
+
+	data := BatchDeleteData{
+		Deleted: []string{},
+		Failed:  []BatchDeleteFailure{},
+	}
+
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check legal holds",
+		})
+		return
+	}
+
+	// Every key is checked on its own so one refusal doesn't block the rest
+	seen := make(map[string]bool, len(req.Keys))
+	allowed := make([]string, 0, len(req.Keys))
+	for _, key := range req.Keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if err := validateKey(key); err != nil {
+			data.Failed = append(data.Failed, BatchDeleteFailure{Key: key, Error: err.Error()})
+			continue
+		}
+		if !canAccessKey(user, key) {
+			data.Failed = append(data.Failed, BatchDeleteFailure{Key: key, Error: "access denied"})
+			continue
+		}
+		if heldPrefixOf(held, key) != "" {
+			data.Failed = append(data.Failed, BatchDeleteFailure{Key: key, Error: "file is under legal hold", Code: CodeLegalHold})
+			continue
+		}
+		if locked, err := h.database.IsFileLocked(key); err != nil || locked {
+			if err != nil {
+				h.logger.Error("failed to check file lock", zap.String("key", key), zap.Error(err))
+			}
+			data.Failed = append(data.Failed, BatchDeleteFailure{Key: key, Error: "file is locked", Code: CodeLocked})
+			continue
+		}
+		allowed = append(allowed, key)
+	}
+
+	// Each file is moved to the trash on its own, so there is no bulk call to stop early
+	for _, key := range allowed {
+		if err := h.trashFile(r.Context(), user, key); err != nil {
+			h.logger.Warn("failed to move file to trash", zap.String("key", key), zap.Error(err))
+			_, message := storageStatus(err, "failed to delete file")
+			data.Failed = append(data.Failed, BatchDeleteFailure{Key: key, Error: message})
+			continue
+		}
+		data.Deleted = append(data.Deleted, key)
+	}
+
+	h.logger.Info("batch delete finished", zap.String("user", user.Name), zap.Int("deleted", len(data.Deleted)), zap.Int("failed", len(data.Failed)))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// DeletePrefix handles the recursive delete endpoint (admin only)
+func (h *Handler) DeletePrefix(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+
+	// An empty prefix matches every object, so wiping the bucket must be spelled out
+	if prefix == "" && r.URL.Query().Get("confirm") != "all" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "prefix parameter required; pass confirm=all to delete every object",
+		})
+		return
+	}
+
+	if prefix != "" {
+		if err := validatePrefix(prefix); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	if h.rejectHeldOverlap(w, prefix) {
+		return
+	}
+
+	if h.rejectLockedUnder(w, prefix) {
+		return
+	}
+
+	if h.approvals != nil && h.approvals.Required("delete_prefix") {
+		pending, err := h.approvals.Submit(user, "delete_prefix", prefix, deletePrefixPayload{
+			Prefix:      prefix,
+			RequestedBy: user.Name,
+		})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
+	deleted, err := h.deletePrefix(r.Context(), user, prefix)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Data: DeletePrefixData{
+				Prefix:  prefix,
+				Deleted: deleted,
+			},
+			Error: "failed to delete all files under prefix",
+		})
+		return
+	}
+	if h.approvals != nil {
+		h.approvals.Audit(user, "delete_prefix", prefix, strconv.Itoa(deleted))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: DeletePrefixData{
+			Prefix:  prefix,
+			Deleted: deleted,
+		},
+	})
+}
+
+// deletePrefixPayload is the stored payload of a deferred prefix delete
+type deletePrefixPayload struct {
+	Prefix      string `json:"prefix"`
+	RequestedBy string `json:"requested_by"`
+}
+
+// deletePrefix removes every object under prefix along with its metadata, on behalf of user
+func (h *Handler) deletePrefix(ctx context.Context, user *auth.User, prefix string) (int, error) {
+	h.logger.Warn("prefix delete started", zap.String("user", user.Name), zap.String("prefix", prefix))
+
+	deleted, err := h.s3Service.DeletePrefix(ctx, prefix)
+	if err != nil {
+		return deleted, err
+	}
+
+	if _, err := h.database.DeleteFileRecordsByPrefix(prefix); err != nil {
+		h.logger.Error("failed to delete file metadata", zap.String("prefix", prefix), zap.Error(err))
+	}
+	if deleted > 0 {
+		h.publish(events.ActionDeleted, user, h.s3Service, prefix, "")
+	}
+	return deleted, nil
+}
+
+// rejectHeld writes a 423 response and returns true if key is under a legal hold.
+// Holds that can't be checked are treated as present, so nothing is destroyed by mistake.
+func (h *Handler) rejectHeld(w http.ResponseWriter, key string) bool {
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check legal holds",
+		})
+		return true
+	}
+
+	if prefix := heldPrefixOf(held, key); prefix != "" {
+		h.logger.Warn("operation blocked by legal hold", zap.String("key", key), zap.String("hold", prefix))
+		respondJSON(w, http.StatusLocked, Response{
+			Success: false,
+			Error:   fmt.Sprintf("files under %q are under legal hold", prefix),
+			Code:    CodeLegalHold,
+		})
+		return true
+	}
+
+	return false
+}
+
+// rejectHeldOverlap is rejectHeld for operations on a whole prefix: any hold
+// inside or above the prefix blocks it
+func (h *Handler) rejectHeldOverlap(w http.ResponseWriter, prefix string) bool {
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check legal holds",
+		})
+		return true
+	}
+
+	for _, p := range held {
+		if strings.HasPrefix(p, prefix) || strings.HasPrefix(prefix, p) {
+			h.logger.Warn("prefix operation blocked by legal hold", zap.String("prefix", prefix), zap.String("hold", p))
+			respondJSON(w, http.StatusLocked, Response{
+				Success: false,
+				Error:   fmt.Sprintf("files under %q are under legal hold", p),
+				Code:    CodeLegalHold,
+			})
+			return true
+		}
+	}
+
+	return false
+}
+
+// rejectLocked writes a 423 response and returns true if key is locked. Like legal
+// holds, a lock that can't be checked is treated as present.
+func (h *Handler) rejectLocked(w http.ResponseWriter, key string) bool {
+	locked, err := h.database.IsFileLocked(key)
+	if err != nil {
+		h.logger.Error("failed to check file lock", zap.String("key", key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check file lock",
+		})
+		return true
+	}
+
+	if locked {
+		h.logger.Warn("operation blocked by file lock", zap.String("key", key))
+		respondJSON(w, http.StatusLocked, Response{
+			Success: false,
+			Error:   fmt.Sprintf("file %q is locked", key),
+			Code:    CodeLocked,
+		})
+		return true
+	}
+
+	return false
+}
+
+// rejectLockedUnder is rejectLocked for operations on a whole prefix: any locked
+// file under the prefix blocks it
+func (h *Handler) rejectLockedUnder(w http.ResponseWriter, prefix string) bool {
+	key, err := h.database.LockedFileUnder(prefix)
+	if err != nil {
+		h.logger.Error("failed to check file locks", zap.String("prefix", prefix), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check file locks",
+		})
+		return true
+	}
+
+	if key != "" {
+		h.logger.Warn("prefix operation blocked by file lock", zap.String("prefix", prefix), zap.String("key", key))
+		respondJSON(w, http.StatusLocked, Response{
+			Success: false,
+			Error:   fmt.Sprintf("file %q is locked", key),
+			Code:    CodeLocked,
+		})
+		return true
+	}
+
+	return false
+}
+
+// saveRecord stores the metadata of a new upload. The object is already stored,
+// so a failure is logged and the record is rebuilt from the key on the next listing.
+func (h *Handler) saveRecord(record *db.FileRecord) {
+	if err := h.database.SaveFileRecord(record); err != nil {
+		h.logger.Error("failed to save file metadata", zap.String("key", record.Key), zap.Error(err))
+	}
+}
+
+// recordFromListing builds the metadata of an object that has none, such as one uploaded
+// before metadata was tracked or whose record could not be saved, from what its key and
+// listing entry tell
+func recordFromListing(file service.File) *db.FileRecord {
+	record := &db.FileRecord{
+		Key:          file.Key,
+		OwnerID:      service.UserFromKey(file.Key),
+		OriginalName: service.OriginalName(file.Key),
+		Size:         file.Size,
+		UploadedAt:   time.Now(),
+	}
+	record.Category = service.Categorize("", record.OriginalName)
+	if !file.LastModified.IsZero() {
+		record.UploadedAt = file.LastModified
+	}
+	return record
+}
+
+// backfillRecord builds and stores metadata for an object that has none
+func (h *Handler) backfillRecord(file service.File) *db.FileRecord {
+	record := recordFromListing(file)
+	if err := h.database.BackfillFileRecord(record); err != nil {
+		h.logger.Warn("failed to backfill file metadata", zap.String("key", file.Key), zap.Error(err))
+	}
+	return record
+}
+
+// queueBackfill hands listed objects without metadata to RunRecordBackfill, so listing
+// stays a read. When the queue is full they are dropped; the next listing queues them again.
+func (h *Handler) queueBackfill(files []service.File) {
+	select {
+	case h.backfills <- files:
+	default:
+		h.logger.Debug("file metadata backfill queue full", zap.Int("files", len(files)))
+	}
+}
+
+// RunRecordBackfill stores metadata for the objects listings found without any, until
+// ctx is cancelled
+func (h *Handler) RunRecordBackfill(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case files := <-h.backfills:
+			for _, file := range files {
+				h.backfillRecord(file)
+			}
+		}
+	}
+}
+
+// heldPrefixes returns the key prefixes frozen by active legal holds. A hold on a
+// user freezes that user's folder.
+func (h *Handler) heldPrefixes() ([]string, error) {
+	holds, err := h.database.ListLegalHolds()
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([]string, 0, len(holds))
+	for _, hold := range holds {
+		if hold.Scope == db.HoldScopeUser {
+			prefixes = append(prefixes, service.UserPrefix(hold.Target))
+			continue
+		}
+		prefixes = append(prefixes, hold.Target)
+	}
+	return prefixes, nil
+}
+
+// canAccessKey reports whether user may read or change key: admins may use any key,
+// everyone else only keys in their own folder. Keys that aren't valid paths are refused
+// for everyone, so a caller that skipped validateKey can't be walked out of the folder
+// with users/<id>/../
+func canAccessKey(user *auth.User, key string) bool {
+	if !validPath(key) {
+		return false
+	}
+	return user.Role == auth.RoleAdmin || strings.HasPrefix(key, service.UserPrefix(user.ID))
+}
+
+// rejectForeign writes a 403 and returns true when user may not access key
+func (h *Handler) rejectForeign(w http.ResponseWriter, user *auth.User, key string) bool {
+	if canAccessKey(user, key) {
+		return false
+	}
+
+	h.logger.Warn("access to another user's file denied", zap.String("user", user.Name), zap.String("key", key))
+	respondJSON(w, http.StatusForbidden, Response{
+		Success: false,
+		Error:   "access denied",
+	})
+	return true
+}
+
+// heldPrefixOf returns the held prefix that covers key, or "" if none does
+func heldPrefixOf(held []string, key string) string {
+	for _, prefix := range held {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// nearDuplicates warns about keys that differ from key only by case, whitespace or
+// normalization, when the key policy asks for the warning
+func (h *Handler) nearDuplicates(r *http.Request, key string) DuplicateWarning {
+	if !h.keyPolicy.WarnNearDuplicates {
+		return DuplicateWarning{}
+	}
+	duplicates, err := h.s3Service.FindNearDuplicates(r.Context(), key)
+	if err != nil {
+		h.logger.Warn("near-duplicate check failed", zap.String("key", key), zap.Error(err))
+		return DuplicateWarning{}
+	}
+	if len(duplicates) == 0 {
+		return DuplicateWarning{}
+	}
+	return DuplicateWarning{
+		Warning:        "similar files already exist in this folder",
+		NearDuplicates: duplicates,
+	}
+}
+
+// validatePrefix rejects list prefixes that try to escape the key namespace
+func validatePrefix(prefix string) error {
+	if len(prefix) > maxKeyLength {
+		return fmt.Errorf("prefix is too long")
+	}
+	if !validPath(prefix) {
+		return fmt.Errorf("invalid prefix")
+	}
+	return nil
+}
+
+// validateKey applies the same rules to an object key, which must also be present.
+// Some S3-compatible backends resolve "." and ".." in keys, so a key like
+// users/me/../other/file could otherwise reach past the folder checks.
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("key parameter required")
+	}
+	if len(key) > maxKeyLength {
+		return fmt.Errorf("key is too long")
+	}
+	if !validPath(key) {
+		return fmt.Errorf("invalid key")
+	}
+	return nil
+}
+
+// maxKeyLength is the longest key S3 accepts, in bytes
+const maxKeyLength = 1024
+
+// validPath reports whether p stays inside the key namespace: relative, without
+// backslashes, "." or ".." segments or control characters
+func validPath(p string) bool {
+	if strings.HasPrefix(p, "/") || strings.Contains(p, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	for _, c := range p {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+	return true
 }
\ No newline at end of file
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultHealthCheckTimeout bounds each dependency check until SetHealthCheckTimeout is called
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// DependencyStatus is the result of checking one dependency
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReadinessResponse is returned by the readiness endpoint
+type ReadinessResponse struct {
+	Status    string                      `json:"status"`
+	Timestamp string                      `json:"timestamp"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+}
+
+// SetHealthCheckTimeout bounds each dependency check of HealthReady so a hung backend fails fast
+func (h *Handler) SetHealthCheckTimeout(timeout time.Duration) {
+	h.healthCheckTimeout = timeout
+}
+
+// HealthReady checks that S3 and the database answer and returns 503 if either does not
+func (h *Handler) HealthReady(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(context.Context) error{
+		"s3":       h.s3Service.Ping,
+		"database": h.database.Ping,
+	}
+
+	resp := ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Checks:    make(map[string]DependencyStatus, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), h.healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			status := DependencyStatus{
+				Status:    "up",
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			resp.Checks[name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, status := range resp.Checks {
+		if status.Status != "up" {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	respondJSON(w, code, resp)
+}
\ No newline at end of file
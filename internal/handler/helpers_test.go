@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/mail"
+	"s3-test-app/internal/ratelimit"
+	"s3-test-app/internal/service"
+)
+
+// testBucket is the bucket test handlers store files in
+const testBucket = "test-bucket"
+
+// testMaxUploadSize is the upload limit of test handlers
+const testMaxUploadSize = 1 << 20
+
+// newTestS3 creates an S3Service backed by a fake S3 server. configure may adjust
+// the S3 configuration before the service is created
+func newTestS3(t *testing.T, configure ...func(*config.S3Config)) (*service.S3Service, *fakes3.Server) {
+	t.Helper()
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+	cfg := &config.S3Config{
+		Endpoint:         fake.URL,
+		Region:           "us-east-1",
+		Bucket:           testBucket,
+		AccessKey:        "test",
+		SecretKey:        "test",
+		MaxAttempts:      1,
+		RetryMode:        config.RetryModeStandard,
+		OperationTimeout: 10 * time.Second,
+		DialTimeout:      time.Second,
+		MaxConcurrentOps: 8,
+		ConcurrencyWait:  time.Second,
+		ListConcurrency:  2,
+	}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	s3Svc, err := service.NewS3Service(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create S3 service: %v", err)
+	}
+	return s3Svc, fake
+}
+
+// newTestHandler creates a Handler over a fresh database and fake S3 bucket, passing
+// configure on to newTestS3
+func newTestHandler(t *testing.T, configure ...func(*config.S3Config)) (*Handler, *db.Database, *fakes3.Server) {
+	t.Helper()
+	database := newTestDatabase(t)
+	s3Svc, fake := newTestS3(t, configure...)
+	h := NewHandler(s3Svc, database, zap.NewNop(), config.KeyPolicyConfig{}, config.UploadTypeConfig{}, testMaxUploadSize, config.TrashConfig{Retention: 24 * time.Hour})
+	return h, database, fake
+}
+
+// newTestDatabase opens a fresh database in a temporary directory
+func newTestDatabase(t *testing.T) *db.Database {
+	t.Helper()
+	database, err := db.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// createTestUser stores a user whose password is "password" and returns it as the
+// authenticated user the handlers see
+func createTestUser(t *testing.T, database *db.Database, username string, role auth.Role) *auth.User {
+	t.Helper()
+	email := username + "@example.com"
+	if err := database.CreateUser(username+"-id", username, email, "password", role); err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return &auth.User{ID: username + "-id", Name: username, Email: email, Role: role}
+}
+
+// newTestTokenManager creates a token manager backed by database
+func newTestTokenManager(database *db.Database) *auth.TokenManager {
+	tokenManager := auth.NewTokenManager("test-secret")
+	tokenManager.SetUserStore(database)
+	tokenManager.SetRevocationStore(database)
+	tokenManager.SetAPITokenStore(database)
+	return tokenManager
+}
+
+// recordingMailer keeps sent messages so tests can read the links and tokens in them
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// last returns the most recently sent message
+func (m *recordingMailer) last(t *testing.T) mail.Message {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		t.Fatal("no email was sent")
+	}
+	return m.messages[len(m.messages)-1]
+}
+
+// newTestAuthHandler creates an AuthHandler with test defaults, returning its mailer
+func newTestAuthHandler(database *db.Database, tokenManager *auth.TokenManager, logger *zap.Logger) (*AuthHandler, *recordingMailer) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			PasswordResetTTL:     time.Hour,
+			EmailVerificationTTL: time.Hour,
+			TokenTTL:             time.Hour,
+			LoginMaxAttempts:     100,
+			LoginWindow:          time.Minute,
+		},
+		Server: config.ServerConfig{BaseURL: "http://localhost:8080"},
+	}
+	mailer := &recordingMailer{}
+	limiter := ratelimit.New(cfg.Auth.LoginMaxAttempts, cfg.Auth.LoginWindow)
+	return NewAuthHandler(tokenManager, database, logger, cfg, limiter, mailer), mailer
+}
+
+// jsonRequest builds a request with body encoded as JSON
+func jsonRequest(t *testing.T, method, target string, body any) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// asUser attaches user to the request context as the auth middleware would
+func asUser(r *http.Request, user *auth.User) *http.Request {
+	return r.WithContext(auth.SetUserInContext(r.Context(), user))
+}
+
+// decodeResponse reads the JSON envelope of a recorded response
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder) Response {
+	t.Helper()
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, rec.Body.String())
+	}
+	return resp
+}
+
+// decodeData reads the data of a successful JSON envelope into v
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	var resp struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v: %s", err, rec.Body.String())
+	}
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		t.Fatalf("failed to decode data: %v: %s", err, resp.Data)
+	}
+}
\ No newline at end of file
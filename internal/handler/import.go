@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// importSystemOwner owns the objects an import records when the request names no user
+const importSystemOwner = "system"
+
+// ImportBucketRequest asks for the objects under Prefix that have no metadata to be
+// recorded as owned by OwnerID, or by the system when it is empty. A dry run only counts
+// what would be imported.
+type ImportBucketRequest struct {
+	Prefix  string `json:"prefix"`
+	OwnerID string `json:"owner_id"`
+	DryRun  bool   `json:"dry_run"`
+}
+
+// ImportConflictData is an object whose existing metadata records a different size
+type ImportConflictData struct {
+	Key          string `json:"key"`
+	RecordedSize int64  `json:"recorded_size"`
+	ObjectSize   int64  `json:"object_size"`
+}
+
+// ImportJobData reports the progress of a bucket import
+type ImportJobData struct {
+	ID         string               `json:"id"`
+	Prefix     string               `json:"prefix"`
+	OwnerID    string               `json:"owner_id"`
+	DryRun     bool                 `json:"dry_run"`
+	Status     string               `json:"status"`
+	Cursor     string               `json:"cursor,omitempty"`
+	Scanned    int                  `json:"scanned"`
+	Imported   int                  `json:"imported"`
+	Skipped    int                  `json:"skipped"`
+	Conflicts  int                  `json:"conflicts"`
+	Error      string               `json:"error,omitempty"`
+	StartedBy  string               `json:"started_by"`
+	StartedAt  string               `json:"started_at"`
+	FinishedAt string               `json:"finished_at,omitempty"`
+	Conflicted []ImportConflictData `json:"conflicted,omitempty"`
+}
+
+// importJobData converts an import job and its conflicts to their API form
+func importJobData(job *db.ImportJob, conflicts []db.ImportConflict) ImportJobData {
+	data := ImportJobData{
+		ID:        job.ID,
+		Prefix:    job.Prefix,
+		OwnerID:   job.OwnerID,
+		DryRun:    job.DryRun,
+		Status:    job.Status,
+		Cursor:    job.Cursor,
+		Scanned:   job.Scanned,
+		Imported:  job.Imported,
+		Skipped:   job.Skipped,
+		Conflicts: job.Conflicts,
+		Error:     job.Error,
+		StartedBy: job.StartedBy,
+		StartedAt: job.StartedAt.Format(time.RFC3339),
+	}
+	if job.FinishedAt != nil {
+		data.FinishedAt = job.FinishedAt.Format(time.RFC3339)
+	}
+	for _, conflict := range conflicts {
+		data.Conflicted = append(data.Conflicted, ImportConflictData{
+			Key:          conflict.Key,
+			RecordedSize: conflict.RecordedSize,
+			ObjectSize:   conflict.ObjectSize,
+		})
+	}
+	return data
+}
+
+// ImportBucket starts a background import of the objects that were put in the bucket
+// without going through the app (admin only). Only one import runs at a time.
+func (h *Handler) ImportBucket(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+
+	var req ImportBucketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	if req.Prefix != "" {
+		if err := validatePrefix(req.Prefix); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.OwnerID == "" {
+		req.OwnerID = importSystemOwner
+	} else if _, err := h.database.GetUserByID(req.OwnerID); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "owner not found",
+		})
+		return
+	}
+
+	id, err := auth.NewRandomToken(12)
+	if err != nil {
+		h.logger.Error("failed to generate import id", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to start import",
+		})
+		return
+	}
+
+	job := &db.ImportJob{
+		ID:        id,
+		Prefix:    req.Prefix,
+		OwnerID:   req.OwnerID,
+		DryRun:    req.DryRun,
+		StartedBy: admin.ID,
+	}
+	if err := h.database.CreateImportJob(job); err != nil {
+		if errors.Is(err, db.ErrImportRunning) {
+			respondJSON(w, http.StatusConflict, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		h.logger.Error("failed to create import job", zap.String("prefix", req.Prefix), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to start import",
+		})
+		return
+	}
+
+	// The job is stored as running, so RunBucketImports picks it up after a restart even
+	// if this signal is lost
+	select {
+	case h.imports <- job.ID:
+	default:
+	}
+
+	h.logger.Info("bucket import started",
+		zap.String("user", admin.Name),
+		zap.String("import", job.ID),
+		zap.String("prefix", job.Prefix),
+		zap.String("owner_id", job.OwnerID),
+		zap.Bool("dry_run", job.DryRun),
+	)
+
+	respondJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Data:    importJobData(job, nil),
+	})
+}
+
+// GetImportJob reports the progress of a bucket import and the conflicts it found (admin only)
+func (h *Handler) GetImportJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	job, err := h.database.GetImportJob(id)
+	if err != nil {
+		if errors.Is(err, db.ErrImportJobNotFound) {
+			respondJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "import not found",
+			})
+			return
+		}
+		h.logger.Error("failed to get import job", zap.String("import", id), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to get import",
+		})
+		return
+	}
+
+	conflicts, err := h.database.ListImportConflicts(id)
+	if err != nil {
+		h.logger.Error("failed to list import conflicts", zap.String("import", id), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to get import",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    importJobData(job, conflicts),
+	})
+}
+
+// RunBucketImports runs bucket imports as they are started, until ctx is cancelled.
+// Imports a restart interrupted are resumed first, from their last saved page.
+func (h *Handler) RunBucketImports(ctx context.Context) {
+	jobs, err := h.database.RunningImportJobs()
+	if err != nil {
+		h.logger.Error("failed to load unfinished imports", zap.Error(err))
+	}
+	for _, job := range jobs {
+		h.logger.Info("resuming bucket import", zap.String("import", job.ID), zap.String("cursor", job.Cursor))
+		h.runImport(ctx, job)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-h.imports:
+			job, err := h.database.GetImportJob(id)
+			if err != nil {
+				h.logger.Error("failed to load import job", zap.String("import", id), zap.Error(err))
+				continue
+			}
+			if job.Status == db.ImportRunning {
+				h.runImport(ctx, job)
+			}
+		}
+	}
+}
+
+// runImport walks the bucket for job, saving its progress after every page. Objects
+// without metadata are recorded; objects whose metadata records another size are
+// reported as conflicts and left alone. When ctx is cancelled the job stays running,
+// to be resumed by the next RunBucketImports.
+func (h *Handler) runImport(ctx context.Context, job *db.ImportJob) {
+	err := h.s3Service.WalkFiles(ctx, job.Prefix, job.Cursor, func(files []service.File) error {
+		keys := make([]string, len(files))
+		for i, file := range files {
+			keys[i] = file.Key
+		}
+		records, err := h.database.FileRecordsByKeys(keys)
+		if err != nil {
+			return err
+		}
+
+		var conflicts []db.ImportConflict
+		for _, file := range files {
+			// Folder markers aren't files
+			if strings.HasSuffix(file.Key, "/") && file.Size == 0 {
+				continue
+			}
+			job.Scanned++
+
+			existing := records[file.Key]
+			switch {
+			case existing == nil:
+				if !job.DryRun {
+					if err := h.database.BackfillFileRecord(h.importRecord(ctx, job, file)); err != nil {
+						return err
+					}
+				}
+				job.Imported++
+			case existing.Size != file.Size:
+				conflicts = append(conflicts, db.ImportConflict{
+					Key:          file.Key,
+					RecordedSize: existing.Size,
+					ObjectSize:   file.Size,
+				})
+				job.Conflicts++
+			default:
+				job.Skipped++
+			}
+		}
+
+		job.Cursor = files[len(files)-1].Key
+		return h.database.SaveImportProgress(job, conflicts)
+	})
+
+	if err != nil && ctx.Err() != nil {
+		h.logger.Info("bucket import interrupted", zap.String("import", job.ID), zap.String("cursor", job.Cursor))
+		return
+	}
+
+	status, errMsg := db.ImportCompleted, ""
+	if err != nil {
+		h.logger.Error("bucket import failed", zap.String("import", job.ID), zap.String("cursor", job.Cursor), zap.Error(err))
+		status, errMsg = db.ImportFailed, err.Error()
+	}
+	if err := h.database.FinishImportJob(job, status, errMsg); err != nil {
+		h.logger.Error("failed to finish import job", zap.String("import", job.ID), zap.Error(err))
+	}
+
+	details := fmt.Sprintf("status=%s owner=%s dry_run=%t scanned=%d imported=%d skipped=%d conflicts=%d",
+		status, job.OwnerID, job.DryRun, job.Scanned, job.Imported, job.Skipped, job.Conflicts)
+	if err := h.database.RecordAudit(db.AuditEntry{
+		Actor:   job.StartedBy,
+		Action:  "import_bucket",
+		Target:  job.Prefix,
+		Details: details,
+	}); err != nil {
+		h.logger.Error("failed to record audit entry", zap.String("action", "import_bucket"), zap.Error(err))
+	}
+
+	h.logger.Info("bucket import finished",
+		zap.String("import", job.ID),
+		zap.String("status", status),
+		zap.Int("scanned", job.Scanned),
+		zap.Int("imported", job.Imported),
+		zap.Int("skipped", job.Skipped),
+		zap.Int("conflicts", job.Conflicts),
+	)
+}
+
+// importRecord builds the metadata an import stores for file. The content type comes
+// from the extension, or from the object itself when the extension is unknown.
+func (h *Handler) importRecord(ctx context.Context, job *db.ImportJob, file service.File) *db.FileRecord {
+	record := recordFromListing(file)
+	record.OwnerID = job.OwnerID
+
+	record.ContentType = mime.TypeByExtension(path.Ext(record.OriginalName))
+	if record.ContentType == "" {
+		info, err := h.s3Service.StatFile(ctx, file.Key)
+		if err != nil {
+			h.logger.Warn("failed to read content type of imported object", zap.String("key", file.Key), zap.Error(err))
+		} else {
+			record.ContentType = info.ContentType
+		}
+	}
+	record.Category = service.Categorize(record.ContentType, record.OriginalName)
+	return record
+}
\ No newline at end of file
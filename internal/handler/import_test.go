@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+func startImport(t *testing.T, h *Handler, admin *auth.User, req ImportBucketRequest) (*httptest.ResponseRecorder, ImportJobData) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ImportBucket(rec, asUser(jsonRequest(t, http.MethodPost, "/api/admin/import-bucket", req), admin))
+	var data ImportJobData
+	if rec.Code == http.StatusAccepted {
+		decodeData(t, rec, &data)
+	}
+	return rec, data
+}
+
+func getImport(t *testing.T, h *Handler, admin *auth.User, id string) (*httptest.ResponseRecorder, ImportJobData) {
+	t.Helper()
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	r := httptest.NewRequest(http.MethodGet, "/api/admin/import-bucket/"+id, nil)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	h.GetImportJob(rec, asUser(r, admin))
+	var data ImportJobData
+	if rec.Code == http.StatusOK {
+		decodeData(t, rec, &data)
+	}
+	return rec, data
+}
+
+// runImports runs RunBucketImports until the import id has finished and returns its report
+func runImports(t *testing.T, h *Handler, admin *auth.User, id string) ImportJobData {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.RunBucketImports(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec, data := getImport(t, h, admin, id)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+		}
+		if data.Status != db.ImportRunning {
+			return data
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("import still running: %+v", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestImportBucketRecordsObjectsWithoutMetadata(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	owner := createTestUser(t, database, "alice", auth.RoleUploader)
+	fake.SetPageSize(2)
+
+	fake.Put(testBucket, "legacy/1712345-report.pdf", []byte("report"))
+	fake.Put(testBucket, "legacy/README", []byte("readme"))
+	fake.Put(testBucket, "legacy/photos/", nil)
+	fake.Put(testBucket, "legacy/known.txt", []byte("known"))
+	fake.Put(testBucket, "legacy/changed.txt", []byte("changed since"))
+	fake.Put(testBucket, "elsewhere/skipped.txt", []byte("outside the prefix"))
+	for key, size := range map[string]int64{"legacy/known.txt": 5, "legacy/changed.txt": 3} {
+		if err := database.SaveFileRecord(&db.FileRecord{Key: key, OwnerID: admin.ID, OriginalName: "kept", Size: size, UploadedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveFileRecord: %v", err)
+		}
+	}
+
+	rec, started := startImport(t, h, admin, ImportBucketRequest{Prefix: "legacy/", OwnerID: owner.ID})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	report := runImports(t, h, admin, started.ID)
+
+	if report.Status != db.ImportCompleted || report.Scanned != 4 || report.Imported != 2 || report.Skipped != 1 || report.Conflicts != 1 {
+		t.Errorf("report = %+v, want 4 scanned, 2 imported, 1 skipped and 1 conflict", report)
+	}
+	if len(report.Conflicted) != 1 || report.Conflicted[0] != (ImportConflictData{Key: "legacy/changed.txt", RecordedSize: 3, ObjectSize: 13}) {
+		t.Errorf("conflicted = %+v, want legacy/changed.txt recorded at 3 bytes", report.Conflicted)
+	}
+
+	record, err := database.GetFileRecord("legacy/1712345-report.pdf")
+	if err != nil || record == nil {
+		t.Fatalf("GetFileRecord: %v, %v", record, err)
+	}
+	if record.OwnerID != owner.ID || record.OriginalName != "report.pdf" || record.ContentType != "application/pdf" || record.Size != 6 {
+		t.Errorf("imported record = %+v, want report.pdf owned by alice", record)
+	}
+	if record, _ := database.GetFileRecord("legacy/README"); record == nil || record.OwnerID != owner.ID {
+		t.Errorf("object without an extension: record = %+v, want it imported", record)
+	}
+	if n := fake.Requests("HeadObject"); n != 1 {
+		t.Errorf("made %d HeadObject requests, want 1 for the object without an extension", n)
+	}
+	if record, _ := database.GetFileRecord("legacy/changed.txt"); record == nil || record.Size != 3 || record.OriginalName != "kept" {
+		t.Errorf("conflicting record = %+v, want it left alone", record)
+	}
+	for _, key := range []string{"legacy/photos/", "elsewhere/skipped.txt"} {
+		if record, _ := database.GetFileRecord(key); record != nil {
+			t.Errorf("%s was imported", key)
+		}
+	}
+}
+
+func TestImportBucketDefaultsToSystemOwner(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "orphan.txt", []byte("data"))
+
+	_, started := startImport(t, h, admin, ImportBucketRequest{})
+	runImports(t, h, admin, started.ID)
+
+	if record, _ := database.GetFileRecord("orphan.txt"); record == nil || record.OwnerID != importSystemOwner {
+		t.Errorf("record = %+v, want it owned by %s", record, importSystemOwner)
+	}
+}
+
+func TestImportBucketDryRunWritesNothing(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "legacy/a.txt", []byte("a"))
+	fake.Put(testBucket, "legacy/b", []byte("b"))
+
+	_, started := startImport(t, h, admin, ImportBucketRequest{Prefix: "legacy/", DryRun: true})
+	report := runImports(t, h, admin, started.ID)
+
+	if report.Status != db.ImportCompleted || report.Imported != 2 || !report.DryRun {
+		t.Errorf("report = %+v, want a completed dry run counting 2 imports", report)
+	}
+	for _, key := range []string{"legacy/a.txt", "legacy/b"} {
+		if record, _ := database.GetFileRecord(key); record != nil {
+			t.Errorf("dry run stored a record for %s", key)
+		}
+	}
+	if n := fake.Requests("HeadObject"); n != 0 {
+		t.Errorf("dry run made %d HeadObject requests, want 0", n)
+	}
+}
+
+func TestImportBucketResumesFromCursor(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	for _, key := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		fake.Put(testBucket, key, []byte("data"))
+	}
+
+	// An import interrupted by a restart after the page ending at b.txt
+	job := &db.ImportJob{ID: "interrupted", OwnerID: importSystemOwner, StartedBy: admin.ID}
+	if err := database.CreateImportJob(job); err != nil {
+		t.Fatalf("CreateImportJob: %v", err)
+	}
+	job.Cursor, job.Scanned, job.Imported = "b.txt", 2, 2
+	if err := database.SaveImportProgress(job, nil); err != nil {
+		t.Fatalf("SaveImportProgress: %v", err)
+	}
+
+	report := runImports(t, h, admin, job.ID)
+
+	if report.Status != db.ImportCompleted || report.Scanned != 4 || report.Imported != 4 || report.Cursor != "d.txt" {
+		t.Errorf("report = %+v, want the counts carried over and the walk finished at d.txt", report)
+	}
+	for key, want := range map[string]bool{"a.txt": false, "b.txt": false, "c.txt": true, "d.txt": true} {
+		if record, _ := database.GetFileRecord(key); (record != nil) != want {
+			t.Errorf("%s recorded = %t, want %t", key, record != nil, want)
+		}
+	}
+}
+
+func TestImportBucketRejectsSecondImportAndUnknownOwner(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	if rec, _ := startImport(t, h, admin, ImportBucketRequest{OwnerID: "nobody"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown owner: status = %d, want 400", rec.Code)
+	}
+	if rec, _ := startImport(t, h, admin, ImportBucketRequest{Prefix: "../"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid prefix: status = %d, want 400", rec.Code)
+	}
+
+	// Nothing runs the first import, so it stays running
+	if rec, _ := startImport(t, h, admin, ImportBucketRequest{Prefix: service.UserPrefix(admin.ID)}); rec.Code != http.StatusAccepted {
+		t.Fatalf("first import: status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := startImport(t, h, admin, ImportBucketRequest{}); rec.Code != http.StatusConflict {
+		t.Errorf("second import: status = %d, want 409", rec.Code)
+	}
+
+	if rec, _ := getImport(t, h, admin, "missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown import: status = %d, want 404", rec.Code)
+	}
+}
\ No newline at end of file
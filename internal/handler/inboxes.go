@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+const (
+	// inboxDefaultTTL is how long an inbox accepts uploads when the request doesn't say
+	inboxDefaultTTL = 7 * 24 * time.Hour
+	// inboxMaxTTL caps how long an inbox may accept uploads
+	inboxMaxTTL = 30 * 24 * time.Hour
+	// inboxTokenSize is the number of random bytes in an inbox token
+	inboxTokenSize = 32
+)
+
+// CreateInboxRequest asks for a token that lets someone without an account upload files
+// under Prefix. MaxFiles defaults to one file and MaxBytes to the maximum upload size.
+type CreateInboxRequest struct {
+	Prefix    string `json:"prefix"`
+	ExpiresIn string `json:"expires_in"`
+	MaxFiles  int    `json:"max_files"`
+	MaxBytes  int64  `json:"max_bytes"`
+}
+
+// InboxData describes a newly created inbox. The token is only ever shown here
+type InboxData struct {
+	ID        string `json:"id"`
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	Prefix    string `json:"prefix"`
+	ExpiresAt string `json:"expires_at"`
+	MaxFiles  int    `json:"max_files"`
+	MaxBytes  int64  `json:"max_bytes"`
+}
+
+// CreateInbox issues an upload inbox token (admin only)
+func (h *Handler) CreateInbox(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	var req CreateInboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	if req.Prefix == "" || !strings.HasSuffix(req.Prefix, "/") {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "prefix must be a folder ending in /",
+		})
+		return
+	}
+	if err := validatePrefix(req.Prefix); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ttl := inboxDefaultTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > inboxMaxTTL {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   fmt.Sprintf("expires_in must be a positive duration of at most %s", inboxMaxTTL),
+			})
+			return
+		}
+		ttl = d
+	}
+
+	if req.MaxFiles < 0 || req.MaxBytes < 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "max_files and max_bytes must not be negative",
+		})
+		return
+	}
+	if req.MaxFiles == 0 {
+		req.MaxFiles = 1
+	}
+	if req.MaxBytes == 0 {
+		req.MaxBytes = h.maxUploadSize
+	}
+
+	id, err := auth.NewRandomToken(12)
+	if err != nil {
+		h.logger.Error("failed to generate inbox id", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create inbox",
+		})
+		return
+	}
+	token, err := auth.NewRandomToken(inboxTokenSize)
+	if err != nil {
+		h.logger.Error("failed to generate inbox token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create inbox",
+		})
+		return
+	}
+
+	now := time.Now()
+	inbox := &db.Inbox{
+		ID:        id,
+		Prefix:    req.Prefix,
+		CreatedBy: user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxFiles:  req.MaxFiles,
+		MaxBytes:  req.MaxBytes,
+	}
+	if err := h.database.CreateInbox(token, inbox); err != nil {
+		h.logger.Error("failed to create inbox", zap.String("prefix", req.Prefix), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create inbox",
+		})
+		return
+	}
+
+	h.logger.Info("inbox created",
+		zap.String("user", user.Name),
+		zap.String("inbox", id),
+		zap.String("prefix", req.Prefix),
+		zap.Time("expires_at", inbox.ExpiresAt),
+		zap.Int("max_files", inbox.MaxFiles),
+		zap.Int64("max_bytes", inbox.MaxBytes),
+	)
+
+	respondJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: InboxData{
+			ID:        id,
+			Token:     token,
+			URL:       h.baseURL + "/inbox/" + token + "/upload",
+			Prefix:    inbox.Prefix,
+			ExpiresAt: inbox.ExpiresAt.Format(time.RFC3339),
+			MaxFiles:  inbox.MaxFiles,
+			MaxBytes:  inbox.MaxBytes,
+		},
+	})
+}
+
+// InboxUpload stores files sent to an inbox by someone without an account. Each file is
+// counted against the inbox's limits before it is stored, and the inbox is consumed once
+// they are used up. Files are recorded as owned by the inbox.
+func (h *Handler) InboxUpload(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	inbox, err := h.database.GetInbox(token)
+	if err != nil {
+		if errors.Is(err, db.ErrInboxNotFound) {
+			h.logger.Warn("upload to unknown inbox", zap.String("remote_addr", r.RemoteAddr))
+			respondJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "inbox not found",
+			})
+			return
+		}
+		h.logger.Error("failed to get inbox", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to upload file",
+		})
+		return
+	}
+
+	if !inbox.Available(time.Now()) {
+		h.logger.Warn("upload to unavailable inbox", zap.String("inbox", inbox.ID), zap.String("remote_addr", r.RemoteAddr))
+		respondJSON(w, http.StatusGone, Response{
+			Success: false,
+			Error:   db.ErrInboxGone.Error(),
+			Code:    CodeInboxGone,
+		})
+		return
+	}
+
+	// The body can't be larger than what the inbox has room for
+	remaining := inbox.MaxBytes - inbox.ByteCount
+	if r.ContentLength > remaining+multipartOverhead {
+		respondJSON(w, http.StatusRequestEntityTooLarge, Response{
+			Success: false,
+			Error:   fmt.Sprintf("upload exceeds the %d bytes left in the inbox", remaining),
+			Code:    CodeTooLarge,
+		})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, remaining+multipartOverhead)
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondJSON(w, http.StatusRequestEntityTooLarge, Response{
+				Success: false,
+				Error:   fmt.Sprintf("upload exceeds the %d bytes left in the inbox", remaining),
+				Code:    CodeTooLarge,
+			})
+			return
+		}
+		h.logger.Warn("failed to parse inbox upload", zap.String("inbox", inbox.ID), zap.Error(err))
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "failed to parse form",
+		})
+		return
+	}
+
+	headers := append(r.MultipartForm.File["file"], r.MultipartForm.File["files[]"]...)
+	if len(headers) == 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "no file part in form",
+			Code:    CodeNoFilePart,
+		})
+		return
+	}
+
+	// The inbox uploads as a user of its own, so records and events name it
+	uploader := &auth.User{ID: inbox.OwnerID(), Name: inbox.OwnerID(), Role: auth.RoleUploader}
+	upload := uploadParams{
+		storage:      h.s3Service,
+		storageClass: service.DefaultStorageClass,
+		prefix:       inbox.Prefix,
+		skipQuota:    true,
+	}
+
+	// Whether the inbox was used up is only known once every file has been counted
+	defer func() {
+		if after, err := h.database.GetInbox(token); err == nil && after.ConsumedAt != nil {
+			h.logger.Info("inbox consumed", zap.String("inbox", inbox.ID), zap.Int("files", after.FileCount), zap.Int64("bytes", after.ByteCount))
+		}
+	}()
+
+	// A single file reports its failure through the status, like a regular upload
+	if len(headers) == 1 {
+		data, err := h.storeInboxUpload(r, token, inbox, uploader, headers[0], headers[0].Filename, upload)
+		if err != nil {
+			var uerr *uploadError
+			errors.As(err, &uerr)
+			if uerr.code == CodeStorageBusy {
+				w.Header().Set("Retry-After", storageBusyRetryAfter)
+			}
+			respondJSON(w, uerr.status, Response{
+				Success: false,
+				Error:   uerr.message,
+				Code:    uerr.code,
+			})
+			return
+		}
+
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data:    data,
+		})
+		return
+	}
+
+	batch := UploadBatchData{
+		Files: make([]UploadResult, 0, len(headers)),
+	}
+	used := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		data, err := h.storeInboxUpload(r, token, inbox, uploader, header, uniqueName(used, header.Filename), upload)
+		if err != nil {
+			var uerr *uploadError
+			errors.As(err, &uerr)
+			batch.Failed++
+			batch.Files = append(batch.Files, UploadResult{
+				Success:    false,
+				Error:      uerr.message,
+				Code:       uerr.code,
+				UploadData: UploadData{Filename: header.Filename, Size: header.Size},
+			})
+			continue
+		}
+		batch.Uploaded++
+		batch.Files = append(batch.Files, UploadResult{
+			Success:    true,
+			UploadData: data,
+		})
+	}
+
+	// Per-file failures are reported in the payload rather than through the status
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    batch,
+	})
+}
+
+// storeInboxUpload reserves room for one file in the inbox and stores it, giving the
+// reservation back when the file isn't stored
+func (h *Handler) storeInboxUpload(r *http.Request, token string, inbox *db.Inbox, uploader *auth.User, header *multipart.FileHeader, name string, params uploadParams) (UploadData, error) {
+	if err := h.database.ReserveInboxUpload(token, header.Size); err != nil {
+		switch {
+		case errors.Is(err, db.ErrInboxGone):
+			h.logger.Warn("inbox upload refused, inbox used up or expired", zap.String("inbox", inbox.ID), zap.String("filename", header.Filename), zap.String("remote_addr", r.RemoteAddr))
+			return UploadData{}, &uploadError{http.StatusGone, err.Error(), CodeInboxGone}
+		case errors.Is(err, db.ErrInboxTooLarge):
+			h.logger.Warn("inbox upload refused, not enough space left", zap.String("inbox", inbox.ID), zap.String("filename", header.Filename), zap.Int64("size", header.Size), zap.String("remote_addr", r.RemoteAddr))
+			return UploadData{}, &uploadError{http.StatusRequestEntityTooLarge, err.Error(), CodeTooLarge}
+		}
+		h.logger.Error("failed to reserve inbox upload", zap.String("inbox", inbox.ID), zap.Error(err))
+		return UploadData{}, &uploadError{http.StatusInternalServerError, "failed to upload file", ""}
+	}
+
+	data, err := h.storeUpload(r, uploader, header, name, params)
+	if err != nil {
+		if releaseErr := h.database.ReleaseInboxUpload(token, header.Size); releaseErr != nil {
+			h.logger.Error("failed to release inbox upload", zap.String("inbox", inbox.ID), zap.Error(releaseErr))
+		}
+		h.logger.Warn("inbox upload failed", zap.String("inbox", inbox.ID), zap.String("filename", header.Filename), zap.String("remote_addr", r.RemoteAddr), zap.Error(err))
+		return UploadData{}, err
+	}
+
+	h.logger.Info("inbox upload stored",
+		zap.String("inbox", inbox.ID),
+		zap.String("key", data.Key),
+		zap.String("filename", header.Filename),
+		zap.Int64("size", header.Size),
+		zap.String("sha256", data.SHA256),
+		zap.String("remote_addr", r.RemoteAddr),
+	)
+	return data, nil
+}
\ No newline at end of file
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+)
+
+// createInbox issues an inbox as admin and returns it
+func createInbox(t *testing.T, h *Handler, admin *auth.User, req CreateInboxRequest) InboxData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.CreateInbox(rec, asUser(jsonRequest(t, http.MethodPost, "/api/admin/inboxes", req), admin))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create inbox status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	var data InboxData
+	decodeData(t, rec, &data)
+	return data
+}
+
+// inboxUpload sends files to the inbox issued under token without any user
+func inboxUpload(t *testing.T, h *Handler, token string, files ...testFile) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.InboxUpload(rec, withToken(uploadRequest(t, nil, files...), token))
+	return rec
+}
+
+func expectInboxGone(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusGone {
+		t.Fatalf("status = %d, want 410: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeInboxGone {
+		t.Errorf("code = %q, want %q", resp.Code, CodeInboxGone)
+	}
+}
+
+func TestInboxUploadLandsUnderInboxPrefix(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	inbox := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/acme/"})
+
+	rec := inboxUpload(t, h, inbox.Token, testFile{name: "invoice.txt", content: []byte("amount due")})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data UploadData
+	decodeData(t, rec, &data)
+
+	if !strings.HasPrefix(data.Key, "incoming/acme/") {
+		t.Errorf("key = %q, want it under the inbox prefix", data.Key)
+	}
+	if obj := fake.Get(testBucket, data.Key); obj == nil || string(obj.Data) != "amount due" {
+		t.Errorf("object %s was not stored", data.Key)
+	}
+	record, err := database.GetFileRecord(data.Key)
+	if err != nil || record == nil {
+		t.Fatalf("GetFileRecord = %v, %v", record, err)
+	}
+	if record.OwnerID != "inbox:"+inbox.ID {
+		t.Errorf("owner = %q, want the inbox", record.OwnerID)
+	}
+}
+
+func TestInboxUploadFileCountLimit(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	inbox := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/", MaxFiles: 2})
+
+	for _, name := range []string{"first.txt", "second.txt"} {
+		if rec := inboxUpload(t, h, inbox.Token, testFile{name: name, content: []byte("notes")}); rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	expectInboxGone(t, inboxUpload(t, h, inbox.Token, testFile{name: "third.txt", content: []byte("notes")}))
+
+	if keys := fake.Keys(testBucket); len(keys) != 2 {
+		t.Errorf("bucket holds %v, want the two files the inbox allowed", keys)
+	}
+}
+
+func TestInboxUploadBatchStopsAtFileCount(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	inbox := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/", MaxFiles: 2})
+
+	rec := inboxUpload(t, h, inbox.Token,
+		testFile{name: "a.txt", content: []byte("a")},
+		testFile{name: "b.txt", content: []byte("b")},
+		testFile{name: "c.txt", content: []byte("c")},
+	)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var batch UploadBatchData
+	decodeData(t, rec, &batch)
+	if batch.Uploaded != 2 || batch.Failed != 1 || batch.Files[2].Code != CodeInboxGone {
+		t.Errorf("batch = %+v, want the third file refused with %s", batch, CodeInboxGone)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 2 {
+		t.Errorf("bucket holds %v, want two files", keys)
+	}
+}
+
+func TestInboxUploadByteLimit(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	inbox := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/", MaxFiles: 10, MaxBytes: 10})
+
+	if rec := inboxUpload(t, h, inbox.Token, testFile{name: "six.bin", content: make([]byte, 6)}); rec.Code != http.StatusOK {
+		t.Fatalf("first upload: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := inboxUpload(t, h, inbox.Token, testFile{name: "five.bin", content: make([]byte, 5)})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload past the allowance: status = %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeTooLarge {
+		t.Errorf("code = %q, want %q", resp.Code, CodeTooLarge)
+	}
+
+	// Filling the allowance exactly uses the inbox up
+	if rec := inboxUpload(t, h, inbox.Token, testFile{name: "four.bin", content: make([]byte, 4)}); rec.Code != http.StatusOK {
+		t.Fatalf("upload filling the allowance: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	expectInboxGone(t, inboxUpload(t, h, inbox.Token, testFile{name: "one.bin", content: make([]byte, 1)}))
+
+	if keys := fake.Keys(testBucket); len(keys) != 2 {
+		t.Errorf("bucket holds %v, want the two files that fit", keys)
+	}
+}
+
+func TestInboxUploadIgnoresCreatorQuota(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	h.SetDefaultQuota(1)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	inbox := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/"})
+
+	// The inbox's own allowance bounds it, not a user's quota
+	if rec := inboxUpload(t, h, inbox.Token, testFile{name: "notes.txt", content: []byte("notes")}); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestInboxUploadRefusesUnavailableInbox(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	// The API only issues inboxes that expire in the future, so age one in the database
+	now := time.Now()
+	expired := &db.Inbox{ID: "expired", Prefix: "incoming/", CreatedBy: admin.ID, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour), MaxFiles: 5, MaxBytes: testMaxUploadSize}
+	if err := database.CreateInbox("expired-token", expired); err != nil {
+		t.Fatalf("CreateInbox: %v", err)
+	}
+	expectInboxGone(t, inboxUpload(t, h, "expired-token", testFile{name: "late.txt", content: []byte("notes")}))
+
+	used := createInbox(t, h, admin, CreateInboxRequest{Prefix: "incoming/"})
+	if rec := inboxUpload(t, h, used.Token, testFile{name: "first.txt", content: []byte("notes")}); rec.Code != http.StatusOK {
+		t.Fatalf("first upload: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	expectInboxGone(t, inboxUpload(t, h, used.Token, testFile{name: "again.txt", content: []byte("notes")}))
+
+	rec := inboxUpload(t, h, "never-issued", testFile{name: "notes.txt", content: []byte("notes")})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token: status = %d, want 404", rec.Code)
+	}
+
+	if keys := fake.Keys(testBucket); len(keys) != 1 {
+		t.Errorf("bucket holds %v, want only the upload the used-up inbox took", keys)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+// invalidKeys are keys every key-taking endpoint must refuse before touching storage
+func invalidKeys(user *auth.User) map[string]string {
+	prefix := service.UserPrefix(user.ID)
+	return map[string]string{
+		"empty":            "",
+		"dot dot":          prefix + "../other-id/secret.txt",
+		"dot":              prefix + "./notes.txt",
+		"absolute":         "/" + prefix + "notes.txt",
+		"backslash":        prefix + `..\other-id\secret.txt`,
+		"control":          prefix + "notes\x00.txt",
+		"too long":         prefix + strings.Repeat("a", 1024),
+		"trailing dot dot": prefix + "..",
+	}
+}
+
+func TestKeyEndpointsRejectInvalidKeys(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	valid := service.UserPrefix(admin.ID) + "1712345-notes.txt"
+
+	query := func(handler http.HandlerFunc, method string) func(key string) *httptest.ResponseRecorder {
+		return func(key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			handler(rec, asUser(httptest.NewRequest(method, "/?key="+url.QueryEscape(key), nil), admin))
+			return rec
+		}
+	}
+	body := func(handler http.HandlerFunc, req func(key string) any) func(key string) *httptest.ResponseRecorder {
+		return func(key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			handler(rec, asUser(jsonRequest(t, http.MethodPost, "/", req(key)), admin))
+			return rec
+		}
+	}
+
+	endpoints := map[string]func(key string) *httptest.ResponseRecorder{
+		"download":      query(h.DownloadFile, http.MethodGet),
+		"stat":          query(h.StatFile, http.MethodGet),
+		"delete":        query(h.DeleteFile, http.MethodDelete),
+		"get tags":      query(h.GetTags, http.MethodGet),
+		"list versions": query(h.ListVersions, http.MethodGet),
+		"restore trash": query(h.RestoreTrash, http.MethodPost),
+		"set tags": func(key string) *httptest.ResponseRecorder {
+			return setTags(t, h, admin, key, map[string]string{"project": "apollo"})
+		},
+		"rename from": body(h.RenameFile, func(key string) any { return RenameRequest{From: key, To: valid + ".renamed"} }),
+		"rename to":   body(h.RenameFile, func(key string) any { return RenameRequest{From: valid, To: key} }),
+		"move":        body(h.MoveFiles, func(key string) any { return MoveRequest{Key: key, Destination: "archive/"} }),
+		"lock":        body(h.LockFile, func(key string) any { return LockRequest{Key: key} }),
+		"share":       body(h.CreateShare, func(key string) any { return CreateShareRequest{Key: key} }),
+		"restore version": body(h.RestoreVersion, func(key string) any {
+			return RestoreVersionRequest{Key: key, VersionID: "v1"}
+		}),
+		"confirm upload": body(h.ConfirmUpload, func(key string) any { return ConfirmUploadRequest{Key: key} }),
+	}
+
+	fake.Put(testBucket, valid, []byte("notes"))
+	for name, call := range endpoints {
+		for kind, key := range invalidKeys(admin) {
+			t.Run(name+"/"+kind, func(t *testing.T) {
+				if rec := call(key); rec.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+				}
+			})
+		}
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 1 || keys[0] != valid {
+		t.Errorf("bucket holds %v, want only %s", keys, valid)
+	}
+}
+
+func TestBatchDeleteRejectsInvalidKeysPerKey(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	valid := service.UserPrefix(admin.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, valid, []byte("notes"))
+
+	traversal := service.UserPrefix(admin.ID) + "../other-id/secret.txt"
+	rec := httptest.NewRecorder()
+	h.BatchDelete(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/batch-delete", BatchDeleteRequest{Keys: []string{traversal, valid}}), admin))
+	var data BatchDeleteData
+	decodeData(t, rec, &data)
+	if len(data.Deleted) != 1 || data.Deleted[0] != valid {
+		t.Errorf("deleted = %v, want only %s", data.Deleted, valid)
+	}
+	if len(data.Failed) != 1 || data.Failed[0].Key != traversal || data.Failed[0].Error != "invalid key" {
+		t.Errorf("failed = %+v, want %s refused as an invalid key", data.Failed, traversal)
+	}
+}
+
+func TestUploadKeepsAdversarialNamesInUserFolder(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	prefix := service.UserPrefix(user.ID)
+
+	for _, name := range []string{
+		`..\..\win.txt`,
+		"..",
+		"invoice\u202etxt.exe",
+		strings.Repeat("a", 300) + ".txt",
+		strings.Repeat("한", 150) + ".txt",
+		"a\xffb.txt",
+	} {
+		rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: name, content: []byte("notes")}))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: status = %d, want 200: %s", name, rec.Code, rec.Body.String())
+			continue
+		}
+		var data UploadData
+		decodeData(t, rec, &data)
+		rest, ok := strings.CutPrefix(data.Key, prefix)
+		if !ok || strings.Contains(rest, "/") || validateKey(data.Key) != nil {
+			t.Errorf("%q stored as %q, want a valid key directly in %s", name, data.Key, prefix)
+		}
+	}
+	for _, key := range fake.Keys(testBucket) {
+		if !strings.HasPrefix(key, prefix) {
+			t.Errorf("object %q escaped %s", key, prefix)
+		}
+	}
+}
\ No newline at end of file
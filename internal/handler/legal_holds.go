@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+)
+
+// LegalHoldHandler lets admins freeze users and key prefixes during investigations
+type LegalHoldHandler struct {
+	database  *db.Database
+	logger    *zap.Logger
+	approvals *ApprovalHandler
+}
+
+// PlaceHoldRequest places a legal hold on a user or prefix
+type PlaceHoldRequest struct {
+	Scope  string `json:"scope"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+}
+
+// ReleaseHoldRequest lifts a legal hold
+type ReleaseHoldRequest struct {
+	Reason string `json:"reason"`
+}
+
+// LegalHoldData describes an active legal hold
+type LegalHoldData struct {
+	ID       string `json:"id"`
+	Scope    string `json:"scope"`
+	Target   string `json:"target"`
+	Reason   string `json:"reason"`
+	PlacedBy string `json:"placed_by"`
+	PlacedAt string `json:"placed_at"`
+}
+
+// LegalHoldsData is the payload of the legal hold listing endpoint
+type LegalHoldsData struct {
+	Holds []LegalHoldData `json:"holds"`
+}
+
+// placeHoldPayload is the stored payload of a deferred hold placement
+type placeHoldPayload struct {
+	Scope       string `json:"scope"`
+	Target      string `json:"target"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"requested_by"`
+}
+
+// releaseHoldPayload is the stored payload of a deferred hold release
+type releaseHoldPayload struct {
+	ID          string `json:"id"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"requested_by"`
+}
+
+// NewLegalHoldHandler creates a new legal hold handler
+func NewLegalHoldHandler(database *db.Database, logger *zap.Logger, approvals *ApprovalHandler) *LegalHoldHandler {
+	h := &LegalHoldHandler{
+		database:  database,
+		logger:    logger,
+		approvals: approvals,
+	}
+
+	approvals.Register("place_legal_hold", func(ctx context.Context, payload json.RawMessage) error {
+		var p placeHoldPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		_, err := h.place(p.Scope, p.Target, p.Reason, p.RequestedBy)
+		return err
+	})
+	approvals.Register("release_legal_hold", func(ctx context.Context, payload json.RawMessage) error {
+		var p releaseHoldPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		return h.release(p.ID, p.Reason, p.RequestedBy)
+	})
+
+	return h
+}
+
+// ListHolds returns every active legal hold
+func (h *LegalHoldHandler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := h.database.ListLegalHolds()
+	if err != nil {
+		h.logger.Error("failed to list legal holds", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to retrieve legal holds",
+		})
+		return
+	}
+
+	items := make([]LegalHoldData, 0, len(holds))
+	for _, hold := range holds {
+		items = append(items, legalHoldData(hold))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: LegalHoldsData{
+			Holds: items,
+		},
+	})
+}
+
+// PlaceHold places a legal hold, or submits it for approval when required
+func (h *LegalHoldHandler) PlaceHold(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+
+	var req PlaceHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "reason is required",
+		})
+		return
+	}
+
+	switch req.Scope {
+	case db.HoldScopeUser:
+		if _, err := h.database.GetUserByID(req.Target); err != nil {
+			respondJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "user not found",
+			})
+			return
+		}
+	case db.HoldScopePrefix:
+		if req.Target == "" || validatePrefix(req.Target) != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "a valid, non-empty prefix is required",
+			})
+			return
+		}
+	default:
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   `scope must be "user" or "prefix"`,
+		})
+		return
+	}
+
+	if existing, err := h.database.FindLegalHold(req.Scope, req.Target); err == nil && existing != nil {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   "a legal hold is already active on this target",
+		})
+		return
+	}
+
+	if h.approvals.Required("place_legal_hold") {
+		pending, err := h.approvals.Submit(admin, "place_legal_hold", req.Scope+":"+req.Target, placeHoldPayload{
+			Scope:       req.Scope,
+			Target:      req.Target,
+			Reason:      req.Reason,
+			RequestedBy: admin.ID,
+		})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
+	hold, err := h.place(req.Scope, req.Target, req.Reason, admin.ID)
+	if err != nil {
+		h.logger.Error("failed to place legal hold", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to place legal hold",
+		})
+		return
+	}
+	h.approvals.Audit(admin, "place_legal_hold", req.Scope+":"+req.Target, req.Reason)
+
+	respondJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    legalHoldData(hold),
+	})
+}
+
+// ReleaseHold lifts a legal hold, or submits the release for approval when required
+func (h *LegalHoldHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+
+	var req ReleaseHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "reason is required",
+		})
+		return
+	}
+
+	hold, err := h.database.GetLegalHold(id)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "legal hold not found",
+		})
+		return
+	}
+
+	if h.approvals.Required("release_legal_hold") {
+		pending, err := h.approvals.Submit(admin, "release_legal_hold", hold.Scope+":"+hold.Target, releaseHoldPayload{
+			ID:          id,
+			Reason:      req.Reason,
+			RequestedBy: admin.ID,
+		})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
+	if err := h.release(id, req.Reason, admin.ID); err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "legal hold not found",
+		})
+		return
+	}
+	h.approvals.Audit(admin, "release_legal_hold", hold.Scope+":"+hold.Target, req.Reason)
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "legal hold released",
+		},
+	})
+}
+
+// place stores a new hold
+func (h *LegalHoldHandler) place(scope, target, reason, placedBy string) (*db.LegalHold, error) {
+	id, err := auth.NewRandomToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := &db.LegalHold{
+		ID:       id,
+		Scope:    scope,
+		Target:   target,
+		Reason:   reason,
+		PlacedBy: placedBy,
+		PlacedAt: time.Now(),
+	}
+	if err := h.database.CreateLegalHold(hold); err != nil {
+		return nil, err
+	}
+
+	h.logger.Warn("legal hold placed",
+		zap.String("hold_id", id),
+		zap.String("scope", scope),
+		zap.String("target", target),
+		zap.String("placed_by", placedBy),
+		zap.String("reason", reason),
+	)
+
+	return hold, nil
+}
+
+// release lifts an active hold
+func (h *LegalHoldHandler) release(id, reason, releasedBy string) error {
+	hold, err := h.database.GetLegalHold(id)
+	if err != nil {
+		return err
+	}
+	if err := h.database.ReleaseLegalHold(id, releasedBy, reason); err != nil {
+		return err
+	}
+
+	h.logger.Warn("legal hold released",
+		zap.String("hold_id", id),
+		zap.String("scope", hold.Scope),
+		zap.String("target", hold.Target),
+		zap.String("released_by", releasedBy),
+		zap.String("reason", reason),
+	)
+
+	return nil
+}
+
+// legalHoldData converts a stored hold to its API form
+func legalHoldData(hold *db.LegalHold) LegalHoldData {
+	return LegalHoldData{
+		ID:       hold.ID,
+		Scope:    hold.Scope,
+		Target:   hold.Target,
+		Reason:   hold.Reason,
+		PlacedBy: hold.PlacedBy,
+		PlacedAt: hold.PlacedAt.Format(time.RFC3339),
+	}
+}
\ No newline at end of file
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// Lifecycle modes: rules enforced by the storage backend, or by the app's own sweep
+const (
+	lifecycleModeNative   = "native"
+	lifecycleModeEmulated = "emulated"
+)
+
+// LifecycleRuleData is one expiration rule: objects under Prefix are deleted once they
+// are ExpirationDays old
+type LifecycleRuleData struct {
+	ID             string `json:"id"`
+	Prefix         string `json:"prefix"`
+	ExpirationDays int32  `json:"expiration_days"`
+}
+
+// LifecycleRequest is the request body of the lifecycle update endpoint. The rules
+// replace every rule set before; an empty list removes them.
+type LifecycleRequest struct {
+	Rules []LifecycleRuleData `json:"rules"`
+}
+
+// LifecycleData is the payload of the lifecycle endpoints. In emulated mode the backend
+// has no lifecycle support and the app deletes expired objects itself on every sweep.
+type LifecycleData struct {
+	Bucket string              `json:"bucket"`
+	Mode   string              `json:"mode"`
+	Rules  []LifecycleRuleData `json:"rules"`
+	// UnmanagedRules counts backend rules the app leaves alone, such as transitions
+	UnmanagedRules int `json:"unmanaged_rules,omitempty"`
+}
+
+// GetLifecycle returns the expiration rules of a bucket (admin only)
+func (h *Handler) GetLifecycle(w http.ResponseWriter, r *http.Request) {
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	data, err := h.lifecycleData(r.Context(), storage)
+	if err != nil {
+		h.logger.Error("failed to get lifecycle rules", zap.String("bucket", storage.Name()), zap.Error(err))
+		respondStorageError(w, err, "failed to get lifecycle rules")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// PutLifecycle replaces the expiration rules of a bucket (admin only). The rules go to
+// the backend's lifecycle configuration; when the backend doesn't support one they are
+// stored and enforced by the app's sweep instead, reported as mode "emulated". Every rule
+// needs a prefix, and rules reaching into a legal hold are refused since the backend
+// would expire held files regardless.
+func (h *Handler) PutLifecycle(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var req LifecycleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	rules := make([]service.LifecycleRule, 0, len(req.Rules))
+	for i, rule := range req.Rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("expire-%d", i+1)
+		}
+		rules = append(rules, service.LifecycleRule{
+			ID:             rule.ID,
+			Prefix:         rule.Prefix,
+			ExpirationDays: rule.ExpirationDays,
+		})
+	}
+	if err := service.ValidateLifecycleRules(rules); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	for _, rule := range rules {
+		if h.rejectHeldOverlap(w, rule.Prefix) {
+			return
+		}
+	}
+
+	mode := lifecycleModeNative
+	err = storage.PutLifecycleRules(r.Context(), rules)
+	if errors.Is(err, service.ErrLifecycleUnsupported) {
+		mode = lifecycleModeEmulated
+		stored := make([]db.LifecycleRule, 0, len(rules))
+		for _, rule := range rules {
+			stored = append(stored, db.LifecycleRule{
+				ID:             rule.ID,
+				Prefix:         rule.Prefix,
+				ExpirationDays: rule.ExpirationDays,
+			})
+		}
+		err = h.database.ReplaceLifecycleRules(storage.Name(), stored, user.ID)
+	} else if err == nil {
+		// The backend enforces the rules now, so any emulated ones would only duplicate them
+		if err := h.database.ReplaceLifecycleRules(storage.Name(), nil, user.ID); err != nil {
+			h.logger.Error("failed to clear emulated lifecycle rules", zap.String("bucket", storage.Name()), zap.Error(err))
+		}
+	}
+	if err != nil {
+		h.logger.Error("failed to update lifecycle rules", zap.String("bucket", storage.Name()), zap.Error(err))
+		respondStorageError(w, err, "failed to update lifecycle rules")
+		return
+	}
+
+	h.logger.Info("lifecycle rules updated", zap.String("user", user.Name), zap.String("bucket", storage.Name()), zap.String("mode", mode), zap.Int("rules", len(rules)))
+
+	data, err := h.lifecycleData(r.Context(), storage)
+	if err != nil {
+		h.logger.Error("failed to read back lifecycle rules", zap.String("bucket", storage.Name()), zap.Error(err))
+		respondStorageError(w, err, "failed to read lifecycle rules")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// lifecycleData describes the rules in effect for storage. Stored emulated rules take
+// precedence, since they only exist when the backend turned the rules down.
+func (h *Handler) lifecycleData(ctx context.Context, storage *service.S3Service) (LifecycleData, error) {
+	data := LifecycleData{
+		Bucket: storage.Name(),
+		Mode:   lifecycleModeEmulated,
+		Rules:  []LifecycleRuleData{},
+	}
+
+	stored, err := h.database.ListLifecycleRules(storage.Name())
+	if err != nil {
+		return data, err
+	}
+	if len(stored) > 0 {
+		for _, rule := range stored {
+			data.Rules = append(data.Rules, LifecycleRuleData{
+				ID:             rule.ID,
+				Prefix:         rule.Prefix,
+				ExpirationDays: rule.ExpirationDays,
+			})
+		}
+		return data, nil
+	}
+
+	rules, unmanaged, err := storage.GetLifecycleRules(ctx)
+	if errors.Is(err, service.ErrLifecycleUnsupported) {
+		return data, nil
+	}
+	if err != nil {
+		return data, err
+	}
+
+	data.Mode = lifecycleModeNative
+	data.UnmanagedRules = unmanaged
+	for _, rule := range rules {
+		data.Rules = append(data.Rules, LifecycleRuleData{
+			ID:             rule.ID,
+			Prefix:         rule.Prefix,
+			ExpirationDays: rule.ExpirationDays,
+		})
+	}
+	return data, nil
+}
+
+// RunLifecycleSweep enforces the emulated lifecycle rules on every sweep interval until
+// ctx is cancelled. A zero interval disables it.
+func (h *Handler) RunLifecycleSweep(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, held, err := h.sweepLifecycle(ctx)
+			if err != nil && ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				h.logger.Error("lifecycle sweep failed", zap.Int("expired", expired), zap.Error(err))
+			}
+			if expired > 0 || held > 0 {
+				h.logger.Info("lifecycle sweep finished", zap.Int("expired", expired), zap.Int("held", held))
+			}
+		}
+	}
+}
+
+// sweepLifecycle deletes the objects that outlived an emulated lifecycle rule and
+// returns how many were deleted and how many were kept for a legal hold or lock. A failing rule
+// doesn't stop the others; the first error is returned.
+func (h *Handler) sweepLifecycle(ctx context.Context) (expired, heldCount int, err error) {
+	rules, err := h.database.ListLifecycleRules("")
+	if err != nil || len(rules) == 0 {
+		return 0, 0, err
+	}
+	held, err := h.heldPrefixes()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var firstErr error
+	for _, rule := range rules {
+		storage, err := h.s3Service.ForBucket(rule.Bucket)
+		if err != nil {
+			h.logger.Warn("skipping lifecycle rule of unknown bucket", zap.String("bucket", rule.Bucket), zap.String("rule", rule.ID))
+			continue
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -int(rule.ExpirationDays))
+		keys, err := storage.ListExpired(ctx, rule.Prefix, cutoff)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		// Locks are kept with the metadata, which only covers the primary bucket
+		var records map[string]*db.FileRecord
+		if storage == h.s3Service {
+			if records, err = h.database.FileRecordsByKeys(keys); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+		}
+
+		expiring := keys[:0]
+		for _, key := range keys {
+			if heldPrefixOf(held, key) != "" {
+				heldCount++
+				continue
+			}
+			if record := records[key]; record != nil && record.Locked {
+				heldCount++
+				continue
+			}
+			expiring = append(expiring, key)
+		}
+		if len(expiring) == 0 {
+			continue
+		}
+
+		deleted, err := storage.DeleteFiles(ctx, expiring)
+		if storage == h.s3Service {
+			if err := h.database.DeleteFileRecords(deleted...); err != nil {
+				h.logger.Error("failed to delete file metadata", zap.Error(err))
+			}
+		}
+		expired += len(deleted)
+		h.logger.Info("expired objects by lifecycle rule", zap.String("bucket", rule.Bucket), zap.String("rule", rule.ID), zap.String("prefix", rule.Prefix), zap.Int("count", len(deleted)))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return expired, heldCount, firstErr
+}
\ No newline at end of file
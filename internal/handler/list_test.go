@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+func listFiles(t *testing.T, h *Handler, user *auth.User, target string) (*httptest.ResponseRecorder, ListFilesData) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ListFiles(rec, asUser(httptest.NewRequest(http.MethodGet, target, nil), user))
+	var data ListFilesData
+	if rec.Code == http.StatusOK {
+		decodeData(t, rec, &data)
+	}
+	return rec, data
+}
+
+func TestListFilesFollowsPagination(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.SetPageSize(2)
+	for i := range 5 {
+		fake.Put(testBucket, fmt.Sprintf("%s%d-file%d.txt", service.UserPrefix(admin.ID), i, i), []byte("data"))
+	}
+
+	rec, data := listFiles(t, h, admin, "/api/files")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if data.Count != 5 {
+		t.Errorf("listed %d files, want all 5 across pages", data.Count)
+	}
+	if pages := fake.Requests("ListObjectsV2"); pages != 3 {
+		t.Errorf("made %d list requests, want 3", pages)
+	}
+}
+
+func TestListFilesBackfillsInBackground(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.pdf"
+	fake.Put(testBucket, key, []byte("data"))
+
+	rec, data := listFiles(t, h, user, "/api/files")
+	if rec.Code != http.StatusOK || data.Count != 1 {
+		t.Fatalf("status = %d, count = %d: %s", rec.Code, data.Count, rec.Body.String())
+	}
+	if data.Files[0].OriginalName != "report.pdf" || data.Files[0].OwnerID != user.ID {
+		t.Errorf("listed %+v, want metadata derived from the key", data.Files[0])
+	}
+	if record, _ := database.GetFileRecord(key); record != nil {
+		t.Fatal("listing wrote file metadata itself")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.RunRecordBackfill(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		record, err := database.GetFileRecord(key)
+		if err != nil {
+			t.Fatalf("GetFileRecord: %v", err)
+		}
+		if record != nil {
+			if record.OriginalName != "report.pdf" {
+				t.Errorf("backfilled name = %q, want report.pdf", record.OriginalName)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("metadata was not backfilled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListFilesRejectsTraversalPrefix(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	for _, prefix := range []string{"../", "users/../secret/", "/etc/"} {
+		rec, _ := listFiles(t, h, admin, "/api/files?prefix="+prefix)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("prefix %q: status = %d, want 400", prefix, rec.Code)
+		}
+	}
+}
+
+func TestListFilesScopedToOwner(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	bob := createTestUser(t, database, "bob", auth.RoleUploader)
+
+	own := service.UserPrefix(alice.ID) + "1712345-own.txt"
+	unrecorded := service.UserPrefix(alice.ID) + "1712346-unrecorded.txt"
+	dropped := service.UserPrefix(alice.ID) + "1712347-dropped.txt"
+	other := service.UserPrefix(bob.ID) + "1712348-bob.txt"
+	for _, key := range []string{own, unrecorded, dropped, other} {
+		fake.Put(testBucket, key, []byte("data"))
+	}
+	// Records name the owner; a file without one belongs to the folder's user
+	for key, owner := range map[string]string{own: alice.ID, dropped: "inbox:abc", other: bob.ID} {
+		if err := database.SaveFileRecord(&db.FileRecord{Key: key, OwnerID: owner, OriginalName: service.OriginalName(key), UploadedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveFileRecord: %v", err)
+		}
+	}
+
+	_, data := listFiles(t, h, alice, "/api/files")
+	got := make(map[string]bool)
+	for _, file := range data.Files {
+		got[file.Key] = true
+	}
+	if len(got) != 2 || !got[own] || !got[unrecorded] {
+		t.Errorf("alice lists %v, want only %s and %s", got, own, unrecorded)
+	}
+
+	_, data = listFiles(t, h, admin, "/api/files")
+	owners := make(map[string]string)
+	for _, file := range data.Files {
+		owners[file.Key] = file.OwnerID
+	}
+	for key, owner := range map[string]string{own: alice.ID, unrecorded: alice.ID, dropped: "inbox:abc", other: bob.ID} {
+		if owners[key] != owner {
+			t.Errorf("admin sees %s owned by %q, want %q", key, owners[key], owner)
+		}
+	}
+}
\ No newline at end of file
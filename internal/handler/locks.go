@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+// LockRequest is the request body of the lock endpoint. Unlock lifts the lock instead
+// of placing it.
+type LockRequest struct {
+	Key    string `json:"key"`
+	Unlock bool   `json:"unlock"`
+}
+
+// LockData is the payload of the lock endpoint
+type LockData struct {
+	Key    string `json:"key"`
+	Locked bool   `json:"locked"`
+}
+
+// LockFile locks a file in the primary bucket against being overwritten, renamed, moved
+// or deleted, or lifts the lock (admin only)
+func (h *Handler) LockFile(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	var req LockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+	if err := validateKey(req.Key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	info, err := h.s3Service.StatFile(r.Context(), req.Key)
+	if err != nil {
+		respondStorageError(w, err, "failed to stat file")
+		return
+	}
+
+	// The lock lives on the file's record, so a file without one gets it first
+	record, err := h.database.GetFileRecord(req.Key)
+	if err != nil {
+		h.logger.Error("failed to get file metadata", zap.String("key", req.Key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to lock file",
+		})
+		return
+	}
+	if record == nil {
+		h.backfillRecord(service.File{Key: info.Key, Size: info.Size, LastModified: info.LastModified})
+	}
+
+	locked := !req.Unlock
+	if err := h.database.SetFileLocked(req.Key, locked); err != nil {
+		h.logger.Error("failed to set file lock", zap.String("key", req.Key), zap.Bool("locked", locked), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to lock file",
+		})
+		return
+	}
+
+	h.logger.Info("file lock changed", zap.String("user", user.Name), zap.String("key", req.Key), zap.Bool("locked", locked))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: LockData{
+			Key:    req.Key,
+			Locked: locked,
+		},
+	})
+}
\ No newline at end of file
@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"s3-test-app/internal/auth"
+	mw "s3-test-app/internal/middleware"
+)
+
+// lockFile has user lock key, or unlock it
+func lockFile(t *testing.T, h *Handler, user *auth.User, key string, unlock bool) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.LockFile(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/lock", LockRequest{Key: key, Unlock: unlock}), user))
+	return rec
+}
+
+// mustLock locks key as admin and fails the test if that doesn't work
+func mustLock(t *testing.T, h *Handler, admin *auth.User, key string) {
+	t.Helper()
+	if rec := lockFile(t, h, admin, key, false); rec.Code != http.StatusOK {
+		t.Fatalf("lock %s: status = %d: %s", key, rec.Code, rec.Body.String())
+	}
+}
+
+// assertLocked checks that rec is the 423 a locked file answers with
+func assertLocked(t *testing.T, op string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusLocked {
+		t.Errorf("%s: status = %d, want 423: %s", op, rec.Code, rec.Body.String())
+		return
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeLocked {
+		t.Errorf("%s: code = %q, want %s", op, resp.Code, CodeLocked)
+	}
+}
+
+func TestLockBlocksSingleFileOperations(t *testing.T) {
+	const key = "release/app-1.0.tar.gz"
+	const other = "release/app-0.9.tar.gz"
+	operations := []struct {
+		name string
+		call func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder
+	}{
+		{"delete", func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(key), nil), admin))
+			return rec
+		}},
+		{"rename from", func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder {
+			return renameFile(t, h, admin, RenameRequest{From: key, To: "release/renamed.tar.gz"})
+		}},
+		{"rename over", func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder {
+			return renameFile(t, h, admin, RenameRequest{From: other, To: key, Overwrite: true})
+		}},
+		{"restore version", func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.RestoreVersion(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/versions/restore", RestoreVersionRequest{Key: key, VersionID: "v1"}), admin))
+			return rec
+		}},
+		{"delete prefix", func(t *testing.T, h *Handler, admin *auth.User) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeletePrefix(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files/prefix?prefix=release/", nil), admin))
+			return rec
+		}},
+	}
+
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			h, database, fake := newTestHandler(t)
+			admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+			fake.Put(testBucket, key, []byte("release"))
+			fake.Put(testBucket, other, []byte("older"))
+			mustLock(t, h, admin, key)
+
+			assertLocked(t, op.name, op.call(t, h, admin))
+			if keys := fake.Keys(testBucket); !slices.Equal(keys, []string{other, key}) {
+				t.Errorf("keys = %v, want both files untouched", keys)
+			}
+			if object := fake.Get(testBucket, key); object == nil || string(object.Data) != "release" {
+				t.Error("locked file was changed")
+			}
+
+			// Once unlocked, the same operation goes through
+			if rec := lockFile(t, h, admin, key, true); rec.Code != http.StatusOK {
+				t.Fatalf("unlock: status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if rec := op.call(t, h, admin); rec.Code == http.StatusLocked {
+				t.Errorf("after unlock: still 423: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLockBlocksUploadOverwrite(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	// Uploads are keyed by the second they arrive in, so every key the upload may get is locked
+	now := time.Now().Unix()
+	for second := now; second <= now+2; second++ {
+		key := fmt.Sprintf("users/%s/%d-report.txt", admin.ID, second)
+		fake.Put(testBucket, key, []byte("original"))
+		mustLock(t, h, admin, key)
+	}
+
+	rec := uploadFile(h, admin, uploadRequest(t, nil, testFile{name: "report.txt", content: []byte("replacement")}))
+	assertLocked(t, "upload", rec)
+	if n := fake.Requests("PutObject"); n != 0 {
+		t.Errorf("PutObject called %d times for a locked key", n)
+	}
+}
+
+func TestLockBlocksBatchDeletePerKey(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "release/locked.txt", []byte("keep"))
+	fake.Put(testBucket, "release/free.txt", []byte("drop"))
+	mustLock(t, h, admin, "release/locked.txt")
+
+	rec := httptest.NewRecorder()
+	h.BatchDelete(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/batch-delete", BatchDeleteRequest{Keys: []string{"release/locked.txt", "release/free.txt"}}), admin))
+	var data BatchDeleteData
+	decodeData(t, rec, &data)
+	if len(data.Failed) != 1 || data.Failed[0].Key != "release/locked.txt" || data.Failed[0].Code != CodeLocked {
+		t.Errorf("failed = %+v, want only the locked file with %s", data.Failed, CodeLocked)
+	}
+	if keys := fake.Keys(testBucket); !slices.Contains(keys, "release/locked.txt") || slices.Contains(keys, "release/free.txt") {
+		t.Errorf("keys = %v, want only the locked file left", keys)
+	}
+}
+
+func TestLockBlocksMovePerKey(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "release/locked.txt", []byte("keep"))
+	fake.Put(testBucket, "release/free.txt", []byte("move"))
+	fake.Put(testBucket, "incoming/target.txt", []byte("new"))
+	fake.Put(testBucket, "archive/target.txt", []byte("protected"))
+	mustLock(t, h, admin, "release/locked.txt")
+	mustLock(t, h, admin, "archive/target.txt")
+
+	move := func(req MoveRequest) MoveData {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.MoveFiles(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/move", req), admin))
+		var data MoveData
+		decodeData(t, rec, &data)
+		return data
+	}
+
+	// A locked source stays put while the rest of the prefix moves
+	data := move(MoveRequest{Prefix: "release/", Destination: "archive/"})
+	if data.Moved != 1 || data.Failed != 1 {
+		t.Fatalf("moved %d, failed %d, want 1 and 1: %+v", data.Moved, data.Failed, data.Results)
+	}
+	for _, result := range data.Results {
+		if result.From == "release/locked.txt" && result.Code != CodeLocked {
+			t.Errorf("locked source: code = %q, want %s", result.Code, CodeLocked)
+		}
+	}
+
+	// A locked destination isn't overwritten
+	data = move(MoveRequest{Key: "incoming/target.txt", Destination: "archive/", Overwrite: true})
+	if data.Failed != 1 || data.Results[0].Code != CodeLocked {
+		t.Errorf("locked destination: %+v, want a %s failure", data.Results, CodeLocked)
+	}
+	if object := fake.Get(testBucket, "archive/target.txt"); object == nil || string(object.Data) != "protected" {
+		t.Error("locked destination was overwritten")
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	uploader := createTestUser(t, database, "alice", auth.RoleUploader)
+	fake.Put(testBucket, "release/app.tar.gz", []byte("release"))
+
+	if rec := lockFile(t, h, admin, "release/missing.tar.gz", false); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file: status = %d, want 404", rec.Code)
+	}
+	if rec := lockFile(t, h, admin, "release/../app.tar.gz", false); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid key: status = %d, want 400", rec.Code)
+	}
+
+	// Only admins reach the handler, to lock or to unlock
+	r := chi.NewRouter()
+	r.With(mw.RequireRole(auth.RoleAdmin)).Post("/api/files/lock", h.LockFile)
+	for _, unlock := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/lock", LockRequest{Key: "release/app.tar.gz", Unlock: unlock}), uploader))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("uploader (unlock=%t): status = %d, want 403", unlock, rec.Code)
+		}
+	}
+
+	rec := lockFile(t, h, admin, "release/app.tar.gz", false)
+	var data LockData
+	decodeData(t, rec, &data)
+	if !data.Locked {
+		t.Error("lock response says the file is unlocked")
+	}
+	if locked, err := database.IsFileLocked("release/app.tar.gz"); err != nil || !locked {
+		t.Errorf("IsFileLocked = %t, %v, want locked", locked, err)
+	}
+
+	decodeData(t, lockFile(t, h, admin, "release/app.tar.gz", true), &data)
+	if data.Locked {
+		t.Error("unlock response says the file is locked")
+	}
+	if locked, err := database.IsFileLocked("release/app.tar.gz"); err != nil || locked {
+		t.Errorf("IsFileLocked = %t, %v, want unlocked", locked, err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+)
+
+// PermissionsData is what a role allows, as exposed to clients
+type PermissionsData struct {
+	CanUpload bool `json:"can_upload"`
+	CanView   bool `json:"can_view"`
+	CanDelete bool `json:"can_delete"`
+	CanManage bool `json:"can_manage"`
+}
+
+// MeData is the payload of the current user endpoint
+type MeData struct {
+	ID            string          `json:"id"`
+	Username      string          `json:"username"`
+	Email         string          `json:"email"`
+	Role          string          `json:"role"`
+	EmailVerified bool            `json:"email_verified"`
+	Permissions   PermissionsData `json:"permissions"`
+	// Quota is the storage quota in effect; 0 means unlimited
+	Quota     int64 `json:"quota"`
+	QuotaUsed int64 `json:"quota_used"`
+	// LastLoginAt is omitted until the user has logged in with a password
+	LastLoginAt time.Time `json:"last_login_at,omitzero"`
+}
+
+// GetMe returns the signed-in user. The details are read from the database rather than
+// the token, so a role change or deletion shows up before the token expires.
+func (h *Handler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	dbUser, err := h.database.GetUserByID(user.ID)
+	if err != nil {
+		h.logger.Warn("failed to load current user", zap.String("user_id", user.ID), zap.Error(err))
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	perm := auth.PermissionMap[dbUser.Role]
+	data := MeData{
+		ID:            dbUser.ID,
+		Username:      dbUser.Username,
+		Email:         dbUser.Email,
+		Role:          string(dbUser.Role),
+		EmailVerified: dbUser.EmailVerified,
+		Permissions: PermissionsData{
+			CanUpload: perm.CanUpload,
+			CanView:   perm.CanView,
+			CanDelete: perm.CanDelete,
+			CanManage: perm.CanManage,
+		},
+		LastLoginAt: dbUser.LastLoginAt,
+	}
+	if quota, err := h.userQuotaData(dbUser.ID); err == nil {
+		data.Quota = quota.Quota
+		data.QuotaUsed = quota.Used
+	} else {
+		h.logger.Warn("failed to get storage quota for profile", zap.String("user_id", dbUser.ID), zap.Error(err))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
\ No newline at end of file
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/events"
+	"s3-test-app/internal/service"
+)
+
+// maxMoveKeys caps how many objects one move request may cover
+const maxMoveKeys = 10000
+
+// Outcomes of a single object in a move
+const (
+	moveStatusMoved   = "moved"
+	moveStatusSkipped = "skipped"
+	moveStatusFailed  = "failed"
+)
+
+// MoveRequest is the request body of the move endpoint. Either Key names one object or
+// Prefix selects every object under it. Each object keeps its key relative to the
+// source, which for a single key is its folder, under Destination: moving prefix
+// "staging/" to "release/" turns staging/a/b.zip into release/a/b.zip.
+type MoveRequest struct {
+	Key         string `json:"key"`
+	Prefix      string `json:"prefix"`
+	Destination string `json:"destination"`
+	Overwrite   bool   `json:"overwrite"`
+}
+
+// MoveResultData is the outcome of one object in a move
+type MoveResultData struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// MoveData is the payload of the move endpoint
+type MoveData struct {
+	Results []MoveResultData `json:"results"`
+	Moved   int              `json:"moved"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+}
+
+// MoveFiles moves one object or every object under a prefix to a destination prefix,
+// copying and deleting each one. Objects whose destination already exists are skipped
+// unless overwrite is set, and the outcome is reported per object.
+func (h *Handler) MoveFiles(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	// Moving both writes the new keys and removes the old ones
+	if !user.HasPermission(auth.Permission{CanUpload: true, CanDelete: true}) {
+		h.logger.Warn("move attempt by user without permission", zap.String("user", user.Name), zap.String("role", string(user.Role)))
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to move files",
+		})
+		return
+	}
+
+	var req MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	if (req.Key == "") == (req.Prefix == "") {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "exactly one of key or prefix is required",
+		})
+		return
+	}
+	if req.Destination == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "destination is required",
+		})
+		return
+	}
+	if err := validatePrefix(req.Destination); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx := r.Context()
+
+	var source string
+	var keys []string
+	if req.Key != "" {
+		if err := validateKey(req.Key); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		if dir := path.Dir(req.Key); dir != "." {
+			source = dir + "/"
+		}
+		keys = []string{req.Key}
+	} else {
+		if err := validatePrefix(req.Prefix); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		source = req.Prefix
+	}
+
+	if source == req.Destination {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "destination must differ from the source folder",
+		})
+		return
+	}
+
+	origin := req.Key
+	if origin == "" {
+		origin = req.Prefix
+	}
+	if h.rejectForeign(w, user, origin) || h.rejectForeign(w, user, req.Destination) {
+		return
+	}
+
+	if req.Prefix != "" {
+		var err error
+		keys, err = h.s3Service.ListKeys(ctx, req.Prefix, maxMoveKeys)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to check legal holds",
+		})
+		return
+	}
+
+	// lockedKeyOf returns the first of keys that is locked. Like rejectLocked, a lock
+	// that can't be checked is treated as present.
+	lockedKeyOf := func(keys ...string) string {
+		for _, key := range keys {
+			locked, err := h.database.IsFileLocked(key)
+			if err != nil {
+				h.logger.Error("failed to check file lock", zap.String("key", key), zap.Error(err))
+				return key
+			}
+			if locked {
+				return key
+			}
+		}
+		return ""
+	}
+
+	data := MoveData{Results: make([]MoveResultData, 0, len(keys))}
+	fail := func(from, to, message, code string) {
+		data.Results = append(data.Results, MoveResultData{From: from, To: to, Status: moveStatusFailed, Error: message, Code: code})
+		data.Failed++
+	}
+
+	// Every object is checked on its own so one refusal doesn't block the rest
+	moves := make([]service.Move, 0, len(keys))
+	for _, key := range keys {
+		to := req.Destination + strings.TrimPrefix(key, source)
+		if err := validateKey(to); err != nil {
+			fail(key, to, err.Error(), "")
+			continue
+		}
+		if !canAccessKey(user, key) || !canAccessKey(user, to) {
+			fail(key, to, "access denied", "")
+			continue
+		}
+		if prefix := heldPrefixOf(held, key); prefix != "" {
+			fail(key, to, fmt.Sprintf("files under %q are under legal hold", prefix), CodeLegalHold)
+			continue
+		}
+		if prefix := heldPrefixOf(held, to); prefix != "" {
+			fail(key, to, fmt.Sprintf("files under %q are under legal hold", prefix), CodeLegalHold)
+			continue
+		}
+		if locked := lockedKeyOf(key, to); locked != "" {
+			fail(key, to, fmt.Sprintf("file %q is locked", locked), CodeLocked)
+			continue
+		}
+		moves = append(moves, service.Move{Source: key, Destination: to})
+	}
+
+	for _, result := range h.s3Service.MoveFiles(ctx, moves, req.Overwrite) {
+		from, to := result.Source, result.Destination
+		switch {
+		case result.Err == nil:
+			// The destination's folder decides who owns the file from now on
+			if err := h.database.RenameFileRecord(from, to, service.UserFromKey(to)); err != nil {
+				h.logger.Error("failed to move file metadata", zap.String("from", from), zap.String("to", to), zap.Error(err))
+			}
+			h.publish(events.ActionRenamed, user, h.s3Service, to, from)
+			data.Results = append(data.Results, MoveResultData{From: from, To: to, Status: moveStatusMoved})
+			data.Moved++
+		case errors.Is(result.Err, service.ErrDestinationExists):
+			data.Results = append(data.Results, MoveResultData{From: from, To: to, Status: moveStatusSkipped, Error: service.ErrDestinationExists.Error(), Code: CodeDestExists})
+			data.Skipped++
+		case errors.Is(result.Err, service.ErrRenameIncomplete):
+			fail(from, to, service.ErrRenameIncomplete.Error(), "")
+		default:
+			h.logger.Warn("failed to move file", zap.String("from", from), zap.String("to", to), zap.Error(result.Err))
+			_, message := storageStatus(result.Err, "failed to move file")
+			fail(from, to, message, "")
+		}
+	}
+
+	h.logger.Info("move finished", zap.String("user", user.Name), zap.String("source", source), zap.String("destination", req.Destination), zap.Int("moved", data.Moved), zap.Int("skipped", data.Skipped), zap.Int("failed", data.Failed))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
\ No newline at end of file
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+)
+
+// AbortedUploadInfo is an incomplete multipart upload removed by a cleanup
+type AbortedUploadInfo struct {
+	Bucket    string `json:"bucket"`
+	Key       string `json:"key"`
+	UploadID  string `json:"upload_id"`
+	Initiated string `json:"initiated"`
+}
+
+// MultipartCleanupData is the payload of the multipart cleanup endpoint
+type MultipartCleanupData struct {
+	OlderThan string              `json:"older_than"`
+	Aborted   []AbortedUploadInfo `json:"aborted"`
+	Failed    int                 `json:"failed"`
+}
+
+// CleanupMultipart aborts stale multipart uploads in every bucket right away (admin only).
+// Uploads older than the configured age are aborted unless ?older_than= asks for another age.
+func (h *Handler) CleanupMultipart(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	olderThan := h.s3Service.MultipartMaxAge()
+	if raw := r.URL.Query().Get("older_than"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "older_than must be a non-negative duration such as 1h",
+			})
+			return
+		}
+		olderThan = d
+	}
+
+	data := MultipartCleanupData{
+		OlderThan: olderThan.String(),
+		Aborted:   []AbortedUploadInfo{},
+	}
+	for _, name := range h.s3Service.BucketNames() {
+		storage, err := h.s3Service.ForBucket(name)
+		if err != nil {
+			continue
+		}
+		aborted, failed, err := storage.AbortStaleMultipartUploads(r.Context(), olderThan)
+		for _, upload := range aborted {
+			data.Aborted = append(data.Aborted, AbortedUploadInfo{
+				Bucket:    upload.Bucket,
+				Key:       upload.Key,
+				UploadID:  upload.UploadID,
+				Initiated: upload.Initiated.Format(time.RFC3339),
+			})
+		}
+		data.Failed += failed
+		if err != nil {
+			respondStorageError(w, err, "failed to list multipart uploads in bucket "+name)
+			return
+		}
+	}
+
+	h.logger.Info("multipart cleanup triggered", zap.String("user", user.Name), zap.Duration("older_than", olderThan), zap.Int("aborted", len(data.Aborted)), zap.Int("failed", data.Failed))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
\ No newline at end of file
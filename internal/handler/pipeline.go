@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/metrics"
+	"s3-test-app/internal/service"
+)
+
+// Upload hook failure policies
+const (
+	// HookPolicyAbort refuses the upload when the hook fails
+	HookPolicyAbort = "abort"
+	// HookPolicyWarn logs the failure and stores the upload anyway
+	HookPolicyWarn = "warn"
+)
+
+// uploadCheck is what the upload pipeline hooks see of a file before it is stored
+type uploadCheck struct {
+	user *auth.User
+	// name is the filename the upload is stored under and contentType the declared type
+	name        string
+	contentType string
+	size        int64
+	// head holds the first bytes of the file, for sniffing
+	head []byte
+	// sha256 is the hex digest of the content and expectedSHA256 the one the client sent
+	sha256         string
+	expectedSHA256 string
+}
+
+// uploadHook is one stage of the upload pipeline
+type uploadHook struct {
+	name   string
+	policy string
+	// critical hooks guard the storage policy and can't be turned off
+	critical bool
+	enabled  atomic.Bool
+	run      func(ctx context.Context, check *uploadCheck) error
+}
+
+// uploadPipeline runs the hooks every proxied upload passes before it is stored, in order
+type uploadPipeline struct {
+	logger *zap.Logger
+
+	mu    sync.RWMutex
+	hooks []*uploadHook
+}
+
+// PipelineHookData describes one upload pipeline hook
+type PipelineHookData struct {
+	Name     string `json:"name"`
+	Policy   string `json:"policy"`
+	Critical bool   `json:"critical"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// PipelineData is the payload of the upload pipeline endpoint, hooks in the order they run
+type PipelineData struct {
+	Hooks []PipelineHookData `json:"hooks"`
+}
+
+// UpdatePipelineHookRequest is the request body of the hook toggle endpoint
+type UpdatePipelineHookRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// CodeHookFailed is reported when a hook aborts an upload without a more specific error
+const CodeHookFailed = "UPLOAD_HOOK_FAILED"
+
+// newUploadPipeline creates an empty pipeline
+func newUploadPipeline(logger *zap.Logger) *uploadPipeline {
+	return &uploadPipeline{logger: logger}
+}
+
+// register appends an enabled hook to the pipeline
+func (p *uploadPipeline) register(name, policy string, critical bool, run func(ctx context.Context, check *uploadCheck) error) {
+	hook := &uploadHook{
+		name:     name,
+		policy:   policy,
+		critical: critical,
+		run:      run,
+	}
+	hook.enabled.Store(true)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hooks = append(p.hooks, hook)
+}
+
+// lookup returns the named hook, or nil
+func (p *uploadPipeline) lookup(name string) *uploadHook {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, hook := range p.hooks {
+		if hook.name == name {
+			return hook
+		}
+	}
+	return nil
+}
+
+// run passes check through every enabled hook and returns the error of the first one that
+// fails with the abort policy. Failures of hooks that only warn are logged and counted.
+func (p *uploadPipeline) run(ctx context.Context, check *uploadCheck) error {
+	p.mu.RLock()
+	hooks := p.hooks
+	p.mu.RUnlock()
+
+	for _, hook := range hooks {
+		if !hook.enabled.Load() {
+			continue
+		}
+
+		start := time.Now()
+		err := hook.run(ctx, check)
+		metrics.UploadHookDuration.WithLabelValues(hook.name).Observe(time.Since(start).Seconds())
+		if err == nil {
+			continue
+		}
+
+		if hook.policy == HookPolicyWarn {
+			metrics.UploadHookFailures.WithLabelValues(hook.name, "warned").Inc()
+			p.logger.Warn("upload hook failed, storing anyway", zap.String("hook", hook.name), zap.String("filename", check.name), zap.Error(err))
+			continue
+		}
+		metrics.UploadHookFailures.WithLabelValues(hook.name, "aborted").Inc()
+		return err
+	}
+	return nil
+}
+
+// describe lists the hooks in the order they run
+func (p *uploadPipeline) describe() []PipelineHookData {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	hooks := make([]PipelineHookData, 0, len(p.hooks))
+	for _, hook := range p.hooks {
+		hooks = append(hooks, PipelineHookData{
+			Name:     hook.name,
+			Policy:   hook.policy,
+			Critical: hook.critical,
+			Enabled:  hook.enabled.Load(),
+		})
+	}
+	return hooks
+}
+
+// registerUploadHooks installs the checks every upload goes through
+func (h *Handler) registerUploadHooks() {
+	h.pipeline.register("mime", HookPolicyAbort, true, func(ctx context.Context, check *uploadCheck) error {
+		if err := service.CheckFileType(h.uploadTypes, check.name, check.contentType, check.head); err != nil {
+			h.logger.Warn("rejected upload by file type", zap.String("user", check.user.Name), zap.String("filename", check.name), zap.Error(err))
+			return &uploadError{http.StatusUnsupportedMediaType, err.Error(), CodeFileType}
+		}
+		return nil
+	})
+
+	h.pipeline.register("checksum", HookPolicyAbort, true, func(ctx context.Context, check *uploadCheck) error {
+		if check.expectedSHA256 != "" && check.expectedSHA256 != check.sha256 {
+			h.logger.Warn("upload does not match client checksum", zap.String("user", check.user.Name), zap.String("expected", check.expectedSHA256), zap.String("received", check.sha256))
+			return &uploadError{http.StatusUnprocessableEntity, "file content does not match X-Content-SHA256", CodeChecksum}
+		}
+		return nil
+	})
+}
+
+// runUploadHooks passes an upload through the pipeline, turning a refusal into the
+// uploadError reported to the client
+func (h *Handler) runUploadHooks(ctx context.Context, check *uploadCheck) *uploadError {
+	err := h.pipeline.run(ctx, check)
+	if err == nil {
+		return nil
+	}
+	var uerr *uploadError
+	if errors.As(err, &uerr) {
+		return uerr
+	}
+	return &uploadError{http.StatusUnprocessableEntity, err.Error(), CodeHookFailed}
+}
+
+// GetPipeline lists the upload pipeline hooks in the order they run
+func (h *Handler) GetPipeline(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: PipelineData{
+			Hooks: h.pipeline.describe(),
+		},
+	})
+}
+
+// UpdatePipelineHook turns a non-critical upload hook on or off until the next restart
+func (h *Handler) UpdatePipelineHook(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+	name := chi.URLParam(r, "hook")
+
+	hook := h.pipeline.lookup(name)
+	if hook == nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "unknown upload hook",
+		})
+		return
+	}
+
+	var req UpdatePipelineHookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "enabled is required",
+		})
+		return
+	}
+
+	if hook.critical && !*req.Enabled {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   fmt.Sprintf("upload hook %q is critical and can't be turned off", name),
+		})
+		return
+	}
+
+	hook.enabled.Store(*req.Enabled)
+
+	h.logger.Info("upload hook toggled", zap.String("admin", admin.ID), zap.String("hook", name), zap.Bool("enabled", *req.Enabled))
+	if h.approvals != nil {
+		h.approvals.Audit(admin, "toggle_upload_hook", name, strconv.FormatBool(*req.Enabled))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: PipelineData{
+			Hooks: h.pipeline.describe(),
+		},
+	})
+}
\ No newline at end of file
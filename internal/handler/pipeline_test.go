@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/metrics"
+)
+
+// failingHook always fails
+func failingHook(ctx context.Context, check *uploadCheck) error {
+	return errors.New("scanner unavailable")
+}
+
+func TestUploadHookPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy  string
+		want    int
+		outcome string
+	}{
+		{HookPolicyWarn, http.StatusOK, "warned"},
+		{HookPolicyAbort, http.StatusUnprocessableEntity, "aborted"},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			h, database, fake := newTestHandler(t)
+			user := createTestUser(t, database, "alice", auth.RoleUploader)
+			hookName := "scan-" + tc.policy
+			h.pipeline.register(hookName, tc.policy, false, failingHook)
+			failures := metrics.UploadHookFailures.WithLabelValues(hookName, tc.outcome)
+			before := testutil.ToFloat64(failures)
+
+			for i := 0; i < 3; i++ {
+				rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "notes.txt", content: []byte("notes")}))
+				if rec.Code != tc.want {
+					t.Fatalf("upload %d: status = %d, want %d: %s", i+1, rec.Code, tc.want, rec.Body.String())
+				}
+				if tc.policy == HookPolicyAbort {
+					if resp := decodeResponse(t, rec); resp.Code != CodeHookFailed {
+						t.Errorf("code = %q, want %q", resp.Code, CodeHookFailed)
+					}
+				}
+			}
+
+			if got := testutil.ToFloat64(failures) - before; got != 3 {
+				t.Errorf("%s failures counted = %v, want 3", tc.outcome, got)
+			}
+			stored := len(fake.Keys(testBucket))
+			if tc.policy == HookPolicyWarn && stored == 0 {
+				t.Error("warned uploads were not stored")
+			}
+			if tc.policy == HookPolicyAbort && stored != 0 {
+				t.Errorf("aborted uploads stored: %v", fake.Keys(testBucket))
+			}
+		})
+	}
+}
+
+// updatePipelineHook toggles hook through the admin endpoint
+func updatePipelineHook(t *testing.T, h *Handler, admin *auth.User, hook string, body any) *httptest.ResponseRecorder {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("hook", hook)
+	r := jsonRequest(t, http.MethodPut, "/api/admin/pipeline/"+hook, body)
+	r = asUser(r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)), admin)
+	rec := httptest.NewRecorder()
+	h.UpdatePipelineHook(rec, r)
+	return rec
+}
+
+func TestPipelineHookToggle(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	h.pipeline.register("scan", HookPolicyAbort, false, failingHook)
+
+	rec := httptest.NewRecorder()
+	h.GetPipeline(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/admin/pipeline", nil), admin))
+	var pipeline PipelineData
+	decodeData(t, rec, &pipeline)
+	want := []PipelineHookData{
+		{Name: "mime", Policy: HookPolicyAbort, Critical: true, Enabled: true},
+		{Name: "checksum", Policy: HookPolicyAbort, Critical: true, Enabled: true},
+		{Name: "scan", Policy: HookPolicyAbort, Enabled: true},
+	}
+	if len(pipeline.Hooks) != len(want) {
+		t.Fatalf("hooks = %+v, want %+v", pipeline.Hooks, want)
+	}
+	for i := range want {
+		if pipeline.Hooks[i] != want[i] {
+			t.Errorf("hook %d = %+v, want %+v", i, pipeline.Hooks[i], want[i])
+		}
+	}
+
+	if rec := uploadFile(h, admin, uploadRequest(t, nil, testFile{name: "notes.txt", content: []byte("notes")})); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("upload with the failing hook on: status = %d, want 422", rec.Code)
+	}
+
+	off := false
+	if rec := updatePipelineHook(t, h, admin, "scan", UpdatePipelineHookRequest{Enabled: &off}); rec.Code != http.StatusOK {
+		t.Fatalf("disable status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if rec := uploadFile(h, admin, uploadRequest(t, nil, testFile{name: "notes.txt", content: []byte("notes")})); rec.Code != http.StatusOK {
+		t.Errorf("upload with the failing hook off: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := updatePipelineHook(t, h, admin, "mime", UpdatePipelineHookRequest{Enabled: &off}); rec.Code != http.StatusConflict {
+		t.Errorf("disabling a critical hook: status = %d, want 409", rec.Code)
+	}
+	if rec := updatePipelineHook(t, h, admin, "thumbnail", UpdatePipelineHookRequest{Enabled: &off}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown hook: status = %d, want 404", rec.Code)
+	}
+	if rec := updatePipelineHook(t, h, admin, "scan", map[string]string{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing enabled: status = %d, want 400", rec.Code)
+	}
+}
\ No newline at end of file
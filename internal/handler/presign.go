@@ -0,0 +1,509 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/events"
+	"s3-test-app/internal/service"
+)
+
+// presignPolicyTTL is how long a presigned POST policy or PUT URL stays valid
+const presignPolicyTTL = 15 * time.Minute
+
+// directUploadGrace is how long after its URL expired a direct upload may still be
+// confirmed before the sweep removes whatever was uploaded
+const directUploadGrace = 15 * time.Minute
+
+// PresignPostRequest describes the file a client intends to upload directly to S3
+type PresignPostRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	AllowEmpty  bool   `json:"allow_empty"`
+}
+
+// PresignUploadData is the payload of the presigned PUT endpoint
+type PresignUploadData struct {
+	Key       string `json:"key"`
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// ConfirmUploadRequest is sent by the client after a direct upload finished. The object
+// is checked against the size declared when the upload was presigned
+type ConfirmUploadRequest struct {
+	Key string `json:"key"`
+}
+
+// PresignPostData is the payload of the presigned POST endpoint
+type PresignPostData struct {
+	Key       string            `json:"key"`
+	URL       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt string            `json:"expires_at"`
+}
+
+// ConfirmUploadData is the payload of the upload confirmation endpoint
+type ConfirmUploadData struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	DuplicateWarning
+}
+
+// PresignPost issues a POST policy so the browser can upload straight to S3
+func (h *Handler) PresignPost(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	if !auth.PermissionMap[user.Role].CanUpload {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to upload files",
+		})
+		return
+	}
+
+	if !h.s3Service.SupportsPostPolicy() {
+		respondJSON(w, http.StatusNotImplemented, Response{
+			Success: false,
+			Error:   "storage backend does not support POST policy uploads",
+		})
+		return
+	}
+
+	var req PresignPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Filename == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "filename is required",
+		})
+		return
+	}
+
+	if uerr := h.checkDirectUpload(user, req); uerr != nil {
+		respondJSON(w, uerr.status, Response{
+			Success: false,
+			Error:   uerr.message,
+			Code:    uerr.code,
+		})
+		return
+	}
+
+	key := h.directUploadKey(user, req.Filename)
+
+	// Storage takes exactly the declared size, which the quota was checked against
+	post, err := h.s3Service.PresignPostPolicy(r.Context(), key, service.PostPolicyConditions{
+		ContentType: req.ContentType,
+		MinSize:     req.Size,
+		MaxSize:     req.Size,
+		Expires:     presignPolicyTTL,
+	})
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create upload policy",
+		})
+		return
+	}
+
+	if err := h.trackDirectUpload(key, user.ID, req.Filename, req.Size, req.AllowEmpty); err != nil {
+		h.logger.Error("failed to record direct upload", zap.String("key", key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create upload policy",
+		})
+		return
+	}
+
+	h.logger.Info("presigned post issued", zap.String("user", user.Name), zap.String("key", key))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: PresignPostData{
+			Key:       key,
+			URL:       post.URL,
+			Fields:    post.Fields,
+			ExpiresAt: time.Now().Add(presignPolicyTTL).Format(time.RFC3339),
+		},
+	})
+}
+
+// PresignUpload issues a presigned PUT URL so the browser can upload straight to S3.
+// The client calls the complete endpoint once the PUT succeeded
+func (h *Handler) PresignUpload(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	if !auth.PermissionMap[user.Role].CanUpload {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to upload files",
+		})
+		return
+	}
+
+	var req PresignPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Filename == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "filename is required",
+		})
+		return
+	}
+
+	if uerr := h.checkDirectUpload(user, req); uerr != nil {
+		respondJSON(w, uerr.status, Response{
+			Success: false,
+			Error:   uerr.message,
+			Code:    uerr.code,
+		})
+		return
+	}
+
+	key := h.directUploadKey(user, req.Filename)
+
+	url, err := h.s3Service.PresignPutURL(r.Context(), key, req.Size, presignPolicyTTL)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create upload URL",
+		})
+		return
+	}
+
+	if err := h.trackDirectUpload(key, user.ID, req.Filename, req.Size, req.AllowEmpty); err != nil {
+		h.logger.Error("failed to record direct upload", zap.String("key", key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to create upload URL",
+		})
+		return
+	}
+
+	h.logger.Info("presigned put issued", zap.String("user", user.Name), zap.String("key", key))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: PresignUploadData{
+			Key:       key,
+			URL:       url,
+			Method:    http.MethodPut,
+			ExpiresAt: time.Now().Add(presignPolicyTTL).Format(time.RFC3339),
+		},
+	})
+}
+
+// ConfirmUpload verifies that a directly uploaded object landed with the expected size
+func (h *Handler) ConfirmUpload(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	// The role may have changed since the upload was presigned
+	if !auth.PermissionMap[user.Role].CanUpload {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to upload files",
+		})
+		return
+	}
+
+	var req ConfirmUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+	if err := validateKey(req.Key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	pending, err := h.database.TakeDirectUpload(req.Key, user.ID)
+	if errors.Is(err, db.ErrDirectUploadNotFound) {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "no pending upload for this key",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get direct upload", zap.String("key", req.Key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to confirm upload",
+		})
+		return
+	}
+
+	info, err := h.s3Service.StatFile(r.Context(), req.Key)
+	if err != nil {
+		h.logger.Warn("confirm for missing object", zap.String("key", req.Key), zap.Error(err))
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "uploaded object not found",
+		})
+		return
+	}
+
+	if info.Size == 0 && !pending.AllowEmpty {
+		h.logger.Warn("direct upload produced an empty object", zap.String("key", req.Key))
+		if err := h.s3Service.DeleteFile(r.Context(), req.Key); err != nil {
+			h.logger.Error("failed to remove empty upload", zap.String("key", req.Key), zap.Error(err))
+		}
+		respondJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   "uploaded object is empty",
+			Code:    CodeEmptyFile,
+		})
+		return
+	}
+
+	// Storage enforces the declared size, but a backend that ignores the signed length
+	// must not let an oversized object through
+	if info.Size > h.maxUploadSize {
+		h.logger.Warn("direct upload exceeds the maximum upload size", zap.String("key", req.Key), zap.Int64("size", info.Size))
+		if err := h.s3Service.DeleteFile(r.Context(), req.Key); err != nil {
+			h.logger.Error("failed to remove oversized upload", zap.String("key", req.Key), zap.Error(err))
+		}
+		respondJSON(w, http.StatusRequestEntityTooLarge, Response{
+			Success: false,
+			Error:   fmt.Sprintf("file exceeds the maximum upload size of %d bytes", h.maxUploadSize),
+			Code:    CodeTooLarge,
+		})
+		return
+	}
+
+	if info.Size != pending.Size {
+		h.logger.Warn("direct upload size mismatch", zap.String("key", req.Key), zap.Int64("expected", pending.Size), zap.Int64("actual", info.Size))
+		if err := h.s3Service.DeleteFile(r.Context(), req.Key); err != nil {
+			h.logger.Error("failed to remove mismatched upload", zap.String("key", req.Key), zap.Error(err))
+		}
+		respondJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Error:   "uploaded object size does not match",
+		})
+		return
+	}
+
+	if uerr := h.checkDirectUploadType(r.Context(), req.Key, pending.Filename, info.ContentType); uerr != nil {
+		// A busy backend says nothing about the object, so the client may confirm again
+		if uerr.code == CodeStorageBusy {
+			if err := h.trackDirectUpload(req.Key, user.ID, pending.Filename, pending.Size, pending.AllowEmpty); err != nil {
+				h.logger.Error("failed to record direct upload", zap.String("key", req.Key), zap.Error(err))
+			}
+			w.Header().Set("Retry-After", storageBusyRetryAfter)
+			respondJSON(w, uerr.status, Response{
+				Success: false,
+				Error:   uerr.message,
+				Code:    uerr.code,
+			})
+			return
+		}
+		if err := h.s3Service.DeleteFile(r.Context(), req.Key); err != nil {
+			h.logger.Error("failed to remove refused upload", zap.String("key", req.Key), zap.Error(err))
+		}
+		respondJSON(w, uerr.status, Response{
+			Success: false,
+			Error:   uerr.message,
+			Code:    uerr.code,
+		})
+		return
+	}
+
+	// Other uploads may have used up the quota since the upload was issued
+	if uerr := h.checkQuota(user.ID, info.Size); uerr != nil {
+		if err := h.s3Service.DeleteFile(r.Context(), req.Key); err != nil {
+			h.logger.Error("failed to remove upload over quota", zap.String("key", req.Key), zap.Error(err))
+		}
+		respondJSON(w, uerr.status, Response{
+			Success: false,
+			Error:   uerr.message,
+			Code:    uerr.code,
+		})
+		return
+	}
+
+	h.saveRecord(&db.FileRecord{
+		Key:          req.Key,
+		OwnerID:      user.ID,
+		OriginalName: pending.Filename,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		Category:     service.Categorize(info.ContentType, pending.Filename),
+		UploadedAt:   time.Now(),
+	})
+
+	h.logger.Info("direct upload confirmed", zap.String("user", user.Name), zap.String("key", req.Key), zap.Int64("size", info.Size))
+	// The object went straight to the bucket, past the service's replication
+	h.s3Service.ReplicateUpload(req.Key)
+	h.publish(events.ActionUploaded, user, h.s3Service, req.Key, "")
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: ConfirmUploadData{
+			Key:              req.Key,
+			Size:             info.Size,
+			DuplicateWarning: h.nearDuplicates(r, req.Key),
+		},
+	})
+}
+
+// checkDirectUpload applies the upload limits that can be checked before any content exists
+func (h *Handler) checkDirectUpload(user *auth.User, req PresignPostRequest) *uploadError {
+	if req.Size == 0 && !req.AllowEmpty {
+		return &uploadError{http.StatusBadRequest, "file is empty; pass allow_empty=true to upload it anyway", CodeEmptyFile}
+	}
+	if req.Size > h.maxUploadSize {
+		return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds the maximum upload size of %d bytes", h.maxUploadSize), CodeTooLarge}
+	}
+	if uerr := h.checkQuota(user.ID, req.Size); uerr != nil {
+		return uerr
+	}
+	if err := service.CheckFileExtension(h.uploadTypes, req.Filename); err != nil {
+		h.logger.Warn("rejected direct upload by file type", zap.String("user", user.Name), zap.String("filename", req.Filename), zap.Error(err))
+		return &uploadError{http.StatusUnsupportedMediaType, err.Error(), CodeFileType}
+	}
+	return nil
+}
+
+// checkDirectUploadType applies the full file type policy to an object that was uploaded
+// directly, by sniffing its first bytes the same way a proxied upload is checked
+func (h *Handler) checkDirectUploadType(ctx context.Context, key, filename, contentType string) *uploadError {
+	stream, err := h.s3Service.StreamFileRange(ctx, key, "bytes=0-511")
+	if errors.Is(err, service.ErrBusy) {
+		return &uploadError{http.StatusServiceUnavailable, err.Error(), CodeStorageBusy}
+	}
+	if err != nil {
+		h.logger.Error("failed to read direct upload", zap.String("key", key), zap.Error(err))
+		return &uploadError{http.StatusInternalServerError, "failed to read uploaded object", ""}
+	}
+	defer stream.Body.Close()
+
+	head, err := io.ReadAll(io.LimitReader(stream.Body, 512))
+	if err != nil {
+		h.logger.Error("failed to read direct upload", zap.String("key", key), zap.Error(err))
+		return &uploadError{http.StatusInternalServerError, "failed to read uploaded object", ""}
+	}
+
+	if err := service.CheckFileType(h.uploadTypes, filename, contentType, head); err != nil {
+		h.logger.Warn("rejected direct upload by file type", zap.String("key", key), zap.Error(err))
+		return &uploadError{http.StatusUnsupportedMediaType, err.Error(), CodeFileType}
+	}
+	return nil
+}
+
+// directUploadKey builds the key a direct upload of filename by user is stored under
+func (h *Handler) directUploadKey(user *auth.User, filename string) string {
+	return fmt.Sprintf("%s%d-%s", service.UserPrefix(user.ID), time.Now().Unix(), service.NormalizeFilename(filename, h.keyPolicy))
+}
+
+// trackDirectUpload records a pending direct upload of size bytes; expired ones are
+// cleared by RunDirectUploadSweep
+func (h *Handler) trackDirectUpload(key, userID, filename string, size int64, allowEmpty bool) error {
+	return h.database.SaveDirectUpload(&db.DirectUpload{
+		Key:        key,
+		UserID:     userID,
+		Filename:   filename,
+		Size:       size,
+		AllowEmpty: allowEmpty,
+		ExpiresAt:  time.Now().Add(presignPolicyTTL),
+	})
+}
+
+// RunDirectUploadSweep forgets direct uploads that were never confirmed and removes
+// anything the client uploaded for them, until ctx is cancelled. Pending uploads are
+// kept in the database, so the ones issued before a restart are swept too
+func (h *Handler) RunDirectUploadSweep(ctx context.Context) {
+	ticker := time.NewTicker(presignPolicyTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed := h.sweepDirectUploads(ctx); removed > 0 {
+				h.logger.Info("removed unconfirmed direct uploads", zap.Int("count", removed))
+			}
+		}
+	}
+}
+
+// sweepDirectUploads drops pending uploads past their grace period and deletes their
+// objects, returning how many objects were removed
+func (h *Handler) sweepDirectUploads(ctx context.Context) int {
+	cutoff := time.Now().Add(-directUploadGrace)
+	expired, err := h.database.ListDirectUploadsBefore(cutoff)
+	if err != nil {
+		h.logger.Error("failed to list unconfirmed uploads", zap.Error(err))
+		return 0
+	}
+
+	removed := 0
+	for _, pending := range expired {
+		// A confirmation that took the entry in the meantime wins
+		claimed, err := h.database.ClaimExpiredDirectUpload(pending.Key, cutoff)
+		if err != nil {
+			h.logger.Error("failed to claim unconfirmed upload", zap.String("key", pending.Key), zap.Error(err))
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if _, err := h.s3Service.StatFile(ctx, pending.Key); err != nil {
+			// Nothing was uploaded; on any other error the next sweep tries again
+			if !errors.Is(err, service.ErrNotFound) {
+				h.logger.Error("failed to check unconfirmed upload", zap.String("key", pending.Key), zap.Error(err))
+				h.restoreDirectUpload(pending)
+			}
+			continue
+		}
+		if err := h.s3Service.DeleteFile(ctx, pending.Key); err != nil {
+			h.logger.Error("failed to remove unconfirmed upload", zap.String("key", pending.Key), zap.Error(err))
+			h.restoreDirectUpload(pending)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// restoreDirectUpload puts back a pending upload the sweep claimed but couldn't clean up
+func (h *Handler) restoreDirectUpload(pending *db.DirectUpload) {
+	if err := h.database.SaveDirectUpload(pending); err != nil {
+		h.logger.Error("failed to restore unconfirmed upload", zap.String("key", pending.Key), zap.Error(err))
+	}
+}
\ No newline at end of file
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+)
+
+// UserQuotaRequest overrides a user's storage quota. A null quota_bytes returns the
+// user to the default and 0 lifts the limit
+type UserQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
+
+// UserQuotaData describes a user's storage quota and how much of it is used
+type UserQuotaData struct {
+	UserID string `json:"user_id"`
+	// Override is the per-user quota, or null when the default applies
+	Override *int64 `json:"override"`
+	// Quota is the quota in effect; 0 means unlimited
+	Quota int64 `json:"quota"`
+	Used  int64 `json:"used"`
+}
+
+// SetDefaultQuota sets the storage quota of users without an override; 0 means unlimited
+func (h *Handler) SetDefaultQuota(quota int64) {
+	h.defaultQuota = quota
+}
+
+// quotaFor returns the quota in effect for userID and its override, if any
+func (h *Handler) quotaFor(userID string) (int64, *int64, error) {
+	override, err := h.database.GetUserQuota(userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if override != nil {
+		return *override, override, nil
+	}
+	return h.defaultQuota, nil, nil
+}
+
+// checkQuota refuses to store incoming more bytes for ownerID when that would take them
+// past their quota. Only the primary bucket keeps the records usage is counted from
+func (h *Handler) checkQuota(ownerID string, incoming int64) *uploadError {
+	quota, _, err := h.quotaFor(ownerID)
+	if err != nil {
+		h.logger.Error("failed to get storage quota", zap.String("owner", ownerID), zap.Error(err))
+		return &uploadError{http.StatusInternalServerError, "failed to check storage quota", ""}
+	}
+	if quota == 0 {
+		return nil
+	}
+
+	used, err := h.database.SumFileSizesByOwner(ownerID)
+	if err != nil {
+		h.logger.Error("failed to get storage usage", zap.String("owner", ownerID), zap.Error(err))
+		return &uploadError{http.StatusInternalServerError, "failed to check storage quota", ""}
+	}
+
+	if used+incoming > quota {
+		h.logger.Warn("storage quota exceeded", zap.String("owner", ownerID), zap.Int64("quota", quota), zap.Int64("used", used), zap.Int64("incoming", incoming))
+		return &uploadError{
+			http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("storage quota exceeded: %d of %d bytes used, %d more requested", used, quota, incoming),
+			CodeQuotaExceeded,
+		}
+	}
+	return nil
+}
+
+// GetUserQuota returns a user's storage quota and usage (admin only)
+func (h *Handler) GetUserQuota(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+
+	data, err := h.userQuotaData(userID)
+	if err != nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// UpdateUserQuota overrides a user's storage quota (admin only). Files already stored
+// stay even if they no longer fit; only new uploads are refused
+func (h *Handler) UpdateUserQuota(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	userID := chi.URLParam(r, "id")
+
+	var req UserQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	if req.QuotaBytes != nil && *req.QuotaBytes < 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "quota_bytes must not be negative",
+		})
+		return
+	}
+
+	if err := h.database.SetUserQuota(userID, req.QuotaBytes); err != nil {
+		h.logger.Warn("failed to set user quota", zap.String("user_id", userID), zap.Error(err))
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "user not found",
+		})
+		return
+	}
+
+	data, err := h.userQuotaData(userID)
+	if err != nil {
+		h.logger.Error("failed to read back user quota", zap.String("user_id", userID), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to read user quota",
+		})
+		return
+	}
+
+	h.logger.Info("user quota updated", zap.String("admin", user.ID), zap.String("user_id", userID), zap.Int64("quota", data.Quota))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// userQuotaData gathers the quota and usage of userID
+func (h *Handler) userQuotaData(userID string) (UserQuotaData, error) {
+	quota, override, err := h.quotaFor(userID)
+	if err != nil {
+		return UserQuotaData{}, err
+	}
+	used, err := h.database.SumFileSizesByOwner(userID)
+	if err != nil {
+		return UserQuotaData{}, err
+	}
+	return UserQuotaData{
+		UserID:   userID,
+		Override: override,
+		Quota:    quota,
+		Used:     used,
+	}, nil
+}
\ No newline at end of file
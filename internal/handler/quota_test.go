@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+)
+
+// updateUserQuota sets the quota override of userID through the admin endpoint
+func updateUserQuota(t *testing.T, h *Handler, admin *auth.User, userID string, body any) *httptest.ResponseRecorder {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", userID)
+	r := jsonRequest(t, http.MethodPut, "/api/admin/users/"+userID+"/quota", body)
+	r = asUser(r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx)), admin)
+	rec := httptest.NewRecorder()
+	h.UpdateUserQuota(rec, r)
+	return rec
+}
+
+// uploadBytes uploads n bytes as user and returns the response
+func uploadBytes(t *testing.T, h *Handler, user *auth.User, name string, n int) *httptest.ResponseRecorder {
+	return uploadFile(h, user, uploadRequest(t, nil, testFile{name: name, content: make([]byte, n)}))
+}
+
+func expectQuotaExceeded(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeQuotaExceeded {
+		t.Errorf("code = %q, want %q", resp.Code, CodeQuotaExceeded)
+	}
+}
+
+func TestQuotaBoundary(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	h.SetDefaultQuota(10)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	if rec := uploadBytes(t, h, user, "six.bin", 6); rec.Code != http.StatusOK {
+		t.Fatalf("first upload: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	expectQuotaExceeded(t, uploadBytes(t, h, user, "five.bin", 5))
+	if rec := uploadBytes(t, h, user, "four.bin", 4); rec.Code != http.StatusOK {
+		t.Fatalf("upload filling the quota exactly: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	expectQuotaExceeded(t, uploadBytes(t, h, user, "one.bin", 1))
+
+	if keys := fake.Keys(testBucket); len(keys) != 2 {
+		t.Errorf("bucket holds %v, want only the two uploads that fit", keys)
+	}
+
+	// Other users have their own allowance
+	other := createTestUser(t, database, "bob", auth.RoleUploader)
+	if rec := uploadBytes(t, h, other, "ten.bin", 10); rec.Code != http.StatusOK {
+		t.Errorf("other user: status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestQuotaUnlimitedByDefault(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for i := range 3 {
+		if rec := uploadBytes(t, h, user, "big.bin", testMaxUploadSize); rec.Code != http.StatusOK {
+			t.Fatalf("upload %d: status = %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestQuotaOverride(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	h.SetDefaultQuota(10)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	five, zero := int64(5), int64(0)
+	rec := updateUserQuota(t, h, admin, user.ID, UserQuotaRequest{QuotaBytes: &five})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var data UserQuotaData
+	decodeData(t, rec, &data)
+	if data.Quota != 5 || data.Override == nil || *data.Override != 5 {
+		t.Errorf("quota = %+v, want an override of 5", data)
+	}
+	expectQuotaExceeded(t, uploadBytes(t, h, user, "six.bin", 6))
+
+	// 0 lifts the limit
+	if rec := updateUserQuota(t, h, admin, user.ID, UserQuotaRequest{QuotaBytes: &zero}); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := uploadBytes(t, h, user, "twenty.bin", 20); rec.Code != http.StatusOK {
+		t.Fatalf("unlimited upload: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// null returns the user to the default, which the stored files already exceed
+	rec = updateUserQuota(t, h, admin, user.ID, UserQuotaRequest{})
+	decodeData(t, rec, &data)
+	if data.Quota != 10 || data.Override != nil || data.Used != 20 {
+		t.Errorf("quota = %+v, want the default of 10 with 20 used", data)
+	}
+	expectQuotaExceeded(t, uploadBytes(t, h, user, "one.bin", 1))
+
+	negative := int64(-1)
+	if rec := updateUserQuota(t, h, admin, user.ID, UserQuotaRequest{QuotaBytes: &negative}); rec.Code != http.StatusBadRequest {
+		t.Errorf("negative quota: status = %d, want 400", rec.Code)
+	}
+	if rec := updateUserQuota(t, h, admin, "nobody", UserQuotaRequest{QuotaBytes: &five}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestQuotaFreedByDeleteAndCheckedOnRestore(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	h.SetDefaultQuota(10)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	rec := uploadBytes(t, h, admin, "first.bin", 10)
+	var first UploadData
+	decodeData(t, rec, &first)
+
+	rec = httptest.NewRecorder()
+	h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(first.Key), nil), admin))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := uploadBytes(t, h, admin, "second.bin", 10); rec.Code != http.StatusOK {
+		t.Fatalf("upload after delete: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListTrash(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/trash", nil), admin))
+	var trash ListTrashData
+	decodeData(t, rec, &trash)
+	if trash.Count != 1 {
+		t.Fatalf("trash = %+v, want the deleted file", trash.Items)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RestoreTrash(rec, asUser(httptest.NewRequest(http.MethodPost, "/api/trash/restore?key="+url.QueryEscape(trash.Items[0].Key), nil), admin))
+	expectQuotaExceeded(t, rec)
+}
+
+func TestDirectUploadChecksQuota(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	h.SetDefaultQuota(10)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, tc := range []struct {
+		size int64
+		want int
+	}{
+		{10, http.StatusOK},
+		{11, http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		h.PresignUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/presign", PresignPostRequest{Filename: "file.bin", Size: tc.size}), user))
+		if rec.Code != tc.want {
+			t.Errorf("size %d: status = %d, want %d: %s", tc.size, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+}
+
+func TestLimitsReportQuota(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	h.SetDefaultQuota(10)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	uploadBytes(t, h, user, "four.bin", 4)
+
+	rec := httptest.NewRecorder()
+	h.GetLimits(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/limits", nil), user))
+	var limits LimitsData
+	decodeData(t, rec, &limits)
+	if limits.Quota != 10 || limits.QuotaUsed != 4 {
+		t.Errorf("limits = %+v, want a quota of 10 with 4 used", limits)
+	}
+}
+
+func TestQuotaAppliesToOtherBuckets(t *testing.T) {
+	h, database, fake := newTestHandler(t, func(cfg *config.S3Config) {
+		cfg.Buckets = "archive"
+		cfg.AutoCreateBucket = true
+	})
+	h.SetDefaultQuota(10)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	toArchive := func(user *auth.User, name string, n int) *httptest.ResponseRecorder {
+		r := uploadRequest(t, nil, testFile{name: name, content: make([]byte, n)})
+		r.URL.RawQuery = "bucket=archive"
+		return uploadFile(h, user, r)
+	}
+
+	// The archive keeps no usage records, so only admins may write there
+	if rec := toArchive(user, "small.bin", 1); rec.Code != http.StatusForbidden {
+		t.Errorf("uploader: status = %d, want 403: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := uploadBytes(t, h, admin, "six.bin", 6); rec.Code != http.StatusOK {
+		t.Fatalf("primary upload: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	expectQuotaExceeded(t, toArchive(admin, "five.bin", 5))
+	if rec := toArchive(admin, "four.bin", 4); rec.Code != http.StatusOK {
+		t.Errorf("archive upload within the quota: status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if keys := fake.Keys("archive"); len(keys) != 1 {
+		t.Errorf("archive holds %v, want only the upload that fit", keys)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"s3-test-app/internal/auth"
+)
+
+func renameFile(t *testing.T, h *Handler, user *auth.User, req RenameRequest) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.RenameFile(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/rename", req), user))
+	return rec
+}
+
+func TestRenameFile(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "reports/draft.txt", []byte("draft"))
+
+	rec := renameFile(t, h, admin, RenameRequest{From: "reports/draft.txt", To: "reports/final.txt"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if keys := fake.Keys(testBucket); !slices.Equal(keys, []string{"reports/final.txt"}) {
+		t.Errorf("keys = %v, want only the renamed file", keys)
+	}
+}
+
+func TestRenameFileRejectsInvalidKeys(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	fake.Put(testBucket, "reports/draft.txt", []byte("draft"))
+
+	for _, req := range []RenameRequest{
+		{From: "reports/../reports/draft.txt", To: "reports/final.txt"},
+		{From: "reports/./draft.txt", To: "reports/final.txt"},
+		{From: "reports/draft.txt", To: "reports/../../final.txt"},
+		{From: "reports/draft.txt", To: "/reports/final.txt"},
+		{From: "reports/draft.txt", To: "reports\\final.txt"},
+		{From: "reports/draft.txt", To: "reports/final\x00.txt"},
+		{From: "", To: "reports/final.txt"},
+		{From: "reports/draft.txt", To: "reports/"},
+		{From: "reports/draft.txt", To: "reports/draft.txt"},
+	} {
+		if rec := renameFile(t, h, admin, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%q -> %q: status = %d, want 400", req.From, req.To, rec.Code)
+		}
+	}
+	if keys := fake.Keys(testBucket); !slices.Equal(keys, []string{"reports/draft.txt"}) {
+		t.Errorf("keys = %v, want the source untouched", keys)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/service"
+)
+
+// ReplicationData is the payload of the replication status endpoint
+type ReplicationData struct {
+	service.ReplicationStatus
+	// Pending is how many keys wait to be copied to the secondary, Retrying how many of
+	// them failed at least once
+	Pending        int        `json:"pending"`
+	Retrying       int        `json:"retrying"`
+	OldestQueuedAt *time.Time `json:"oldest_queued_at,omitempty"`
+}
+
+// ReplicationStatus reports the depth of the replication queue and the last error of
+// replication to the secondary endpoint
+func (h *Handler) ReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	data := ReplicationData{ReplicationStatus: h.s3Service.ReplicationStatus()}
+	if !data.Enabled {
+		respondJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data:    data,
+		})
+		return
+	}
+
+	stats, err := h.database.ReplicationQueueStats()
+	if err != nil {
+		h.logger.Error("failed to read replication queue", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to read replication queue",
+		})
+		return
+	}
+	data.Pending = stats.Pending
+	data.Retrying = stats.Retrying
+	data.OldestQueuedAt = stats.OldestQueuedAt
+	// Failures from before a restart are only known to the queue
+	if data.LastError == "" && stats.LastError != "" {
+		data.LastError = stats.LastError
+		data.LastErrorKey = stats.LastErrorKey
+		data.LastErrorAt = stats.LastAttemptAt
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
\ No newline at end of file
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRespondJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondJSON(rec, http.StatusCreated, Response{
+		Success: true,
+		Data:    MessageData{Message: "created"},
+	})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if got, want := rec.Body.String(), `{"success":true,"data":{"message":"created"}}`+"\n"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestRespondJSONEncodingFailureIsClean500(t *testing.T) {
+	rec := httptest.NewRecorder()
+	respondJSON(rec, http.StatusOK, Response{Success: true, Data: math.Inf(1)})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Success || resp.Error == "" {
+		t.Errorf("body = %q, want a complete error envelope", rec.Body.String())
+	}
+}
+
+func TestRespondJSONDoesNotLeakPooledBuffers(t *testing.T) {
+	large := strings.Repeat("x", maxPooledBuffer*2)
+	respondJSON(httptest.NewRecorder(), http.StatusOK, Response{Error: large})
+
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		respondJSON(rec, http.StatusOK, Response{Success: true})
+		if got := rec.Body.String(); got != `{"success":true}`+"\n" {
+			t.Fatalf("body = %q, a pooled buffer kept earlier output", got)
+		}
+	}
+}
+
+func BenchmarkRespondJSON(b *testing.B) {
+	payload := Response{
+		Success: true,
+		Data: UploadData{
+			Key:         "users/alice-id/1712345-report.pdf",
+			Filename:    "report.pdf",
+			Size:        1 << 20,
+			ContentType: "application/pdf",
+		},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		respondJSON(httptest.NewRecorder(), http.StatusOK, payload)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+const (
+	// defaultSearchLimit and maxSearchLimit bound the page size of a search
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+	// maxSearchScan caps how many objects a search of a bucket without file records reads
+	maxSearchScan = 100000
+)
+
+// SearchFilesData is the payload of the search endpoint. Total is only known when the
+// search ran against the file records; Truncated means a bucket scan gave up early
+type SearchFilesData struct {
+	Query     string      `json:"query"`
+	Files     []FileEntry `json:"files"`
+	Count     int         `json:"count"`
+	Total     *int        `json:"total,omitempty"`
+	Offset    int         `json:"offset"`
+	Limit     int         `json:"limit"`
+	HasMore   bool        `json:"has_more"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Bucket    string      `json:"bucket"`
+}
+
+// SearchFiles finds files whose original name or key contains ?q=, optionally filtered
+// by min_size, max_size, from, to, content_type and uploader, a page at a time with
+// limit and offset. The primary bucket is searched through its file records; other
+// buckets have none and are scanned instead, without the content type filter
+func (h *Handler) SearchFiles(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	search, err := parseFileSearch(r.URL.Query())
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Non-admins only search their own folder
+	if user.Role != auth.RoleAdmin {
+		if search.OwnerID != "" && search.OwnerID != user.ID {
+			respondJSON(w, http.StatusForbidden, Response{
+				Success: false,
+				Error:   "access denied to files outside your folder",
+			})
+			return
+		}
+		search.KeyPrefix = service.UserPrefix(user.ID)
+	}
+
+	held, err := h.heldPrefixes()
+	if err != nil {
+		h.logger.Error("failed to load legal holds", zap.Error(err))
+	}
+
+	data := SearchFilesData{
+		Query:  search.Query,
+		Offset: search.Offset,
+		Limit:  search.Limit,
+		Bucket: storage.Name(),
+	}
+
+	if storage == h.s3Service {
+		records, total, err := h.database.SearchFileRecords(search)
+		if err != nil {
+			h.logger.Error("failed to search files", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to search files",
+			})
+			return
+		}
+		data.Files = make([]FileEntry, 0, len(records))
+		for _, record := range records {
+			category := record.Category
+			if category == "" {
+				category = service.Categorize(record.ContentType, record.OriginalName)
+			}
+			data.Files = append(data.Files, FileEntry{
+				File: service.File{
+					Key:          record.Key,
+					Size:         record.Size,
+					LastModified: record.UploadedAt,
+				},
+				OriginalName: record.OriginalName,
+				ContentType:  record.ContentType,
+				Category:     category,
+				OwnerID:      record.OwnerID,
+				LegalHold:    heldPrefixOf(held, record.Key) != "",
+			})
+		}
+		data.Total = &total
+		data.HasMore = search.Offset+len(records) < total
+	} else {
+		if search.ContentType != "" {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "content_type can only be searched in the primary bucket",
+			})
+			return
+		}
+		data.Files, data.HasMore, data.Truncated, err = h.scanSearch(r, storage, search, held)
+		if err != nil {
+			h.logger.Error("failed to scan files for search", zap.String("bucket", storage.Name()), zap.Error(err))
+			respondStorageError(w, err, "failed to search files")
+			return
+		}
+	}
+
+	data.Count = len(data.Files)
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// scanSearch runs search over a bucket without file records by listing its objects.
+// The owner filter becomes the owner's folder, and names come from the keys
+func (h *Handler) scanSearch(r *http.Request, storage *service.S3Service, search db.FileSearch, held []string) (files []FileEntry, hasMore, truncated bool, err error) {
+	prefix := search.KeyPrefix
+	if search.OwnerID != "" {
+		prefix = service.UserPrefix(search.OwnerID)
+	}
+	query := strings.ToLower(search.Query)
+
+	files = make([]FileEntry, 0)
+	matched, scanned := 0, 0
+	err = storage.ScanFiles(r.Context(), prefix, func(file service.File) bool {
+		scanned++
+		if scanned > maxSearchScan {
+			truncated = true
+			return false
+		}
+		if !strings.Contains(strings.ToLower(file.Key), query) ||
+			(search.MinSize > 0 && file.Size < search.MinSize) ||
+			(search.MaxSize > 0 && file.Size > search.MaxSize) ||
+			(!search.UploadedFrom.IsZero() && file.LastModified.Before(search.UploadedFrom)) ||
+			(!search.UploadedBefore.IsZero() && !file.LastModified.Before(search.UploadedBefore)) {
+			return true
+		}
+
+		matched++
+		if matched <= search.Offset {
+			return true
+		}
+		if len(files) == search.Limit {
+			hasMore = true
+			return false
+		}
+		name := service.OriginalName(file.Key)
+		files = append(files, FileEntry{
+			File:         file,
+			OriginalName: name,
+			Category:     service.Categorize("", name),
+			OwnerID:      service.UserFromKey(file.Key),
+			LegalHold:    heldPrefixOf(held, file.Key) != "",
+		})
+		return true
+	})
+	return files, hasMore, truncated, err
+}
+
+// parseFileSearch reads the search parameters of a query string
+func parseFileSearch(query url.Values) (db.FileSearch, error) {
+	search := db.FileSearch{
+		Query:       strings.TrimSpace(query.Get("q")),
+		OwnerID:     query.Get("uploader"),
+		ContentType: strings.ToLower(strings.TrimSpace(query.Get("content_type"))),
+		Limit:       defaultSearchLimit,
+	}
+	if search.Query == "" {
+		return search, fmt.Errorf("q parameter required")
+	}
+	if search.ContentType != "" && !strings.Contains(search.ContentType, "/") {
+		return search, fmt.Errorf("content_type must be a media type such as image/png or image/*")
+	}
+
+	var err error
+	if search.MinSize, err = parseNonNegative(query, "min_size"); err != nil {
+		return search, err
+	}
+	if search.MaxSize, err = parseNonNegative(query, "max_size"); err != nil {
+		return search, err
+	}
+	if search.MaxSize > 0 && search.MinSize > search.MaxSize {
+		return search, fmt.Errorf("min_size must not be larger than max_size")
+	}
+
+	if raw := query.Get("from"); raw != "" {
+		if search.UploadedFrom, err = parseSearchDate(raw, false); err != nil {
+			return search, fmt.Errorf("from: %w", err)
+		}
+	}
+	if raw := query.Get("to"); raw != "" {
+		if search.UploadedBefore, err = parseSearchDate(raw, true); err != nil {
+			return search, fmt.Errorf("to: %w", err)
+		}
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			return search, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+		}
+		search.Limit = limit
+	}
+	if raw := query.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return search, fmt.Errorf("offset must not be negative")
+		}
+		search.Offset = offset
+	}
+
+	return search, nil
+}
+
+// parseNonNegative reads an optional byte count from the query; a missing one is 0
+func parseNonNegative(query url.Values, name string) (int64, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number of bytes", name)
+	}
+	return n, nil
+}
+
+// parseSearchDate accepts an RFC 3339 time or a plain date. A plain date used as the end
+// of a range covers that whole day
+func parseSearchDate(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date like 2006-01-02 or an RFC 3339 time")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
\ No newline at end of file
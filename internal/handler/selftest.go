@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// S3SelfTest runs the storage permission self-test against the bucket parameter, or the
+// primary bucket without it. It answers 503 when a step failed.
+func (h *Handler) S3SelfTest(w http.ResponseWriter, r *http.Request) {
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result := storage.SelfTest(r.Context())
+	if err := result.Err(); err != nil {
+		h.logger.Warn("s3 self-test failed", zap.String("bucket", result.Bucket), zap.String("step", result.FailedStep), zap.Error(err))
+		respondJSON(w, http.StatusServiceUnavailable, Response{
+			Success: false,
+			Error:   err.Error(),
+			Data:    result,
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    result,
+	})
+}
@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/ratelimit"
+)
+
+// rateLimitSettingPrefix namespaces the settings that override rate limit policies
+const rateLimitSettingPrefix = "ratelimit."
+
+// SettingsHandler lets admins change runtime settings without a restart
+type SettingsHandler struct {
+	database  *db.Database
+	logger    *zap.Logger
+	approvals *ApprovalHandler
+	limits    *ratelimit.Registry
+
+	// defaults holds the configured value of every setting, used when an override is removed
+	defaults map[string]string
+
+	// mu serializes changes so the stored and applied settings move together
+	mu sync.Mutex
+}
+
+// UpdateSettingRequest is the request body of the setting update endpoint
+type UpdateSettingRequest struct {
+	Value string `json:"value"`
+}
+
+// UpdateSettingsRequest is the request body of the bulk settings update endpoint.
+// A null value resets that setting to its default.
+type UpdateSettingsRequest struct {
+	Version *int64             `json:"version"`
+	Changes map[string]*string `json:"changes"`
+}
+
+// SettingData describes one setting and where its current value comes from
+type SettingData struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Default    string `json:"default"`
+	Overridden bool   `json:"overridden"`
+	UpdatedBy  string `json:"updated_by,omitempty"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+// SettingsData is the payload of the settings listing endpoint
+type SettingsData struct {
+	Version  int64         `json:"version"`
+	Settings []SettingData `json:"settings"`
+}
+
+// settingsError is a rejected settings change with the status it maps to
+type settingsError struct {
+	status  int
+	message string
+}
+
+// Error returns the message reported to the client
+func (e *settingsError) Error() string {
+	return e.message
+}
+
+// NewSettingsHandler creates a new settings handler
+func NewSettingsHandler(database *db.Database, logger *zap.Logger, approvals *ApprovalHandler, limits *ratelimit.Registry) *SettingsHandler {
+	defaults := make(map[string]string)
+	for _, name := range limits.Names() {
+		defaults[rateLimitSettingPrefix+name] = limits.Policy(name).Spec().String()
+	}
+
+	return &SettingsHandler{
+		database:  database,
+		logger:    logger,
+		approvals: approvals,
+		limits:    limits,
+		defaults:  defaults,
+	}
+}
+
+// LoadOverrides applies the settings stored by admins. Invalid stored values are logged and skipped.
+func (h *SettingsHandler) LoadOverrides() error {
+	settings, err := h.database.ListSettings()
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range settings {
+		if err := h.apply(setting.Key, setting.Value); err != nil {
+			h.logger.Warn("ignoring stored setting", zap.String("key", setting.Key), zap.Error(err))
+			continue
+		}
+		h.logger.Info("setting override applied", zap.String("key", setting.Key), zap.String("value", setting.Value))
+	}
+
+	return nil
+}
+
+// ListSettings returns every setting with its current and default value. The
+// version is also sent as the ETag, to be echoed back by bulk updates.
+func (h *SettingsHandler) ListSettings(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	data, err := h.snapshot()
+	h.mu.Unlock()
+	if err != nil {
+		h.logger.Error("failed to list settings", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to retrieve settings",
+		})
+		return
+	}
+
+	w.Header().Set("ETag", settingsETag(data.Version))
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// UpdateSettings applies several changes at once, only if the settings are still at
+// the version the admin was editing. The version comes from the body or If-Match.
+func (h *SettingsHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+
+	var req UpdateSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	if req.Version == nil {
+		if match := r.Header.Get("If-Match"); match != "" {
+			version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(match, "W/"), `"`), 10, 64)
+			if err != nil {
+				respondJSON(w, http.StatusBadRequest, Response{
+					Success: false,
+					Error:   "invalid If-Match header",
+				})
+				return
+			}
+			req.Version = &version
+		}
+	}
+	if req.Version == nil || *req.Version < 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "the settings version being edited is required",
+		})
+		return
+	}
+	if len(req.Changes) == 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "changes are required",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.commit(admin, *req.Version, req.Changes)
+	if errors.Is(err, db.ErrSettingsConflict) {
+		// Send the current state so the admin can redo the edit on top of it
+		current, snapErr := h.snapshot()
+		if snapErr != nil {
+			h.logger.Error("failed to list settings", zap.Error(snapErr))
+		} else {
+			w.Header().Set("ETag", settingsETag(current.Version))
+		}
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Data:    current,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	keys := make([]string, 0, len(req.Changes))
+	for key := range req.Changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	details, _ := json.Marshal(req.Changes)
+	h.logger.Info("settings updated", zap.String("admin", admin.ID), zap.Strings("keys", keys))
+	h.approvals.Audit(admin, "update_settings", strings.Join(keys, ","), string(details))
+
+	data, err := h.snapshot()
+	if err != nil {
+		h.logger.Error("failed to list settings", zap.Error(err))
+	}
+	w.Header().Set("ETag", settingsETag(data.Version))
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// UpdateSetting validates, stores and applies a new value for a setting
+func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+	key := chi.URLParam(r, "key")
+
+	if _, ok := h.defaults[key]; !ok {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "unknown setting",
+		})
+		return
+	}
+
+	var req UpdateSettingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// A single-key edit always applies on top of the latest version
+	if _, err := h.commit(admin, -1, map[string]*string{key: &req.Value}); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	h.logger.Info("setting updated", zap.String("admin", admin.ID), zap.String("key", key), zap.String("value", req.Value))
+	h.approvals.Audit(admin, "update_setting", key, req.Value)
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "setting updated",
+		},
+	})
+}
+
+// ResetSetting removes an override and restores the configured value
+func (h *SettingsHandler) ResetSetting(w http.ResponseWriter, r *http.Request) {
+	admin := auth.GetUserFromContext(r.Context())
+	key := chi.URLParam(r, "key")
+
+	value, ok := h.defaults[key]
+	if !ok {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "unknown setting",
+		})
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.commit(admin, -1, map[string]*string{key: nil}); err != nil {
+		var serr *settingsError
+		if errors.As(err, &serr) {
+			h.writeError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "setting is not overridden",
+		})
+		return
+	}
+
+	h.logger.Info("setting reset", zap.String("admin", admin.ID), zap.String("key", key))
+	h.approvals.Audit(admin, "reset_setting", key, value)
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: MessageData{
+			Message: "setting reset to default",
+		},
+	})
+}
+
+// commit validates changes against the resulting set of settings, stores them in one
+// transaction at version expected (negative skips the check) and then applies them.
+// A nil value resets that setting. The caller must hold h.mu.
+func (h *SettingsHandler) commit(admin *auth.User, expected int64, changes map[string]*string) (int64, error) {
+	// The settings as they would be after the change, so rules spanning keys can be checked
+	specs := make(map[string]config.RateLimitSpec, len(h.defaults))
+	for _, name := range h.limits.Names() {
+		specs[name] = h.limits.Policy(name).Spec()
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	dbChanges := make([]db.SettingChange, 0, len(changes))
+	for _, key := range keys {
+		value := changes[key]
+		name, ok := strings.CutPrefix(key, rateLimitSettingPrefix)
+		if _, known := h.defaults[key]; !ok || !known {
+			return 0, &settingsError{http.StatusBadRequest, fmt.Sprintf("unknown setting %q", key)}
+		}
+
+		effective := h.defaults[key]
+		if value != nil {
+			effective = *value
+		}
+		spec, err := config.ParseRateLimitSpec(effective)
+		if err != nil {
+			return 0, &settingsError{http.StatusBadRequest, fmt.Sprintf("%s: %v", key, err)}
+		}
+		specs[name] = spec
+		dbChanges = append(dbChanges, db.SettingChange{Key: key, Value: value})
+	}
+
+	if err := checkSettingInvariants(specs); err != nil {
+		return 0, &settingsError{http.StatusBadRequest, err.Error()}
+	}
+
+	version, err := h.database.ApplySettings(expected, dbChanges, admin.ID)
+	if err != nil {
+		return version, err
+	}
+
+	// Stored, so put every change into effect together
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, rateLimitSettingPrefix)
+		h.limits.Policy(name).Update(specs[name])
+	}
+
+	return version, nil
+}
+
+// snapshot returns every setting with the current version. The caller must hold h.mu.
+func (h *SettingsHandler) snapshot() (SettingsData, error) {
+	version, err := h.database.SettingsVersion()
+	if err != nil {
+		return SettingsData{}, err
+	}
+
+	stored, err := h.database.ListSettings()
+	if err != nil {
+		return SettingsData{}, err
+	}
+
+	overrides := make(map[string]*db.Setting, len(stored))
+	for _, setting := range stored {
+		overrides[setting.Key] = setting
+	}
+
+	items := make([]SettingData, 0, len(h.defaults))
+	for _, name := range h.limits.Names() {
+		key := rateLimitSettingPrefix + name
+		item := SettingData{
+			Key:     key,
+			Value:   h.limits.Policy(name).Spec().String(),
+			Default: h.defaults[key],
+		}
+		if setting, ok := overrides[key]; ok {
+			item.Overridden = true
+			item.UpdatedBy = setting.UpdatedBy
+			item.UpdatedAt = setting.UpdatedAt.Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+
+	return SettingsData{
+		Version:  version,
+		Settings: items,
+	}, nil
+}
+
+// writeError answers a failed settings change
+func (h *SettingsHandler) writeError(w http.ResponseWriter, err error) {
+	var serr *settingsError
+	if errors.As(err, &serr) {
+		respondJSON(w, serr.status, Response{
+			Success: false,
+			Error:   serr.message,
+		})
+		return
+	}
+
+	h.logger.Error("failed to save settings", zap.Error(err))
+	respondJSON(w, http.StatusInternalServerError, Response{
+		Success: false,
+		Error:   "failed to save settings",
+	})
+}
+
+// settingsETag formats a settings version as an ETag
+func settingsETag(version int64) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(version, 10))
+}
+
+// checkSettingInvariants rejects combinations of settings that are each valid but
+// don't make sense together
+func checkSettingInvariants(specs map[string]config.RateLimitSpec) error {
+	// Downloads pass both limits, so a looser download limit would never take effect
+	heavy, okHeavy := specs["download-heavy"]
+	base, okBase := specs["api-default"]
+	if okHeavy && okBase && perSecond(heavy) > perSecond(base) {
+		return fmt.Errorf("download-heavy (%s) must not allow more than api-default (%s), which also applies to downloads", heavy, base)
+	}
+	return nil
+}
+
+// perSecond returns the sustained rate a spec allows
+func perSecond(spec config.RateLimitSpec) float64 {
+	return float64(spec.Rate) / spec.Per.Seconds()
+}
+
+// apply validates value and puts it into effect
+func (h *SettingsHandler) apply(key, value string) error {
+	name, ok := strings.CutPrefix(key, rateLimitSettingPrefix)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	policy, ok := h.limits.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown rate limit policy %q", name)
+	}
+
+	spec, err := config.ParseRateLimitSpec(value)
+	if err != nil {
+		return err
+	}
+	policy.Update(spec)
+	return nil
+}
\ No newline at end of file
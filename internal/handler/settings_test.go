@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/ratelimit"
+)
+
+// newTestSettings creates a settings handler over an api-default and a download-heavy policy
+func newTestSettings(t *testing.T) (*SettingsHandler, *ratelimit.Registry, *db.Database) {
+	t.Helper()
+	database := newTestDatabase(t)
+	limits := ratelimit.NewRegistry(map[string]config.RateLimitSpec{
+		"api-default":    {Rate: 100, Per: time.Minute, Burst: 100, Key: config.RateLimitByUser},
+		"download-heavy": {Rate: 10, Per: time.Minute, Burst: 10, Key: config.RateLimitByUser},
+	})
+	return NewSettingsHandler(database, zap.NewNop(), newTestApprovals(database), limits), limits, database
+}
+
+func listSettings(t *testing.T, h *SettingsHandler, admin *auth.User) (SettingsData, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ListSettings(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/admin/settings", nil), admin))
+	var data SettingsData
+	decodeData(t, rec, &data)
+	return data, rec.Header().Get("ETag")
+}
+
+func updateSettings(t *testing.T, h *SettingsHandler, admin *auth.User, req UpdateSettingsRequest, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := jsonRequest(t, http.MethodPut, "/api/admin/settings", req)
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	h.UpdateSettings(rec, asUser(r, admin))
+	return rec
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestUpdateSettingsAppliesAtVersion(t *testing.T) {
+	h, limits, database := newTestSettings(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	before, etag := listSettings(t, h, admin)
+
+	rec := updateSettings(t, h, admin, UpdateSettingsRequest{
+		Version: &before.Version,
+		Changes: map[string]*string{"ratelimit.api-default": ptr("200/1m,burst=50,key=user")},
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if got := limits.Policy("api-default").Spec(); got.Rate != 200 || got.Burst != 50 {
+		t.Errorf("api-default = %s, want the new limit applied", got)
+	}
+
+	after, newETag := listSettings(t, h, admin)
+	if after.Version != before.Version+1 || newETag == etag {
+		t.Errorf("version %d -> %d, ETag %s -> %s; want both bumped", before.Version, after.Version, etag, newETag)
+	}
+
+	// The ETag can stand in for the version
+	rec = updateSettings(t, h, admin, UpdateSettingsRequest{
+		Changes: map[string]*string{"ratelimit.api-default": nil},
+	}, newETag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("If-Match update status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if got := limits.Policy("api-default").Spec(); got.Rate != 100 {
+		t.Errorf("api-default = %s after reset, want the default", got)
+	}
+}
+
+func TestUpdateSettingsStaleVersionConflicts(t *testing.T) {
+	h, limits, database := newTestSettings(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	other := createTestUser(t, database, "other", auth.RoleAdmin)
+	start, _ := listSettings(t, h, admin)
+
+	// Another admin saves first
+	if rec := updateSettings(t, h, other, UpdateSettingsRequest{
+		Version: &start.Version,
+		Changes: map[string]*string{"ratelimit.download-heavy": ptr("5/1m")},
+	}, ""); rec.Code != http.StatusOK {
+		t.Fatalf("first update status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := updateSettings(t, h, admin, UpdateSettingsRequest{
+		Version: &start.Version,
+		Changes: map[string]*string{"ratelimit.download-heavy": ptr("8/1m")},
+	}, "")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale update status = %d, want 409", rec.Code)
+	}
+	var current SettingsData
+	decodeData(t, rec, &current)
+	if current.Version != start.Version+1 || rec.Header().Get("ETag") != settingsETag(current.Version) {
+		t.Errorf("conflict carried version %d and ETag %s, want the current ones", current.Version, rec.Header().Get("ETag"))
+	}
+	if got := limits.Policy("download-heavy").Spec(); got.Rate != 5 {
+		t.Errorf("download-heavy = %s, want the first admin's value kept", got)
+	}
+}
+
+func TestUpdateSettingsIsAllOrNothing(t *testing.T) {
+	h, limits, database := newTestSettings(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+
+	for _, tc := range []struct {
+		name    string
+		changes map[string]*string
+	}{
+		{"invariant", map[string]*string{
+			"ratelimit.api-default":    ptr("50/1m"),
+			"ratelimit.download-heavy": ptr("60/1m"),
+		}},
+		{"invalid value", map[string]*string{
+			"ratelimit.api-default":    ptr("50/1m"),
+			"ratelimit.download-heavy": ptr("fast"),
+		}},
+		{"unknown key", map[string]*string{
+			"ratelimit.api-default": ptr("50/1m"),
+			"ratelimit.uploads":     ptr("5/1m"),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before, _ := listSettings(t, h, admin)
+			rec := updateSettings(t, h, admin, UpdateSettingsRequest{Version: &before.Version, Changes: tc.changes}, "")
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+			}
+			if got := limits.Policy("api-default").Spec(); got.Rate != 100 {
+				t.Errorf("api-default = %s, want nothing applied", got)
+			}
+			if after, _ := listSettings(t, h, admin); after.Version != before.Version {
+				t.Errorf("version moved from %d to %d", before.Version, after.Version)
+			}
+		})
+	}
+}
+
+func TestUpdateSettingsChecksInvariantsOnTheResult(t *testing.T) {
+	h, limits, database := newTestSettings(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	before, _ := listSettings(t, h, admin)
+
+	// Raising download-heavy alone would break the invariant; raising both together doesn't
+	rec := updateSettings(t, h, admin, UpdateSettingsRequest{
+		Version: &before.Version,
+		Changes: map[string]*string{
+			"ratelimit.api-default":    ptr("500/1m"),
+			"ratelimit.download-heavy": ptr("200/1m"),
+		},
+	}, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if limits.Policy("download-heavy").Spec().Rate != 200 || limits.Policy("api-default").Spec().Rate != 500 {
+		t.Error("changes were not applied together")
+	}
+}
\ No newline at end of file
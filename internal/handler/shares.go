@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+const (
+	// shareDefaultTTL is how long a share link lives when the request doesn't say
+	shareDefaultTTL = 7 * 24 * time.Hour
+	// shareMaxTTL caps how long a share link may live
+	shareMaxTTL = 30 * 24 * time.Hour
+	// shareTokenSize is the number of random bytes in a share token
+	shareTokenSize = 32
+)
+
+// CreateShareRequest asks for a public download link to a file
+type CreateShareRequest struct {
+	Key          string `json:"key"`
+	ExpiresIn    string `json:"expires_in"`
+	MaxDownloads int    `json:"max_downloads"`
+}
+
+// ShareData describes a newly created share link. The token is only ever shown here
+type ShareData struct {
+	Token        string `json:"token"`
+	URL          string `json:"url"`
+	Key          string `json:"key"`
+	ExpiresAt    string `json:"expires_at"`
+	MaxDownloads int    `json:"max_downloads"`
+}
+
+// SetBaseURL sets the public address share links are built on
+func (h *Handler) SetBaseURL(baseURL string) {
+	h.baseURL = baseURL
+}
+
+// CreateShare issues a link that lets anyone download a file without an account
+func (h *Handler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	var req CreateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request body",
+		})
+		return
+	}
+
+	if err := validateKey(req.Key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ttl := shareDefaultTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > shareMaxTTL {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   fmt.Sprintf("expires_in must be a positive duration of at most %s", shareMaxTTL),
+			})
+			return
+		}
+		ttl = d
+	}
+
+	if req.MaxDownloads < 0 {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "max_downloads must not be negative",
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, req.Key) {
+		return
+	}
+
+	if _, err := h.s3Service.StatFile(r.Context(), req.Key); err != nil {
+		respondStorageError(w, err, "failed to share file")
+		return
+	}
+
+	token, err := auth.NewRandomToken(shareTokenSize)
+	if err != nil {
+		h.logger.Error("failed to generate share token", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to share file",
+		})
+		return
+	}
+
+	now := time.Now()
+	share := &db.Share{
+		Key:          req.Key,
+		CreatedBy:    user.ID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+		MaxDownloads: req.MaxDownloads,
+	}
+	if err := h.database.CreateShare(token, share); err != nil {
+		h.logger.Error("failed to create share", zap.String("key", req.Key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to share file",
+		})
+		return
+	}
+
+	h.logger.Info("share created", zap.String("user", user.Name), zap.String("key", req.Key), zap.Time("expires_at", share.ExpiresAt))
+
+	respondJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: ShareData{
+			Token:        token,
+			URL:          h.baseURL + "/s/" + token,
+			Key:          req.Key,
+			ExpiresAt:    share.ExpiresAt.Format(time.RFC3339),
+			MaxDownloads: share.MaxDownloads,
+		},
+	})
+}
+
+// RevokeShare deletes a share link; only its creator or an admin may revoke it
+func (h *Handler) RevokeShare(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	token := chi.URLParam(r, "token")
+
+	share, err := h.database.GetShare(token)
+	if err != nil {
+		if errors.Is(err, db.ErrShareNotFound) {
+			respondJSON(w, http.StatusNotFound, Response{
+				Success: false,
+				Error:   "share not found",
+			})
+			return
+		}
+		h.logger.Error("failed to get share", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to revoke share",
+		})
+		return
+	}
+
+	// Someone else's share is reported as missing so tokens can't be probed
+	if share.CreatedBy != user.ID && user.Role != auth.RoleAdmin {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "share not found",
+		})
+		return
+	}
+
+	if err := h.database.DeleteShare(token); err != nil && !errors.Is(err, db.ErrShareNotFound) {
+		h.logger.Error("failed to revoke share", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to revoke share",
+		})
+		return
+	}
+
+	h.logger.Info("share revoked", zap.String("user", user.Name), zap.String("key", share.Key))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    MessageData{Message: "share revoked"},
+	})
+}
+
+// DownloadShare streams a shared file to anyone holding the link. Each request counts
+// as one download, so ranges aren't offered
+func (h *Handler) DownloadShare(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := chi.URLParam(r, "token")
+
+	share, err := h.database.GetShare(token)
+	if err != nil {
+		if errors.Is(err, db.ErrShareNotFound) {
+			http.Error(w, "share not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to get share", zap.Error(err))
+		http.Error(w, "failed to download file", http.StatusInternalServerError)
+		return
+	}
+
+	if !share.Available(time.Now()) {
+		http.Error(w, "share link has expired or used up its downloads", http.StatusGone)
+		return
+	}
+
+	// Check the object first so a missing file doesn't use up a download
+	if _, err := h.s3Service.StatFile(ctx, share.Key); err != nil {
+		status, message := storageStatus(err, "failed to download file")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("failed to stat shared file", zap.String("key", share.Key), zap.Error(err))
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	if err := h.database.ClaimShareDownload(token); err != nil {
+		switch {
+		case errors.Is(err, db.ErrShareGone):
+			http.Error(w, "share link has expired or used up its downloads", http.StatusGone)
+		case errors.Is(err, db.ErrShareNotFound):
+			http.Error(w, "share not found", http.StatusNotFound)
+		default:
+			h.logger.Error("failed to claim share download", zap.Error(err))
+			http.Error(w, "failed to download file", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	obj, err := h.s3Service.StreamFile(ctx, share.Key)
+	if err != nil {
+		h.logger.Error("failed to download shared file", zap.String("key", share.Key), zap.Error(err))
+		setRetryAfter(w, err)
+		status, message := storageStatus(err, "failed to download file")
+		http.Error(w, message, status)
+		return
+	}
+	defer obj.Body.Close()
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	name := service.OriginalName(share.Key)
+	if record, err := h.database.GetFileRecord(share.Key); err == nil && record != nil && record.OriginalName != "" {
+		name = record.OriginalName
+	}
+
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", name))
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Compressed objects are sent as stored when the client accepts gzip, else decompressed
+	body := io.Reader(obj.Body)
+	switch {
+	case obj.ContentEncoding == "":
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	case obj.ContentEncoding == service.ContentEncodingGzip && acceptsGzip(r):
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Header().Set("Content-Encoding", obj.ContentEncoding)
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
+	default:
+		decoded, err := obj.Decoded()
+		if err != nil {
+			h.logger.Error("failed to decompress shared file", zap.String("key", share.Key), zap.Error(err))
+			http.Error(w, "failed to download file", http.StatusInternalServerError)
+			return
+		}
+		defer decoded.Close()
+		w.Header().Set("Vary", "Accept-Encoding")
+		body = decoded
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, body); err != nil {
+		h.logger.Warn("shared download interrupted", zap.String("key", share.Key), zap.Error(err))
+	}
+}
\ No newline at end of file
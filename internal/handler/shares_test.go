@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// createShare shares key as user and returns the new link
+func createShare(t *testing.T, h *Handler, user *auth.User, req CreateShareRequest) ShareData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.CreateShare(rec, asUser(jsonRequest(t, http.MethodPost, "/api/shares", req), user))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create share status = %d, want 201: %s", rec.Code, rec.Body.String())
+	}
+	var data ShareData
+	decodeData(t, rec, &data)
+	return data
+}
+
+// withToken sets the token URL parameter the share routes read
+func withToken(r *http.Request, token string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("token", token)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+// downloadShare fetches a share link anonymously
+func downloadShare(h *Handler, token string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.DownloadShare(rec, withToken(httptest.NewRequest(http.MethodGet, "/s/"+token, nil), token))
+	return rec
+}
+
+func TestDownloadShare(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+
+	share := createShare(t, h, user, CreateShareRequest{Key: key})
+	rec := downloadShare(h, share.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "quarterly numbers" {
+		t.Errorf("body = %q, want the shared file", rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != contentDisposition("attachment", "report.txt") {
+		t.Errorf("Content-Disposition = %q, want the original name", got)
+	}
+
+	if rec := downloadShare(h, "never-issued"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDownloadShareGoneAfterMaxDownloads(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+
+	share := createShare(t, h, user, CreateShareRequest{Key: key, MaxDownloads: 2})
+	for i := range 2 {
+		if rec := downloadShare(h, share.Token); rec.Code != http.StatusOK {
+			t.Fatalf("download %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+	if rec := downloadShare(h, share.Token); rec.Code != http.StatusGone {
+		t.Errorf("download past the limit: status = %d, want 410", rec.Code)
+	}
+}
+
+func TestDownloadShareGoneAfterExpiry(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+
+	// The API only issues links that expire in the future, so age one in the database
+	now := time.Now()
+	if err := database.CreateShare("expired-token", &db.Share{Key: key, CreatedBy: user.ID, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}); err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+
+	rec := downloadShare(h, "expired-token")
+	if rec.Code != http.StatusGone {
+		t.Errorf("status = %d, want 410", rec.Code)
+	}
+	if rec.Body.String() == "quarterly numbers" {
+		t.Error("expired link served the file")
+	}
+}
+
+func TestDownloadShareRefusedAfterRevoke(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	other := createTestUser(t, database, "bob", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+	share := createShare(t, h, user, CreateShareRequest{Key: key})
+
+	revoke := func(user *auth.User) int {
+		rec := httptest.NewRecorder()
+		h.RevokeShare(rec, asUser(withToken(httptest.NewRequest(http.MethodDelete, "/api/shares/"+share.Token, nil), share.Token), user))
+		return rec.Code
+	}
+
+	// Only the creator or an admin may revoke
+	if code := revoke(other); code != http.StatusNotFound {
+		t.Errorf("revoke by another user: status = %d, want 404", code)
+	}
+	if rec := downloadShare(h, share.Token); rec.Code != http.StatusOK {
+		t.Fatalf("download before revoke: status = %d, want 200", rec.Code)
+	}
+	if code := revoke(user); code != http.StatusOK {
+		t.Fatalf("revoke: status = %d, want 200", code)
+	}
+
+	rec := downloadShare(h, share.Token)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("download after revoke: status = %d, want 404", rec.Code)
+	}
+	if rec.Body.String() == "quarterly numbers" {
+		t.Error("revoked link served the file")
+	}
+}
+
+func TestDownloadShareConcurrentNeverExceedsLimit(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+
+	const limit = 3
+	share := createShare(t, h, user, CreateShareRequest{Key: key, MaxDownloads: limit})
+
+	const attempts = 12
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := downloadShare(h, share.Token)
+			mu.Lock()
+			codes[rec.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if codes[http.StatusOK] != limit || codes[http.StatusGone] != attempts-limit {
+		t.Errorf("statuses = %v, want %d × 200 and %d × 410", codes, limit, attempts-limit)
+	}
+}
+
+func TestDownloadShareMissingFileKeepsDownload(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.txt"
+	fake.Put(testBucket, key, []byte("quarterly numbers"))
+	share := createShare(t, h, user, CreateShareRequest{Key: key, MaxDownloads: 1})
+
+	failObject(fake, key, http.StatusNotFound, "NoSuchKey")
+	if rec := downloadShare(h, share.Token); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing file: status = %d, want 404", rec.Code)
+	}
+
+	stored, err := database.GetShare(share.Token)
+	if err != nil {
+		t.Fatalf("GetShare: %v", err)
+	}
+	if stored.DownloadCount != 0 {
+		t.Errorf("download_count = %d after a failed download, want 0", stored.DownloadCount)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// fileSortFields are the values accepted by the sort parameter of the file listing
+var fileSortFields = []string{"name", "size", "modified"}
+
+// fileSort orders a file listing. An empty field keeps the order the bucket returned.
+type fileSort struct {
+	field string
+	desc  bool
+}
+
+// parseFileSort reads ?sort=name|size|modified&order=asc|desc
+func parseFileSort(query url.Values) (fileSort, error) {
+	var s fileSort
+	s.field = query.Get("sort")
+	if s.field != "" && !slices.Contains(fileSortFields, s.field) {
+		return s, fmt.Errorf("sort must be one of %s", strings.Join(fileSortFields, ", "))
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		s.desc = true
+	default:
+		return s, fmt.Errorf("order must be asc or desc")
+	}
+	return s, nil
+}
+
+// apply sorts files and, when ordering by name, folders. Ties fall back to the key so
+// the order is stable across requests.
+func (s fileSort) apply(files []FileEntry, folders []string) {
+	if s.field == "" {
+		return
+	}
+
+	slices.SortStableFunc(files, func(a, b FileEntry) int {
+		var c int
+		switch s.field {
+		case "name":
+			c = cmp.Compare(strings.ToLower(displayName(a)), strings.ToLower(displayName(b)))
+		case "size":
+			c = cmp.Compare(a.Size, b.Size)
+		case "modified":
+			c = a.LastModified.Compare(b.LastModified)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.Key, b.Key)
+		}
+		if s.desc {
+			c = -c
+		}
+		return c
+	})
+
+	if s.field == "name" {
+		slices.SortFunc(folders, func(a, b string) int {
+			c := cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+			if s.desc {
+				c = -c
+			}
+			return c
+		})
+	}
+}
+
+// displayName is the name a file is shown under: its original name, or its key
+func displayName(file FileEntry) string {
+	if file.OriginalName != "" {
+		return file.OriginalName
+	}
+	return file.Key
+}
\ No newline at end of file
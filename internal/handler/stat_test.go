@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+func statFile(h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.StatFile(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/files/stat?key="+url.QueryEscape(key), nil), user))
+	return rec
+}
+
+func TestStatFile(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.pdf"
+	fake.Put(testBucket, key, []byte("hello"))
+
+	rec := statFile(h, user, key)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var info service.FileInfo
+	decodeData(t, rec, &info)
+	if info.Size != 5 || info.ETag == "" {
+		t.Errorf("stat = %+v, want size 5 and an ETag", info)
+	}
+}
+
+func TestStatFileMissingKeyIs404(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := statFile(h, user, service.UserPrefix(user.ID)+"missing.txt")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); resp.Success || resp.Error == "" {
+		t.Errorf("response = %+v, want an error envelope", resp)
+	}
+}
+
+func TestStatFileRejectsInvalidKeys(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, key := range []string{"", service.UserPrefix(user.ID) + "../other-id/secret.txt", "/" + service.UserPrefix(user.ID) + "a.txt"} {
+		if rec := statFile(h, user, key); rec.Code != http.StatusBadRequest {
+			t.Errorf("key %q: status = %d, want 400", key, rec.Code)
+		}
+	}
+}
\ No newline at end of file
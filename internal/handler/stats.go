@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+// Sources of the storage statistics
+const (
+	statsSourceDatabase = "database"
+	statsSourceBucket   = "bucket"
+)
+
+// PrefixStats is the storage used under one folder
+type PrefixStats struct {
+	Prefix  string `json:"prefix"`
+	Bytes   int64  `json:"bytes"`
+	Objects int64  `json:"objects"`
+}
+
+// StatsData is the payload of the storage statistics endpoint
+type StatsData struct {
+	Bucket      string        `json:"bucket"`
+	Endpoint    string        `json:"endpoint"`
+	Source      string        `json:"source"`
+	TotalBytes  int64         `json:"total_bytes"`
+	ObjectCount int64         `json:"object_count"`
+	Prefixes    []PrefixStats `json:"prefixes"`
+	ComputedAt  string        `json:"computed_at"`
+}
+
+// AdminStats reports how much data is stored in total and per user folder (admin only).
+// Figures come from the file records unless ?source=bucket asks for a (cached) bucket scan,
+// which also counts objects the app has no record of, such as trash.
+func (h *Handler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil || user.Role != auth.RoleAdmin {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	stats := StatsData{
+		Bucket:   h.s3Service.Bucket(),
+		Endpoint: h.s3Service.Endpoint(),
+		Prefixes: make([]PrefixStats, 0),
+	}
+
+	switch source := r.URL.Query().Get("source"); source {
+	case "", statsSourceDatabase:
+		owners, err := h.database.UsageByOwner()
+		if err != nil {
+			h.logger.Error("failed to compute usage from file records", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to compute storage usage",
+			})
+			return
+		}
+		stats.Source = statsSourceDatabase
+		for _, owner := range owners {
+			stats.TotalBytes += owner.Bytes
+			stats.ObjectCount += owner.Files
+			stats.Prefixes = append(stats.Prefixes, PrefixStats{
+				Prefix:  service.UserPrefix(owner.OwnerID),
+				Bytes:   owner.Bytes,
+				Objects: owner.Files,
+			})
+		}
+		stats.ComputedAt = time.Now().Format(time.RFC3339)
+	case statsSourceBucket:
+		usage, err := h.s3Service.Usage(r.Context(), r.URL.Query().Get("prefix"))
+		if err != nil {
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to compute storage usage",
+			})
+			return
+		}
+		stats.Source = statsSourceBucket
+		stats.TotalBytes = usage.Bytes
+		stats.ObjectCount = usage.Objects
+		for prefix, p := range usage.ByPrefix {
+			stats.Prefixes = append(stats.Prefixes, PrefixStats{Prefix: prefix, Bytes: p.Bytes, Objects: p.Objects})
+		}
+		sort.Slice(stats.Prefixes, func(i, j int) bool {
+			return stats.Prefixes[i].Bytes > stats.Prefixes[j].Bytes
+		})
+		stats.ComputedAt = usage.ComputedAt.Format(time.RFC3339)
+	default:
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "source must be database or bucket",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    stats,
+	})
+}
\ No newline at end of file
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/service"
+)
+
+// failObject makes every request the fake receives for key, including copies from it, fail
+// with status and code
+func failObject(fake *fakes3.Server, key string, status int, code string) {
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		if r.URL.Path != "/"+testBucket+"/"+key && !strings.HasSuffix(source, "/"+key) {
+			return false
+		}
+		fakes3.WriteError(w, r, status, code)
+		return true
+	})
+}
+
+func TestStorageErrorClasses(t *testing.T) {
+	endpoints := []struct {
+		name string
+		call func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder
+	}{
+		{"stat", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return statFile(h, user, key)
+		}},
+		{"download", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return downloadFile(h, user, key, nil)
+		}},
+		{"get tags", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return getTags(h, user, key)
+		}},
+		{"set tags", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return setTags(t, h, user, key, map[string]string{"project": "apollo"})
+		}},
+		{"delete", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(key), nil), user))
+			return rec
+		}},
+		{"rename", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return renameFile(t, h, user, RenameRequest{From: key, To: key + ".renamed"})
+		}},
+	}
+	classes := []struct {
+		name    string
+		status  int
+		code    string
+		want    int
+		message string
+	}{
+		{"missing", http.StatusNotFound, "NoSuchKey", http.StatusNotFound, "file not found"},
+		{"refused", http.StatusForbidden, "AccessDenied", http.StatusForbidden, "access denied by storage"},
+		{"other", http.StatusBadRequest, "InvalidRequest", http.StatusInternalServerError, ""},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, class := range classes {
+			t.Run(endpoint.name+"/"+class.name, func(t *testing.T) {
+				h, database, fake := newTestHandler(t)
+				admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+				key := service.UserPrefix(admin.ID) + "1712345-notes.txt"
+				fake.Put(testBucket, key, []byte("notes"))
+				failObject(fake, key, class.status, class.code)
+
+				rec := endpoint.call(t, h, admin, key)
+				if rec.Code != class.want {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, class.want, rec.Body.String())
+				}
+				body := rec.Body.String()
+				if class.message != "" && !strings.Contains(body, class.message) {
+					t.Errorf("body %q does not say %q", body, class.message)
+				}
+				// Raw SDK error text stays in the server log
+				for _, leak := range []string{class.code, "api error", "operation error"} {
+					if strings.Contains(body, leak) {
+						t.Errorf("body %q leaks %q", body, leak)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBatchDeleteReportsStorageErrorClasses(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	key := service.UserPrefix(admin.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, key, []byte("notes"))
+	failObject(fake, key, http.StatusForbidden, "AccessDenied")
+
+	rec := httptest.NewRecorder()
+	h.BatchDelete(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/batch-delete", BatchDeleteRequest{Keys: []string{key}}), admin))
+	var data BatchDeleteData
+	decodeData(t, rec, &data)
+	if len(data.Failed) != 1 || data.Failed[0].Error != "access denied by storage" {
+		t.Errorf("failed = %+v, want %s refused by storage", data.Failed, key)
+	}
+}
+
+func TestMissingObjectsAre404AndFailuresAre500(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		call    func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder
+		failure string
+	}{
+		{"stat", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return statFile(h, user, key)
+		}, "failed to stat file"},
+		{"download", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return downloadFile(h, user, key, nil)
+		}, "failed to download file"},
+		{"get tags", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return getTags(h, user, key)
+		}, "failed to get tags"},
+		{"set tags", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return setTags(t, h, user, key, map[string]string{"project": "apollo"})
+		}, "failed to set tags"},
+		{"delete", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(key), nil), user))
+			return rec
+		}, "failed to delete file"},
+		{"rename", func(t *testing.T, h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+			return renameFile(t, h, user, RenameRequest{From: key, To: key + ".renamed"})
+		}, "failed to copy file"},
+	} {
+		t.Run(tc.name+"/missing", func(t *testing.T) {
+			h, database, fake := newTestHandler(t)
+			admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+			key := service.UserPrefix(admin.ID) + "1712345-missing.txt"
+
+			rec := tc.call(t, h, admin, key)
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), "file not found") {
+				t.Errorf("body = %q, want file not found", rec.Body.String())
+			}
+			if keys := fake.Keys(testBucket); len(keys) != 0 {
+				t.Errorf("bucket holds %v after a request for a missing file", keys)
+			}
+		})
+
+		t.Run(tc.name+"/failure", func(t *testing.T) {
+			h, database, fake := newTestHandler(t)
+			admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+			key := service.UserPrefix(admin.ID) + "1712345-notes.txt"
+			fake.Put(testBucket, key, []byte("notes"))
+			failObject(fake, key, http.StatusInternalServerError, "InternalError")
+
+			rec := tc.call(t, h, admin, key)
+			if rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body.String())
+			}
+			if body := rec.Body.String(); !strings.Contains(body, tc.failure) || strings.Contains(body, "InternalError") {
+				t.Errorf("body = %q, want only %q", body, tc.failure)
+			}
+		})
+	}
+}
+
+func TestDeleteMissingFileLeavesNoTrashEntry(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	admin := createTestUser(t, database, "admin", auth.RoleAdmin)
+	key := service.UserPrefix(admin.ID) + "1712345-missing.txt"
+
+	rec := httptest.NewRecorder()
+	h.DeleteFile(rec, asUser(httptest.NewRequest(http.MethodDelete, "/api/files?key="+url.QueryEscape(key), nil), admin))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListTrash(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/trash", nil), admin))
+	var trash ListTrashData
+	decodeData(t, rec, &trash)
+	if trash.Count != 0 {
+		t.Errorf("trash = %+v, want it empty", trash.Items)
+	}
+}
+
+func TestBusyStorageAnswers503WithRetryAfter(t *testing.T) {
+	h, database, fake := newTestHandler(t, func(cfg *config.S3Config) {
+		cfg.MaxConcurrentOps = 1
+		cfg.ConcurrencyWait = 20 * time.Millisecond
+	})
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-notes.txt"
+	fake.Put(testBucket, key, []byte("notes"))
+
+	// An open download holds the only slot
+	stream, err := h.s3Service.StreamFile(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := downloadFile(h, user, key, nil)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != storageBusyRetryAfter {
+		t.Errorf("download: status = %d, Retry-After = %q, want 503 with %s", rec.Code, rec.Header().Get("Retry-After"), storageBusyRetryAfter)
+	}
+
+	rec = uploadFile(h, user, uploadRequest(t, nil, testFile{name: "other.txt", content: []byte("other")}))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != storageBusyRetryAfter {
+		t.Errorf("upload: status = %d, Retry-After = %q, want 503 with %s", rec.Code, rec.Header().Get("Retry-After"), storageBusyRetryAfter)
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeStorageBusy {
+		t.Errorf("upload code = %q, want %q", resp.Code, CodeStorageBusy)
+	}
+
+	stream.Body.Close()
+	if rec := downloadFile(h, user, key, nil); rec.Code != http.StatusOK {
+		t.Errorf("download after the slot freed: status = %d, want 200", rec.Code)
+	}
+}
\ No newline at end of file
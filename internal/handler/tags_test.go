@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/service"
+)
+
+func getTags(h *Handler, user *auth.User, key string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.GetTags(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/files/tags?key="+url.QueryEscape(key), nil), user))
+	return rec
+}
+
+func setTags(t *testing.T, h *Handler, user *auth.User, key string, tags map[string]string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.SetTags(rec, asUser(jsonRequest(t, http.MethodPut, "/api/files/tags?key="+url.QueryEscape(key), TagsRequest{Tags: tags}), user))
+	return rec
+}
+
+func TestSetAndGetTags(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-report.pdf"
+	fake.Put(testBucket, key, []byte("%PDF"))
+
+	if rec := setTags(t, h, user, key, map[string]string{"project": "apollo"}); rec.Code != http.StatusOK {
+		t.Fatalf("set status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := getTags(h, user, key)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data TagsData
+	decodeData(t, rec, &data)
+	if data.Tags["project"] != "apollo" || len(data.Tags) != 1 {
+		t.Errorf("tags = %v, want project=apollo", data.Tags)
+	}
+}
+
+func TestTagsRejectInvalidKeysBeforeOwnership(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	alice := createTestUser(t, database, "alice", auth.RoleUploader)
+	bob := createTestUser(t, database, "bob", auth.RoleUploader)
+	fake.Put(testBucket, service.UserPrefix(bob.ID)+"private.txt", []byte("private"))
+	own := service.UserPrefix(alice.ID)
+
+	for _, key := range []string{
+		"",
+		own + "../" + bob.ID + "/private.txt",
+		own + "./private.txt",
+		"/" + own + "private.txt",
+		own + "a\\b.txt",
+		own + "a\nb.txt",
+	} {
+		if rec := getTags(h, alice, key); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %q: status = %d, want 400", key, rec.Code)
+		}
+		if rec := setTags(t, h, alice, key, map[string]string{"x": "y"}); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %q: status = %d, want 400", key, rec.Code)
+		}
+	}
+
+	// A valid key in another folder is still refused by the ownership check
+	if rec := getTags(h, alice, service.UserPrefix(bob.ID)+"private.txt"); rec.Code != http.StatusForbidden {
+		t.Errorf("foreign key: status = %d, want 403", rec.Code)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/events"
+	"s3-test-app/internal/service"
+)
+
+// TrashItem is a soft-deleted file that can still be restored
+type TrashItem struct {
+	Key          string    `json:"key"`
+	OriginalKey  string    `json:"original_key"`
+	OriginalName string    `json:"original_name"`
+	OwnerID      string    `json:"owner_id"`
+	Size         int64     `json:"size"`
+	DeletedBy    string    `json:"deleted_by"`
+	DeletedAt    time.Time `json:"deleted_at"`
+	PurgeAt      time.Time `json:"purge_at"`
+}
+
+// ListTrashData is the payload of the trash listing endpoint
+type ListTrashData struct {
+	Items []TrashItem `json:"items"`
+	Count int         `json:"count"`
+}
+
+// RestoreData is the payload of the restore endpoint
+type RestoreData struct {
+	Key string `json:"key"`
+}
+
+// EmptyTrashData is the payload of the empty trash endpoint
+type EmptyTrashData struct {
+	Purged int `json:"purged"`
+	Held   int `json:"held"`
+}
+
+// ListTrash lists soft-deleted files. Admins see every entry, other users their own.
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !auth.PermissionMap[user.Role].CanDelete {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to manage the trash",
+		})
+		return
+	}
+
+	ownerID := user.ID
+	if user.Role == auth.RoleAdmin {
+		ownerID = ""
+	}
+
+	entries, err := h.database.ListTrash(ownerID)
+	if err != nil {
+		h.logger.Error("failed to list trash", zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to list trash",
+		})
+		return
+	}
+
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	records, err := h.database.FileRecordsByKeys(keys)
+	if err != nil {
+		h.logger.Error("failed to load file metadata", zap.Error(err))
+	}
+
+	items := make([]TrashItem, len(entries))
+	for i, entry := range entries {
+		name := service.OriginalName(entry.OriginalKey)
+		if record := records[entry.Key]; record != nil {
+			name = record.OriginalName
+		}
+		items[i] = TrashItem{
+			Key:          entry.Key,
+			OriginalKey:  entry.OriginalKey,
+			OriginalName: name,
+			OwnerID:      entry.OwnerID,
+			Size:         entry.Size,
+			DeletedBy:    entry.DeletedBy,
+			DeletedAt:    entry.DeletedAt,
+			PurgeAt:      entry.DeletedAt.Add(h.trash.Retention),
+		}
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: ListTrashData{
+			Items: items,
+			Count: len(items),
+		},
+	})
+}
+
+// RestoreTrash moves a soft-deleted file back to its original key
+func (h *Handler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !auth.PermissionMap[user.Role].CanDelete {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to manage the trash",
+		})
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	entry, err := h.database.GetTrashEntry(key)
+	if err != nil {
+		h.logger.Error("failed to get trash entry", zap.String("key", key), zap.Error(err))
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "failed to look up trash entry",
+		})
+		return
+	}
+	if entry == nil {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "trash entry not found",
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, entry.OriginalKey) {
+		return
+	}
+
+	// Trashed files don't count against the quota, so bringing one back has to fit again
+	if uerr := h.checkQuota(entry.OwnerID, entry.Size); uerr != nil {
+		respondJSON(w, uerr.status, Response{
+			Success: false,
+			Error:   uerr.message,
+			Code:    uerr.code,
+		})
+		return
+	}
+
+	err = h.s3Service.RestoreFile(r.Context(), entry.Key, entry.OriginalKey)
+	if errors.Is(err, service.ErrRestoreConflict) {
+		respondJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		// The trashed object is gone, so the entry can never be restored
+		if err := h.database.DeleteTrashEntries(entry.Key); err != nil {
+			h.logger.Error("failed to delete trash entry", zap.String("key", entry.Key), zap.Error(err))
+		}
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "trashed file no longer exists",
+		})
+		return
+	}
+	if err != nil && !errors.Is(err, service.ErrRenameIncomplete) {
+		h.logger.Error("failed to restore file", zap.String("key", entry.Key), zap.Error(err))
+		respondStorageError(w, err, "failed to restore file")
+		return
+	}
+
+	// A leftover trash copy is harmless; the purge removes it with the entry
+	if err == nil {
+		if err := h.database.DeleteTrashEntries(entry.Key); err != nil {
+			h.logger.Error("failed to delete trash entry", zap.String("key", entry.Key), zap.Error(err))
+		}
+	}
+	if err := h.database.RenameFileRecord(entry.Key, entry.OriginalKey, ""); err != nil {
+		h.logger.Error("failed to restore file metadata", zap.String("key", entry.OriginalKey), zap.Error(err))
+	}
+
+	h.logger.Info("file restored", zap.String("user", user.Name), zap.String("key", entry.OriginalKey))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: RestoreData{
+			Key: entry.OriginalKey,
+		},
+	})
+}
+
+// EmptyTrash permanently deletes everything in the trash (admin only). Files under legal hold are kept.
+func (h *Handler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+
+	if h.approvals != nil && h.approvals.Required("empty_trash") {
+		pending, err := h.approvals.Submit(user, "empty_trash", "trash", emptyTrashPayload{
+			RequestedBy: user.Name,
+		})
+		if err != nil {
+			h.logger.Error("failed to create pending action", zap.Error(err))
+			respondJSON(w, http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "failed to request approval",
+			})
+			return
+		}
+		h.approvals.WritePending(w, pending)
+		return
+	}
+
+	purged, held, err := h.emptyTrash(r.Context(), user)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, Response{
+			Success: false,
+			Data: EmptyTrashData{
+				Purged: purged,
+				Held:   held,
+			},
+			Error: "failed to empty trash",
+		})
+		return
+	}
+	if h.approvals != nil {
+		h.approvals.Audit(user, "empty_trash", "trash", strconv.Itoa(purged))
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: EmptyTrashData{
+			Purged: purged,
+			Held:   held,
+		},
+	})
+}
+
+// emptyTrashPayload is the stored payload of a deferred trash purge
+type emptyTrashPayload struct {
+	RequestedBy string `json:"requested_by"`
+}
+
+// emptyTrash purges every trash entry not under legal hold on behalf of user and
+// returns how many were purged and held back
+func (h *Handler) emptyTrash(ctx context.Context, user *auth.User) (int, int, error) {
+	entries, err := h.database.ListTrash("")
+	if err != nil {
+		h.logger.Error("failed to list trash", zap.Error(err))
+		return 0, 0, err
+	}
+
+	purged, held, err := h.purgeTrash(ctx, entries)
+	if err != nil {
+		h.logger.Error("failed to empty trash", zap.Int("purged", purged), zap.Error(err))
+		return purged, held, err
+	}
+
+	h.logger.Warn("trash emptied", zap.String("user", user.Name), zap.Int("purged", purged), zap.Int("held", held))
+	return purged, held, nil
+}
+
+// RunTrashPurge permanently deletes trash entries older than the retention period
+// on every purge interval until ctx is cancelled
+func (h *Handler) RunTrashPurge(ctx context.Context) {
+	ticker := time.NewTicker(h.trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			entries, err := h.database.ListTrashBefore(time.Now().Add(-h.trash.Retention))
+			if err != nil {
+				h.logger.Error("failed to list expired trash", zap.Error(err))
+				continue
+			}
+			purged, _, err := h.purgeTrash(ctx, entries)
+			if err != nil {
+				h.logger.Error("failed to purge trash", zap.Error(err))
+			}
+			if purged > 0 {
+				h.logger.Info("purged expired trash", zap.Int("count", purged))
+			}
+		}
+	}
+}
+
+// trashFile moves key into the trash on behalf of user and records the deletion
+func (h *Handler) trashFile(ctx context.Context, user *auth.User, key string) error {
+	info, err := h.s3Service.StatFile(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	deletedAt := time.Now()
+	trashKey, err := h.s3Service.TrashFile(ctx, key, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	ownerID := service.UserFromKey(key)
+	if record, err := h.database.GetFileRecord(key); err == nil && record != nil {
+		ownerID = record.OwnerID
+	}
+
+	if err := h.database.AddTrashEntry(&db.TrashEntry{
+		Key:         trashKey,
+		OriginalKey: key,
+		OwnerID:     ownerID,
+		Size:        info.Size,
+		DeletedBy:   user.ID,
+		DeletedAt:   deletedAt,
+	}); err != nil {
+		// The object is in the trash already; without an entry it is only purged by hand
+		h.logger.Error("failed to record trash entry", zap.String("key", key), zap.String("trash_key", trashKey), zap.Error(err))
+	}
+	if err := h.database.RenameFileRecord(key, trashKey, ""); err != nil {
+		h.logger.Error("failed to move file metadata to trash", zap.String("key", key), zap.Error(err))
+	}
+
+	h.publish(events.ActionDeleted, user, h.s3Service, key, "")
+	return nil
+}
+
+// purgeTrash permanently deletes the given trash entries, except those under legal hold,
+// and returns how many were purged and how many were held
+func (h *Handler) purgeTrash(ctx context.Context, entries []*db.TrashEntry) (int, int, error) {
+	held, err := h.heldPrefixes()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	heldCount := 0
+	for _, entry := range entries {
+		if heldPrefixOf(held, entry.OriginalKey) != "" {
+			heldCount++
+			continue
+		}
+		keys = append(keys, entry.Key)
+	}
+	if len(keys) == 0 {
+		return 0, heldCount, nil
+	}
+
+	deleted, deleteErr := h.s3Service.DeleteFiles(ctx, keys)
+	if err := h.database.DeleteTrashEntries(deleted...); err != nil {
+		h.logger.Error("failed to delete trash entries", zap.Error(err))
+	}
+	if err := h.database.DeleteFileRecords(deleted...); err != nil {
+		h.logger.Error("failed to delete file metadata", zap.Error(err))
+	}
+
+	return len(deleted), heldCount, deleteErr
+}
\ No newline at end of file
@@ -0,0 +1,597 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/service"
+)
+
+// testFile is one file part of a test upload
+type testFile struct {
+	field   string
+	name    string
+	content []byte
+}
+
+// uploadRequest builds a multipart upload of files with the given form fields
+func uploadRequest(t *testing.T, fields map[string]string, files ...testFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range files {
+		field := file.field
+		if field == "" {
+			field = "file"
+		}
+		part, err := form.CreateFormFile(field, file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file.content)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/files/upload", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+func uploadFile(h *Handler, user *auth.User, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.UploadFile(rec, asUser(r, user))
+	return rec
+}
+
+func TestUploadWithoutFilePart(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, map[string]string{"allow_empty": "true"}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeNoFilePart {
+		t.Errorf("code = %q, want %q", resp.Code, CodeNoFilePart)
+	}
+}
+
+func TestUploadRejectsEmptyFile(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "empty.txt"}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeEmptyFile {
+		t.Errorf("code = %q, want %q", resp.Code, CodeEmptyFile)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("empty file stored as %v", keys)
+	}
+}
+
+func TestUploadAllowsEmptyFileOnRequest(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, map[string]string{"allow_empty": "true"}, testFile{name: "empty.txt"}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data UploadData
+	decodeData(t, rec, &data)
+	if data.Size != 0 {
+		t.Errorf("size = %d, want 0", data.Size)
+	}
+	if fake.Get(testBucket, data.Key) == nil {
+		t.Errorf("%s was not stored", data.Key)
+	}
+}
+
+func TestUploadBatchReportsEmptyFilesPerFile(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, nil,
+		testFile{field: "files[]", name: "empty.txt"},
+		testFile{field: "files[]", name: "notes.txt", content: []byte("notes")},
+	))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var batch UploadBatchData
+	decodeData(t, rec, &batch)
+	if batch.Uploaded != 1 || batch.Failed != 1 || batch.Files[0].Code != CodeEmptyFile {
+		t.Errorf("batch = %+v, want the empty file alone to fail with %s", batch, CodeEmptyFile)
+	}
+}
+
+func TestDirectUploadRejectsEmptyFile(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := httptest.NewRecorder()
+	h.PresignUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/presign", PresignPostRequest{Filename: "empty.txt"}), user))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeEmptyFile {
+		t.Errorf("code = %q, want %q", resp.Code, CodeEmptyFile)
+	}
+}
+
+func TestConfirmUploadRemovesEmptyObject(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-empty.txt"
+	if err := h.trackDirectUpload(key, user.ID, "empty.txt", 0, false); err != nil {
+		t.Fatalf("trackDirectUpload: %v", err)
+	}
+	fake.Put(testBucket, key, nil)
+
+	rec := httptest.NewRecorder()
+	h.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/confirm", ConfirmUploadRequest{Key: key}), user))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeEmptyFile {
+		t.Errorf("code = %q, want %q", resp.Code, CodeEmptyFile)
+	}
+	if fake.Get(testBucket, key) != nil {
+		t.Error("empty object was left in the bucket")
+	}
+}
+
+// presignUpload asks for a presigned PUT of a size byte file and returns its key
+func presignUpload(t *testing.T, h *Handler, user *auth.User, filename string, size int64) PresignUploadData {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.PresignUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/presign", PresignPostRequest{Filename: filename, Size: size}), user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("presign status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data PresignUploadData
+	decodeData(t, rec, &data)
+	return data
+}
+
+func TestPresignedPutSignsDeclaredSize(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	presigned := presignUpload(t, h, user, "notes.txt", 5)
+	if !strings.Contains(presigned.URL, "X-Amz-SignedHeaders=content-length") {
+		t.Errorf("URL %s does not sign the content length", presigned.URL)
+	}
+}
+
+func TestConfirmUploadChecksDeclaredSize(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, tc := range []struct {
+		name     string
+		declared int64
+		content  []byte
+		want     int
+	}{
+		{"matching size", 5, []byte("notes"), http.StatusOK},
+		{"larger than declared", 5, []byte("notes and more"), http.StatusUnprocessableEntity},
+		{"smaller than declared", 5, []byte("note"), http.StatusUnprocessableEntity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			presigned := presignUpload(t, h, user, tc.name+".txt", tc.declared)
+			fake.Put(testBucket, presigned.Key, tc.content)
+
+			rec := httptest.NewRecorder()
+			h.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/confirm", ConfirmUploadRequest{Key: presigned.Key}), user))
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+			if kept := fake.Get(testBucket, presigned.Key) != nil; kept != (tc.want == http.StatusOK) {
+				t.Errorf("object kept = %v after status %d", kept, rec.Code)
+			}
+		})
+	}
+}
+
+func TestConfirmUploadRejectsObjectOverMaxSize(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	key := service.UserPrefix(user.ID) + "1712345-big.bin"
+	size := int64(testMaxUploadSize + 1)
+	if err := h.trackDirectUpload(key, user.ID, "big.bin", size, false); err != nil {
+		t.Fatalf("trackDirectUpload: %v", err)
+	}
+	fake.Put(testBucket, key, bytes.Repeat([]byte("x"), int(size)))
+
+	rec := httptest.NewRecorder()
+	h.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/confirm", ConfirmUploadRequest{Key: key}), user))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeTooLarge {
+		t.Errorf("code = %q, want %q", resp.Code, CodeTooLarge)
+	}
+	if fake.Get(testBucket, key) != nil {
+		t.Error("oversized object was left in the bucket")
+	}
+}
+
+func TestDirectUploadSweepSurvivesRestart(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	expired := presignUpload(t, h, user, "expired.txt", 5)
+	live := presignUpload(t, h, user, "live.txt", 5)
+	fake.Put(testBucket, expired.Key, []byte("notes"))
+	fake.Put(testBucket, live.Key, []byte("notes"))
+
+	// Age one upload past its grace period
+	if err := database.SaveDirectUpload(&db.DirectUpload{
+		Key:       expired.Key,
+		UserID:    user.ID,
+		Filename:  "expired.txt",
+		Size:      5,
+		ExpiresAt: time.Now().Add(-directUploadGrace - time.Minute),
+	}); err != nil {
+		t.Fatalf("SaveDirectUpload: %v", err)
+	}
+
+	// A handler started afresh over the same database still knows both uploads
+	restarted := NewHandler(h.s3Service, database, zap.NewNop(), config.KeyPolicyConfig{}, config.UploadTypeConfig{}, testMaxUploadSize, config.TrashConfig{Retention: 24 * time.Hour})
+	if removed := restarted.sweepDirectUploads(context.Background()); removed != 1 {
+		t.Errorf("sweep removed %d objects, want 1", removed)
+	}
+	if fake.Get(testBucket, expired.Key) != nil {
+		t.Error("unconfirmed upload past its grace period was left in the bucket")
+	}
+
+	rec := httptest.NewRecorder()
+	restarted.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/confirm", ConfirmUploadRequest{Key: live.Key}), user))
+	if rec.Code != http.StatusOK {
+		t.Errorf("confirm after restart: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	restarted.ConfirmUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/confirm", ConfirmUploadRequest{Key: expired.Key}), user))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("confirm of swept upload: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestUploadTruncatedBody(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	full := uploadRequest(t, nil, testFile{name: "notes.txt", content: bytes.Repeat([]byte("notes "), 100)})
+	body, _ := io.ReadAll(full.Body)
+	r := httptest.NewRequest(http.MethodPost, "/api/files/upload", bytes.NewReader(body[:len(body)/2]))
+	r.Header = full.Header
+	r.ContentLength = int64(len(body))
+
+	rec := uploadFile(h, user, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeTruncatedBody {
+		t.Errorf("code = %q, want %q", resp.Code, CodeTruncatedBody)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("truncated upload stored as %v", keys)
+	}
+}
+
+func TestUploadRemovesObjectStoredShort(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	// The backend acknowledges the PUT but keeps only part of the body
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Copy-Source") != "" || r.URL.Query().Has("tagging") {
+			return false
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")
+		fake.Put(testBucket, key, []byte("no"))
+		w.WriteHeader(http.StatusOK)
+		return true
+	})
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "notes.txt", content: []byte("notes")}))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeTruncatedBody {
+		t.Errorf("code = %q, want %q", resp.Code, CodeTruncatedBody)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("short object left as %v", keys)
+	}
+}
+
+func TestUploadReportsStoredSizeAndChecksum(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	content := []byte("notes")
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "notes.txt", content: content}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data UploadData
+	decodeData(t, rec, &data)
+	sum := sha256.Sum256(content)
+	if data.Size != int64(len(content)) || data.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("upload = %+v, want size %d and sha256 %x", data, len(content), sum)
+	}
+	if obj := fake.Get(testBucket, data.Key); obj == nil || !bytes.Equal(obj.Data, content) {
+		t.Errorf("stored object = %v", obj)
+	}
+}
+
+func TestUploadSizeLimit(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "limit.bin", content: make([]byte, testMaxUploadSize)}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("file at the limit: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = uploadFile(h, user, uploadRequest(t, nil, testFile{name: "over.bin", content: make([]byte, testMaxUploadSize+1)}))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("file over the limit: status = %d, want 413", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeTooLarge {
+		t.Errorf("code = %q, want %q", resp.Code, CodeTooLarge)
+	}
+}
+
+func TestUploadRejectsOversizedBodies(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, tc := range []struct {
+		name          string
+		contentLength func(n int64) int64
+	}{
+		{"declared", func(n int64) int64 { return n }},
+		{"chunked", func(int64) int64 { return -1 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := uploadRequest(t, nil, testFile{name: "huge.bin", content: make([]byte, testMaxUploadSize+multipartOverhead)})
+			r.ContentLength = tc.contentLength(r.ContentLength)
+
+			rec := uploadFile(h, user, r)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body.String())
+			}
+			if resp := decodeResponse(t, rec); resp.Code != CodeTooLarge {
+				t.Errorf("code = %q, want %q", resp.Code, CodeTooLarge)
+			}
+		})
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("oversized upload stored as %v", keys)
+	}
+}
+
+func TestDirectUploadSizeLimit(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	for _, tc := range []struct {
+		size int64
+		want int
+	}{
+		{testMaxUploadSize, http.StatusOK},
+		{testMaxUploadSize + 1, http.StatusRequestEntityTooLarge},
+	} {
+		rec := httptest.NewRecorder()
+		h.PresignUpload(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/presign", PresignPostRequest{Filename: "file.bin", Size: tc.size}), user))
+		if rec.Code != tc.want {
+			t.Errorf("size %d: status = %d, want %d: %s", tc.size, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+}
+
+func TestGetLimitsReportsMaxUploadSize(t *testing.T) {
+	h, database, _ := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := httptest.NewRecorder()
+	h.GetLimits(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/limits", nil), user))
+	var limits LimitsData
+	decodeData(t, rec, &limits)
+	if limits.MaxUploadSize != testMaxUploadSize {
+		t.Errorf("max_upload_size = %d, want %d", limits.MaxUploadSize, testMaxUploadSize)
+	}
+}
+
+func TestUploadChecksClientChecksum(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	content := []byte("notes")
+	sum := sha256.Sum256(content)
+	other := sha256.Sum256([]byte("other"))
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		files  []testFile
+		want   int
+		code   string
+	}{
+		{"matching", strings.ToUpper(hex.EncodeToString(sum[:])), []testFile{{name: "notes.txt", content: content}}, http.StatusOK, ""},
+		{"mismatch", hex.EncodeToString(other[:]), []testFile{{name: "notes.txt", content: content}}, http.StatusUnprocessableEntity, CodeChecksum},
+		{"not a digest", "abc", []testFile{{name: "notes.txt", content: content}}, http.StatusBadRequest, ""},
+		{"several files", hex.EncodeToString(sum[:]), []testFile{
+			{field: "files[]", name: "a.txt", content: content},
+			{field: "files[]", name: "b.txt", content: content},
+		}, http.StatusBadRequest, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := fake.Requests("PutObject")
+			r := uploadRequest(t, nil, tc.files...)
+			r.Header.Set("X-Content-SHA256", tc.header)
+
+			rec := uploadFile(h, user, r)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+			if tc.want == http.StatusOK {
+				return
+			}
+			if resp := decodeResponse(t, rec); resp.Code != tc.code {
+				t.Errorf("code = %q, want %q", resp.Code, tc.code)
+			}
+			if n := fake.Requests("PutObject") - before; n != 0 {
+				t.Errorf("%d objects written for a refused upload", n)
+			}
+		})
+	}
+}
+
+func TestUploadSendsDigestsToStorage(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	content := []byte("notes")
+
+	var contentMD5 string
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") == "" && !r.URL.Query().Has("tagging") {
+			contentMD5 = r.Header.Get("Content-MD5")
+		}
+		return false
+	})
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "notes.txt", content: content}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data UploadData
+	decodeData(t, rec, &data)
+	sum := md5.Sum(content)
+	if data.MD5 != hex.EncodeToString(sum[:]) {
+		t.Errorf("md5 = %q, want %x", data.MD5, sum)
+	}
+	if want := base64.StdEncoding.EncodeToString(sum[:]); contentMD5 != want {
+		t.Errorf("Content-MD5 sent to storage = %q, want %q", contentMD5, want)
+	}
+}
+
+func TestUploadRejectedByStorageChecksum(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	// The body reaches the backend different from what was hashed
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Copy-Source") != "" || r.URL.Query().Has("tagging") {
+			return false
+		}
+		fakes3.WriteError(w, r, http.StatusBadRequest, "BadDigest")
+		return true
+	})
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "notes.txt", content: []byte("notes")}))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+	if resp := decodeResponse(t, rec); resp.Code != CodeChecksum {
+		t.Errorf("code = %q, want %q", resp.Code, CodeChecksum)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("corrupted upload stored as %v", keys)
+	}
+}
+
+func TestUploadOverLimitNamesTheLimit(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	rec := uploadFile(h, user, uploadRequest(t, nil, testFile{name: "over.bin", content: make([]byte, testMaxUploadSize+1)}))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+	if resp := decodeResponse(t, rec); !strings.Contains(resp.Error, strconv.Itoa(testMaxUploadSize)) {
+		t.Errorf("error %q does not name the limit of %d bytes", resp.Error, testMaxUploadSize)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("oversized upload stored as %v", keys)
+	}
+}
+
+func TestDirectUploadPolicyCapsSize(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+
+	// The fake has no form uploads, so accept the startup probe's POST
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || r.URL.Path != "/"+testBucket {
+			return false
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	})
+	if !h.s3Service.ProbePostPolicy(context.Background()) {
+		t.Fatal("POST policy probe failed")
+	}
+
+	rec := httptest.NewRecorder()
+	h.PresignPost(rec, asUser(jsonRequest(t, http.MethodPost, "/api/upload/presign-post", PresignPostRequest{Filename: "file.bin", Size: 10}), user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var data PresignPostData
+	decodeData(t, rec, &data)
+
+	raw, err := base64.StdEncoding.DecodeString(data.Fields["policy"])
+	if err != nil {
+		t.Fatalf("policy field %q: %v", data.Fields["policy"], err)
+	}
+	var policy struct {
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		t.Fatalf("policy %s: %v", raw, err)
+	}
+	for _, condition := range policy.Conditions {
+		var rangeCondition []any
+		if json.Unmarshal(condition, &rangeCondition) != nil || len(rangeCondition) != 3 || rangeCondition[0] != "content-length-range" {
+			continue
+		}
+		// Storage takes exactly the declared size
+		if min, _ := rangeCondition[1].(float64); min != 10 {
+			t.Errorf("content-length-range min = %v, want 10", rangeCondition[1])
+		}
+		if max, _ := rangeCondition[2].(float64); max != 10 {
+			t.Errorf("content-length-range max = %v, want 10", rangeCondition[2])
+		}
+		return
+	}
+	t.Errorf("policy %s has no content-length-range condition", raw)
+}
\ No newline at end of file
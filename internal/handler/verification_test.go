@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// verificationLinkFrom extracts the verification link from a verification email
+func verificationLinkFrom(t *testing.T, body string) string {
+	t.Helper()
+	const marker = "by opening this link: "
+	i := strings.Index(body, marker)
+	if i < 0 {
+		t.Fatalf("no verification link in email: %q", body)
+	}
+	return strings.Fields(body[i+len(marker):])[0]
+}
+
+func verifyEmail(h *AuthHandler, link string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.VerifyEmailHandler(rec, httptest.NewRequest(http.MethodGet, link, nil))
+	return rec
+}
+
+func TestSignupSendsVerificationLink(t *testing.T) {
+	database := newTestDatabase(t)
+	h, mailer := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+	h.cfg.Auth.SignupKey = "signup-key"
+
+	rec := httptest.NewRecorder()
+	h.SignupHandler(rec, jsonRequest(t, http.MethodPost, "/api/auth/signup", SignupRequest{
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Password:  "password",
+		SignupKey: "signup-key",
+	}))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("signup status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	msg := mailer.last(t)
+	if msg.To != "alice@example.com" {
+		t.Errorf("verification sent to %q", msg.To)
+	}
+	link := verificationLinkFrom(t, msg.Body)
+	if !strings.HasPrefix(link, "http://localhost:8080/api/auth/verify?token=") {
+		t.Errorf("link = %q, want one under BASE_URL", link)
+	}
+
+	user, err := database.GetUserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Fatal("email verified before the link was opened")
+	}
+
+	if rec := verifyEmail(h, link); rec.Code != http.StatusOK {
+		t.Fatalf("verify status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if verified, err := database.IsEmailVerified(user.ID); err != nil || !verified {
+		t.Errorf("IsEmailVerified = %v, %v after verifying", verified, err)
+	}
+	if rec := verifyEmail(h, link); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused link status = %d, want 400", rec.Code)
+	}
+}
+
+func TestVerifyEmailRejectsUnknownToken(t *testing.T) {
+	database := newTestDatabase(t)
+	h, _ := newTestAuthHandler(database, newTestTokenManager(database), zap.NewNop())
+
+	for _, target := range []string{"/api/auth/verify", "/api/auth/verify?token=made-up"} {
+		if rec := verifyEmail(h, target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// VersionsData is the payload of the file versions endpoint
+type VersionsData struct {
+	Key      string                  `json:"key"`
+	Versions []service.ObjectVersion `json:"versions"`
+}
+
+// RestoreVersionRequest is the request body of the restore version endpoint
+type RestoreVersionRequest struct {
+	Key       string `json:"key"`
+	VersionID string `json:"version_id"`
+}
+
+// ListVersions lists every stored version of a file, newest first
+func (h *Handler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	key := r.URL.Query().Get("key")
+
+	if err := validateKey(key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, key) {
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	versions, err := storage.ListVersions(r.Context(), key)
+	if err != nil {
+		respondStorageError(w, err, "failed to list versions")
+		return
+	}
+	if len(versions) == 0 {
+		respondJSON(w, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "file not found",
+		})
+		return
+	}
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: VersionsData{
+			Key:      key,
+			Versions: versions,
+		},
+	})
+}
+
+// RestoreVersion makes an older version of a file the latest again by copying it over the file
+func (h *Handler) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if !auth.PermissionMap[user.Role].CanUpload {
+		respondJSON(w, http.StatusForbidden, Response{
+			Success: false,
+			Error:   "insufficient permissions to restore files",
+		})
+		return
+	}
+
+	var req RestoreVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VersionID == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "key and version_id are required",
+		})
+		return
+	}
+	if err := validateKey(req.Key); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if h.rejectForeign(w, user, req.Key) || h.rejectHeld(w, req.Key) {
+		return
+	}
+
+	storage, err := h.bucketFor(r)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if storage == h.s3Service && h.rejectLocked(w, req.Key) {
+		return
+	}
+
+	ctx := r.Context()
+	if err := storage.RestoreVersion(ctx, req.Key, req.VersionID); err != nil {
+		respondStorageError(w, err, "failed to restore version")
+		return
+	}
+
+	info, err := storage.StatFile(ctx, req.Key)
+	if err != nil {
+		h.logger.Error("failed to stat restored file", zap.String("key", req.Key), zap.Error(err))
+		respondStorageError(w, err, "failed to stat restored file")
+		return
+	}
+
+	// The file record has to describe the restored content, not the one it replaced
+	if storage == h.s3Service {
+		if record, err := h.database.GetFileRecord(req.Key); err == nil && record != nil {
+			h.saveRecord(&db.FileRecord{
+				Key:          record.Key,
+				OwnerID:      record.OwnerID,
+				OriginalName: record.OriginalName,
+				Size:         info.Size,
+				ContentType:  info.ContentType,
+				Category:     service.Categorize(info.ContentType, record.OriginalName),
+				SHA256:       info.Metadata[sha256MetadataKey],
+				UploadedAt:   time.Now(),
+			})
+		}
+	}
+
+	h.logger.Info("file version restored", zap.String("user", user.Name), zap.String("bucket", storage.Name()), zap.String("key", req.Key), zap.String("version_id", req.VersionID))
+
+	respondJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    info,
+	})
+}
\ No newline at end of file
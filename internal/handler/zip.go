@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/service"
+)
+
+// maxZipEntries caps how many objects a single archive may contain
+const maxZipEntries = 10000
+
+// zipManifestName is the archive entry listing files that could not be included
+const zipManifestName = "MANIFEST.txt"
+
+// DownloadZipRequest selects the objects to bundle into an archive. It is posted as JSON,
+// or given as ?keys=a,b,c&prefix=&strict= when the archive is fetched with GET
+type DownloadZipRequest struct {
+	Keys   []string `json:"keys"`
+	Prefix string   `json:"prefix"`
+	Strict bool     `json:"strict"`
+}
+
+// DownloadZip streams the requested objects as a zip archive built on the fly, naming
+// entries after the files' original names. In strict mode every key must exist;
+// otherwise missing keys are listed in a manifest entry.
+func (h *Handler) DownloadZip(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		respondJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "unauthorized",
+		})
+		return
+	}
+
+	var req DownloadZipRequest
+	if r.Method == http.MethodGet {
+		req = zipRequestFromQuery(r.URL.Query())
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "invalid request",
+		})
+		return
+	}
+
+	if len(req.Keys) == 0 && req.Prefix == "" {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "keys or prefix is required",
+		})
+		return
+	}
+
+	ctx := r.Context()
+	keys := req.Keys
+	if len(keys) == 0 {
+		if err := validatePrefix(req.Prefix); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		if h.rejectForeign(w, user, req.Prefix) {
+			return
+		}
+
+		var err error
+		keys, err = h.s3Service.ListKeys(ctx, req.Prefix, maxZipEntries)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	if len(keys) > maxZipEntries {
+		respondJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("at most %d files can be downloaded at once", maxZipEntries),
+		})
+		return
+	}
+
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			respondJSON(w, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error() + ": " + key,
+			})
+			return
+		}
+		if h.rejectForeign(w, user, key) {
+			return
+		}
+	}
+
+	records, err := h.database.FileRecordsByKeys(keys)
+	if err != nil {
+		// Entries fall back to names derived from the keys
+		h.logger.Warn("failed to look up names for zip entries", zap.Error(err))
+	}
+
+	// Strict mode has to find missing keys before any archive bytes are sent
+	if req.Strict {
+		for _, key := range keys {
+			if _, err := h.s3Service.StatFile(ctx, key); err != nil {
+				status, message := storageStatus(err, "failed to check file")
+				respondJSON(w, status, Response{
+					Success: false,
+					Error:   message + ": " + key,
+				})
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", fmt.Sprintf("files-%d.zip", time.Now().Unix())))
+
+	zw := zip.NewWriter(w)
+	names := map[string]bool{zipManifestName: true}
+	var missing []string
+
+	for _, key := range keys {
+		obj, err := h.s3Service.StreamFile(ctx, key)
+		if errors.Is(err, service.ErrNotFound) && !req.Strict {
+			missing = append(missing, key)
+			continue
+		}
+		if err != nil {
+			// Headers are already sent, so the only way to signal failure is a broken archive
+			h.logger.Error("zip download aborted", zap.String("key", key), zap.Error(err))
+			return
+		}
+
+		// Entries hold the files as uploaded, so compressed objects are decompressed
+		body, err := obj.Decoded()
+		if err != nil {
+			obj.Body.Close()
+			h.logger.Error("zip download aborted", zap.String("key", key), zap.Error(err))
+			return
+		}
+
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     uniqueName(names, zipEntryName(key, records[key])),
+			Method:   zip.Deflate,
+			Modified: obj.LastModified,
+		})
+		if err == nil {
+			_, err = io.Copy(entry, body)
+		}
+		body.Close()
+		if err != nil {
+			h.logger.Error("zip download aborted", zap.String("key", key), zap.Error(err))
+			return
+		}
+	}
+
+	if len(missing) > 0 {
+		entry, err := zw.Create(zipManifestName)
+		if err == nil {
+			_, err = io.WriteString(entry, "The following files were not found and are not included:\n"+strings.Join(missing, "\n")+"\n")
+		}
+		if err != nil {
+			h.logger.Error("failed to write zip manifest", zap.Error(err))
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		h.logger.Error("failed to finish zip archive", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("zip download served", zap.String("user", user.Name), zap.Int("files", len(keys)-len(missing)), zap.Int("missing", len(missing)))
+}
+
+// zipRequestFromQuery reads an archive selection from the query string of a GET request
+func zipRequestFromQuery(query url.Values) DownloadZipRequest {
+	req := DownloadZipRequest{Prefix: query.Get("prefix")}
+	req.Strict, _ = strconv.ParseBool(query.Get("strict"))
+	for _, value := range query["keys"] {
+		for _, key := range strings.Split(value, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				req.Keys = append(req.Keys, key)
+			}
+		}
+	}
+	return req
+}
+
+// zipEntryName names the archive entry for key after the file's original name. Original
+// names come from clients, so they are sanitized to keep entries from escaping the
+// folder the archive is extracted into
+func zipEntryName(key string, record *db.FileRecord) string {
+	name := service.OriginalName(key)
+	if record != nil && record.OriginalName != "" {
+		name = record.OriginalName
+	}
+	return service.SanitizeFilename(name)
+}
+
+// uniqueName returns name, or name with a numeric suffix if it is already in used, and marks it used
+func uniqueName(used map[string]bool, name string) string {
+	candidate := name
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
\ No newline at end of file
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/db"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/service"
+)
+
+// downloadZip asks for an archive of the files req selects
+func downloadZip(t *testing.T, h *Handler, user *auth.User, req DownloadZipRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.DownloadZip(rec, asUser(jsonRequest(t, http.MethodPost, "/api/files/download-zip", req), user))
+	return rec
+}
+
+// readZip opens the archive in rec and returns its entries in order with their contents
+func readZip(t *testing.T, rec *httptest.ResponseRecorder) ([]string, map[string]string) {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a zip archive: %v", err)
+	}
+	names := make([]string, 0, len(archive.File))
+	contents := make(map[string]string, len(archive.File))
+	for _, file := range archive.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		names = append(names, file.Name)
+		contents[file.Name] = string(data)
+	}
+	return names, contents
+}
+
+// putNamedFile stores content under key with a record giving it originalName
+func putNamedFile(t *testing.T, database *db.Database, fake *fakes3.Server, user *auth.User, key, originalName, content string) {
+	t.Helper()
+	fake.Put(testBucket, key, []byte(content))
+	if err := database.SaveFileRecord(&db.FileRecord{Key: key, OwnerID: user.ID, OriginalName: originalName, Size: int64(len(content)), UploadedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveFileRecord: %v", err)
+	}
+}
+
+func TestDownloadZipDeduplicatesEntryNames(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	prefix := service.UserPrefix(user.ID)
+
+	putNamedFile(t, database, fake, user, prefix+"1712345-report.txt", "report.txt", "first")
+	putNamedFile(t, database, fake, user, prefix+"1712346-report.txt", "report.txt", "second")
+	putNamedFile(t, database, fake, user, prefix+"archive/1712347-report.txt", "report.txt", "third")
+	putNamedFile(t, database, fake, user, prefix+"1712348-manifest.txt", "MANIFEST.txt", "not the manifest")
+	putNamedFile(t, database, fake, user, prefix+"1712349-escape.txt", "../../etc/passwd", "sanitized")
+
+	rec := downloadZip(t, h, user, DownloadZipRequest{Keys: []string{
+		prefix + "1712345-report.txt",
+		prefix + "1712346-report.txt",
+		prefix + "archive/1712347-report.txt",
+		prefix + "1712348-manifest.txt",
+		prefix + "1712349-escape.txt",
+	}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	names, contents := readZip(t, rec)
+	want := []string{"report.txt", "report (1).txt", "report (2).txt", "MANIFEST (1).txt", ".._.._etc_passwd"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Fatalf("entries = %q, want %q", names, want)
+	}
+	for name, content := range map[string]string{
+		"report.txt":       "first",
+		"report (1).txt":   "second",
+		"report (2).txt":   "third",
+		"MANIFEST (1).txt": "not the manifest",
+		".._.._etc_passwd": "sanitized",
+	} {
+		if contents[name] != content {
+			t.Errorf("%s = %q, want %q", name, contents[name], content)
+		}
+	}
+}
+
+func TestDownloadZipStrictAbortsOnMissingKey(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	prefix := service.UserPrefix(user.ID)
+	present := prefix + "1712345-report.txt"
+	missing := prefix + "1712346-gone.txt"
+	putNamedFile(t, database, fake, user, present, "report.txt", "numbers")
+
+	rec := downloadZip(t, h, user, DownloadZipRequest{Keys: []string{present, missing}, Strict: true})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct == "application/zip" {
+		t.Error("strict failure was sent as an archive")
+	}
+	if resp := decodeResponse(t, rec); !strings.Contains(resp.Error, missing) {
+		t.Errorf("error = %q, want it to name %s", resp.Error, missing)
+	}
+	if n := fake.Requests("GetObject"); n != 0 {
+		t.Errorf("%d objects were read before the strict check failed, want 0", n)
+	}
+}
+
+func TestDownloadZipListsMissingKeysInManifest(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	prefix := service.UserPrefix(user.ID)
+	present := prefix + "1712345-report.txt"
+	missing := prefix + "1712346-gone.txt"
+	putNamedFile(t, database, fake, user, present, "report.txt", "numbers")
+
+	rec := downloadZip(t, h, user, DownloadZipRequest{Keys: []string{present, missing}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	names, contents := readZip(t, rec)
+	if strings.Join(names, "|") != "report.txt|"+zipManifestName {
+		t.Fatalf("entries = %q, want the file and the manifest", names)
+	}
+	if !strings.Contains(contents[zipManifestName], missing) {
+		t.Errorf("manifest = %q, want it to list %s", contents[zipManifestName], missing)
+	}
+}
+
+func TestDownloadZipFromQuery(t *testing.T) {
+	h, database, fake := newTestHandler(t)
+	user := createTestUser(t, database, "alice", auth.RoleUploader)
+	prefix := service.UserPrefix(user.ID)
+	putNamedFile(t, database, fake, user, prefix+"1712345-a.txt", "a.txt", "a")
+	putNamedFile(t, database, fake, user, prefix+"1712346-b.txt", "b.txt", "b")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/files/download-zip?strict=true&keys="+prefix+"1712345-a.txt,"+prefix+"1712346-b.txt", nil)
+	rec := httptest.NewRecorder()
+	h.DownloadZip(rec, asUser(r, user))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if names, _ := readZip(t, rec); strings.Join(names, "|") != "a.txt|b.txt" {
+		t.Errorf("entries = %q, want a.txt and b.txt", names)
+	}
+}
\ No newline at end of file
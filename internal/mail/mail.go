@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender delivers outgoing email
+type EmailSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrNoTransport is returned by DisabledSender for every message
+var ErrNoTransport = errors.New("no mail transport configured")
+
+// DisabledSender drops every message, for deployments without a mail transport
+type DisabledSender struct{}
+
+// Send reports that the message could not be delivered
+func (DisabledSender) Send(ctx context.Context, msg Message) error {
+	return ErrNoTransport
+}
+
+// LogSender writes messages to the log instead of delivering them, for local
+// development. Bodies carry verification and reset tokens, so only their size is logged
+type LogSender struct {
+	logger *zap.Logger
+}
+
+// NewLogSender creates a sender that logs every message
+func NewLogSender(logger *zap.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+// Send logs the message's recipient and subject
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	s.logger.Info("email",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.Int("body_bytes", len(msg.Body)),
+	)
+	return nil
+}
+
+// NewSender returns the sender for the configuration: LogSender when logDelivery is
+// set, DisabledSender otherwise
+func NewSender(logger *zap.Logger, logDelivery bool) EmailSender {
+	if logDelivery {
+		logger.Warn("MAIL_LOG_DELIVERY is set: emails are logged, not sent")
+		return NewLogSender(logger)
+	}
+	return DisabledSender{}
+}
\ No newline at end of file
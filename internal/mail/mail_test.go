@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogSenderDoesNotLogBody(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	sender := NewLogSender(zap.New(core))
+
+	err := sender.Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Verify your email address",
+		Body:    "Confirm your email address by opening this link: http://localhost/verify?token=secret-token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["to"] != "alice@example.com" || fields["subject"] != "Verify your email address" {
+		t.Errorf("fields = %v, want recipient and subject", fields)
+	}
+	for key, value := range fields {
+		if s, ok := value.(string); ok && s != "" && key != "to" && key != "subject" {
+			t.Errorf("field %q = %q is logged", key, s)
+		}
+	}
+}
+
+func TestNewSender(t *testing.T) {
+	if _, ok := NewSender(zap.NewNop(), true).(*LogSender); !ok {
+		t.Error("NewSender with log delivery isn't a LogSender")
+	}
+
+	sender := NewSender(zap.NewNop(), false)
+	if err := sender.Send(context.Background(), Message{To: "alice@example.com"}); !errors.Is(err, ErrNoTransport) {
+		t.Errorf("Send() = %v, want ErrNoTransport", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// transferBuckets spans fast API calls up to multi-minute file transfers, in seconds
+var transferBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts handled requests by route pattern, method and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration records request latency by route pattern and method
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route and method.",
+		Buckets: transferBuckets,
+	}, []string{"route", "method"})
+
+	// S3Duration records S3 call latency by operation and result.
+	// Downloads are timed until the object stream is open, not until the body is drained.
+	S3Duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "s3_operation_duration_seconds",
+		Help:    "S3 operation latency, by operation and result.",
+		Buckets: transferBuckets,
+	}, []string{"operation", "result"})
+
+	// S3Requests counts calls made by the S3 client, by SDK operation, HTTP status code and
+	// error class. Retries are part of the call they belong to.
+	S3Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_client_requests_total",
+		Help: "S3 client calls, by SDK operation, status code and error class.",
+	}, []string{"operation", "status", "error_class"})
+
+	// S3RequestDuration records S3 client call latency by SDK operation, retries included
+	S3RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "s3_client_request_duration_seconds",
+		Help:    "S3 client call latency including retries, by SDK operation.",
+		Buckets: transferBuckets,
+	}, []string{"operation"})
+
+	// S3TransferBytes counts request and response body bytes exchanged with S3, by SDK
+	// operation and direction, upload or download
+	S3TransferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_client_transfer_bytes_total",
+		Help: "Body bytes sent to and received from S3, by SDK operation and direction.",
+	}, []string{"operation", "direction"})
+
+	// S3InFlight is the number of object transfers currently holding a slot, by operation
+	S3InFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "s3_operations_in_flight",
+		Help: "S3 uploads and downloads currently running, by operation.",
+	}, []string{"operation"})
+
+	// S3Rejected counts operations refused because no slot freed up in time
+	S3Rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operations_rejected_total",
+		Help: "S3 operations refused because the concurrency limit stayed reached, by operation.",
+	}, []string{"operation"})
+
+	// S3CacheRequests counts read cache lookups by operation and result, hit or miss
+	S3CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_read_cache_requests_total",
+		Help: "Object read cache lookups, by operation and result.",
+	}, []string{"operation", "result"})
+
+	// S3CacheBytes is the size of the objects held in the read cache
+	S3CacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "s3_read_cache_bytes",
+		Help: "Bytes of object content held in the read cache.",
+	})
+
+	// UploadsInFlight is the number of uploads currently being received
+	UploadsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "uploads_in_flight",
+		Help: "Uploads currently being received.",
+	})
+
+	// UploadHookDuration records how long each upload pipeline hook takes, by hook name
+	UploadHookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upload_hook_duration_seconds",
+		Help:    "Upload pipeline hook latency, by hook.",
+		Buckets: transferBuckets,
+	}, []string{"hook"})
+
+	// UploadHookFailures counts failed upload pipeline hooks by hook name and outcome,
+	// aborted when the upload was refused or warned when it went ahead
+	UploadHookFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "upload_hook_failures_total",
+		Help: "Upload pipeline hook failures, by hook and outcome.",
+	}, []string{"hook", "outcome"})
+)
+
+// S3 operation labels
+const (
+	OpUpload    = "upload"
+	OpDownload  = "download"
+	OpList      = "list"
+	OpDelete    = "delete"
+	OpStat      = "stat"
+	OpLifecycle = "lifecycle"
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		S3Duration,
+		S3Requests,
+		S3RequestDuration,
+		S3TransferBytes,
+		S3InFlight,
+		S3Rejected,
+		S3CacheRequests,
+		S3CacheBytes,
+		UploadsInFlight,
+		UploadHookDuration,
+		UploadHookFailures,
+	)
+}
+
+// ObserveS3 records how long an S3 operation started at start took and whether it failed
+func ObserveS3(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	S3Duration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the collected metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
\ No newline at end of file
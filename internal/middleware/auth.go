@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -11,31 +13,37 @@ import (
 func AuthMiddleware(tokenManager *auth.TokenManager) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var tokenString string
-
-			// First, try to get token from cookie (for HTML page requests)
-			if cookie, err := r.Cookie("auth_token"); err == nil {
-				tokenString = cookie.Value
-			} else {
-				// Fall back to Authorization header (for API requests from JS)
-				authHeader := r.Header.Get("Authorization")
-				if authHeader == "" {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
-					return
-				}
+			tokenString, err := auth.TokenFromRequest(r)
+			if err == auth.ErrNoToken {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+				return
+			}
 
-				// Extract token from "Bearer <token>" format
-				parts := strings.Split(authHeader, " ")
-				if len(parts) != 2 || parts[0] != "Bearer" {
-					http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
+			// Personal access tokens carry no claims; they stand for their owner as they are now
+			if auth.IsAPIToken(tokenString) {
+				user, err := tokenManager.ValidateAPIToken(tokenString)
+				if err != nil {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
 					return
 				}
-
-				tokenString = parts[1]
+				ctx := auth.SetAPITokenAuthInContext(auth.SetUserInContext(r.Context(), user))
+				*r = *r.WithContext(ctx)
+				next.ServeHTTP(w, r)
+				return
 			}
 
 			// Validate token
 			claims, err := tokenManager.ValidateToken(tokenString)
+			if errors.Is(err, auth.ErrUnsupportedTokenVersion) {
+				// The cookie can never become valid again, so drop it
+				tokenManager.ClearTokenCookie(w)
+				http.Error(w, "Session expired, please log in again", http.StatusUnauthorized)
+				return
+			}
 			if err != nil {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				return
@@ -50,6 +58,36 @@ func AuthMiddleware(tokenManager *auth.TokenManager) func(http.Handler) http.Han
 	}
 }
 
+// VerificationStore reports whether a user has confirmed their email address
+type VerificationStore interface {
+	IsEmailVerified(userID string) (bool, error)
+}
+
+// RequireVerifiedEmail middleware rejects users whose email address is not verified
+func RequireVerifiedEmail(store VerificationStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := auth.GetUserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			verified, err := store.IsEmailVerified(user.ID)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if !verified {
+				http.Error(w, "Email address not verified", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RequireRole middleware checks if user has required role
 func RequireRole(role auth.Role) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -81,11 +119,31 @@ func RequirePermission(perm auth.Permission) func(http.Handler) http.Handler {
 			}
 
 			if !user.HasPermission(perm) {
-				http.Error(w, "Forbidden", http.StatusForbidden)
+				http.Error(w, fmt.Sprintf("Forbidden: the %s role cannot %s files", user.Role, missingActions(user, perm)), http.StatusForbidden)
 				return
 			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
+}
+
+// missingActions names the actions of perm the user's role is not allowed, such as
+// "upload or delete"
+func missingActions(user *auth.User, perm auth.Permission) string {
+	granted := auth.PermissionMap[user.Role]
+	var actions []string
+	if perm.CanUpload && !granted.CanUpload {
+		actions = append(actions, "upload")
+	}
+	if perm.CanView && !granted.CanView {
+		actions = append(actions, "view")
+	}
+	if perm.CanDelete && !granted.CanDelete {
+		actions = append(actions, "delete")
+	}
+	if perm.CanManage && !granted.CanManage {
+		actions = append(actions, "manage")
+	}
+	return strings.Join(actions, " or ")
 }
\ No newline at end of file
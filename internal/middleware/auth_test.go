@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+// apiTokenStore resolves a fixed set of personal access tokens
+type apiTokenStore map[string]*auth.User
+
+func (s apiTokenStore) LookupAPIToken(token string) (*auth.User, error) {
+	if user, ok := s[token]; ok {
+		return user, nil
+	}
+	return nil, auth.ErrInvalidAPIToken
+}
+
+func TestAuthMiddlewareAPIToken(t *testing.T) {
+	user := &auth.User{ID: "alice-id", Name: "alice", Role: auth.RoleUploader}
+	const token = auth.APITokenPrefix + "valid"
+	tokenManager := auth.NewTokenManager("test-secret")
+	tokenManager.SetAPITokenStore(apiTokenStore{token: user})
+
+	session, err := tokenManager.GenerateToken(user, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	var seen *auth.User
+	var viaToken bool
+	handler := AuthMiddleware(tokenManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.GetUserFromContext(r.Context())
+		viaToken = auth.IsAPITokenAuth(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		name         string
+		bearer       string
+		want         int
+		wantViaToken bool
+	}{
+		{"API token", token, http.StatusOK, true},
+		{"unknown API token", auth.APITokenPrefix + "unknown", http.StatusUnauthorized, false},
+		{"session", session, http.StatusOK, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			seen, viaToken = nil, false
+			r := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+			r.Header.Set("Authorization", "Bearer "+tc.bearer)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want != http.StatusOK {
+				if seen != nil {
+					t.Error("handler ran for a rejected request")
+				}
+				return
+			}
+			if seen == nil || seen.ID != user.ID {
+				t.Errorf("user in context = %+v, want %s", seen, user.ID)
+			}
+			if viaToken != tc.wantViaToken {
+				t.Errorf("IsAPITokenAuth = %v, want %v", viaToken, tc.wantViaToken)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"s3-test-app/internal/auth"
+)
+
+func TestCSRF(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret")
+	handler := CSRF(tokenManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	const session = "session-token"
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		cookie string
+		csrf   string
+		want   int
+	}{
+		{"cookie write with token", http.MethodPost, session, tokenManager.CSRFToken(session), http.StatusOK},
+		{"cookie write without token", http.MethodPost, session, "", http.StatusForbidden},
+		{"cookie write with wrong token", http.MethodDelete, session, "forged", http.StatusForbidden},
+		{"cookie write with another session's token", http.MethodPut, session, tokenManager.CSRFToken("other-session"), http.StatusForbidden},
+		{"cookie read", http.MethodGet, session, "", http.StatusOK},
+		{"cookie head", http.MethodHead, session, "", http.StatusOK},
+		{"cookie options", http.MethodOptions, session, "", http.StatusOK},
+		{"bearer-only write", http.MethodPost, "", "", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/api/files", nil)
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_token", Value: tc.cookie})
+			} else {
+				r.Header.Set("Authorization", "Bearer "+session)
+			}
+			if tc.csrf != "" {
+				r.Header.Set(auth.CSRFHeader, tc.csrf)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
\ No newline at end of file
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/metrics"
+)
+
+// Metrics records request counts and latencies per route pattern.
+// Requests that match no route share one label so unknown paths cannot inflate cardinality.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MetricsAccess guards /metrics: a request bearing scrapeToken is let through as is,
+// anything else must come from an admin. An empty scrapeToken leaves admins only.
+func MetricsAccess(tokenManager *auth.TokenManager, scrapeToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		adminOnly := AuthMiddleware(tokenManager)(RequireRole(auth.RoleAdmin)(next))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scrapeToken != "" {
+				bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+				if ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(scrapeToken)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			adminOnly.ServeHTTP(w, r)
+		})
+	}
+}
\ No newline at end of file
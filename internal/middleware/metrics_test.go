@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+)
+
+func TestMetricsAccess(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret")
+	token := func(role auth.Role) string {
+		t.Helper()
+		token, err := tokenManager.GenerateToken(&auth.User{ID: string(role) + "-id", Name: string(role), Role: role}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	handler := MetricsAccess(tokenManager, "scrape-secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"scrape token", "Bearer scrape-secret", http.StatusOK},
+		{"admin", "Bearer " + token(auth.RoleAdmin), http.StatusOK},
+		{"uploader", "Bearer " + token(auth.RoleUploader), http.StatusForbidden},
+		{"viewer", "Bearer " + token(auth.RoleViewer), http.StatusForbidden},
+		{"wrong scrape token", "Bearer scrape-secreT", http.StatusUnauthorized},
+		{"no credentials", "", http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestMetricsAccessWithoutScrapeToken(t *testing.T) {
+	handler := MetricsAccess(auth.NewTokenManager("test-secret"), "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// An empty token must not match an empty bearer
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+}
\ No newline at end of file
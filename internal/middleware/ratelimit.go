@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/ratelimit"
+)
+
+// RateLimit middleware rejects requests beyond the policy's limits with 429.
+// Policies keyed by user must run after AuthMiddleware; unauthenticated requests fall back to the client IP.
+func RateLimit(policy *ratelimit.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := "ip:" + remoteIP(r)
+			if policy.KeyByUser() {
+				if user := auth.GetUserFromContext(r.Context()); user != nil {
+					key = "user:" + user.ID
+				}
+			}
+
+			allowed, retryAfter := policy.Allow(key)
+			if !allowed {
+				// The policy name lets users tell support which limit they hit
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				w.Header().Set("X-RateLimit-Policy", policy.Name())
+				http.Error(w, fmt.Sprintf("Too many requests (rate limit policy %q)", policy.Name()), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP returns the client address without its port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
\ No newline at end of file
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"s3-test-app/internal/auth"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/ratelimit"
+)
+
+// limited serves 200 behind a RateLimit middleware for policy
+func limited(policy *ratelimit.Policy) http.Handler {
+	return RateLimit(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// request sends a request from remoteAddr, authenticated as user when it isn't nil
+func request(handler http.Handler, remoteAddr string, user *auth.User) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+	r.RemoteAddr = remoteAddr
+	if user != nil {
+		r = r.WithContext(auth.SetUserInContext(r.Context(), user))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRateLimitRejectsBeyondBurst(t *testing.T) {
+	handler := limited(ratelimit.NewPolicy("auth-strict", config.RateLimitSpec{Rate: 2, Per: time.Minute, Burst: 2, Key: config.RateLimitByIP}))
+
+	for i := 0; i < 2; i++ {
+		if rec := request(handler, "10.0.0.1:1234", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+
+	rec := request(handler, "10.0.0.1:5678", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	if retry, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 30 {
+		t.Errorf("Retry-After = %q, want the seconds until a token refills", rec.Header().Get("Retry-After"))
+	}
+	if got := rec.Header().Get("X-RateLimit-Policy"); got != "auth-strict" {
+		t.Errorf("X-RateLimit-Policy = %q, want auth-strict", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"auth-strict"`) {
+		t.Errorf("body = %q, want the policy name", rec.Body.String())
+	}
+
+	if rec := request(handler, "10.0.0.2:1234", nil); rec.Code != http.StatusOK {
+		t.Errorf("another client: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitKeyedByUser(t *testing.T) {
+	handler := limited(ratelimit.NewPolicy("api-default", config.RateLimitSpec{Rate: 1, Per: time.Minute, Burst: 1, Key: config.RateLimitByUser}))
+	alice := &auth.User{ID: "alice-id"}
+	bob := &auth.User{ID: "bob-id"}
+
+	// Users behind the same address are counted separately
+	if rec := request(handler, "10.0.0.1:1234", alice); rec.Code != http.StatusOK {
+		t.Fatalf("alice: status = %d, want 200", rec.Code)
+	}
+	if rec := request(handler, "10.0.0.1:1234", bob); rec.Code != http.StatusOK {
+		t.Fatalf("bob: status = %d, want 200", rec.Code)
+	}
+	if rec := request(handler, "10.0.0.2:1234", alice); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("alice from another address: status = %d, want 429", rec.Code)
+	}
+
+	// Without a user the client IP is the key
+	if rec := request(handler, "10.0.0.1:1234", nil); rec.Code != http.StatusOK {
+		t.Errorf("anonymous: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitFollowsPolicyUpdates(t *testing.T) {
+	policy := ratelimit.NewPolicy("download-heavy", config.RateLimitSpec{Rate: 1, Per: time.Hour, Burst: 1, Key: config.RateLimitByIP})
+	handler := limited(policy)
+
+	request(handler, "10.0.0.1:1234", nil)
+	if rec := request(handler, "10.0.0.1:1234", nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+
+	policy.Update(config.RateLimitSpec{Rate: 1000, Per: time.Second, Burst: 10, Key: config.RateLimitByIP})
+	time.Sleep(5 * time.Millisecond)
+	if rec := request(handler, "10.0.0.1:1234", nil); rec.Code != http.StatusOK {
+		t.Errorf("after raising the limit: status = %d, want 200", rec.Code)
+	}
+}
\ No newline at end of file
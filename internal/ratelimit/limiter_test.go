@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterLocksOutAfterBurst(t *testing.T) {
+	l := New(3, time.Minute)
+
+	for i := range 3 {
+		if allowed, _ := l.Allow("alice|10.0.0.1"); !allowed {
+			t.Fatalf("attempt %d was refused, want the first 3 allowed", i+1)
+		}
+	}
+
+	allowed, wait := l.Allow("alice|10.0.0.1")
+	if allowed {
+		t.Fatal("attempt past the burst was allowed")
+	}
+	// One token refills every window/burst
+	if wait <= 0 || wait > 20*time.Second {
+		t.Errorf("wait = %v, want up to 20s", wait)
+	}
+
+	// Keys are limited independently
+	if allowed, _ := l.Allow("alice|10.0.0.2"); !allowed {
+		t.Error("another key was refused")
+	}
+}
+
+func TestLimiterResetRestoresAllowance(t *testing.T) {
+	l := New(3, time.Minute)
+	for range 3 {
+		l.Allow("alice")
+	}
+	if allowed, _ := l.Allow("alice"); allowed {
+		t.Fatal("attempt past the burst was allowed")
+	}
+
+	l.Reset("alice")
+
+	for i := range 3 {
+		if allowed, _ := l.Allow("alice"); !allowed {
+			t.Fatalf("attempt %d after reset was refused", i+1)
+		}
+	}
+	if allowed, _ := l.Allow("alice"); allowed {
+		t.Error("reset allowed more than a full burst")
+	}
+}
+
+func TestLimiterRefillsOverWindow(t *testing.T) {
+	const window = 100 * time.Millisecond
+	l := New(2, window)
+	for range 2 {
+		l.Allow("alice")
+	}
+
+	allowed, wait := l.Allow("alice")
+	if allowed {
+		t.Fatal("attempt past the burst was allowed")
+	}
+
+	// Waiting as long as told frees one attempt
+	time.Sleep(wait)
+	if allowed, _ := l.Allow("alice"); !allowed {
+		t.Fatalf("attempt after waiting %v was refused", wait)
+	}
+
+	// A whole window refills the full burst
+	time.Sleep(window)
+	for i := range 2 {
+		if allowed, _ := l.Allow("alice"); !allowed {
+			t.Fatalf("attempt %d after a full window was refused", i+1)
+		}
+	}
+}
+
+func TestLimiterCleanupDropsRefilledBuckets(t *testing.T) {
+	const window = 200 * time.Millisecond
+	l := New(2, window)
+	l.Allow("idle")
+	time.Sleep(window)
+	l.Allow("busy")
+	l.Allow("busy")
+
+	if removed := l.Cleanup(); removed != 1 {
+		t.Errorf("Cleanup removed %d buckets, want 1", removed)
+	}
+	if allowed, _ := l.Allow("busy"); allowed {
+		t.Error("cleanup forgot a bucket that had not refilled")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"s3-test-app/internal/config"
+)
+
+// Policy is a named rate limit applied to a group of routes. Its limits can be
+// changed while it is in use.
+type Policy struct {
+	name    string
+	limiter *Limiter
+
+	mu   sync.RWMutex
+	spec config.RateLimitSpec
+}
+
+// NewPolicy creates a policy enforcing spec
+func NewPolicy(name string, spec config.RateLimitSpec) *Policy {
+	return &Policy{
+		name:    name,
+		limiter: newLimiter(rateOf(spec), float64(spec.Burst)),
+		spec:    spec,
+	}
+}
+
+// Name returns the policy name
+func (p *Policy) Name() string {
+	return p.name
+}
+
+// Spec returns the limits currently enforced
+func (p *Policy) Spec() config.RateLimitSpec {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.spec
+}
+
+// KeyByUser reports whether requests are counted per user rather than per IP
+func (p *Policy) KeyByUser() bool {
+	return p.Spec().Key == config.RateLimitByUser
+}
+
+// Update replaces the policy's limits. Existing clients keep their remaining tokens.
+func (p *Policy) Update(spec config.RateLimitSpec) {
+	p.mu.Lock()
+	p.spec = spec
+	p.mu.Unlock()
+
+	p.limiter.setRate(rateOf(spec), float64(spec.Burst))
+}
+
+// Allow consumes a request for key, see Limiter.Allow
+func (p *Policy) Allow(key string) (bool, time.Duration) {
+	return p.limiter.Allow(key)
+}
+
+// rateOf converts a spec to tokens per second
+func rateOf(spec config.RateLimitSpec) float64 {
+	return float64(spec.Rate) / spec.Per.Seconds()
+}
+
+// Registry holds the named policies so they can be looked up and tuned by name
+type Registry struct {
+	policies map[string]*Policy
+}
+
+// NewRegistry creates a policy for every spec
+func NewRegistry(specs map[string]config.RateLimitSpec) *Registry {
+	policies := make(map[string]*Policy, len(specs))
+	for name, spec := range specs {
+		policies[name] = NewPolicy(name, spec)
+	}
+	return &Registry{policies: policies}
+}
+
+// Policy returns the named policy. It panics on unknown names, which are programming errors.
+func (r *Registry) Policy(name string) *Policy {
+	p, ok := r.policies[name]
+	if !ok {
+		panic(fmt.Sprintf("unknown rate limit policy %q", name))
+	}
+	return p
+}
+
+// Lookup returns the named policy, if it exists
+func (r *Registry) Lookup(name string) (*Policy, bool) {
+	p, ok := r.policies[name]
+	return p, ok
+}
+
+// Names returns the policy names in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.policies))
+	for name := range r.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run periodically cleans up idle buckets of every policy until ctx is cancelled
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, p := range r.policies {
+				p.limiter.Cleanup()
+			}
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownBucket is returned when a bucket name is not in the configured registry
+var ErrUnknownBucket = errors.New("unknown bucket")
+
+// bucketRegistry holds a service per configured bucket, all sharing one S3 client
+type bucketRegistry struct {
+	// names lists the buckets in configuration order, the primary first
+	names    []string
+	services map[string]*S3Service
+}
+
+// ForBucket returns the service for the bucket registered as name, or the
+// primary bucket's service when name is empty. Names outside the registry
+// are refused, so arbitrary buckets never reach S3.
+func (s *S3Service) ForBucket(name string) (*S3Service, error) {
+	if name == "" {
+		return s.buckets.services[s.buckets.names[0]], nil
+	}
+	svc, ok := s.buckets.services[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownBucket, name)
+	}
+	return svc, nil
+}
+
+// BucketNames lists the registered bucket names, the primary first
+func (s *S3Service) BucketNames() []string {
+	return append([]string(nil), s.buckets.names...)
+}
+
+// Name returns the name the bucket is registered under
+func (s *S3Service) Name() string {
+	return s.name
+}
+
+// ensureBucket checks that the bucket exists and, when autoCreate is set, creates it if missing.
+// A missing bucket without autoCreate is an error, so a misconfiguration shows at startup
+// rather than on the first upload. A bucket that can't be checked, for instance because
+// the credentials may not list it, is only logged.
+func (s *S3Service) ensureBucket(ctx context.Context, region string, autoCreate bool) error {
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err == nil {
+		s.logger.Info("bucket exists", zap.String("bucket", s.bucket))
+		return nil
+	}
+	if !isBucketNotFound(err) {
+		s.logger.Warn("could not check bucket", zap.String("bucket", s.bucket), zap.Error(err))
+		return nil
+	}
+	if !autoCreate {
+		return fmt.Errorf("bucket %q does not exist; create it or set S3_AUTO_CREATE_BUCKET=true", s.bucket)
+	}
+
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(s.bucket),
+	}
+	// us-east-1 is the default location and must not be sent as a constraint.
+	// Path-style addressing, which MinIO and SeaweedFS need, is set on the client.
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(region),
+		}
+	}
+
+	if _, err := s.client.CreateBucket(ctx, input); err != nil {
+		var owned *types.BucketAlreadyOwnedByYou
+		if errors.As(err, &owned) {
+			// Another instance created it between our check and now
+			s.logger.Info("bucket exists", zap.String("bucket", s.bucket))
+			return nil
+		}
+		return fmt.Errorf("failed to create bucket %q: %w", s.bucket, err)
+	}
+
+	s.logger.Info("bucket created", zap.String("bucket", s.bucket), zap.String("region", region))
+	return nil
+}
+
+// Ping checks that the bucket can be reached with a HeadBucket call
+func (s *S3Service) Ping(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	return nil
+}
+
+// isBucketNotFound reports whether an SDK error means the bucket does not exist
+func isBucketNotFound(err error) bool {
+	var noSuchBucket *types.NoSuchBucket
+	if errors.As(err, &noSuchBucket) || isNotFound(err) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+}
\ No newline at end of file
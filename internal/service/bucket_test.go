@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/fakes3"
+)
+
+const testBucket = "test-bucket"
+
+// testConfig returns an S3 configuration pointing at fake
+func testConfig(fake *fakes3.Server) *config.S3Config {
+	return &config.S3Config{
+		Endpoint:         fake.URL,
+		Region:           "us-east-1",
+		Bucket:           testBucket,
+		AccessKey:        "test",
+		SecretKey:        "test",
+		MaxAttempts:      1,
+		RetryMode:        config.RetryModeStandard,
+		OperationTimeout: 10 * time.Second,
+		DialTimeout:      time.Second,
+		MaxConcurrentOps: 8,
+		ConcurrencyWait:  time.Second,
+		ListConcurrency:  2,
+	}
+}
+
+func TestStartupWithExistingBucket(t *testing.T) {
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+
+	if _, err := NewS3Service(testConfig(fake), zap.NewNop()); err != nil {
+		t.Fatalf("NewS3Service: %v", err)
+	}
+	if n := fake.Requests("CreateBucket"); n != 0 {
+		t.Errorf("CreateBucket called %d times for an existing bucket", n)
+	}
+}
+
+func TestStartupFailsOnMissingBucket(t *testing.T) {
+	fake := fakes3.New(t)
+
+	_, err := NewS3Service(testConfig(fake), zap.NewNop())
+	if err == nil {
+		t.Fatal("NewS3Service succeeded without the bucket")
+	}
+	if !strings.Contains(err.Error(), testBucket) || !strings.Contains(err.Error(), "S3_AUTO_CREATE_BUCKET") {
+		t.Errorf("error %q should name the bucket and the flag", err)
+	}
+	if n := fake.Requests("CreateBucket"); n != 0 {
+		t.Errorf("CreateBucket called %d times without auto-create", n)
+	}
+}
+
+func TestStartupCreatesMissingBucket(t *testing.T) {
+	for _, tc := range []struct {
+		region     string
+		constraint string
+	}{
+		{"us-east-1", ""},
+		{"eu-west-1", "<LocationConstraint>eu-west-1</LocationConstraint>"},
+	} {
+		t.Run(tc.region, func(t *testing.T) {
+			fake := fakes3.New(t)
+			var body []byte
+			fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+				if r.Method == http.MethodPut && strings.Trim(r.URL.Path, "/") == testBucket {
+					body, _ = io.ReadAll(r.Body)
+					r.Body = io.NopCloser(bytes.NewReader(body))
+				}
+				return false
+			})
+			cfg := testConfig(fake)
+			cfg.Region = tc.region
+			cfg.AutoCreateBucket = true
+
+			if _, err := NewS3Service(cfg, zap.NewNop()); err != nil {
+				t.Fatalf("NewS3Service: %v", err)
+			}
+			if n := fake.Requests("CreateBucket"); n != 1 {
+				t.Fatalf("CreateBucket called %d times, want 1", n)
+			}
+			if tc.constraint == "" && len(body) != 0 {
+				t.Errorf("us-east-1 sent a location constraint: %s", body)
+			}
+			if tc.constraint != "" && !bytes.Contains(body, []byte(tc.constraint)) {
+				t.Errorf("CreateBucket body %s lacks %s", body, tc.constraint)
+			}
+		})
+	}
+}
+
+func TestStartupToleratesBucketCreatedConcurrently(t *testing.T) {
+	fake := fakes3.New(t)
+	// HeadBucket says missing, but another instance creates it before we do
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodHead && strings.Trim(r.URL.Path, "/") == testBucket {
+			fake.CreateBucket(testBucket)
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		return false
+	})
+	cfg := testConfig(fake)
+	cfg.AutoCreateBucket = true
+
+	if _, err := NewS3Service(cfg, zap.NewNop()); err != nil {
+		t.Errorf("NewS3Service: %v", err)
+	}
+}
+
+func TestStartupContinuesWhenBucketCannotBeChecked(t *testing.T) {
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+	// Credentials that may write objects but not head the bucket
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodHead && strings.Trim(r.URL.Path, "/") == testBucket {
+			fakes3.WriteError(w, r, http.StatusForbidden, "AccessDenied")
+			return true
+		}
+		return false
+	})
+
+	if _, err := NewS3Service(testConfig(fake), zap.NewNop()); err != nil {
+		t.Errorf("NewS3Service: %v", err)
+	}
+}
\ No newline at end of file
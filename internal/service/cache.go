@@ -0,0 +1,218 @@
+package service
+
+import (
+	"container/list"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3-test-app/internal/metrics"
+)
+
+// objectCache keeps the content and metadata of small objects in memory, least recently
+// used first out. Writes made through S3Service invalidate the key; writes made around it,
+// such as presigned uploads, are only noticed once the entry's TTL runs out and the
+// object's ETag is found to have changed. A nil cache is disabled.
+type objectCache struct {
+	maxBytes  int64
+	maxObject int64
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	size  int64
+	order *list.List
+	items map[string]*list.Element
+	// generation increases on every invalidation, so a read that raced a write doesn't
+	// store what it read before the write
+	generation uint64
+}
+
+// cacheEntry is one cached object
+type cacheEntry struct {
+	id      string
+	info    FileInfo
+	data    []byte
+	expires time.Time
+}
+
+// newObjectCache returns a cache holding up to maxBytes of objects no larger than
+// maxObject each, or nil when maxBytes is 0
+func newObjectCache(maxBytes, maxObject int64, ttl time.Duration) *objectCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &objectCache{
+		maxBytes:  maxBytes,
+		maxObject: min(maxObject, maxBytes),
+		ttl:       ttl,
+		order:     list.New(),
+		items:     make(map[string]*list.Element),
+	}
+}
+
+// countLookup records a read cache hit or miss for operation
+func (c *objectCache) countLookup(operation string, hit bool) {
+	if c == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	metrics.S3CacheRequests.WithLabelValues(operation, result).Inc()
+}
+
+// cacheID identifies key across the buckets sharing one cache
+func cacheID(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// fits reports whether an object of size bytes may be cached
+func (c *objectCache) fits(size int64) bool {
+	return c != nil && size <= c.maxObject
+}
+
+// lookup returns the entry for id and whether it is still fresh. Stale entries are
+// returned so their ETag can be revalidated.
+func (c *objectCache) lookup(id string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	return entry, time.Now().Before(entry.expires)
+}
+
+// begin returns the generation a read starts at, to be passed to put
+func (c *objectCache) begin() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put stores an object read at generation, unless something was invalidated since
+func (c *objectCache) put(id string, info FileInfo, data []byte, generation uint64) {
+	if !c.fits(int64(len(data))) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.items[id]; ok {
+		c.remove(elem)
+	}
+	info.Metadata = maps.Clone(info.Metadata)
+	c.items[id] = c.order.PushFront(&cacheEntry{
+		id:      id,
+		info:    info,
+		data:    data,
+		expires: time.Now().Add(c.ttl),
+	})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+	metrics.S3CacheBytes.Set(float64(c.size))
+}
+
+// revalidate extends a stale entry whose object still has the same ETag, and drops it otherwise
+func (c *objectCache) revalidate(id, etag string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*cacheEntry)
+	if etag != "" && entry.info.ETag == etag {
+		entry.expires = time.Now().Add(c.ttl)
+		return
+	}
+	c.remove(elem)
+	metrics.S3CacheBytes.Set(float64(c.size))
+}
+
+// invalidate drops the entries for ids
+func (c *objectCache) invalidate(ids ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, id := range ids {
+		if elem, ok := c.items[id]; ok {
+			c.remove(elem)
+		}
+	}
+	metrics.S3CacheBytes.Set(float64(c.size))
+}
+
+// invalidatePrefix drops every entry whose id starts with prefix
+func (c *objectCache) invalidatePrefix(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for id, elem := range c.items {
+		if strings.HasPrefix(id, prefix) {
+			c.remove(elem)
+		}
+	}
+	metrics.S3CacheBytes.Set(float64(c.size))
+}
+
+// remove unlinks elem; the caller holds mu
+func (c *objectCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.id)
+	c.size -= int64(len(entry.data))
+}
+
+// cachedRange returns the part of data selected by a "bytes=start-end" range header
+// and its Content-Range value. Other forms report false and are left to S3.
+func cachedRange(data []byte, rangeHeader string) ([]byte, string, bool) {
+	spec, ok := strings.CutPrefix(rangeHeader, "bytes=")
+	if !ok {
+		return nil, "", false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, "", false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= int64(len(data)) {
+		return nil, "", false
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return nil, "", false
+	}
+	end = min(end, int64(len(data))-1)
+	return data[start : end+1], fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)), true
+}
\ No newline at end of file
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/config"
+)
+
+// CanaryPrefix holds the probe objects written by the canary. It is hidden from listings.
+const CanaryPrefix = "canary/"
+
+// isCanaryKey reports whether key belongs to the canary
+func isCanaryKey(key string) bool {
+	return strings.HasPrefix(key, CanaryPrefix)
+}
+
+// CanaryStatus is a snapshot of the canary's recent results
+type CanaryStatus struct {
+	Enabled             bool      `json:"enabled"`
+	Runs                int64     `json:"runs"`
+	Failures            int64     `json:"failures"`
+	Mismatches          int64     `json:"mismatches"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Alerting            bool      `json:"alerting"`
+	LastRun             time.Time `json:"last_run,omitzero"`
+	LastSuccess         time.Time `json:"last_success,omitzero"`
+	LastError           string    `json:"last_error,omitempty"`
+	UploadLatencyMs     int64     `json:"upload_latency_ms"`
+	DownloadLatencyMs   int64     `json:"download_latency_ms"`
+}
+
+// Canary periodically writes, reads back and deletes a probe object to catch
+// a storage path that is reachable but returning wrong data
+type Canary struct {
+	s3     *S3Service
+	logger *zap.Logger
+	cfg    config.CanaryConfig
+
+	mu     sync.Mutex
+	status CanaryStatus
+}
+
+// NewCanary creates a canary using the given configuration
+func NewCanary(s3Service *S3Service, logger *zap.Logger, cfg config.CanaryConfig) *Canary {
+	return &Canary{
+		s3:     s3Service,
+		logger: logger,
+		cfg:    cfg,
+		status: CanaryStatus{Enabled: cfg.Interval > 0},
+	}
+}
+
+// Run probes storage every interval until ctx is cancelled. It does nothing when the canary is disabled.
+func (c *Canary) Run(ctx context.Context) {
+	if c.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.Probe(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the latest canary results
+func (c *Canary) Status() CanaryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Probe runs one upload, read-back and delete cycle and records the result
+func (c *Canary) Probe(ctx context.Context) {
+	c.mu.Lock()
+	run := c.status.Runs + 1
+	c.mu.Unlock()
+
+	// Content is derived from the seed and run number, so a failing run can be reproduced
+	expected := make([]byte, c.cfg.Size)
+	rand.New(rand.NewSource(c.cfg.Seed + run)).Read(expected)
+	key := fmt.Sprintf("%s%d-%d.bin", CanaryPrefix, time.Now().UnixNano(), run)
+
+	probeCtx, cancelProbe := context.WithTimeout(ctx, time.Minute)
+	uploadLatency, downloadLatency, mismatch, err := c.probe(probeCtx, key, expected)
+	cancelProbe()
+
+	// The probe object must not linger even if the read-back failed
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if delErr := c.s3.DeleteFile(cleanupCtx, key); delErr != nil && err == nil {
+		err = fmt.Errorf("failed to delete canary object: %w", delErr)
+	}
+	cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status.Runs = run
+	c.status.LastRun = time.Now()
+	c.status.UploadLatencyMs = uploadLatency.Milliseconds()
+	c.status.DownloadLatencyMs = downloadLatency.Milliseconds()
+
+	if err == nil {
+		if c.status.Alerting {
+			c.logger.Info("canary recovered", zap.Int("failed_runs", c.status.ConsecutiveFailures))
+		}
+		c.status.ConsecutiveFailures = 0
+		c.status.Alerting = false
+		c.status.LastSuccess = c.status.LastRun
+		c.status.LastError = ""
+		c.logger.Debug("canary probe succeeded",
+			zap.Duration("upload_latency", uploadLatency),
+			zap.Duration("download_latency", downloadLatency),
+		)
+		return
+	}
+
+	c.status.Failures++
+	c.status.ConsecutiveFailures++
+	c.status.LastError = err.Error()
+
+	// Corruption is alerted on immediately; transient errors only once they persist
+	if mismatch {
+		c.status.Mismatches++
+		c.status.Alerting = true
+		c.logger.Error("CANARY ALERT: storage returned corrupted data",
+			zap.String("key", key),
+			zap.Int64("run", run),
+			zap.Int64("seed", c.cfg.Seed),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if c.status.ConsecutiveFailures >= c.cfg.AlertAfter {
+		c.status.Alerting = true
+		c.logger.Error("CANARY ALERT: storage probe failing",
+			zap.Int("consecutive_failures", c.status.ConsecutiveFailures),
+			zap.Error(err),
+		)
+		return
+	}
+
+	c.logger.Warn("canary probe failed", zap.Int("consecutive_failures", c.status.ConsecutiveFailures), zap.Error(err))
+}
+
+// probe uploads expected under key and reads it back through GetFile
+func (c *Canary) probe(ctx context.Context, key string, expected []byte) (upload, download time.Duration, mismatch bool, err error) {
+	start := time.Now()
+	if err := c.s3.UploadFile(ctx, key, bytes.NewReader(expected), UploadOptions{ContentType: "application/octet-stream"}); err != nil {
+		return time.Since(start), 0, false, err
+	}
+	upload = time.Since(start)
+
+	start = time.Now()
+	obj, err := c.s3.GetFile(ctx, key)
+	download = time.Since(start)
+	if err != nil {
+		return upload, download, false, err
+	}
+
+	if !bytes.Equal(obj.Data, expected) {
+		return upload, download, true, fmt.Errorf("read back %d bytes that differ from the %d bytes written", len(obj.Data), len(expected))
+	}
+
+	return upload, download, false, nil
+}
\ No newline at end of file
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// File categories used to group and filter listings
+const (
+	CategoryImage    = "image"
+	CategoryVideo    = "video"
+	CategoryAudio    = "audio"
+	CategoryDocument = "document"
+	CategoryArchive  = "archive"
+	CategoryCode     = "code"
+	CategoryOther    = "other"
+)
+
+// Categories lists every category Categorize can return
+var Categories = []string{
+	CategoryImage,
+	CategoryVideo,
+	CategoryAudio,
+	CategoryDocument,
+	CategoryArchive,
+	CategoryCode,
+	CategoryOther,
+}
+
+// extensionCategories covers types that content types describe poorly,
+// such as source files sniffed as text/plain
+var extensionCategories = map[string]string{
+	".go": CategoryCode, ".py": CategoryCode, ".js": CategoryCode, ".ts": CategoryCode,
+	".java": CategoryCode, ".c": CategoryCode, ".h": CategoryCode, ".cpp": CategoryCode,
+	".rs": CategoryCode, ".rb": CategoryCode, ".php": CategoryCode, ".sh": CategoryCode,
+	".sql": CategoryCode, ".json": CategoryCode, ".yaml": CategoryCode, ".yml": CategoryCode,
+	".toml": CategoryCode, ".xml": CategoryCode, ".html": CategoryCode, ".css": CategoryCode,
+
+	".pdf": CategoryDocument, ".doc": CategoryDocument, ".docx": CategoryDocument,
+	".xls": CategoryDocument, ".xlsx": CategoryDocument, ".ppt": CategoryDocument,
+	".pptx": CategoryDocument, ".odt": CategoryDocument, ".ods": CategoryDocument,
+	".odp": CategoryDocument, ".rtf": CategoryDocument, ".txt": CategoryDocument,
+	".md": CategoryDocument, ".csv": CategoryDocument,
+
+	".zip": CategoryArchive, ".tar": CategoryArchive, ".gz": CategoryArchive,
+	".tgz": CategoryArchive, ".bz2": CategoryArchive, ".xz": CategoryArchive,
+	".7z": CategoryArchive, ".rar": CategoryArchive,
+}
+
+// contentTypeCategories maps full content types that aren't covered by their top-level type
+var contentTypeCategories = map[string]string{
+	"application/pdf":               CategoryDocument,
+	"application/msword":            CategoryDocument,
+	"application/rtf":               CategoryDocument,
+	"application/vnd.ms-excel":      CategoryDocument,
+	"application/vnd.ms-powerpoint": CategoryDocument,
+	"text/plain":                    CategoryDocument,
+	"text/csv":                      CategoryDocument,
+	"text/markdown":                 CategoryDocument,
+
+	"application/zip":              CategoryArchive,
+	"application/gzip":             CategoryArchive,
+	"application/x-gzip":           CategoryArchive,
+	"application/x-tar":            CategoryArchive,
+	"application/x-bzip2":          CategoryArchive,
+	"application/x-xz":             CategoryArchive,
+	"application/x-7z-compressed":  CategoryArchive,
+	"application/x-rar-compressed": CategoryArchive,
+	"application/vnd.rar":          CategoryArchive,
+
+	"application/json":       CategoryCode,
+	"application/javascript": CategoryCode,
+	"application/xml":        CategoryCode,
+	"application/x-sh":       CategoryCode,
+	"text/html":              CategoryCode,
+	"text/css":               CategoryCode,
+	"text/javascript":        CategoryCode,
+	"text/xml":               CategoryCode,
+}
+
+// Categorize derives a file's category from its name and content type.
+// The extension wins when it is known; anything unrecognised is CategoryOther.
+func Categorize(contentType, name string) string {
+	if category, ok := extensionCategories[strings.ToLower(path.Ext(name))]; ok {
+		return category
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return CategoryOther
+	}
+	if category, ok := contentTypeCategories[mediaType]; ok {
+		return category
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(mediaType, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(mediaType, "audio/"):
+		return CategoryAudio
+	case strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(mediaType, "application/vnd.oasis.opendocument."):
+		return CategoryDocument
+	case strings.HasPrefix(mediaType, "text/x-"):
+		return CategoryCode
+	}
+
+	return CategoryOther
+}
\ No newline at end of file
@@ -0,0 +1,52 @@
+package service
+
+import "testing"
+
+func TestCategorize(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		filename    string
+		want        string
+	}{
+		{"exact content type", "application/pdf", "report", CategoryDocument},
+		{"content type with parameters", "text/csv; charset=utf-8", "export", CategoryDocument},
+		{"content type case", "Application/ZIP", "bundle", CategoryArchive},
+		{"image family", "image/webp", "photo", CategoryImage},
+		{"video family", "video/mp4", "clip", CategoryVideo},
+		{"audio family", "audio/ogg", "track", CategoryAudio},
+		{"office family", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "letter", CategoryDocument},
+		{"text/x- family", "text/x-python", "script", CategoryCode},
+		{"extension without content type", "", "main.go", CategoryCode},
+		{"extension over generic content type", "application/octet-stream", "backup.tar", CategoryArchive},
+		{"extension over sniffed text", "text/plain; charset=utf-8", "query.sql", CategoryCode},
+		{"extension case", "", "SLIDES.PPTX", CategoryDocument},
+		{"last extension counts", "", "logs.tar.gz", CategoryArchive},
+		{"unknown content type and extension", "application/octet-stream", "blob.bin", CategoryOther},
+		{"malformed content type", "not a type;;", "blob", CategoryOther},
+		{"nothing to go on", "", "", CategoryOther},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Categorize(tc.contentType, tc.filename); got != tc.want {
+				t.Errorf("Categorize(%q, %q) = %q, want %q", tc.contentType, tc.filename, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCategorizeReturnsListedCategories(t *testing.T) {
+	known := make(map[string]bool, len(Categories))
+	for _, category := range Categories {
+		known[category] = true
+	}
+	for _, category := range extensionCategories {
+		if !known[category] {
+			t.Errorf("extension category %q is missing from Categories", category)
+		}
+	}
+	for contentType, category := range contentTypeCategories {
+		if !known[category] {
+			t.Errorf("category %q of %s is missing from Categories", category, contentType)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ContentEncodingGzip is the Content-Encoding of objects stored gzip-compressed
+const ContentEncodingGzip = "gzip"
+
+// OriginalSizeMetadataKey is the object metadata entry holding the size of a compressed
+// object before compression
+const OriginalSizeMetadataKey = "original-size"
+
+// precompressedExtensions are formats that are compressed containers even though their
+// category doesn't say so
+var precompressedExtensions = map[string]bool{
+	".docx": true, ".xlsx": true, ".pptx": true, ".odt": true, ".ods": true, ".odp": true,
+	".pdf": true, ".jar": true, ".apk": true, ".whl": true, ".woff": true, ".woff2": true,
+}
+
+// Compressible reports whether a file is worth storing gzip-compressed. Images, video,
+// audio, archives and other formats that are compressed already would only grow.
+func Compressible(contentType, name string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	// The few image formats that are plain text or uncompressed
+	if mediaType == "image/svg+xml" || mediaType == "image/bmp" {
+		return true
+	}
+	if precompressedExtensions[strings.ToLower(path.Ext(name))] || mediaType == "application/pdf" ||
+		strings.HasPrefix(mediaType, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mediaType, "application/vnd.oasis.opendocument.") {
+		return false
+	}
+
+	switch Categorize(contentType, name) {
+	case CategoryImage, CategoryVideo, CategoryAudio, CategoryArchive:
+		return false
+	}
+	return true
+}
+
+// GzipSpool is content compressed into a temporary file, together with the size and
+// digests of the compressed bytes the upload has to declare. Close removes the file.
+type GzipSpool struct {
+	*os.File
+	Size   int64
+	MD5    []byte
+	SHA256 []byte
+}
+
+// SpoolGzip compresses r into a temporary file, rewound and ready to upload
+func SpoolGzip(r io.Reader) (*GzipSpool, error) {
+	file, err := os.CreateTemp("", "upload-*.gz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	spool := &GzipSpool{File: file}
+
+	sha := sha256.New()
+	md := md5.New()
+	counter := &countingWriter{w: io.MultiWriter(file, sha, md)}
+	gz := gzip.NewWriter(counter)
+	if _, err := io.Copy(gz, r); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to compress file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to compress file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	spool.Size = counter.n
+	spool.MD5 = md.Sum(nil)
+	spool.SHA256 = sha.Sum(nil)
+	return spool, nil
+}
+
+// Close closes and removes the spool file
+func (s *GzipSpool) Close() error {
+	err := s.File.Close()
+	if removeErr := os.Remove(s.File.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// originalSize returns the size of an object with the given content encoding before it
+// was compressed, or 0 when it isn't compressed or the size wasn't recorded
+func originalSize(contentEncoding string, metadata map[string]string) int64 {
+	if contentEncoding == "" {
+		return 0
+	}
+	size, err := strconv.ParseInt(metadata[OriginalSizeMetadataKey], 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// Decoded returns the body of the stream with its content encoding undone, so reading
+// it yields the bytes originally uploaded. Closing it closes the stream's body.
+func (o *ObjectStream) Decoded() (io.ReadCloser, error) {
+	if o.ContentEncoding != ContentEncodingGzip {
+		return o.Body, nil
+	}
+	gz, err := gzip.NewReader(o.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress file: %w", err)
+	}
+	return &gzipBody{Reader: gz, body: o.Body}, nil
+}
+
+// gzipBody reads a decompressed object body and closes the underlying one
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
\ No newline at end of file
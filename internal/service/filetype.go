@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"s3-test-app/internal/config"
+)
+
+// ErrUnsupportedType is returned when an upload is refused by the file type policy
+var ErrUnsupportedType = errors.New("file type not allowed")
+
+// sniffedExtensions maps extensions to the type http.DetectContentType reports for genuine
+// files of that kind, so a renamed file can be caught. Formats the sniffer can't recognise
+// are left out, since every such file would look like a mismatch
+var sniffedExtensions = map[string]string{
+	".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".gif": "image/gif",
+	".webp": "image/webp", ".bmp": "image/bmp", ".ico": "image/x-icon",
+	".pdf": "application/pdf", ".zip": "application/zip", ".gz": "application/x-gzip",
+	".mp4": "video/mp4", ".webm": "video/webm",
+}
+
+// CheckFileType applies the upload type policy to a file. The content type is sniffed from
+// head, the first bytes of the file; a type the client declared is checked as well since it
+// is what gets stored and served back, but it never stands in for the sniffed one
+func CheckFileType(policy config.UploadTypeConfig, filename, declared string, head []byte) error {
+	if err := CheckFileExtension(policy, filename); err != nil {
+		return err
+	}
+
+	ext := strings.ToLower(path.Ext(filename))
+	sniffed := mediaType(http.DetectContentType(head))
+	if want, ok := sniffedExtensions[ext]; ok && sniffed != want {
+		return fmt.Errorf("%w: content is %s, not the %s its extension claims", ErrUnsupportedType, sniffed, want)
+	}
+
+	types := []string{sniffed}
+	if declared = mediaType(declared); declared != "" && declared != "application/octet-stream" {
+		types = append(types, declared)
+	}
+	for _, t := range types {
+		if len(policy.AllowedTypes) > 0 && !matchesType(policy.AllowedTypes, t) {
+			return fmt.Errorf("%w: content type %s", ErrUnsupportedType, t)
+		}
+		if matchesType(policy.DeniedTypes, t) {
+			return fmt.Errorf("%w: content type %s", ErrUnsupportedType, t)
+		}
+	}
+	return nil
+}
+
+// CheckFileExtension applies only the extension rules of the upload type policy, for when
+// the content isn't available yet
+func CheckFileExtension(policy config.UploadTypeConfig, filename string) error {
+	ext := strings.ToLower(path.Ext(filename))
+	if len(policy.AllowedExtensions) > 0 && !containsFold(policy.AllowedExtensions, ext) {
+		return fmt.Errorf("%w: extension %q", ErrUnsupportedType, ext)
+	}
+	if containsFold(policy.DeniedExtensions, ext) {
+		return fmt.Errorf("%w: extension %q", ErrUnsupportedType, ext)
+	}
+	return nil
+}
+
+// mediaType strips parameters such as charset from a content type and lowercases it
+func mediaType(contentType string) string {
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+}
+
+// matchesType reports whether contentType matches one of patterns, which are exact
+// media types or wildcards such as "image/*"
+func matchesType(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if major, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(contentType, major+"/") {
+				return true
+			}
+		} else if pattern == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether list holds value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
\ No newline at end of file
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+	"s3-test-app/internal/metrics"
+)
+
+// Error classes of the S3 client metrics
+const (
+	errorClassNone         = "none"
+	errorClassNotFound     = "not_found"
+	errorClassAccessDenied = "access_denied"
+	errorClassThrottled    = "throttled"
+	errorClassClient       = "client"
+	errorClassServer       = "server"
+	errorClassTimeout      = "timeout"
+	errorClassCanceled     = "canceled"
+	errorClassNetwork      = "network"
+)
+
+// instrumentation returns an SDK stack option that records every S3 call in the client
+// metrics and logs calls slower than slow as warnings; slow 0 disables the log. A call
+// is timed across all of its retries, while transferred bytes are counted per attempt.
+func instrumentation(logger *zap.Logger, slow time.Duration) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		// Added last so the operation name is already on the context
+		err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3Instrumentation",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				start := time.Now()
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				elapsed := time.Since(start)
+
+				operation := awsmiddleware.GetOperationName(ctx)
+				status := responseStatus(metadata, err)
+				statusLabel := "none"
+				if status != 0 {
+					statusLabel = strconv.Itoa(status)
+				}
+				metrics.S3Requests.WithLabelValues(operation, statusLabel, errorClass(err, status)).Inc()
+				metrics.S3RequestDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+
+				if slow > 0 && elapsed > slow {
+					logger.Warn("slow s3 operation",
+						zap.String("operation", operation),
+						zap.String("key", inputKey(in.Parameters)),
+						zap.Duration("duration", elapsed),
+						zap.Int("status", status),
+						zap.Error(err))
+				}
+				return out, metadata, err
+			}), middleware.After)
+		if err != nil {
+			return err
+		}
+
+		// Added last so it sits next to the transport and sees each attempt's request
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("S3TransferBytes",
+			func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+				out, metadata, err := next.HandleDeserialize(ctx, in)
+
+				operation := awsmiddleware.GetOperationName(ctx)
+				req, ok := in.Request.(*smithyhttp.Request)
+				if !ok {
+					return out, metadata, err
+				}
+				if req.ContentLength > 0 {
+					metrics.S3TransferBytes.WithLabelValues(operation, "upload").Add(float64(req.ContentLength))
+				}
+				// A HEAD response declares the object's length without sending a body
+				if resp, ok := out.RawResponse.(*smithyhttp.Response); ok && req.Method != http.MethodHead &&
+					resp.StatusCode < 300 && resp.ContentLength > 0 {
+					metrics.S3TransferBytes.WithLabelValues(operation, "download").Add(float64(resp.ContentLength))
+				}
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
+
+// responseStatus returns the HTTP status code of a finished call, or 0 when no response
+// was received
+func responseStatus(metadata middleware.Metadata, err error) int {
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok {
+		return resp.StatusCode
+	}
+	return 0
+}
+
+// errorClass sorts the outcome of a call into a small set of metric labels
+func errorClass(err error, status int) string {
+	switch {
+	case err == nil:
+		return errorClassNone
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return errorClassCanceled
+	case isNotFound(err) || status == http.StatusNotFound:
+		return errorClassNotFound
+	case isAccessDenied(err):
+		return errorClassAccessDenied
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		return errorClassThrottled
+	case status >= 500:
+		return errorClassServer
+	case status >= 400:
+		return errorClassClient
+	}
+	return errorClassNetwork
+}
+
+// inputKey returns the object key or prefix an SDK call addresses, for logging
+func inputKey(params interface{}) string {
+	var key *string
+	switch input := params.(type) {
+	case *s3.GetObjectInput:
+		key = input.Key
+	case *s3.PutObjectInput:
+		key = input.Key
+	case *s3.HeadObjectInput:
+		key = input.Key
+	case *s3.DeleteObjectInput:
+		key = input.Key
+	case *s3.CopyObjectInput:
+		key = input.Key
+	case *s3.GetObjectTaggingInput:
+		key = input.Key
+	case *s3.PutObjectTaggingInput:
+		key = input.Key
+	case *s3.ListObjectsV2Input:
+		key = input.Prefix
+	case *s3.ListObjectVersionsInput:
+		key = input.Prefix
+	}
+	if key == nil {
+		return ""
+	}
+	return *key
+}
\ No newline at end of file
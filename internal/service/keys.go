@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"s3-test-app/internal/config"
+)
+
+// uploadPrefixPattern matches the "<unix>-" prefix the upload handlers put in front of filenames
+var uploadPrefixPattern = regexp.MustCompile(`^\d+-`)
+
+// maxFilenameLength caps a sanitized filename in bytes, leaving room in S3's
+// 1024-byte key limit for the user folder and upload prefix
+const maxFilenameLength = 200
+
+// fallbackFilename replaces a filename that sanitizes down to nothing
+const fallbackFilename = "file"
+
+// NormalizeFilename sanitizes an uploaded filename for use in a key and applies the configured key policy
+func NormalizeFilename(name string, policy config.KeyPolicyConfig) string {
+	name = SanitizeFilename(name)
+	if policy.NFC {
+		name = norm.NFC.String(name)
+	}
+	if policy.CollapseWhitespace {
+		name = strings.Join(strings.FieldsFunc(name, unicode.IsSpace), " ")
+	}
+	if policy.LowercaseExtensions {
+		if ext := path.Ext(name); ext != "" {
+			name = strings.TrimSuffix(name, ext) + strings.ToLower(ext)
+		}
+	}
+	return name
+}
+
+// SanitizeFilename makes a client-supplied filename safe to embed in a key: path separators
+// become underscores so the name can't leave the uploader's folder, control and invisible
+// formatting characters (such as right-to-left overrides) are dropped, names made only of
+// dots are replaced, and the result is cut to maxFilenameLength keeping the extension
+func SanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" {
+		return fallbackFilename
+	}
+
+	if len(name) > maxFilenameLength {
+		ext := path.Ext(name)
+		if len(ext) > maxFilenameLength/2 {
+			ext = ""
+		}
+		name = truncateUTF8(strings.TrimSuffix(name, ext), maxFilenameLength-len(ext)) + ext
+	}
+	return name
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// UserPrefix is the folder holding a user's files
+func UserPrefix(userID string) string {
+	return "users/" + userID + "/"
+}
+
+// UserFromKey returns the ID of the user whose folder holds key, or "" if it is in none
+func UserFromKey(key string) string {
+	rest, ok := strings.CutPrefix(key, "users/")
+	if !ok {
+		return ""
+	}
+	userID, _, ok := strings.Cut(rest, "/")
+	if !ok {
+		return ""
+	}
+	return userID
+}
+
+// OriginalName guesses the name a file was uploaded with from its key,
+// dropping the folder and the timestamp prefix added at upload
+func OriginalName(key string) string {
+	name := path.Base(key)
+	if trimmed := uploadPrefixPattern.ReplaceAllString(name, ""); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
+
+// ComparableName reduces a key's base name to the form used to spot near-duplicates:
+// upload prefix dropped, NFC-normalized, case-folded and with all whitespace removed
+func ComparableName(key string) string {
+	name := uploadPrefixPattern.ReplaceAllString(path.Base(key), "")
+	name = strings.ToLower(norm.NFC.String(name))
+	return strings.Join(strings.FieldsFunc(name, unicode.IsSpace), "")
+}
+
+// FindNearDuplicates returns keys in the same folder as key that differ from it
+// only by case, whitespace or Unicode normalization
+func (s *S3Service) FindNearDuplicates(ctx context.Context, key string) ([]string, error) {
+	folder := ""
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		folder = key[:i+1]
+	}
+
+	result, err := s.ListFiles(ctx, ListOptions{Prefix: folder, Delimiter: "/"})
+	if err != nil {
+		return nil, err
+	}
+
+	target := ComparableName(key)
+	name := uploadPrefixPattern.ReplaceAllString(path.Base(key), "")
+	matches := make([]string, 0)
+	for _, file := range result.Files {
+		if file.Key == key {
+			continue
+		}
+		existing := uploadPrefixPattern.ReplaceAllString(path.Base(file.Key), "")
+		if existing != name && ComparableName(file.Key) == target {
+			matches = append(matches, file.Key)
+		}
+	}
+	return matches, nil
+}
\ No newline at end of file
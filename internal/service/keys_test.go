@@ -0,0 +1,71 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"s3-test-app/internal/config"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "report.pdf", "report.pdf"},
+		{"unix traversal", "../../etc/passwd", ".._.._etc_passwd"},
+		{"windows traversal", `..\..\win.txt`, ".._.._win.txt"},
+		{"dot dot", "..", fallbackFilename},
+		{"single dot", ".", fallbackFilename},
+		{"whitespace only", " \t ", fallbackFilename},
+		{"absolute", "/etc/passwd", "_etc_passwd"},
+		{"right-to-left override", "invoice\u202etxt.exe", "invoicetxt.exe"},
+		{"zero-width space", "a\u200bb.txt", "ab.txt"},
+		{"control characters", "a\x00b\nc\x7f.txt", "abc.txt"},
+		{"invalid utf-8", "a\xffb.txt", "a_b.txt"},
+		{"surrounding space", "  notes.txt  ", "notes.txt"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SanitizeFilename(tc.in); got != tc.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameTruncates(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		ext  string
+	}{
+		{"ascii", strings.Repeat("a", 300) + ".txt", ".txt"},
+		{"multibyte", strings.Repeat("한", 150) + ".txt", ".txt"},
+		{"long extension", "a." + strings.Repeat("b", 300), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SanitizeFilename(tc.in)
+			if len(got) > maxFilenameLength {
+				t.Errorf("length = %d, want at most %d", len(got), maxFilenameLength)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("%q is not valid UTF-8", got)
+			}
+			if tc.ext != "" && !strings.HasSuffix(got, tc.ext) {
+				t.Errorf("%q lost the extension %s", got, tc.ext)
+			}
+		})
+	}
+}
+
+func TestNormalizeFilenameSanitizesFirst(t *testing.T) {
+	got := NormalizeFilename(`..\Report  FINAL.PDF`, config.KeyPolicyConfig{
+		LowercaseExtensions: true,
+		CollapseWhitespace:  true,
+	})
+	if want := ".._Report FINAL.pdf"; got != want {
+		t.Errorf("NormalizeFilename = %q, want %q", got, want)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+	"s3-test-app/internal/metrics"
+)
+
+// maxLifecycleRules is the most rules S3 accepts in one lifecycle configuration
+const maxLifecycleRules = 1000
+
+// ErrLifecycleUnsupported is returned when the backend has no bucket lifecycle support
+var ErrLifecycleUnsupported = errors.New("bucket lifecycle is not supported by the storage backend")
+
+// LifecycleRule expires the objects under Prefix once they are ExpirationDays old
+type LifecycleRule struct {
+	ID             string
+	Prefix         string
+	ExpirationDays int32
+}
+
+// ValidateLifecycleRules checks rules before they are stored. Every rule needs a prefix,
+// so a rule can never expire the whole bucket, and IDs and prefixes must be unique.
+func ValidateLifecycleRules(rules []LifecycleRule) error {
+	if len(rules) > maxLifecycleRules {
+		return fmt.Errorf("at most %d lifecycle rules are allowed", maxLifecycleRules)
+	}
+
+	ids := make(map[string]bool, len(rules))
+	prefixes := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Prefix) == "" {
+			return fmt.Errorf("rule %d: prefix is required; rules may not cover the whole bucket", i+1)
+		}
+		if strings.TrimSpace(rule.ID) == "" {
+			return fmt.Errorf("rule %d: id is required", i+1)
+		}
+		if len(rule.ID) > 255 {
+			return fmt.Errorf("rule %d: id must be at most 255 characters", i+1)
+		}
+		if rule.ExpirationDays < 1 {
+			return fmt.Errorf("rule %d: expiration_days must be at least 1", i+1)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule %d: duplicate id %q", i+1, rule.ID)
+		}
+		if prefixes[rule.Prefix] {
+			return fmt.Errorf("rule %d: duplicate prefix %q", i+1, rule.Prefix)
+		}
+		ids[rule.ID] = true
+		prefixes[rule.Prefix] = true
+	}
+	return nil
+}
+
+// GetLifecycleRules returns the expiration rules of the bucket lifecycle configuration.
+// Rules the app doesn't manage, such as transitions or tag filters, are only counted in
+// unmanaged. ErrLifecycleUnsupported is returned when the backend has no lifecycle support.
+func (s *S3Service) GetLifecycleRules(ctx context.Context) (rules []LifecycleRule, unmanaged int, err error) {
+	current, err := s.getLifecycle(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rules = make([]LifecycleRule, 0, len(current))
+	for _, rule := range current {
+		managed, ok := managedRule(rule)
+		if !ok {
+			unmanaged++
+			continue
+		}
+		rules = append(rules, managed)
+	}
+	return rules, unmanaged, nil
+}
+
+// PutLifecycleRules replaces the expiration rules of the bucket lifecycle configuration
+// with rules. Rules the app doesn't manage are kept as they are. ErrLifecycleUnsupported
+// is returned when the backend has no lifecycle support.
+func (s *S3Service) PutLifecycleRules(ctx context.Context, rules []LifecycleRule) error {
+	current, err := s.getLifecycle(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := make([]types.LifecycleRule, 0, len(current)+len(rules))
+	for _, rule := range current {
+		if _, ok := managedRule(rule); !ok {
+			config = append(config, rule)
+		}
+	}
+	for _, rule := range rules {
+		config = append(config, types.LifecycleRule{
+			ID:         aws.String(rule.ID),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     &types.LifecycleRuleFilter{Prefix: aws.String(rule.Prefix)},
+			Expiration: &types.LifecycleExpiration{Days: aws.Int32(rule.ExpirationDays)},
+		})
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	if len(config) == 0 {
+		_, err = s.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(s.bucket),
+		})
+	} else {
+		_, err = s.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+			Bucket:                 aws.String(s.bucket),
+			LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: config},
+		})
+	}
+	metrics.ObserveS3(metrics.OpLifecycle, start, err)
+	if err != nil {
+		if isLifecycleUnsupported(err) {
+			return ErrLifecycleUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return typed
+		}
+		s.logger.Error("failed to update bucket lifecycle", zap.String("bucket", s.bucket), zap.Error(err))
+		return fmt.Errorf("failed to update bucket lifecycle: %w", err)
+	}
+
+	s.logger.Info("bucket lifecycle updated", zap.String("bucket", s.bucket), zap.Int("rules", len(rules)), zap.Int("unmanaged", len(config)-len(rules)))
+	return nil
+}
+
+// ListExpired returns the keys under prefix last modified before cutoff. Trash and
+// canary objects are never included.
+func (s *S3Service) ListExpired(ctx context.Context, prefix string, cutoff time.Time) ([]string, error) {
+	var keys []string
+	err := s.ListParallel(ctx, ParallelListOptions{Prefix: prefix}, func(files []File) error {
+		for _, file := range files {
+			if file.LastModified.Before(cutoff) {
+				keys = append(keys, file.Key)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// getLifecycle returns every rule of the bucket lifecycle configuration
+func (s *S3Service) getLifecycle(ctx context.Context) ([]types.LifecycleRule, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	result, err := s.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if isNoLifecycle(err) {
+		err = nil
+	}
+	metrics.ObserveS3(metrics.OpLifecycle, start, err)
+	if err != nil {
+		if isLifecycleUnsupported(err) {
+			return nil, ErrLifecycleUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
+		s.logger.Error("failed to get bucket lifecycle", zap.String("bucket", s.bucket), zap.Error(err))
+		return nil, fmt.Errorf("failed to get bucket lifecycle: %w", err)
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return result.Rules, nil
+}
+
+// managedRule converts rule if it is a plain prefix expiration like the ones the app
+// writes, and reports whether it was
+func managedRule(rule types.LifecycleRule) (LifecycleRule, bool) {
+	if rule.Status != types.ExpirationStatusEnabled || rule.Expiration == nil || aws.ToInt32(rule.Expiration.Days) <= 0 {
+		return LifecycleRule{}, false
+	}
+	if len(rule.Transitions) > 0 || len(rule.NoncurrentVersionTransitions) > 0 || rule.NoncurrentVersionExpiration != nil || rule.AbortIncompleteMultipartUpload != nil {
+		return LifecycleRule{}, false
+	}
+
+	// Older configurations put the prefix on the rule itself instead of in a filter
+	prefix := aws.ToString(rule.Prefix)
+	if filter := rule.Filter; filter != nil {
+		if filter.And != nil || filter.Tag != nil || filter.ObjectSizeGreaterThan != nil || filter.ObjectSizeLessThan != nil {
+			return LifecycleRule{}, false
+		}
+		if filter.Prefix != nil {
+			prefix = aws.ToString(filter.Prefix)
+		}
+	}
+	if prefix == "" {
+		return LifecycleRule{}, false
+	}
+
+	return LifecycleRule{
+		ID:             aws.ToString(rule.ID),
+		Prefix:         prefix,
+		ExpirationDays: aws.ToInt32(rule.Expiration.Days),
+	}, true
+}
+
+// isNoLifecycle reports whether an SDK error only means the bucket has no lifecycle configuration yet
+func isNoLifecycle(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration"
+}
+
+// isLifecycleUnsupported reports whether an SDK error means the backend doesn't implement
+// bucket lifecycle, as some SeaweedFS and MinIO gateway setups do
+func isLifecycleUnsupported(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotImplemented", "NotSupported", "MethodNotAllowed":
+			return true
+		}
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotImplemented
+}
\ No newline at end of file
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"s3-test-app/internal/metrics"
+)
+
+// ErrBusy is returned when no operation slot frees up within the configured wait
+var ErrBusy = errors.New("storage is busy, try again later")
+
+// opLimiter bounds how many object transfers run against the backend at once.
+// A nil limiter only tracks the in-flight counts.
+type opLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newOpLimiter returns a limiter allowing max concurrent operations, or nil when max is 0
+func newOpLimiter(max int, wait time.Duration) *opLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &opLimiter{
+		slots: make(chan struct{}, max),
+		wait:  wait,
+	}
+}
+
+// acquire takes a slot for operation, waiting up to the limiter's wait for one to free up.
+// The returned release gives the slot back; it may be called more than once.
+func (l *opLimiter) acquire(ctx context.Context, operation string) (func(), error) {
+	if l != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			timer := time.NewTimer(l.wait)
+			defer timer.Stop()
+			select {
+			case l.slots <- struct{}{}:
+			case <-timer.C:
+				metrics.S3Rejected.WithLabelValues(operation).Inc()
+				return nil, ErrBusy
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	inFlight := metrics.S3InFlight.WithLabelValues(operation)
+	inFlight.Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			inFlight.Dec()
+			if l != nil {
+				<-l.slots
+			}
+		})
+	}, nil
+}
\ No newline at end of file
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"s3-test-app/internal/fakes3"
+	"s3-test-app/internal/metrics"
+)
+
+// acquireAsync starts acquiring a slot and reports the result on the returned channel
+func acquireAsync(l *opLimiter, ctx context.Context) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		release, err := l.acquire(ctx, metrics.OpDownload)
+		if err == nil {
+			defer release()
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestLimiterBlocksBeyondCap(t *testing.T) {
+	const max = 3
+	l := newOpLimiter(max, 5*time.Second)
+
+	releases := make([]func(), 0, max)
+	for range max {
+		release, err := l.acquire(context.Background(), metrics.OpDownload)
+		if err != nil {
+			t.Fatalf("acquire within the cap: %v", err)
+		}
+		releases = append(releases, release)
+	}
+
+	done := acquireAsync(l, context.Background())
+	select {
+	case err := <-done:
+		t.Fatalf("acquire beyond the cap returned %v without waiting", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releases[0]()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("acquire after a release: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire still blocked after a slot was released")
+	}
+}
+
+func TestLimiterFailsBusyAfterWait(t *testing.T) {
+	l := newOpLimiter(1, 20*time.Millisecond)
+	release, err := l.acquire(context.Background(), metrics.OpUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	rejected := metrics.S3Rejected.WithLabelValues(metrics.OpUpload)
+	before := testutil.ToFloat64(rejected)
+	start := time.Now()
+	if _, err := l.acquire(context.Background(), metrics.OpUpload); !errors.Is(err, ErrBusy) {
+		t.Fatalf("acquire = %v, want ErrBusy", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("gave up after %s, before the configured wait", waited)
+	}
+	if got := testutil.ToFloat64(rejected) - before; got != 1 {
+		t.Errorf("rejections counted = %v, want 1", got)
+	}
+}
+
+func TestLimiterStopsWaitingOnCancel(t *testing.T) {
+	l := newOpLimiter(1, time.Minute)
+	release, err := l.acquire(context.Background(), metrics.OpDownload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := acquireAsync(l, ctx)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("acquire = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquire kept waiting after its context was cancelled")
+	}
+}
+
+func TestLimiterReleaseIsIdempotent(t *testing.T) {
+	l := newOpLimiter(2, 10*time.Millisecond)
+	first, _ := l.acquire(context.Background(), metrics.OpDownload)
+	second, _ := l.acquire(context.Background(), metrics.OpDownload)
+	defer second()
+
+	first()
+	first()
+	if _, err := l.acquire(context.Background(), metrics.OpDownload); err != nil {
+		t.Fatalf("acquire after a release: %v", err)
+	}
+	// A repeated release must not have freed the slot still held by second
+	if _, err := l.acquire(context.Background(), metrics.OpDownload); !errors.Is(err, ErrBusy) {
+		t.Errorf("acquire = %v, want ErrBusy with both slots held", err)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := newOpLimiter(0, 0)
+	if l != nil {
+		t.Fatal("a cap of 0 should disable the limiter")
+	}
+	for range 100 {
+		if _, err := l.acquire(context.Background(), metrics.OpDownload); err != nil {
+			t.Fatalf("disabled limiter refused: %v", err)
+		}
+	}
+}
+
+func TestStreamHoldsSlotUntilClosed(t *testing.T) {
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+	fake.Put(testBucket, "users/a/notes.txt", []byte("notes"))
+	cfg := testConfig(fake)
+	cfg.MaxConcurrentOps = 1
+	cfg.ConcurrencyWait = 20 * time.Millisecond
+	s3Svc, err := NewS3Service(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	stream, err := s3Svc.StreamFile(ctx, "users/a/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s3Svc.UploadFile(ctx, "users/a/other.txt", strings.NewReader("other"), UploadOptions{}); !errors.Is(err, ErrBusy) {
+		t.Fatalf("upload while a stream is open = %v, want ErrBusy", err)
+	}
+
+	stream.Body.Close()
+	if err := s3Svc.UploadFile(ctx, "users/a/other.txt", strings.NewReader("other"), UploadOptions{}); err != nil {
+		t.Errorf("upload after the stream closed: %v", err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+	"s3-test-app/internal/metrics"
+)
+
+// ParallelListOptions controls ListParallel
+type ParallelListOptions struct {
+	// Prefix restricts the listing to keys starting with it
+	Prefix string
+	// IncludeHidden also lists trash and canary objects
+	IncludeHidden bool
+}
+
+// ListParallel lists every object under opts.Prefix, splitting the work over the folders
+// directly below it. Folders are discovered with a "/" delimiter; when there are fewer of
+// them than workers, the next level down is used instead, since most keys live under
+// users/. Up to the configured number of workers then list one folder each. Without any
+// folders, the discovery listing already covered everything and nothing runs in parallel.
+//
+// fn receives the objects a page at a time. Calls are never concurrent, so fn needs no
+// locking, but pages of different folders interleave and the keys arrive unsorted. The
+// listing stops at the first error from S3 or fn, and when ctx is cancelled.
+func (s *S3Service) ListParallel(ctx context.Context, opts ParallelListOptions, fn func(files []File) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	emit := func(objects []types.Object) error {
+		files := make([]File, 0, len(objects))
+		for _, obj := range objects {
+			key := aws.ToString(obj.Key)
+			if !opts.IncludeHidden && isHiddenKey(key) {
+				continue
+			}
+			files = append(files, File{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		return fn(files)
+	}
+
+	prefixes, err := s.listLevel(ctx, opts.Prefix, emit)
+	if err != nil {
+		return err
+	}
+	if len(prefixes) > 0 && len(prefixes) < s.listWorkers {
+		var expanded []string
+		for _, prefix := range prefixes {
+			sub, err := s.listLevel(ctx, prefix, emit)
+			if err != nil {
+				return err
+			}
+			expanded = append(expanded, sub...)
+		}
+		prefixes = expanded
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+
+	jobs := make(chan string)
+	pages := make(chan []types.Object)
+	// Each worker reports at most one error before it stops
+	errs := make(chan error, s.listWorkers)
+
+	var wg sync.WaitGroup
+	for range min(s.listWorkers, len(prefixes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for prefix := range jobs {
+				if err := s.listPrefix(ctx, prefix, pages); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, prefix := range prefixes {
+			select {
+			case jobs <- prefix:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+
+	// Pages keep being drained after a failure so no worker is left blocked on a send
+	var fnErr error
+	for page := range pages {
+		if fnErr != nil {
+			continue
+		}
+		if err := emit(page); err != nil {
+			fnErr = err
+			cancel()
+		}
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	// Cancelled before any worker took a folder, so none of them noticed
+	return ctx.Err()
+}
+
+// listLevel lists the objects directly under prefix, passing them to emit, and returns
+// the folders below it
+func (s *S3Service) listLevel(ctx context.Context, prefix string, emit func([]types.Object) error) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+
+	var prefixes []string
+	for paginator.HasMorePages() {
+		page, err := s.nextListPage(ctx, paginator, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, cp := range page.CommonPrefixes {
+			prefixes = append(prefixes, aws.ToString(cp.Prefix))
+		}
+		// Some stores return folder markers like "users/" as objects as well as folders;
+		// those are listed again with their folder, so only keys without a "/" stay here
+		objects := make([]types.Object, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			if !strings.Contains(strings.TrimPrefix(aws.ToString(obj.Key), prefix), "/") {
+				objects = append(objects, obj)
+			}
+		}
+		if err := emit(objects); err != nil {
+			return nil, err
+		}
+	}
+	return prefixes, nil
+}
+
+// listPrefix sends every page of objects under prefix to pages
+func (s *S3Service) listPrefix(ctx context.Context, prefix string, pages chan<- []types.Object) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := s.nextListPage(ctx, paginator, prefix)
+		if err != nil {
+			return err
+		}
+		select {
+		case pages <- page.Contents:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// nextListPage fetches the next listing page within the operation timeout
+func (s *S3Service) nextListPage(ctx context.Context, paginator *s3.ListObjectsV2Paginator, prefix string) (*s3.ListObjectsV2Output, error) {
+	pageCtx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	page, err := paginator.NextPage(pageCtx)
+	metrics.ObserveS3(metrics.OpList, start, err)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
+		s.logger.Error("failed to list objects", zap.String("prefix", prefix), zap.Error(err))
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return page, nil
+}
\ No newline at end of file
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/fakes3"
+)
+
+// newListingService creates a service over a fake bucket holding keys, listed a few keys a page
+func newListingService(t *testing.T, keys []string) (*S3Service, *fakes3.Server) {
+	t.Helper()
+	fake := fakes3.New(t)
+	fake.CreateBucket(testBucket)
+	fake.SetPageSize(7)
+	for _, key := range keys {
+		fake.Put(testBucket, key, []byte("x"))
+	}
+	cfg := testConfig(fake)
+	// More workers than top-level folders, so users/ is split a level down
+	cfg.ListConcurrency = 4
+	s3Svc, err := NewS3Service(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3Svc, fake
+}
+
+// listingKeys returns visible keys spread over user folders and the root, plus hidden ones
+func listingKeys() (visible, hidden []string) {
+	visible = []string{"readme.txt", "users/"}
+	for user := range 5 {
+		for file := range 9 {
+			visible = append(visible, fmt.Sprintf("users/user-%d/file-%d.txt", user, file))
+		}
+	}
+	visible = append(visible, "shared/a/b/c.txt", "shared/top.txt")
+	hidden = []string{TrashPrefix + "1712345/users/user-0/old.txt", CanaryPrefix + "1-1.bin"}
+	return visible, hidden
+}
+
+func TestListParallelListsEveryKeyOnce(t *testing.T) {
+	visible, hidden := listingKeys()
+	s3Svc, _ := newListingService(t, append(slices.Clone(visible), hidden...))
+
+	for _, tc := range []struct {
+		name string
+		opts ParallelListOptions
+		want []string
+	}{
+		{"visible", ParallelListOptions{}, visible},
+		{"with hidden", ParallelListOptions{IncludeHidden: true}, append(slices.Clone(visible), hidden...)},
+		{"prefix", ParallelListOptions{Prefix: "users/user-3/"}, visible[2+3*9 : 2+4*9]},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			err := s3Svc.ListParallel(context.Background(), tc.opts, func(files []File) error {
+				for _, file := range files {
+					got = append(got, file.Key)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ListParallel: %v", err)
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tc.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("listed %d keys %v\nwant %d keys %v", len(got), got, len(want), want)
+			}
+		})
+	}
+}
+
+func TestListParallelStopsOnCallbackError(t *testing.T) {
+	visible, _ := listingKeys()
+	s3Svc, _ := newListingService(t, visible)
+	errStop := errors.New("stop")
+
+	var calls int
+	err := s3Svc.ListParallel(context.Background(), ParallelListOptions{}, func(files []File) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("ListParallel = %v, want the callback's error", err)
+	}
+	if calls != 1 {
+		t.Errorf("callback called %d times after failing, want 1", calls)
+	}
+}
+
+func TestListParallelStopsOnStorageError(t *testing.T) {
+	visible, _ := listingKeys()
+	s3Svc, fake := newListingService(t, visible)
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("prefix") != "users/user-2/" {
+			return false
+		}
+		fakes3.WriteError(w, r, http.StatusForbidden, "AccessDenied")
+		return true
+	})
+
+	err := s3Svc.ListParallel(context.Background(), ParallelListOptions{}, func(files []File) error { return nil })
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("ListParallel = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestListParallelCancellation(t *testing.T) {
+	visible, _ := listingKeys()
+	s3Svc, fake := newListingService(t, visible)
+
+	// Folder listings hang until the client gives up on them
+	var hung atomic.Int32
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Has("delimiter") {
+			return false
+		}
+		hung.Add(1)
+		<-r.Context().Done()
+		return true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s3Svc.ListParallel(ctx, ParallelListOptions{}, func(files []File) error { return nil })
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hung.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ListParallel = %v, want context.Canceled", err)
+		}
+		if waited := time.Since(start); waited > time.Second {
+			t.Errorf("returned %s after cancellation", waited)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListParallel kept running after its context was cancelled")
+	}
+}
+
+func TestListParallelCancelledFromCallback(t *testing.T) {
+	visible, _ := listingKeys()
+	s3Svc, _ := newListingService(t, visible)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls int
+	err := s3Svc.ListParallel(ctx, ParallelListOptions{}, func(files []File) error {
+		calls++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ListParallel = %v, want context.Canceled", err)
+	}
+	if calls > s3Svc.listWorkers+1 {
+		t.Errorf("callback called %d times after the context was cancelled", calls)
+	}
+}
\ No newline at end of file
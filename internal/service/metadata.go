@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxMetadataSize caps the combined size of user metadata keys and values, as S3 does
+const MaxMetadataSize = 2048
+
+// reservedMetadataKeys are set by the app itself and can't be supplied by clients
+var reservedMetadataKeys = map[string]bool{
+	"sha256":                true,
+	OriginalSizeMetadataKey: true,
+}
+
+// ValidateMetadata checks user metadata before it is sent as x-amz-meta-* headers.
+// Keys are lowercase letters, digits and hyphens; values are printable ASCII.
+func ValidateMetadata(metadata map[string]string) error {
+	size := 0
+	for key, value := range metadata {
+		if key == "" {
+			return fmt.Errorf("metadata keys must not be empty")
+		}
+		for _, r := range key {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("metadata key %q may only contain lowercase letters, digits and hyphens", key)
+			}
+		}
+		if reservedMetadataKeys[key] {
+			return fmt.Errorf("metadata key %q is reserved", key)
+		}
+		for _, r := range value {
+			if r < ' ' || r > '~' {
+				return fmt.Errorf("value of metadata key %q may only contain printable ASCII", key)
+			}
+		}
+		if strings.TrimSpace(value) != value {
+			return fmt.Errorf("value of metadata key %q must not start or end with whitespace", key)
+		}
+		size += len(key) + len(value)
+	}
+
+	if size > MaxMetadataSize {
+		return fmt.Errorf("metadata is %d bytes, more than the %d allowed", size, MaxMetadataSize)
+	}
+	return nil
+}
\ No newline at end of file
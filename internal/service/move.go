@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// moveWorkers is how many objects MoveFiles copies at the same time
+const moveWorkers = 8
+
+// ErrDestinationExists is returned when a move would replace an existing object
+var ErrDestinationExists = errors.New("destination key already exists")
+
+// Move is one object to move from Source to Destination
+type Move struct {
+	Source      string
+	Destination string
+}
+
+// MoveResult is the outcome of a Move; Err is nil when the object was moved
+type MoveResult struct {
+	Move
+	Err error
+}
+
+// MoveFiles moves every object in moves by copying it and deleting the source, with up
+// to moveWorkers moves in flight. Unless overwrite is set, a move whose destination
+// already exists is skipped with ErrDestinationExists. Results are in the order of
+// moves; a failed move doesn't stop the others.
+func (s *S3Service) MoveFiles(ctx context.Context, moves []Move, overwrite bool) []MoveResult {
+	results := make([]MoveResult, len(moves))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(moveWorkers, len(moves)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = MoveResult{Move: moves[i], Err: s.moveFile(ctx, moves[i], overwrite)}
+			}
+		}()
+	}
+
+	for i := range moves {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// moveFile performs a single move of MoveFiles
+func (s *S3Service) moveFile(ctx context.Context, move Move, overwrite bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !overwrite {
+		if _, err := s.StatFile(ctx, move.Destination); err == nil {
+			return ErrDestinationExists
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	return s.RenameFile(ctx, move.Source, move.Destination)
+}
\ No newline at end of file
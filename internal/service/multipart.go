@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"go.uber.org/zap"
+	"s3-test-app/internal/metrics"
+)
+
+// AbortedUpload is an incomplete multipart upload removed by the janitor
+type AbortedUpload struct {
+	Bucket    string
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// MultipartMaxAge returns how old an incomplete multipart upload may get before it is aborted
+func (s *S3Service) MultipartMaxAge() time.Duration {
+	return s.multipartMaxAge
+}
+
+// AbortStaleMultipartUploads aborts every incomplete multipart upload started more than
+// olderThan ago. An upload that can't be aborted is logged and counted in failed, and
+// the rest are still tried; err is only set when the uploads can't be listed.
+func (s *S3Service) AbortStaleMultipartUploads(ctx context.Context, olderThan time.Duration) (aborted []AbortedUpload, failed int, err error) {
+	cutoff := time.Now().Add(-olderThan)
+	paginator := s3.NewListMultipartUploadsPaginator(s.client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucket),
+	})
+
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		start := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, start, err)
+		cancel()
+		// Some S3-compatible backends answer NoSuchUpload for a bucket without any uploads
+		if isNoSuchUpload(err) {
+			return aborted, failed, nil
+		}
+		if err != nil {
+			s.logger.Error("failed to list multipart uploads", zap.String("bucket", s.bucket), zap.Error(err))
+			return aborted, failed, fmt.Errorf("failed to list multipart uploads: %w", err)
+		}
+
+		for _, upload := range page.Uploads {
+			initiated := aws.ToTime(upload.Initiated)
+			if initiated.IsZero() || initiated.After(cutoff) {
+				continue
+			}
+			key, uploadID := aws.ToString(upload.Key), aws.ToString(upload.UploadId)
+
+			abortCtx, cancel := s.withTimeout(ctx)
+			start := time.Now()
+			_, err := s.client.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(s.bucket),
+				Key:      upload.Key,
+				UploadId: upload.UploadId,
+			})
+			metrics.ObserveS3(metrics.OpDelete, start, err)
+			cancel()
+			if err != nil && !isNoSuchUpload(err) {
+				s.logger.Error("failed to abort multipart upload", zap.String("bucket", s.bucket), zap.String("key", key), zap.String("upload_id", uploadID), zap.Error(err))
+				failed++
+				continue
+			}
+
+			s.logger.Info("aborted stale multipart upload", zap.String("bucket", s.bucket), zap.String("key", key), zap.String("upload_id", uploadID), zap.Time("initiated", initiated))
+			aborted = append(aborted, AbortedUpload{
+				Bucket:    s.name,
+				Key:       key,
+				UploadID:  uploadID,
+				Initiated: initiated,
+			})
+		}
+	}
+	return aborted, failed, nil
+}
+
+// isNoSuchUpload reports whether an SDK error means the multipart upload is already gone,
+// for instance because the client completed it while the janitor was running
+func isNoSuchUpload(err error) bool {
+	var noSuchUpload *types.NoSuchUpload
+	if errors.As(err, &noSuchUpload) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchUpload"
+}
+
+// RunMultipartJanitor aborts stale multipart uploads in every configured bucket on each
+// cleanup interval until ctx is cancelled. A zero interval disables it.
+func (s *S3Service) RunMultipartJanitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range s.buckets.names {
+				aborted, failed, err := s.buckets.services[name].AbortStaleMultipartUploads(ctx, s.multipartMaxAge)
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				if len(aborted) > 0 || failed > 0 {
+					s.logger.Info("multipart cleanup finished", zap.String("bucket", name), zap.Int("aborted", len(aborted)), zap.Int("failed", failed))
+				}
+			}
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"s3-test-app/internal/config"
+	"s3-test-app/internal/fakes3"
+)
+
+// recordingQueue is a replication queue that keeps what was enqueued
+type recordingQueue struct {
+	mu     sync.Mutex
+	queued []ReplicationTask
+}
+
+func (q *recordingQueue) EnqueueReplication(key, op string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queued = append(q.queued, ReplicationTask{Key: key, Op: op})
+	return nil
+}
+
+func (q *recordingQueue) DueReplications(now time.Time, limit int) ([]ReplicationTask, error) {
+	return nil, nil
+}
+
+func (q *recordingQueue) CompleteReplication(task ReplicationTask) error {
+	return nil
+}
+
+func (q *recordingQueue) RetryReplication(task ReplicationTask, lastErr string, retryAt time.Time) error {
+	return nil
+}
+
+// tasks returns what was enqueued so far
+func (q *recordingQueue) tasks() []ReplicationTask {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]ReplicationTask(nil), q.queued...)
+}
+
+// newReplicatedService creates a service replicating to a second fake endpoint, with its
+// changes recorded in the returned queue
+func newReplicatedService(t *testing.T, configure func(*config.S3Config)) (*S3Service, *fakes3.Server, *recordingQueue) {
+	t.Helper()
+	primary := fakes3.New(t)
+	primary.CreateBucket(testBucket)
+	secondary := fakes3.New(t)
+	secondary.CreateBucket(testBucket)
+
+	cfg := testConfig(primary)
+	cfg.Secondary = config.S3SecondaryConfig{Endpoint: secondary.URL, RetryInterval: time.Minute}
+	if configure != nil {
+		configure(cfg)
+	}
+	s3Svc, err := NewS3Service(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := &recordingQueue{}
+	s3Svc.SetReplicationQueue(queue)
+	return s3Svc, primary, queue
+}
+
+func TestProbeEncryptionQueuesNoReplication(t *testing.T) {
+	s3Svc, fake, queue := newReplicatedService(t, func(cfg *config.S3Config) {
+		cfg.SSE = config.SSEAES256
+	})
+
+	if err := s3Svc.ProbeEncryption(context.Background()); err != nil {
+		t.Fatalf("ProbeEncryption: %v", err)
+	}
+	if n := fake.Requests("DeleteObject"); n != 1 {
+		t.Errorf("DeleteObject called %d times, want the probe object removed once", n)
+	}
+	if keys := fake.Keys(testBucket); len(keys) != 0 {
+		t.Errorf("keys = %v, want the probe object removed", keys)
+	}
+	if tasks := queue.tasks(); len(tasks) != 0 {
+		t.Errorf("probe queued replication: %+v", tasks)
+	}
+
+	// Deleting a file still replicates, so the queue is wired up
+	fake.Put(testBucket, "reports/q1.txt", []byte("q1"))
+	if err := s3Svc.DeleteFile(context.Background(), "reports/q1.txt"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if tasks := queue.tasks(); len(tasks) != 1 || tasks[0].Op != ReplicationDelete {
+		t.Errorf("DeleteFile queued %+v, want one delete", tasks)
+	}
+}
+
+func TestProbePostPolicyQueuesNoReplication(t *testing.T) {
+	s3Svc, fake, queue := newReplicatedService(t, nil)
+	// The fake has no POST policy support; accept the probe upload as a backend with it would
+	fake.Intercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || r.URL.Path != "/"+testBucket {
+			return false
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	})
+
+	if !s3Svc.ProbePostPolicy(context.Background()) {
+		t.Fatal("POST policy probe failed")
+	}
+	if n := fake.Requests("DeleteObject"); n != 1 {
+		t.Errorf("DeleteObject called %d times, want the probe object removed once", n)
+	}
+	if tasks := queue.tasks(); len(tasks) != 0 {
+		t.Errorf("probe queued replication: %+v", tasks)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+	"s3-test-app/internal/config"
+)
+
+// Changes recorded in the replication queue
+const (
+	ReplicationPut    = "put"
+	ReplicationDelete = "delete"
+)
+
+// replicationBatch is how many queued keys the replicator takes from the queue at a time
+const replicationBatch = 100
+
+// replicationMaxBackoff caps how long a key that keeps failing waits between attempts
+const replicationMaxBackoff = 30 * time.Minute
+
+// ReplicationTask is a key waiting to be replicated. Seq changes whenever the key is
+// queued again, so a task finished after a newer change doesn't drop that change.
+type ReplicationTask struct {
+	Key      string
+	Op       string
+	Seq      int64
+	Attempts int
+}
+
+// ReplicationQueue persists the keys whose copy on the secondary is out of date, so
+// changes are still replicated after a restart
+type ReplicationQueue interface {
+	EnqueueReplication(key, op string) error
+	DueReplications(now time.Time, limit int) ([]ReplicationTask, error)
+	CompleteReplication(task ReplicationTask) error
+	RetryReplication(task ReplicationTask, lastErr string, retryAt time.Time) error
+}
+
+// ReplicationStatus describes replication to the secondary since the process started
+type ReplicationStatus struct {
+	Enabled       bool       `json:"enabled"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	Bucket        string     `json:"bucket,omitempty"`
+	Replicated    int64      `json:"replicated"`
+	Failed        int64      `json:"failed"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorKey  string     `json:"last_error_key,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+}
+
+// replicator copies changes of the primary bucket to the secondary endpoint
+type replicator struct {
+	client        *s3.Client
+	endpoint      string
+	bucket        string
+	retryInterval time.Duration
+	// queue is set once at startup, before any change is made
+	queue ReplicationQueue
+	// wake tells RunReplicator that a change was queued
+	wake chan struct{}
+
+	mu     sync.Mutex
+	status ReplicationStatus
+}
+
+// newReplicator creates the replicator for the secondary in cfg
+func newReplicator(ctx context.Context, cfg *config.S3Config, logger *zap.Logger) (*replicator, error) {
+	secondary := cfg.Secondary
+	accessKey, secretKey := secondary.AccessKey, secondary.SecretKey
+	if accessKey == "" {
+		accessKey, secretKey = cfg.AccessKey, cfg.SecretKey
+	}
+
+	client, err := newS3Client(ctx, cfg, secondary.Endpoint, cmp.Or(secondary.Region, cfg.Region), accessKey, secretKey, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &replicator{
+		client:        client,
+		endpoint:      secondary.Endpoint,
+		bucket:        cmp.Or(secondary.Bucket, cfg.Bucket),
+		retryInterval: secondary.RetryInterval,
+		wake:          make(chan struct{}, 1),
+	}
+	r.status = ReplicationStatus{Enabled: true, Endpoint: r.endpoint, Bucket: r.bucket}
+	return r, nil
+}
+
+// SetReplicationQueue sets the queue changes to the bucket are recorded in for
+// replication. It must be called before the service is used; without replication it
+// does nothing.
+func (s *S3Service) SetReplicationQueue(queue ReplicationQueue) {
+	if s.replica != nil {
+		s.replica.queue = queue
+	}
+}
+
+// ReplicationStatus returns how replication to the secondary has fared since startup
+func (s *S3Service) ReplicationStatus() ReplicationStatus {
+	if s.replica == nil {
+		return ReplicationStatus{}
+	}
+	s.replica.mu.Lock()
+	defer s.replica.mu.Unlock()
+	return s.replica.status
+}
+
+// ReplicateUpload queues an object written without going through the service, such as
+// a presigned upload, for replication
+func (s *S3Service) ReplicateUpload(key string) {
+	s.replicate(ReplicationPut, key)
+}
+
+// replicate queues keys changed by op and wakes the replicator. The change has already
+// happened, so a key that can't be queued is only logged.
+func (s *S3Service) replicate(op string, keys ...string) {
+	r := s.replica
+	if r == nil || r.queue == nil || len(keys) == 0 {
+		return
+	}
+
+	for _, key := range keys {
+		if err := r.queue.EnqueueReplication(key, op); err != nil {
+			s.logger.Error("failed to queue replication", zap.String("key", key), zap.String("op", op), zap.Error(err))
+		}
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunReplicator copies queued changes to the secondary until ctx is cancelled: right
+// after they are queued, and on every retry interval for those whose copy failed.
+// Without replication it returns at once.
+func (s *S3Service) RunReplicator(ctx context.Context) {
+	r := s.replica
+	if r == nil || r.queue == nil {
+		return
+	}
+
+	ticker := time.NewTicker(r.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.drainReplication(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// drainReplication replicates every queued key that is due
+func (s *S3Service) drainReplication(ctx context.Context) {
+	r := s.replica
+	for ctx.Err() == nil {
+		tasks, err := r.queue.DueReplications(time.Now(), replicationBatch)
+		if err != nil {
+			s.logger.Error("failed to read replication queue", zap.Error(err))
+			return
+		}
+
+		for _, task := range tasks {
+			err := s.replicateKey(ctx, task.Key)
+			// Shutting down isn't a failure of the key; it stays queued as it is
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				retryAt := time.Now().Add(replicationBackoff(r.retryInterval, task.Attempts))
+				s.logger.Warn("replication failed", zap.String("key", task.Key), zap.String("op", task.Op), zap.Int("attempts", task.Attempts+1), zap.Time("retry_at", retryAt), zap.Error(err))
+				r.recordFailure(task.Key, err)
+				if err := r.queue.RetryReplication(task, err.Error(), retryAt); err != nil {
+					s.logger.Error("failed to reschedule replication", zap.String("key", task.Key), zap.Error(err))
+				}
+				continue
+			}
+
+			r.recordSuccess()
+			if err := r.queue.CompleteReplication(task); err != nil {
+				s.logger.Error("failed to complete replication", zap.String("key", task.Key), zap.Error(err))
+			}
+		}
+
+		// Failed keys are due later, so a full batch means more may be waiting
+		if len(tasks) < replicationBatch {
+			return
+		}
+	}
+}
+
+// replicateKey makes the secondary's copy of key match the primary: the object is
+// copied when it exists and deleted from the secondary when it doesn't, whichever
+// change queued it. A key changed again while it waited is replicated as it is now.
+func (s *S3Service) replicateKey(ctx context.Context, key string) error {
+	r := s.replica
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		if _, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(r.bucket),
+			Key:    aws.String(key),
+		}); err != nil {
+			return fmt.Errorf("failed to delete from secondary: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read from primary: %w", err)
+	}
+	defer object.Body.Close()
+
+	// The body is spooled so the upload can declare its length and be retried
+	spool, err := os.CreateTemp("", "replicate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	size, err := io.Copy(spool, object.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read from primary: %w", err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind spool file: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:          aws.String(r.bucket),
+		Key:             aws.String(key),
+		Body:            spool,
+		ContentLength:   aws.Int64(size),
+		ContentType:     object.ContentType,
+		ContentEncoding: object.ContentEncoding,
+		Metadata:        object.Metadata,
+	}
+	// Tags are copied when the primary has them; backends without tagging have none
+	if tags, err := s.GetObjectTags(ctx, key); err == nil && len(tags) > 0 {
+		input.Tagging = aws.String(encodeTags(tags))
+	}
+
+	if _, err := r.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to write to secondary: %w", err)
+	}
+	return nil
+}
+
+// replicationBackoff returns how long a key waits after its attempts-th failure,
+// doubling from interval up to replicationMaxBackoff
+func replicationBackoff(interval time.Duration, attempts int) time.Duration {
+	backoff := interval
+	for i := 0; i < attempts && backoff < replicationMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, replicationMaxBackoff)
+}
+
+// recordSuccess counts a replicated key in the status
+func (r *replicator) recordSuccess() {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Replicated++
+	r.status.LastSuccessAt = &now
+}
+
+// recordFailure counts a failed attempt in the status
+func (r *replicator) recordFailure(key string, err error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Failed++
+	r.status.LastError = err.Error()
+	r.status.LastErrorKey = key
+	r.status.LastErrorAt = &now
+}
\ No newline at end of file
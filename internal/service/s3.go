@@ -3,101 +3,431 @@ package service
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/logging"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"go.uber.org/zap"
 	"s3-test-app/internal/config"
+	"s3-test-app/internal/metrics"
 )
 
+// ErrNotFound is returned when the requested object does not exist
+var ErrNotFound = errors.New("file not found")
+
+// ErrAccessDenied is returned when the storage backend refuses access to an object
+var ErrAccessDenied = errors.New("access denied by storage")
+
+// ErrRenameIncomplete is returned when a renamed object was copied but its source could not be removed
+var ErrRenameIncomplete = errors.New("file copied but source could not be removed")
+
+// ErrChecksumMismatch is returned when S3 rejects an upload whose content does not match its checksums
+var ErrChecksumMismatch = errors.New("uploaded content does not match its checksum")
+
 // S3Service handles S3 operations
 type S3Service struct {
-	client *s3.Client
-	bucket string
-	logger *zap.Logger
+	client        *s3.Client
+	presignClient *s3.PresignClient
+	bucket        string
+	// name is how the API addresses this bucket; see config.S3Config.BucketRegistry
+	name        string
+	logger      *zap.Logger
+	endpoint    string
+	sse         string
+	sseKMSKeyID string
+
+	postPolicySupported bool
+
+	// opTimeout bounds each S3 call; 0 leaves calls bounded only by the caller's context
+	opTimeout time.Duration
+	// limiter caps concurrent uploads and downloads; it is shared by every bucket since they share one client
+	limiter *opLimiter
+	// cache holds small objects read recently, for every bucket; nil when disabled
+	cache *objectCache
+	// listWorkers is how many folders ListParallel lists at once
+	listWorkers int
+	// multipartMaxAge is how old an incomplete multipart upload may get before the janitor aborts it
+	multipartMaxAge time.Duration
+
+	usageTTL   time.Duration
+	usageMu    sync.Mutex
+	usageCache map[string]*Usage
+
+	// buckets is shared by the services of every configured bucket
+	buckets *bucketRegistry
+	// replica mirrors changes to the secondary endpoint; only the primary bucket has one,
+	// and only when replication is configured
+	replica *replicator
 }
 
 // File represents a file in S3
 type File struct {
-	Key          string
-	Size         int64
-	LastModified string
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
 }
 
 // NewS3Service creates a new S3Service
 func NewS3Service(cfg *config.S3Config, logger *zap.Logger) (*S3Service, error) {
 	ctx := context.Background()
 
+	client, err := newS3Client(ctx, cfg, cfg.Endpoint, cfg.Region, cfg.AccessKey, cfg.SecretKey, logger, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, instrumentation(logger, cfg.SlowOperationThreshold))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sse := cfg.SSE
+	if sse == config.SSENone {
+		sse = ""
+	}
+	if sse != "" {
+		logger.Info("server-side encryption enabled", zap.String("mode", cfg.SSE), zap.Bool("kms_key", cfg.SSEKMSKeyID != ""))
+	} else {
+		logger.Info("server-side encryption disabled")
+	}
+
+	registry, err := cfg.BucketRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := &bucketRegistry{services: make(map[string]*S3Service, len(registry))}
+	limiter := newOpLimiter(cfg.MaxConcurrentOps, cfg.ConcurrencyWait)
+	cache := newObjectCache(cfg.ReadCacheSize, cfg.ReadCacheMaxObject, cfg.ReadCacheTTL)
+	if cache != nil {
+		logger.Info("object read cache enabled", zap.Int64("size", cfg.ReadCacheSize), zap.Int64("max_object", cfg.ReadCacheMaxObject), zap.Duration("ttl", cfg.ReadCacheTTL))
+	}
+	for _, named := range registry {
+		buckets.names = append(buckets.names, named.Name)
+		buckets.services[named.Name] = &S3Service{
+			client:        client,
+			presignClient: s3.NewPresignClient(client),
+			bucket:        named.Bucket,
+			name:          named.Name,
+			endpoint:      cfg.Endpoint,
+			logger:        logger,
+			sse:           sse,
+			sseKMSKeyID:   cfg.SSEKMSKeyID,
+			opTimeout:     cfg.OperationTimeout,
+			limiter:       limiter,
+			cache:         cache,
+			listWorkers:   cfg.ListConcurrency,
+
+			multipartMaxAge: cfg.MultipartMaxAge,
+			usageTTL:        cfg.UsageCacheTTL,
+			usageCache:      make(map[string]*Usage),
+			buckets:         buckets,
+		}
+	}
+
+	bucketCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	for _, name := range buckets.names {
+		if err := buckets.services[name].ensureBucket(bucketCtx, cfg.Region, cfg.AutoCreateBucket); err != nil {
+			return nil, err
+		}
+	}
+
+	// Being able to reach a bucket doesn't mean the credentials may write to it
+	if cfg.VerifyPermissions {
+		for _, name := range buckets.names {
+			result := buckets.services[name].SelfTest(bucketCtx)
+			if err := result.Err(); err != nil {
+				return nil, err
+			}
+			logger.Info("s3 permissions verified", zap.String("bucket", result.Bucket))
+		}
+	}
+
+	// The registry lists the primary bucket first
+	primary := buckets.services[registry[0].Name]
+	if cfg.Secondary.Enabled() {
+		if primary.replica, err = newReplicator(ctx, cfg, logger); err != nil {
+			return nil, err
+		}
+		logger.Info("replication to secondary enabled", zap.String("endpoint", primary.replica.endpoint), zap.String("bucket", primary.replica.bucket))
+	}
+	return primary, nil
+}
+
+// newS3Client creates an S3 client for endpoint with the retry and timeout settings of
+// cfg. optFns adjust the client options further.
+func newS3Client(ctx context.Context, cfg *config.S3Config, endpoint, region, accessKey, secretKey string, logger *zap.Logger, optFns ...func(*s3.Options)) (*s3.Client, error) {
+	httpClient := awshttp.NewBuildableClient().WithDialerOptions(func(d *net.Dialer) {
+		d.Timeout = cfg.DialTimeout
+	})
+
 	sdkConfig, err := awsconfig.LoadDefaultConfig(ctx,
-		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithRegion(region),
 		awsconfig.WithCredentialsProvider(
-			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+			credentials.NewStaticCredentialsProvider(accessKey, secretKey, ""),
 		),
+		awsconfig.WithRetryMaxAttempts(cfg.MaxAttempts),
+		awsconfig.WithRetryMode(aws.RetryMode(cfg.RetryMode)),
+		awsconfig.WithHTTPClient(httpClient),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(sdkConfig, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String(cfg.Endpoint)
+	optFns = append([]func(*s3.Options){func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
 		o.UsePathStyle = true
-	})
+		// Retries are logged at debug level with their attempt number
+		o.ClientLogMode = aws.LogRetries
+		o.Logger = sdkLogger(logger)
+	}}, optFns...)
+	return s3.NewFromConfig(sdkConfig, optFns...), nil
+}
 
-	return &S3Service{
-		client: client,
-		bucket: cfg.Bucket,
-		logger: logger,
-	}, nil
+// withTimeout bounds a single S3 call by the configured operation timeout
+func (s *S3Service) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.opTimeout)
 }
 
-// UploadFile uploads a file to S3
-func (s *S3Service) UploadFile(ctx context.Context, key string, data []byte) error {
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-		Body:   bytes.NewReader(data),
-	})
+// encryption returns the server-side encryption settings for new objects,
+// or empty values when encryption is not configured
+func (s *S3Service) encryption() (types.ServerSideEncryption, *string) {
+	if s.sse == "" {
+		return "", nil
+	}
+	var keyID *string
+	if s.sse == config.SSEKMS && s.sseKMSKeyID != "" {
+		keyID = aws.String(s.sseKMSKeyID)
+	}
+	return types.ServerSideEncryption(s.sse), keyID
+}
+
+// DefaultStorageClass is used for uploads that don't choose a storage class
+const DefaultStorageClass = string(types.StorageClassStandard)
+
+// ValidStorageClass reports whether class is a storage class S3 knows
+func ValidStorageClass(class string) bool {
+	for _, known := range types.StorageClassStandard.Values() {
+		if class == string(known) {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadOptions describes how UploadFile stores an object
+type UploadOptions struct {
+	// ContentType is stored with the object when set
+	ContentType string
+	// ContentEncoding is stored with the object when the body is compressed, and sent
+	// back by S3 on every read
+	ContentEncoding string
+	// StorageClass picks the S3 storage class; empty leaves the choice to the bucket
+	StorageClass string
+	// Metadata is stored as user-defined object metadata
+	Metadata map[string]string
+	// Tags are attached to the object; they must pass ValidateTags
+	Tags map[string]string
+	// ContentMD5 and ChecksumSHA256 are base64 digests of the body. When set, S3 rejects
+	// a body that arrives different from what was hashed instead of storing it.
+	ContentMD5     string
+	ChecksumSHA256 string
+}
+
+// UploadFile streams body to S3 under key. A seekable body lets the SDK sign it without buffering.
+func (s *S3Service) UploadFile(ctx context.Context, key string, body io.Reader, opts UploadOptions) error {
+	release, err := s.limiter.acquire(ctx, metrics.OpUpload)
+	if err != nil {
+		s.logger.Warn("upload not started, no storage slot", zap.String("key", key), zap.Error(err))
+		return err
+	}
+	defer release()
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	input := &s3.PutObjectInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Body:     body,
+		Metadata: opts.Metadata,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+	if opts.StorageClass != "" {
+		input.StorageClass = types.StorageClass(opts.StorageClass)
+	}
+	if len(opts.Tags) > 0 {
+		input.Tagging = aws.String(encodeTags(opts.Tags))
+	}
+	if opts.ContentMD5 != "" {
+		input.ContentMD5 = aws.String(opts.ContentMD5)
+	}
+	if opts.ChecksumSHA256 != "" {
+		input.ChecksumSHA256 = aws.String(opts.ChecksumSHA256)
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+
+	start := time.Now()
+	_, err = s.client.PutObject(ctx, input)
+	metrics.ObserveS3(metrics.OpUpload, start, err)
+	s.cache.invalidate(cacheID(s.bucket, key))
 	if err != nil {
+		if isChecksumMismatch(err) {
+			s.logger.Warn("upload rejected by checksum", zap.String("key", key), zap.Error(err))
+			return ErrChecksumMismatch
+		}
+		if isAccessDenied(err) {
+			s.logger.Warn("upload denied by storage", zap.String("key", key), zap.Error(err))
+			return ErrAccessDenied
+		}
 		s.logger.Error("failed to upload file", zap.String("key", key), zap.Error(err))
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
 	s.logger.Info("file uploaded", zap.String("key", key))
+	s.replicate(ReplicationPut, key)
 	return nil
 }
 
-// ListFiles lists all files in the bucket
-func (s *S3Service) ListFiles(ctx context.Context) ([]File, error) {
-	result, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+// ListOptions controls how ListFiles walks the bucket
+type ListOptions struct {
+	// Prefix restricts the listing to keys starting with it
+	Prefix string
+	// Delimiter groups keys sharing a prefix up to the delimiter into folders
+	Delimiter string
+}
+
+// ListResult holds the objects and folders returned by ListFiles
+type ListResult struct {
+	Files   []File
+	Folders []string
+}
+
+// ListFiles lists files in the bucket according to opts, following pagination until
+// every matching key has been read
+func (s *S3Service) ListFiles(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
-	})
-	if err != nil {
-		s.logger.Error("failed to list files", zap.Error(err))
-		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	if opts.Prefix != "" {
+		input.Prefix = aws.String(opts.Prefix)
+	}
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
 	}
 
-	files := make([]File, 0)
-	for _, obj := range result.Contents {
-		files = append(files, File{
-			Key:          *obj.Key,
-			Size:         *obj.Size,
-			LastModified: obj.LastModified.Format("2006-01-02 15:04:05"),
-		})
+	listing := &ListResult{
+		Files:   make([]File, 0),
+		Folders: make([]string, 0),
 	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		start := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, start, err)
+		cancel()
+		if err != nil {
+			if isAccessDenied(err) {
+				s.logger.Warn("list denied by storage", zap.String("prefix", opts.Prefix), zap.Error(err))
+				return nil, ErrAccessDenied
+			}
+			s.logger.Error("failed to list files", zap.Error(err))
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			if isHiddenKey(aws.ToString(cp.Prefix)) {
+				continue
+			}
+			listing.Folders = append(listing.Folders, aws.ToString(cp.Prefix))
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if isHiddenKey(key) {
+				continue
+			}
 
-	return files, nil
+			// Zero-byte keys ending in the delimiter are folder markers, not files
+			if opts.Delimiter != "" && strings.HasSuffix(key, opts.Delimiter) && aws.ToInt64(obj.Size) == 0 {
+				if key != opts.Prefix {
+					listing.Folders = append(listing.Folders, key)
+				}
+				continue
+			}
+
+			listing.Files = append(listing.Files, File{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+
+	return listing, nil
+}
+
+// Object is a downloaded file together with its stored content type
+type Object struct {
+	Data        []byte
+	ContentType string
 }
 
 // GetFile downloads a file from S3
-func (s *S3Service) GetFile(ctx context.Context, key string) ([]byte, error) {
+func (s *S3Service) GetFile(ctx context.Context, key string) (*Object, error) {
+	id := cacheID(s.bucket, key)
+	entry, fresh := s.cache.lookup(id)
+	s.cache.countLookup(metrics.OpDownload, fresh)
+	if fresh {
+		return &Object{
+			Data:        bytes.Clone(entry.data),
+			ContentType: entry.info.ContentType,
+		}, nil
+	}
+	generation := s.cache.begin()
+
+	release, err := s.limiter.acquire(ctx, metrics.OpDownload)
+	if err != nil {
+		s.logger.Warn("download not started, no storage slot", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
+	metrics.ObserveS3(metrics.OpDownload, start, err)
 	if err != nil {
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
 		s.logger.Error("failed to get file", zap.String("key", key), zap.Error(err))
 		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
@@ -108,20 +438,885 @@ func (s *S3Service) GetFile(ctx context.Context, key string) ([]byte, error) {
 		s.logger.Error("failed to read file", zap.String("key", key), zap.Error(err))
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	s.cache.put(id, fileInfoFromGet(key, result), bytes.Clone(data), generation)
 
-	return data, nil
+	return &Object{
+		Data:        data,
+		ContentType: aws.ToString(result.ContentType),
+	}, nil
 }
 
 // DeleteFile deletes a file from S3
 func (s *S3Service) DeleteFile(ctx context.Context, key string) error {
+	return s.DeleteFileVersion(ctx, key, "")
+}
+
+// DeleteFileVersion permanently deletes one version of an object. An empty versionID
+// deletes the latest, which in a versioned bucket only adds a delete marker.
+func (s *S3Service) DeleteFileVersion(ctx context.Context, key, versionID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: optionalString(versionID),
 	})
+	metrics.ObserveS3(metrics.OpDelete, start, err)
+	s.cache.invalidate(cacheID(s.bucket, key))
 	if err != nil {
+		if versionID != "" && isNotImplemented(err) {
+			return ErrVersioningUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return typed
+		}
 		s.logger.Error("failed to delete file", zap.String("key", key), zap.Error(err))
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
-	s.logger.Info("file deleted", zap.String("key", key))
+	s.logger.Info("file deleted", zap.String("key", key), zap.String("version_id", versionID))
+	// Removing an older version may leave a different one current
+	if versionID == "" {
+		s.replicate(ReplicationDelete, key)
+	} else {
+		s.replicate(ReplicationPut, key)
+	}
+	return nil
+}
+
+// ObjectStream is an open object body together with its metadata.
+// The caller must close Body.
+type ObjectStream struct {
+	Body         io.ReadCloser
+	Size         int64
+	ContentType  string
+	ContentRange string
+	LastModified time.Time
+	// ContentEncoding is set when Body is compressed; see Decoded
+	ContentEncoding string
+}
+
+// StreamFile opens an object for reading without buffering it in memory
+func (s *S3Service) StreamFile(ctx context.Context, key string) (*ObjectStream, error) {
+	return s.StreamFileRange(ctx, key, "")
+}
+
+// StreamFileRange opens part of an object for reading. rangeHeader is an HTTP
+// Range value such as "bytes=0-1023"; when empty the whole object is returned.
+func (s *S3Service) StreamFileRange(ctx context.Context, key, rangeHeader string) (*ObjectStream, error) {
+	return s.StreamFileVersion(ctx, key, "", rangeHeader)
+}
+
+// StreamFileVersion is StreamFileRange for a given version of the object; an empty
+// versionID reads the latest one
+func (s *S3Service) StreamFileVersion(ctx context.Context, key, versionID, rangeHeader string) (*ObjectStream, error) {
+	input := &s3.GetObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: optionalString(versionID),
+	}
+	if rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+
+	// Only the latest version is cached
+	id := cacheID(s.bucket, key)
+	if versionID == "" && s.cache != nil {
+		if stream, ok := s.streamCached(id, rangeHeader); ok {
+			return stream, nil
+		}
+	}
+	generation := s.cache.begin()
+
+	// The slot is held until the body is closed, since the open stream keeps a connection busy
+	release, err := s.limiter.acquire(ctx, metrics.OpDownload)
+	if err != nil {
+		s.logger.Warn("download not started, no storage slot", zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+
+	// The body outlives this call, so the operation timeout only bounds getting the
+	// response; the stream stays open until the caller closes it
+	ctx, cancel := context.WithCancel(ctx)
+	var timer *time.Timer
+	if s.opTimeout > 0 {
+		timer = time.AfterFunc(s.opTimeout, cancel)
+	}
+	start := time.Now()
+	result, err := s.client.GetObject(ctx, input)
+	metrics.ObserveS3(metrics.OpDownload, start, err)
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		cancel()
+		release()
+		if versionID != "" && isNotImplemented(err) {
+			return nil, ErrVersioningUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
+		s.logger.Error("failed to get file", zap.String("key", key), zap.String("range", rangeHeader), zap.Error(err))
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
+
+	// Small whole objects are read in full so the next request can be served from memory
+	if versionID == "" && rangeHeader == "" && s.cache.fits(aws.ToInt64(result.ContentLength)) {
+		data, err := io.ReadAll(result.Body)
+		result.Body.Close()
+		cancel()
+		release()
+		if err != nil {
+			s.logger.Error("failed to read file", zap.String("key", key), zap.Error(err))
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		info := fileInfoFromGet(key, result)
+		s.cache.put(id, info, data, generation)
+		return &ObjectStream{
+			Body:            io.NopCloser(bytes.NewReader(data)),
+			Size:            int64(len(data)),
+			ContentType:     info.ContentType,
+			LastModified:    info.LastModified,
+			ContentEncoding: info.ContentEncoding,
+		}, nil
+	}
+
+	return &ObjectStream{
+		Body:            &cancelOnClose{ReadCloser: result.Body, cancel: cancel, release: release},
+		Size:            aws.ToInt64(result.ContentLength),
+		ContentType:     aws.ToString(result.ContentType),
+		ContentRange:    aws.ToString(result.ContentRange),
+		LastModified:    aws.ToTime(result.LastModified),
+		ContentEncoding: aws.ToString(result.ContentEncoding),
+	}, nil
+}
+
+// streamCached serves a read of the latest version of an object from the cache, if it
+// holds a fresh copy and the range is one it can cut out itself
+func (s *S3Service) streamCached(id, rangeHeader string) (*ObjectStream, bool) {
+	entry, fresh := s.cache.lookup(id)
+	if !fresh {
+		s.cache.countLookup(metrics.OpDownload, false)
+		return nil, false
+	}
+
+	data, contentRange := entry.data, ""
+	if rangeHeader != "" {
+		var ok bool
+		if data, contentRange, ok = cachedRange(entry.data, rangeHeader); !ok {
+			s.cache.countLookup(metrics.OpDownload, false)
+			return nil, false
+		}
+	}
+	s.cache.countLookup(metrics.OpDownload, true)
+
+	return &ObjectStream{
+		Body:            io.NopCloser(bytes.NewReader(data)),
+		Size:            int64(len(data)),
+		ContentType:     entry.info.ContentType,
+		ContentRange:    contentRange,
+		LastModified:    entry.info.LastModified,
+		ContentEncoding: entry.info.ContentEncoding,
+	}, true
+}
+
+// cancelOnClose releases a stream's context and operation slot once the caller is done with the body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	release func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	c.release()
+	return err
+}
+
+// ScanFiles walks every visible object under prefix page by page, calling fn with each
+// file until fn returns false
+func (s *S3Service) ScanFiles(ctx context.Context, prefix string, fn func(file File) bool) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		pageStart := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, pageStart, err)
+		cancel()
+		if err != nil {
+			if typed := classifyError(err); typed != nil {
+				return typed
+			}
+			s.logger.Error("failed to scan files", zap.String("prefix", prefix), zap.Error(err))
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if isHiddenKey(key) {
+				continue
+			}
+			file := File{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			}
+			if !fn(file) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// ListKeys returns every key under prefix, following pagination, up to limit keys.
+// It fails if more than limit keys exist.
+func (s *S3Service) ListKeys(ctx context.Context, prefix string, limit int) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	keys := make([]string, 0)
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		pageStart := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, pageStart, err)
+		cancel()
+		if err != nil {
+			s.logger.Error("failed to list keys", zap.String("prefix", prefix), zap.Error(err))
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, obj := range page.Contents {
+			if isHiddenKey(aws.ToString(obj.Key)) {
+				continue
+			}
+			if len(keys) == limit {
+				return nil, fmt.Errorf("more than %d files under prefix", limit)
+			}
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+
+	return keys, nil
+}
+
+// WalkFiles lists the objects under prefix in key order, starting after the key
+// startAfter when it is set, and hands them to fn a page at a time. Hidden keys are
+// left out. The walk stops at the first error from S3 or fn.
+func (s *S3Service) WalkFiles(ctx context.Context, prefix, startAfter string, fn func(files []File) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		pageStart := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, pageStart, err)
+		cancel()
+		if err != nil {
+			s.logger.Error("failed to walk files", zap.String("prefix", prefix), zap.String("start_after", startAfter), zap.Error(err))
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+
+		files := make([]File, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if isHiddenKey(key) {
+				continue
+			}
+			files = append(files, File{
+				Key:          key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if err := fn(files); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deletePrefixLogEvery is how often DeletePrefix reports progress, in objects
+const deletePrefixLogEvery = 1000
+
+// DeletePrefix deletes every object whose key starts with prefix and returns how many were removed.
+// An empty prefix deletes the whole bucket.
+func (s *S3Service) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	deleted := 0
+	nextLog := deletePrefixLogEvery
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		pageStart := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, pageStart, err)
+		cancel()
+		if err != nil {
+			s.logger.Error("failed to list objects for prefix delete", zap.String("prefix", prefix), zap.Error(err))
+			return deleted, fmt.Errorf("failed to list files: %w", err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+
+		// A listing page holds at most 1000 keys, which is also the DeleteObjects limit
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+		}
+
+		deleteCtx, cancel := s.withTimeout(ctx)
+		deleteStart := time.Now()
+		result, err := s.client.DeleteObjects(deleteCtx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		metrics.ObserveS3(metrics.OpDelete, deleteStart, err)
+		cancel()
+		s.cache.invalidatePrefix(cacheID(s.bucket, prefix))
+		if err != nil {
+			s.logger.Error("failed to delete objects", zap.String("prefix", prefix), zap.Error(err))
+			return deleted, fmt.Errorf("failed to delete files: %w", err)
+		}
+
+		s.replicate(ReplicationDelete, deletedKeys(objects, result.Errors)...)
+		deleted += len(objects) - len(result.Errors)
+		if len(result.Errors) > 0 {
+			first := result.Errors[0]
+			s.logger.Error("some objects could not be deleted",
+				zap.String("prefix", prefix),
+				zap.Int("failed", len(result.Errors)),
+				zap.String("key", aws.ToString(first.Key)),
+				zap.String("error", aws.ToString(first.Message)),
+			)
+			return deleted, fmt.Errorf("failed to delete %d files under prefix", len(result.Errors))
+		}
+
+		if deleted >= nextLog {
+			s.logger.Info("prefix delete progress", zap.String("prefix", prefix), zap.Int("deleted", deleted))
+			nextLog = deleted + deletePrefixLogEvery
+		}
+	}
+
+	s.logger.Info("prefix deleted", zap.String("prefix", prefix), zap.Int("deleted", deleted))
+	return deleted, nil
+}
+
+// deleteObjectsBatch is the most keys a single DeleteObjects call accepts
+const deleteObjectsBatch = 1000
+
+// DeleteFiles deletes the given keys in batches and returns the keys that were deleted.
+// Keys S3 reports as failed are left out; a request-level error stops at the failing batch.
+func (s *S3Service) DeleteFiles(ctx context.Context, keys []string) ([]string, error) {
+	deleted := make([]string, 0, len(keys))
+	for start := 0; start < len(keys); start += deleteObjectsBatch {
+		batch := keys[start:min(start+deleteObjectsBatch, len(keys))]
+
+		objects := make([]types.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		deleteCtx, cancel := s.withTimeout(ctx)
+		deleteStart := time.Now()
+		result, err := s.client.DeleteObjects(deleteCtx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		metrics.ObserveS3(metrics.OpDelete, deleteStart, err)
+		cancel()
+		ids := make([]string, len(batch))
+		for i, key := range batch {
+			ids[i] = cacheID(s.bucket, key)
+		}
+		s.cache.invalidate(ids...)
+		if err != nil {
+			s.logger.Error("failed to delete objects", zap.Int("keys", len(batch)), zap.Error(err))
+			return deleted, fmt.Errorf("failed to delete files: %w", err)
+		}
+
+		for _, e := range result.Errors {
+			s.logger.Error("object could not be deleted", zap.String("key", aws.ToString(e.Key)), zap.String("error", aws.ToString(e.Message)))
+		}
+		batchDeleted := deletedKeys(objects, result.Errors)
+		s.replicate(ReplicationDelete, batchDeleted...)
+		deleted = append(deleted, batchDeleted...)
+	}
+
+	s.logger.Info("files deleted", zap.Int("requested", len(keys)), zap.Int("deleted", len(deleted)))
+	return deleted, nil
+}
+
+// CopyFile copies an object to a new key within the bucket
+func (s *S3Service) CopyFile(ctx context.Context, srcKey, dstKey string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource(s.bucket, srcKey)),
+	}
+	// Copies don't inherit the source's encryption, so request it again
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+
+	_, err := s.client.CopyObject(ctx, input)
+	s.cache.invalidate(cacheID(s.bucket, dstKey))
+	if err != nil {
+		if typed := classifyError(err); typed != nil {
+			return typed
+		}
+		s.logger.Error("failed to copy file", zap.String("src", srcKey), zap.String("dst", dstKey), zap.Error(err))
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	s.logger.Info("file copied", zap.String("src", srcKey), zap.String("dst", dstKey))
+	s.replicate(ReplicationPut, dstKey)
+	return nil
+}
+
+// deletedKeys returns the keys of objects that a DeleteObjects call didn't report as failed
+func deletedKeys(objects []types.ObjectIdentifier, errs []types.Error) []string {
+	failed := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		failed[aws.ToString(e.Key)] = true
+	}
+
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if key := aws.ToString(obj.Key); !failed[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RenameFile moves an object to a new key by copying it and deleting the original
+func (s *S3Service) RenameFile(ctx context.Context, srcKey, dstKey string) error {
+	if err := s.CopyFile(ctx, srcKey, dstKey); err != nil {
+		return err
+	}
+
+	if err := s.DeleteFile(ctx, srcKey); err != nil {
+		// The copy exists, so the rename effectively happened; report the leftover source
+		s.logger.Error("rename left source behind", zap.String("src", srcKey), zap.String("dst", dstKey), zap.Error(err))
+		return fmt.Errorf("%w: %v", ErrRenameIncomplete, err)
+	}
+
+	return nil
+}
+
+// PostPolicyConditions describes the constraints baked into a presigned POST policy
+type PostPolicyConditions struct {
+	KeyPrefix   string
+	ContentType string
+	MinSize     int64
+	MaxSize     int64
+	Expires     time.Duration
+}
+
+// PresignedPost holds the target URL and form fields for a browser POST upload
+type PresignedPost struct {
+	URL    string            `json:"url"`
+	Fields map[string]string `json:"fields"`
+}
+
+// PresignPostPolicy generates a browser-form POST policy for uploading key directly to S3
+func (s *S3Service) PresignPostPolicy(ctx context.Context, key string, conditions PostPolicyConditions) (*PresignedPost, error) {
+	policyConditions := []interface{}{
+		[]interface{}{"content-length-range", conditions.MinSize, conditions.MaxSize},
+	}
+	if conditions.KeyPrefix != "" {
+		policyConditions = append(policyConditions, []interface{}{"starts-with", "$key", conditions.KeyPrefix})
+	}
+	if conditions.ContentType != "" {
+		policyConditions = append(policyConditions, map[string]string{"Content-Type": conditions.ContentType})
+	}
+	sse, kmsKeyID := s.encryption()
+	if sse != "" {
+		policyConditions = append(policyConditions, map[string]string{"x-amz-server-side-encryption": string(sse)})
+	}
+	if kmsKeyID != nil {
+		policyConditions = append(policyConditions, map[string]string{"x-amz-server-side-encryption-aws-kms-key-id": *kmsKeyID})
+	}
+
+	result, err := s.presignClient.PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, func(o *s3.PresignPostOptions) {
+		o.Expires = conditions.Expires
+		o.Conditions = policyConditions
+	})
+	if err != nil {
+		s.logger.Error("failed to presign post policy", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("failed to presign post policy: %w", err)
+	}
+
+	fields := result.Values
+	if conditions.ContentType != "" {
+		fields["Content-Type"] = conditions.ContentType
+	}
+	if sse != "" {
+		fields["x-amz-server-side-encryption"] = string(sse)
+	}
+	if kmsKeyID != nil {
+		fields["x-amz-server-side-encryption-aws-kms-key-id"] = *kmsKeyID
+	}
+
+	return &PresignedPost{
+		URL:    result.URL,
+		Fields: fields,
+	}, nil
+}
+
+// PresignPutURL returns a URL that lets a client PUT key directly to S3 until ttl runs out.
+// The content length is signed into the URL, so storage refuses a body of any other size.
+// Server-side encryption isn't signed into the URL, since the client would then have to
+// send matching headers; the bucket's default encryption applies instead
+func (s *S3Service) PresignPutURL(ctx context.Context, key string, size int64, ttl time.Duration) (string, error) {
+	result, err := s.presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentLength: aws.Int64(size),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		s.logger.Error("failed to presign put", zap.String("key", key), zap.Error(err))
+		return "", fmt.Errorf("failed to presign put: %w", err)
+	}
+	return result.URL, nil
+}
+
+// ProbePostPolicy checks whether the backend accepts POST policy uploads by
+// performing a tiny round-trip, and remembers the result
+func (s *S3Service) ProbePostPolicy(ctx context.Context) bool {
+	key := fmt.Sprintf("%spost-policy-%d", ProbePrefix, time.Now().UnixNano())
+	payload := []byte("probe")
+
+	post, err := s.PresignPostPolicy(ctx, key, PostPolicyConditions{
+		MinSize: 0,
+		MaxSize: int64(len(payload)),
+		Expires: time.Minute,
+	})
+	if err != nil {
+		s.postPolicySupported = false
+		return false
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range post.Fields {
+		form.WriteField(name, value)
+	}
+	part, err := form.CreateFormFile("file", "probe")
+	if err == nil {
+		part.Write(payload)
+	}
+	form.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, post.URL, &body)
+	if err != nil {
+		s.postPolicySupported = false
+		return false
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		s.logger.Warn("post policy probe failed", zap.Error(err))
+		s.postPolicySupported = false
+		return false
+	}
+	resp.Body.Close()
+
+	s.postPolicySupported = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if s.postPolicySupported {
+		if err := s.deleteProbeObject(ctx, key); err != nil {
+			s.logger.Warn("failed to remove post policy probe object", zap.String("key", key), zap.Error(err))
+		}
+	}
+
+	return s.postPolicySupported
+}
+
+// SupportsPostPolicy reports the result of the last POST policy probe
+func (s *S3Service) SupportsPostPolicy() bool {
+	return s.postPolicySupported
+}
+
+// FileInfo describes a single object without its content
+type FileInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	ETag         string            `json:"etag"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"metadata"`
+	// Encryption is the server-side encryption S3 reports for the object, or "none"
+	Encryption string `json:"encryption"`
+	KMSKeyID   string `json:"kms_key_id,omitempty"`
+	// VersionID is set when the bucket keeps versions
+	VersionID string `json:"version_id,omitempty"`
+	// ContentEncoding is "gzip" for objects stored compressed; Size is then the stored
+	// size and OriginalSize, when known, the size before compression
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	OriginalSize    int64  `json:"original_size,omitempty"`
+}
+
+// StatFile returns an object's metadata via HeadObject
+func (s *S3Service) StatFile(ctx context.Context, key string) (*FileInfo, error) {
+	return s.StatFileVersion(ctx, key, "")
+}
+
+// StatFileVersion returns the metadata of a given version of an object; an empty
+// versionID stats the latest one
+func (s *S3Service) StatFileVersion(ctx context.Context, key, versionID string) (*FileInfo, error) {
+	// A cached latest version answers without asking S3
+	id := cacheID(s.bucket, key)
+	var cached *cacheEntry
+	if versionID == "" {
+		var fresh bool
+		cached, fresh = s.cache.lookup(id)
+		s.cache.countLookup(metrics.OpStat, fresh)
+		if fresh {
+			info := cached.info
+			info.Metadata = maps.Clone(info.Metadata)
+			return &info, nil
+		}
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: optionalString(versionID),
+	})
+	if cached != nil {
+		// A stale copy of an unchanged object stays; anything else is dropped
+		etag := ""
+		if err == nil {
+			etag = aws.ToString(result.ETag)
+		}
+		s.cache.revalidate(id, etag)
+	}
+	if err != nil {
+		if versionID != "" && isNotImplemented(err) {
+			return nil, ErrVersioningUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
+		s.logger.Error("failed to stat file", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	metadata := result.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encryption := string(result.ServerSideEncryption)
+	if encryption == "" {
+		encryption = config.SSENone
+	}
+
+	contentEncoding := aws.ToString(result.ContentEncoding)
+
+	return &FileInfo{
+		Key:             key,
+		Size:            aws.ToInt64(result.ContentLength),
+		ContentType:     aws.ToString(result.ContentType),
+		ETag:            aws.ToString(result.ETag),
+		LastModified:    aws.ToTime(result.LastModified),
+		Metadata:        metadata,
+		Encryption:      encryption,
+		KMSKeyID:        aws.ToString(result.SSEKMSKeyId),
+		VersionID:       aws.ToString(result.VersionId),
+		ContentEncoding: contentEncoding,
+		OriginalSize:    originalSize(contentEncoding, metadata),
+	}, nil
+}
+
+// fileInfoFromGet builds the metadata StatFile would report from a GetObject response
+func fileInfoFromGet(key string, result *s3.GetObjectOutput) FileInfo {
+	metadata := result.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	encryption := string(result.ServerSideEncryption)
+	if encryption == "" {
+		encryption = config.SSENone
+	}
+
+	contentEncoding := aws.ToString(result.ContentEncoding)
+
+	return FileInfo{
+		Key:             key,
+		Size:            aws.ToInt64(result.ContentLength),
+		ContentType:     aws.ToString(result.ContentType),
+		ETag:            aws.ToString(result.ETag),
+		LastModified:    aws.ToTime(result.LastModified),
+		Metadata:        metadata,
+		Encryption:      encryption,
+		KMSKeyID:        aws.ToString(result.SSEKMSKeyId),
+		VersionID:       aws.ToString(result.VersionId),
+		ContentEncoding: contentEncoding,
+		OriginalSize:    originalSize(contentEncoding, metadata),
+	}
+}
+
+// sdkLogger routes SDK log output to zap, warnings as warnings and everything else at debug level
+func sdkLogger(logger *zap.Logger) logging.Logger {
+	return logging.LoggerFunc(func(classification logging.Classification, format string, v ...interface{}) {
+		if classification == logging.Warn {
+			logger.Warn("s3 client: " + fmt.Sprintf(format, v...))
+			return
+		}
+		logger.Debug("s3 client: " + fmt.Sprintf(format, v...))
+	})
+}
+
+// isNotFound reports whether an SDK error means the object does not exist
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "NotFound", "NoSuchKey", "NoSuchVersion":
+			return true
+		}
+	}
+	return false
+}
+
+// isAccessDenied reports whether an SDK error means the credentials may not touch the object.
+// HEAD responses carry no error body, so the bare status code is checked as well
+func isAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDenied", "Forbidden", "AllAccessDisabled":
+			return true
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden
+}
+
+// classifyError maps SDK errors callers can act on to the package's sentinel errors
+// and returns nil for everything else
+func classifyError(err error) error {
+	switch {
+	case isNotFound(err):
+		return ErrNotFound
+	case isAccessDenied(err):
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// isChecksumMismatch reports whether an SDK error means S3 found the body did not match its digests
+func isChecksumMismatch(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "BadDigest", "InvalidDigest", "XAmzContentChecksumMismatch", "XAmzContentSHA256Mismatch":
+			return true
+		}
+	}
+	return false
+}
+
+// copySource builds the URL-encoded "bucket/key" value CopyObject expects
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
+
+// ProbeEncryption writes a tiny object with the configured server-side encryption
+// so a backend that rejects it fails at startup instead of on every upload.
+// It does nothing when encryption is disabled.
+func (s *S3Service) ProbeEncryption(ctx context.Context) error {
+	if s.sse == "" {
+		return nil
+	}
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	key := fmt.Sprintf("%ssse-%d", ProbePrefix, time.Now().UnixNano())
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader([]byte("probe")),
+	}
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("S3 backend rejected server-side encryption %q (set S3_SSE=none to disable it): %w", s.sse, err)
+	}
+
+	info, err := s.StatFile(ctx, key)
+	if err != nil {
+		s.logger.Warn("failed to verify encryption probe object", zap.String("key", key), zap.Error(err))
+	} else if info.Encryption != s.sse {
+		s.logger.Warn("S3 backend accepted server-side encryption but does not report it on objects",
+			zap.String("requested", s.sse), zap.String("reported", info.Encryption))
+	}
+
+	if err := s.deleteProbeObject(ctx, key); err != nil {
+		s.logger.Warn("failed to remove encryption probe object", zap.String("key", key), zap.Error(err))
+	}
 	return nil
+}
+
+// deleteProbeObject removes an object a startup probe wrote. Unlike DeleteFile it queues
+// no replication: the probe never reached the secondary, and every startup would
+// otherwise send it a delete.
+func (s *S3Service) deleteProbeObject(ctx context.Context, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
 }
\ No newline at end of file
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ProbePrefix holds the short-lived objects written by startup probes and the self-test
+const ProbePrefix = ".probe/"
+
+// SelfTestStep is the outcome of one call of the self-test and the permission it needs
+type SelfTestStep struct {
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+	LatencyMs  int64  `json:"latency_ms"`
+}
+
+// SelfTestResult is the outcome of a self-test; FailedStep names the first step that failed
+type SelfTestResult struct {
+	Bucket     string         `json:"bucket"`
+	Key        string         `json:"key"`
+	OK         bool           `json:"ok"`
+	FailedStep string         `json:"failed_step,omitempty"`
+	Steps      []SelfTestStep `json:"steps"`
+	err        error
+}
+
+// Err returns nil when the self-test passed, and otherwise an error naming the step that
+// failed and, when S3 denied it, the permission the credentials are missing
+func (r SelfTestResult) Err() error {
+	return r.err
+}
+
+// SelfTest checks the credentials can do everything the app needs by putting a small
+// object under ProbePrefix, heading it, reading it back and deleting it. Steps after a
+// failure are skipped, except that an object that was written is always deleted.
+func (s *S3Service) SelfTest(ctx context.Context) SelfTestResult {
+	result := SelfTestResult{Bucket: s.bucket, Key: ProbePrefix + newProbeID(), OK: true}
+	payload := []byte("self-test " + result.Key)
+
+	run := func(name, permission string, call func(ctx context.Context) error) bool {
+		step := SelfTestStep{Name: name, Permission: permission}
+		if !result.OK && name != "delete" {
+			step.Skipped = true
+			result.Steps = append(result.Steps, step)
+			return false
+		}
+
+		callCtx, cancel := s.withTimeout(ctx)
+		start := time.Now()
+		err := call(callCtx)
+		step.LatencyMs = time.Since(start).Milliseconds()
+		cancel()
+
+		step.OK = err == nil
+		if err != nil {
+			step.Error = err.Error()
+			if result.OK {
+				result.OK = false
+				result.FailedStep = name
+				if isAccessDenied(err) {
+					result.err = fmt.Errorf("s3 self-test failed at %s on bucket %s: access denied, missing %s permission: %w", name, s.bucket, permission, err)
+				} else {
+					result.err = fmt.Errorf("s3 self-test failed at %s on bucket %s (needs %s): %w", name, s.bucket, permission, err)
+				}
+			}
+		}
+		result.Steps = append(result.Steps, step)
+		return err == nil
+	}
+
+	written := run("put", "s3:PutObject", func(ctx context.Context) error {
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(result.Key),
+			Body:   bytes.NewReader(payload),
+		}
+		input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+		_, err := s.client.PutObject(ctx, input)
+		return err
+	})
+
+	run("head", "s3:GetObject", func(ctx context.Context) error {
+		_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(result.Key),
+		})
+		return err
+	})
+
+	run("get", "s3:GetObject", func(ctx context.Context) error {
+		out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(result.Key),
+		})
+		if err != nil {
+			return err
+		}
+		defer out.Body.Close()
+		data, err := io.ReadAll(out.Body)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, payload) {
+			return errors.New("read back content that differs from what was written")
+		}
+		return nil
+	})
+
+	if written {
+		run("delete", "s3:DeleteObject", func(ctx context.Context) error {
+			_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(result.Key),
+			})
+			return err
+		})
+	} else {
+		result.Steps = append(result.Steps, SelfTestStep{Name: "delete", Permission: "s3:DeleteObject", Skipped: true})
+	}
+
+	return result
+}
+
+// newProbeID returns a random version 4 UUID
+func newProbeID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
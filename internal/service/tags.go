@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.uber.org/zap"
+)
+
+// S3 limits on object tags
+const (
+	MaxObjectTags     = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// ValidateTags checks tags against the limits S3 enforces on object tagging
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxObjectTags {
+		return fmt.Errorf("at most %d tags are allowed", MaxObjectTags)
+	}
+
+	for key, value := range tags {
+		if key == "" {
+			return fmt.Errorf("tag keys must not be empty")
+		}
+		if utf8.RuneCountInString(key) > MaxTagKeyLength {
+			return fmt.Errorf("tag key %q is longer than %d characters", key, MaxTagKeyLength)
+		}
+		if utf8.RuneCountInString(value) > MaxTagValueLength {
+			return fmt.Errorf("value of tag %q is longer than %d characters", key, MaxTagValueLength)
+		}
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			return fmt.Errorf("tag key %q uses the reserved aws: prefix", key)
+		}
+		if !validTagText(key) || !validTagText(value) {
+			return fmt.Errorf("tag %q may only contain letters, numbers, spaces and + - = . _ : / @", key)
+		}
+	}
+
+	return nil
+}
+
+// validTagText reports whether s only uses characters S3 accepts in tags
+func validTagText(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsSpace(r) && !strings.ContainsRune("+-=._:/@", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeTags formats tags as the URL query string PutObject expects, in a stable order
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := url.Values{}
+	for _, key := range keys {
+		values.Set(key, tags[key])
+	}
+	return values.Encode()
+}
+
+// SetObjectTags replaces the tags of an object. An empty map removes every tag.
+func (s *S3Service) SetObjectTags(ctx context.Context, key string, tags map[string]string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := ValidateTags(tags); err != nil {
+		return err
+	}
+
+	tagSet := make([]types.Tag, 0, len(tags))
+	for k, v := range tags {
+		tagSet = append(tagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	_, err := s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tagSet},
+	})
+	if err != nil {
+		if typed := classifyError(err); typed != nil {
+			return typed
+		}
+		s.logger.Error("failed to set object tags", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("failed to set object tags: %w", err)
+	}
+
+	s.logger.Info("object tags set", zap.String("key", key), zap.Int("count", len(tags)))
+	s.replicate(ReplicationPut, key)
+	return nil
+}
+
+// GetObjectTags returns the tags of an object
+func (s *S3Service) GetObjectTags(ctx context.Context, key string) (map[string]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if typed := classifyError(err); typed != nil {
+			return nil, typed
+		}
+		s.logger.Error("failed to get object tags", zap.String("key", key), zap.Error(err))
+		return nil, fmt.Errorf("failed to get object tags: %w", err)
+	}
+
+	tags := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
\ No newline at end of file
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TrashPrefix holds soft-deleted objects until they are restored or purged. It is hidden from listings.
+const TrashPrefix = "trash/"
+
+// ErrRestoreConflict is returned when a file can't be restored because its original key is taken again
+var ErrRestoreConflict = errors.New("a file already exists at the original key")
+
+// isHiddenKey reports whether key belongs to an internal prefix that listings skip
+func isHiddenKey(key string) bool {
+	return isCanaryKey(key) || strings.HasPrefix(key, TrashPrefix)
+}
+
+// TrashKey returns the key a file deleted at deletedAt is moved to. The timestamp
+// keeps repeated deletions of the same key apart.
+func TrashKey(key string, deletedAt time.Time) string {
+	return fmt.Sprintf("%s%d/%s", TrashPrefix, deletedAt.UnixNano(), key)
+}
+
+// TrashFile moves an object into the trash and returns its trash key
+func (s *S3Service) TrashFile(ctx context.Context, key string, deletedAt time.Time) (string, error) {
+	trashKey := TrashKey(key, deletedAt)
+	if err := s.CopyFile(ctx, key, trashKey); err != nil {
+		return "", err
+	}
+
+	if err := s.DeleteFile(ctx, key); err != nil {
+		// Leave no orphaned copy behind when the original stays in place
+		if cleanupErr := s.DeleteFile(ctx, trashKey); cleanupErr != nil {
+			s.logger.Error("failed to remove trash copy", zap.String("key", trashKey), zap.Error(cleanupErr))
+		}
+		return "", err
+	}
+
+	s.logger.Info("file moved to trash", zap.String("key", key), zap.String("trash_key", trashKey))
+	return trashKey, nil
+}
+
+// RestoreFile moves a trashed object back to key, unless an object exists there again
+func (s *S3Service) RestoreFile(ctx context.Context, trashKey, key string) error {
+	if _, err := s.StatFile(ctx, key); err == nil {
+		return ErrRestoreConflict
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := s.RenameFile(ctx, trashKey, key); err != nil {
+		return err
+	}
+
+	s.logger.Info("file restored from trash", zap.String("trash_key", trashKey), zap.String("key", key))
+	return nil
+}
\ No newline at end of file
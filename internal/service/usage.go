@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Usage is the storage used under a prefix
+type Usage struct {
+	Bytes   int64
+	Objects int64
+	// ByPrefix breaks the totals down per user folder; other keys are grouped by their top-level folder
+	ByPrefix   map[string]PrefixUsage
+	ComputedAt time.Time
+}
+
+// PrefixUsage is the storage used under one folder
+type PrefixUsage struct {
+	Bytes   int64
+	Objects int64
+}
+
+// Bucket returns the name of the bucket files are stored in
+func (s *S3Service) Bucket() string {
+	return s.bucket
+}
+
+// Endpoint returns the S3 endpoint the service talks to
+func (s *S3Service) Endpoint() string {
+	return s.endpoint
+}
+
+// Usage lists every object under prefix, in parallel, and sums their sizes.
+// Results are reused until the configured cache TTL expires.
+func (s *S3Service) Usage(ctx context.Context, prefix string) (*Usage, error) {
+	s.usageMu.Lock()
+	cached := s.usageCache[prefix]
+	s.usageMu.Unlock()
+	if cached != nil && time.Since(cached.ComputedAt) < s.usageTTL {
+		return cached, nil
+	}
+
+	// Trash and canary objects take up space too, so they are counted
+	usage := &Usage{ByPrefix: make(map[string]PrefixUsage)}
+	err := s.ListParallel(ctx, ParallelListOptions{Prefix: prefix, IncludeHidden: true}, func(files []File) error {
+		for _, file := range files {
+			usage.Bytes += file.Size
+			usage.Objects++
+
+			group := usageGroup(file.Key)
+			p := usage.ByPrefix[group]
+			p.Bytes += file.Size
+			p.Objects++
+			usage.ByPrefix[group] = p
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to list objects for usage", zap.String("prefix", prefix), zap.Error(err))
+		return nil, err
+	}
+	usage.ComputedAt = time.Now()
+
+	s.usageMu.Lock()
+	s.usageCache[prefix] = usage
+	s.usageMu.Unlock()
+	return usage, nil
+}
+
+// usageGroup returns the folder key is counted under: its user folder,
+// otherwise its top-level folder, or "" for keys at the bucket root
+func usageGroup(key string) string {
+	if userID := UserFromKey(key); userID != "" {
+		return UserPrefix(userID)
+	}
+	if folder, _, ok := strings.Cut(key, "/"); ok {
+		return folder + "/"
+	}
+	return ""
+}
\ No newline at end of file
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.uber.org/zap"
+	"s3-test-app/internal/metrics"
+)
+
+// ErrVersioningUnsupported is returned when the storage backend has no object versioning
+var ErrVersioningUnsupported = errors.New("object versioning is not supported by the storage backend")
+
+// ObjectVersion is one stored version of an object, or a delete marker left by deleting it
+type ObjectVersion struct {
+	VersionID    string    `json:"version_id"`
+	Size         int64     `json:"size"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+	IsLatest     bool      `json:"is_latest"`
+	DeleteMarker bool      `json:"delete_marker,omitempty"`
+}
+
+// ListVersions returns every version and delete marker of key, newest first.
+// Buckets without versioning report a single version with the ID "null".
+func (s *S3Service) ListVersions(ctx context.Context, key string) ([]ObjectVersion, error) {
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(key),
+	})
+
+	versions := make([]ObjectVersion, 0)
+	for paginator.HasMorePages() {
+		pageCtx, cancel := s.withTimeout(ctx)
+		start := time.Now()
+		page, err := paginator.NextPage(pageCtx)
+		metrics.ObserveS3(metrics.OpList, start, err)
+		cancel()
+		if err != nil {
+			if isNotImplemented(err) {
+				return nil, ErrVersioningUnsupported
+			}
+			if typed := classifyError(err); typed != nil {
+				return nil, typed
+			}
+			s.logger.Error("failed to list versions", zap.String("key", key), zap.Error(err))
+			return nil, fmt.Errorf("failed to list versions: %w", err)
+		}
+
+		// The prefix also matches longer keys, such as key.bak, which are skipped
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				VersionID:    aws.ToString(version.VersionId),
+				Size:         aws.ToInt64(version.Size),
+				ETag:         aws.ToString(version.ETag),
+				LastModified: aws.ToTime(version.LastModified),
+				IsLatest:     aws.ToBool(version.IsLatest),
+			})
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) != key {
+				continue
+			}
+			versions = append(versions, ObjectVersion{
+				VersionID:    aws.ToString(marker.VersionId),
+				LastModified: aws.ToTime(marker.LastModified),
+				IsLatest:     aws.ToBool(marker.IsLatest),
+				DeleteMarker: true,
+			})
+		}
+	}
+
+	// S3 lists versions and delete markers apart, so put them back in order, newest first
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+	return versions, nil
+}
+
+// RestoreVersion makes an older version of key the latest again by copying it onto key.
+// The old version stays in place, so the restore itself can be undone the same way.
+func (s *S3Service) RestoreVersion(ctx context.Context, key, versionID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(copySource(s.bucket, key) + "?versionId=" + url.QueryEscape(versionID)),
+	}
+	// Copies don't inherit the source's encryption, so request it again
+	input.ServerSideEncryption, input.SSEKMSKeyId = s.encryption()
+
+	_, err := s.client.CopyObject(ctx, input)
+	s.cache.invalidate(cacheID(s.bucket, key))
+	if err != nil {
+		if isNotImplemented(err) {
+			return ErrVersioningUnsupported
+		}
+		if typed := classifyError(err); typed != nil {
+			return typed
+		}
+		s.logger.Error("failed to restore version", zap.String("key", key), zap.String("version_id", versionID), zap.Error(err))
+		return fmt.Errorf("failed to restore version: %w", err)
+	}
+	s.logger.Info("version restored", zap.String("key", key), zap.String("version_id", versionID))
+	s.replicate(ReplicationPut, key)
+	return nil
+}
+
+// isNotImplemented reports whether an SDK error means the backend lacks the requested feature
+func isNotImplemented(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented" {
+		return true
+	}
+
+	var respErr *smithyhttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotImplemented
+}
+
+// optionalString returns nil for an empty string, leaving the SDK parameter unset
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return aws.String(value)
+}
\ No newline at end of file
@@ -29,7 +29,7 @@ func Dashboard(username string, role string) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Document Management System</title><style>\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\n\t\t\thtml, body {\n\t\t\t\theight: 100%;\n\t\t\t\tfont-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif;\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.container {\n\t\t\t\tdisplay: flex;\n\t\t\t\theight: 100vh;\n\t\t\t}\n\n\t\t\t.sidebar {\n\t\t\t\twidth: 200px;\n\t\t\t\tbackground-color: #121212;\n\t\t\t\tborder-right: 1px solid #333;\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t}\n\n\t\t\t.sidebar-content {\n\t\t\t\tflex: 1;\n\t\t\t\tpadding: 20px 0;\n\t\t\t\toverflow-y: auto;\n\t\t\t}\n\n\t\t\t.sidebar-footer {\n\t\t\t\tpadding: 20px;\n\t\t\t\tborder-top: 1px solid #333;\n\t\t\t}\n\n\t\t\t.user-info {\n\t\t\t\tpadding: 0 20px;\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t\tfont-size: 12px;\n\t\t\t}\n\n\t\t\t.user-info div {\n\t\t\t\tmargin-bottom: 5px;\n\t\t\t}\n\n\t\t\t.user-name {\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tmargin-bottom: 5px;\n\t\t\t}\n\n\t\t\t.user-role {\n\t\t\t\tcolor: #888;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t\tfont-size: 11px;\n\t\t\t}\n\n\t\t\t.sidebar h2 {\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tfont-size: 11px;\n\t\t\t\tfont-weight: 700;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 1px;\n\t\t\t\tcolor: #555;\n\t\t\t\tmargin-top: 20px;\n\t\t\t\tmargin-bottom: 10px;\n\t\t\t}\n\n\t\t\t.nav-item {\n\t\t\t\tpadding: 12px 20px;\n\t\t\t\tcursor: pointer;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\tborder-left: 3px solid transparent;\n\t\t\t\tbackground-color: #121212;\n\t\t\t}\n\n\t\t\t.nav-item:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.nav-item.active {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-left-color: #4a9eff;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.logout-btn {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\n\t\t\t.logout-btn:hover {\n\t\t\t\tbackground-color: #555;\n\t\t\t}\n\n\t\t\t.main-content {\n\t\t\t\tflex: 1;\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t}\n\n\t\t\t.header {\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tpadding: 20px 30px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.header h1 {\n\t\t\t\tfont-size: 20px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.content {\n\t\t\t\tflex: 1;\n\t\t\t\toverflow-y: auto;\n\t\t\t\tpadding: 30px;\n\t\t\t}\n\n\t\t\t.page {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.page.active {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.upload-zone {\n\t\t\t\tborder: 2px solid #333;\n\t\t\t\tpadding: 40px 20px;\n\t\t\t\ttext-align: center;\n\t\t\t\tcursor: pointer;\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t}\n\n\t\t\t.upload-zone:hover {\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #212121;\n\t\t\t}\n\n\t\t\t.upload-zone.dragover {\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #1a2a35;\n\t\t\t}\n\n\t\t\t.upload-zone p {\n\t\t\t\tcolor: #888;\n\t\t\t\tfont-size: 14px;\n\t\t\t\tmargin: 10px 0;\n\t\t\t}\n\n\t\t\t#fileInput {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.button {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tmargin-right: 10px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcursor: pointer;\n\t\t\t\ttext-decoration: none;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.button-primary {\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t}\n\n\t\t\t.button-primary:hover {\n\t\t\t\tbackground-color: #3a8eef;\n\t\t\t}\n\n\t\t\t.button-danger {\n\t\t\t\tbackground-color: #ff4444;\n\t\t\t\tcolor: #fff;\n\t\t\t\tpadding: 6px 12px;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tmargin-right: 5px;\n\t\t\t}\n\n\t\t\t.button-danger:hover {\n\t\t\t\tbackground-color: #dd3333;\n\t\t\t}\n\n\t\t\t.button-secondary {\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.button-secondary:hover {\n\t\t\t\tbackground-color: #555;\n\t\t\t}\n\n\t\t\t.file-table {\n\t\t\t\twidth: 100%;\n\t\t\t\tborder-collapse: collapse;\n\t\t\t\tmargin-top: 15px;\n\t\t\t}\n\n\t\t\t.file-table thead {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.file-table th {\n\t\t\t\tpadding: 12px;\n\t\t\t\ttext-align: left;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.file-table td {\n\t\t\t\tpadding: 12px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.file-table tbody tr:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t}\n\n\t\t\t.file-name {\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tword-break: break-all;\n\t\t\t}\n\n\t\t\t.actions {\n\t\t\t\tdisplay: flex;\n\t\t\t\tgap: 5px;\n\t\t\t}\n\n\t\t\t.message {\n\t\t\t\tpadding: 12px 16px;\n\t\t\t\tmargin-bottom: 15px;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tdisplay: none;\n\t\t\t\tborder-left: 3px solid;\n\t\t\t}\n\n\t\t\t.message.show {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.message-success {\n\t\t\t\tbackground-color: #1a3a2a;\n\t\t\t\tcolor: #4ade80;\n\t\t\t\tborder-left-color: #4ade80;\n\t\t\t}\n\n\t\t\t.message-error {\n\t\t\t\tbackground-color: #3a1a1a;\n\t\t\t\tcolor: #ff6b6b;\n\t\t\t\tborder-left-color: #ff6b6b;\n\t\t\t}\n\n\t\t\t.empty-state {\n\t\t\t\ttext-align: center;\n\t\t\t\tpadding: 50px 20px;\n\t\t\t\tcolor: #666;\n\t\t\t}\n\n\t\t\t.user-list {\n\t\t\t\twidth: 100%;\n\t\t\t\tborder-collapse: collapse;\n\t\t\t}\n\n\t\t\t.user-list thead {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.user-list th {\n\t\t\t\tpadding: 12px;\n\t\t\t\ttext-align: left;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.user-list td {\n\t\t\t\tpadding: 12px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.user-list tbody tr:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t}\n\n\t\t\t.role-badge {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\tpadding: 4px 8px;\n\t\t\t\tborder-radius: 0;\n\t\t\t\tfont-size: 11px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.role-badge.admin {\n\t\t\t\tbackground-color: #ff4444;\n\t\t\t\tcolor: #fff;\n\t\t\t}\n\n\t\t\t.role-badge.uploader {\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t}\n\n\t\t\t.role-badge.viewer {\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\t\t</style></head><body><div class=\"container\"><div class=\"sidebar\"><div class=\"sidebar-content\"><div class=\"user-info\"><div class=\"user-name\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Document Management System</title><style>\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\n\t\t\thtml, body {\n\t\t\t\theight: 100%;\n\t\t\t\tfont-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif;\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.container {\n\t\t\t\tdisplay: flex;\n\t\t\t\theight: 100vh;\n\t\t\t}\n\n\t\t\t.sidebar {\n\t\t\t\twidth: 200px;\n\t\t\t\tbackground-color: #121212;\n\t\t\t\tborder-right: 1px solid #333;\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t}\n\n\t\t\t.sidebar-content {\n\t\t\t\tflex: 1;\n\t\t\t\tpadding: 20px 0;\n\t\t\t\toverflow-y: auto;\n\t\t\t}\n\n\t\t\t.sidebar-footer {\n\t\t\t\tpadding: 20px;\n\t\t\t\tborder-top: 1px solid #333;\n\t\t\t}\n\n\t\t\t.user-info {\n\t\t\t\tpadding: 0 20px;\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t\tfont-size: 12px;\n\t\t\t}\n\n\t\t\t.user-info div {\n\t\t\t\tmargin-bottom: 5px;\n\t\t\t}\n\n\t\t\t.user-name {\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tmargin-bottom: 5px;\n\t\t\t}\n\n\t\t\t.user-role {\n\t\t\t\tcolor: #888;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t\tfont-size: 11px;\n\t\t\t}\n\n\t\t\t.sidebar h2 {\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tfont-size: 11px;\n\t\t\t\tfont-weight: 700;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 1px;\n\t\t\t\tcolor: #555;\n\t\t\t\tmargin-top: 20px;\n\t\t\t\tmargin-bottom: 10px;\n\t\t\t}\n\n\t\t\t.nav-item {\n\t\t\t\tpadding: 12px 20px;\n\t\t\t\tcursor: pointer;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\tborder-left: 3px solid transparent;\n\t\t\t\tbackground-color: #121212;\n\t\t\t}\n\n\t\t\t.nav-item:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.nav-item.active {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-left-color: #4a9eff;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.logout-btn {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\n\t\t\t.logout-btn:hover {\n\t\t\t\tbackground-color: #555;\n\t\t\t}\n\n\t\t\t.main-content {\n\t\t\t\tflex: 1;\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t}\n\n\t\t\t.header {\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tpadding: 20px 30px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.header h1 {\n\t\t\t\tfont-size: 20px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.content {\n\t\t\t\tflex: 1;\n\t\t\t\toverflow-y: auto;\n\t\t\t\tpadding: 30px;\n\t\t\t}\n\n\t\t\t.page {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.page.active {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.upload-zone {\n\t\t\t\tborder: 2px solid #333;\n\t\t\t\tpadding: 40px 20px;\n\t\t\t\ttext-align: center;\n\t\t\t\tcursor: pointer;\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t}\n\n\t\t\t.upload-zone:hover {\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #212121;\n\t\t\t}\n\n\t\t\t.upload-zone.dragover {\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #1a2a35;\n\t\t\t}\n\n\t\t\t.upload-zone p {\n\t\t\t\tcolor: #888;\n\t\t\t\tfont-size: 14px;\n\t\t\t\tmargin: 10px 0;\n\t\t\t}\n\n\t\t\t#fileInput {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.button {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\tpadding: 10px 20px;\n\t\t\t\tmargin-right: 10px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcursor: pointer;\n\t\t\t\ttext-decoration: none;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.button-primary {\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t}\n\n\t\t\t.button-primary:hover {\n\t\t\t\tbackground-color: #3a8eef;\n\t\t\t}\n\n\t\t\t.button-danger {\n\t\t\t\tbackground-color: #ff4444;\n\t\t\t\tcolor: #fff;\n\t\t\t\tpadding: 6px 12px;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tmargin-right: 5px;\n\t\t\t}\n\n\t\t\t.button-danger:hover {\n\t\t\t\tbackground-color: #dd3333;\n\t\t\t}\n\n\t\t\t.button-secondary {\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.button-secondary:hover {\n\t\t\t\tbackground-color: #555;\n\t\t\t}\n\n\t\t\t.file-table {\n\t\t\t\twidth: 100%;\n\t\t\t\tborder-collapse: collapse;\n\t\t\t\tmargin-top: 15px;\n\t\t\t}\n\n\t\t\t.file-table thead {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.file-table th {\n\t\t\t\tpadding: 12px;\n\t\t\t\ttext-align: left;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.file-table th.sortable {\n\t\t\t\tcursor: pointer;\n\t\t\t\tuser-select: none;\n\t\t\t}\n\n\t\t\t.file-table th.sortable:hover {\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.file-table td {\n\t\t\t\tpadding: 12px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.file-table tbody tr:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t}\n\n\t\t\t.file-name {\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tword-break: break-all;\n\t\t\t}\n\n\t\t\t.actions {\n\t\t\t\tdisplay: flex;\n\t\t\t\tgap: 5px;\n\t\t\t}\n\n\t\t\t.message {\n\t\t\t\tpadding: 12px 16px;\n\t\t\t\tmargin-bottom: 15px;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tdisplay: none;\n\t\t\t\tborder-left: 3px solid;\n\t\t\t}\n\n\t\t\t.message.show {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.message-success {\n\t\t\t\tbackground-color: #1a3a2a;\n\t\t\t\tcolor: #4ade80;\n\t\t\t\tborder-left-color: #4ade80;\n\t\t\t}\n\n\t\t\t.message-error {\n\t\t\t\tbackground-color: #3a1a1a;\n\t\t\t\tcolor: #ff6b6b;\n\t\t\t\tborder-left-color: #ff6b6b;\n\t\t\t}\n\n\t\t\t.empty-state {\n\t\t\t\ttext-align: center;\n\t\t\t\tpadding: 50px 20px;\n\t\t\t\tcolor: #666;\n\t\t\t}\n\n\t\t\t.user-list {\n\t\t\t\twidth: 100%;\n\t\t\t\tborder-collapse: collapse;\n\t\t\t}\n\n\t\t\t.user-list thead {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t}\n\n\t\t\t.user-list th {\n\t\t\t\tpadding: 12px;\n\t\t\t\ttext-align: left;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #b0b0b0;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.user-list td {\n\t\t\t\tpadding: 12px;\n\t\t\t\tborder-bottom: 1px solid #333;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.user-list tbody tr:hover {\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t}\n\n\t\t\t.role-badge {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\tpadding: 4px 8px;\n\t\t\t\tborder-radius: 0;\n\t\t\t\tfont-size: 11px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\ttext-transform: uppercase;\n\t\t\t\tletter-spacing: 0.5px;\n\t\t\t}\n\n\t\t\t.role-badge.admin {\n\t\t\t\tbackground-color: #ff4444;\n\t\t\t\tcolor: #fff;\n\t\t\t}\n\n\t\t\t.role-badge.uploader {\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t}\n\n\t\t\t.role-badge.viewer {\n\t\t\t\tbackground-color: #444;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t}\n\n\t\t\t.role-badge.hold {\n\t\t\t\tbackground-color: #ffaa00;\n\t\t\t\tcolor: #000;\n\t\t\t\tmargin-left: 8px;\n\t\t\t}\n\n\t\t\t.file-icon {\n\t\t\t\tmargin-right: 8px;\n\t\t\t}\n\t\t</style></head><body><div class=\"container\"><div class=\"sidebar\"><div class=\"sidebar-content\"><div class=\"user-info\"><div class=\"user-name\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -46,7 +46,7 @@ func Dashboard(username string, role string) templ.Component {
 		var templ_7745c5c3_Var2 string
 		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(username)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 354, Col: 86}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 373, Col: 86}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
@@ -69,7 +69,7 @@ func Dashboard(username string, role string) templ.Component {
 		var templ_7745c5c3_Var3 string
 		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(username)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 355, Col: 86}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 374, Col: 86}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 		if templ_7745c5c3_Err != nil {
@@ -82,7 +82,7 @@ func Dashboard(username string, role string) templ.Component {
 		var templ_7745c5c3_Var4 string
 		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(role)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 356, Col: 35}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/dashboard.templ`, Line: 375, Col: 35}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 		if templ_7745c5c3_Err != nil {
@@ -98,7 +98,7 @@ func Dashboard(username string, role string) templ.Component {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</div><div class=\"sidebar-footer\"><button class=\"logout-btn\" onclick=\"logout()\">Logout</button></div></div><div class=\"main-content\"><div class=\"header\"><h1>Document Management System</h1></div><div class=\"content\"><div id=\"message\" class=\"message\"></div><!-- Documents Page --><div id=\"documents\" class=\"page active\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">My Documents</h2><button class=\"button button-secondary\" onclick=\"refreshFiles()\">Refresh</button><table class=\"file-table\" id=\"fileTable\" style=\"display: none;\"><thead><tr><th style=\"width: 50%;\">File Name</th><th style=\"width: 15%;\">Size</th><th style=\"width: 20%;\">Uploaded</th><th style=\"width: 15%;\">Actions</th></tr></thead> <tbody id=\"fileList\"></tbody></table><div class=\"empty-state\" id=\"emptyState\"><div>No documents</div><div style=\"font-size: 12px; margin-top: 10px; color: #555;\">Upload documents using the Upload page</div></div></div><!-- Upload Page --><div id=\"upload\" class=\"page\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">Upload Document</h2><div class=\"upload-zone\" id=\"uploadZone\"><p>Drag and drop files here or click to browse</p><p style=\"font-size: 12px; margin-top: 8px; color: #666;\">Maximum: 500 MB</p><input type=\"file\" id=\"fileInput\"></div><button class=\"button button-primary\" onclick=\"uploadFile()\">Upload</button></div><!-- Users Page (Admin only) --><div id=\"users\" class=\"page\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">User Management</h2><table class=\"user-list\" id=\"userTable\" style=\"display: none;\"><thead><tr><th style=\"width: 30%;\">Username</th><th style=\"width: 30%;\">Email</th><th style=\"width: 20%;\">Role</th><th style=\"width: 20%;\">Actions</th></tr></thead> <tbody id=\"userList\"></tbody></table><div class=\"empty-state\" id=\"emptyUsersState\"><div>No users found</div></div></div></div></div></div><script>\n\t\t\t// Role-based permissions\n\t\t\tconst userRole = '{ role }';\n\t\t\tconst canUpload = ['admin', 'uploader'].includes(userRole);\n\t\t\tconst canDelete = ['admin'].includes(userRole);\n\t\t\tconst canManage = ['admin'].includes(userRole);\n\n\t\t\tconst uploadZone = document.getElementById('uploadZone');\n\t\t\tconst fileInput = document.getElementById('fileInput');\n\t\t\tconst messageDiv = document.getElementById('message');\n\n\t\t\t// Hide upload zone if user doesn't have permission\n\t\t\tif (!canUpload && uploadZone) {\n\t\t\t\tuploadZone.style.display = 'none';\n\t\t\t\tconst uploadBtn = document.querySelector('#upload .button-primary');\n\t\t\t\tif (uploadBtn) uploadBtn.style.display = 'none';\n\t\t\t}\n\n\t\t\tuploadZone.addEventListener('click', () => fileInput.click());\n\n\t\t\tuploadZone.addEventListener('dragover', (e) => {\n\t\t\t\te.preventDefault();\n\t\t\t\tuploadZone.classList.add('dragover');\n\t\t\t});\n\n\t\t\tuploadZone.addEventListener('dragleave', () => {\n\t\t\t\tuploadZone.classList.remove('dragover');\n\t\t\t});\n\n\t\t\tuploadZone.addEventListener('drop', (e) => {\n\t\t\t\te.preventDefault();\n\t\t\t\tuploadZone.classList.remove('dragover');\n\t\t\t\tfileInput.files = e.dataTransfer.files;\n\t\t\t});\n\n\t\t\tfunction getAuthHeader() {\n\t\t\t\tconst token = localStorage.getItem('token');\n\t\t\t\treturn token ? { 'Authorization': 'Bearer ' + token } : {};\n\t\t\t}\n\n\t\t\tfunction showPage(pageName) {\n\t\t\t\tconst pages = document.querySelectorAll('.page');\n\t\t\t\tconst navItems = document.querySelectorAll('.nav-item');\n\n\t\t\t\tpages.forEach(page => page.classList.remove('active'));\n\t\t\t\tnavItems.forEach(item => item.classList.remove('active'));\n\n\t\t\t\tdocument.getElementById(pageName).classList.add('active');\n\t\t\t\tevent.target.classList.add('active');\n\n\t\t\t\tif (pageName === 'documents') {\n\t\t\t\t\trefreshFiles();\n\t\t\t\t} else if (pageName === 'users') {\n\t\t\t\t\tloadUsers();\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction showMessage(message, type) {\n\t\t\t\tmessageDiv.className = 'message show message-' + type;\n\t\t\t\tmessageDiv.textContent = message;\n\t\t\t\tsetTimeout(() => {\n\t\t\t\t\tmessageDiv.classList.remove('show');\n\t\t\t\t}, 4000);\n\t\t\t}\n\n\t\t\tasync function uploadFile() {\n\t\t\t\tconst file = fileInput.files[0];\n\t\t\t\tif (!file) {\n\t\t\t\t\tshowMessage('Please select a file', 'error');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\n\t\t\t\tconst formData = new FormData();\n\t\t\t\tformData.append('file', file);\n\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/upload', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\theaders: getAuthHeader(),\n\t\t\t\t\t\tbody: formData\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\tshowMessage('Document uploaded successfully', 'success');\n\t\t\t\t\t\tfileInput.value = '';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tshowMessage('Upload failed: ' + data.error, 'error');\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tasync function refreshFiles() {\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/files', {\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tif (data.success && data.data.files && data.data.files.length > 0) {\n\t\t\t\t\t\tconst fileList = document.getElementById('fileList');\n\t\t\t\t\t\tfileList.innerHTML = data.data.files.map(file => {\n\t\t\t\t\t\t\tlet actions = '<a href=\"/api/download?key=' + encodeURIComponent(file.key) + '\" class=\"button button-secondary\" style=\"padding: 6px 12px; font-size: 12px;\">Download</a>';\n\t\t\t\t\t\t\tif (canDelete) {\n\t\t\t\t\t\t\t\tactions += '<button class=\"button button-danger\" onclick=\"deleteFile(\\'' + escapeQuotes(file.key) + '\\')\">Delete</button>';\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\treturn '<tr>' +\n\t\t\t\t\t\t\t\t'<td class=\"file-name\">' + escapeHtml(file.key) + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888;\">' + formatBytes(file.size) + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888; font-size: 12px;\">' + file.last_modified + '</td>' +\n\t\t\t\t\t\t\t\t'<td class=\"actions\">' + actions + '</td>' +\n\t\t\t\t\t\t\t\t'</tr>';\n\t\t\t\t\t\t}).join('');\n\t\t\t\t\t\tdocument.getElementById('fileTable').style.display = 'table';\n\t\t\t\t\t\tdocument.getElementById('emptyState').style.display = 'none';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tdocument.getElementById('fileTable').style.display = 'none';\n\t\t\t\t\t\tdocument.getElementById('emptyState').style.display = 'block';\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error loading documents: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tasync function loadUsers() {\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/admin/users', {\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tif (data.success && data.data.users && data.data.users.length > 0) {\n\t\t\t\t\t\tconst userList = document.getElementById('userList');\n\t\t\t\t\t\tuserList.innerHTML = data.data.users.map(user => {\n\t\t\t\t\t\t\tlet roleClass = 'admin';\n\t\t\t\t\t\t\tif (user.role === 'uploader') roleClass = 'uploader';\n\t\t\t\t\t\t\tif (user.role === 'viewer') roleClass = 'viewer';\n\n\t\t\t\t\t\t\treturn '<tr>' +\n\t\t\t\t\t\t\t\t'<td>' + escapeHtml(user.username) + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888;\">' + escapeHtml(user.email) + '</td>' +\n\t\t\t\t\t\t\t\t'<td><span class=\"role-badge ' + roleClass + '\">' + user.role + '</span></td>' +\n\t\t\t\t\t\t\t\t'<td class=\"actions\">' +\n\t\t\t\t\t\t\t\t'<button class=\"button button-danger\" onclick=\"deleteUser(\\'' + escapeQuotes(user.id) + '\\')\">Delete</button>' +\n\t\t\t\t\t\t\t\t'</td>' +\n\t\t\t\t\t\t\t\t'</tr>';\n\t\t\t\t\t\t}).join('');\n\t\t\t\t\t\tdocument.getElementById('userTable').style.display = 'table';\n\t\t\t\t\t\tdocument.getElementById('emptyUsersState').style.display = 'none';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tdocument.getElementById('userTable').style.display = 'none';\n\t\t\t\t\t\tdocument.getElementById('emptyUsersState').style.display = 'block';\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error loading users: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction deleteFile(key) {\n\t\t\t\tif (confirm('Delete this document?')) {\n\t\t\t\t\tfetch('/api/files?key=' + encodeURIComponent(key), {\n\t\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t}).then(response => response.json())\n\t\t\t\t\t.then(data => {\n\t\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\t\tshowMessage('Document deleted', 'success');\n\t\t\t\t\t\t\trefreshFiles();\n\t\t\t\t\t\t} else {\n\t\t\t\t\t\t\tshowMessage('Delete failed: ' + data.error, 'error');\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction deleteUser(userId) {\n\t\t\t\tif (confirm('Delete this user?')) {\n\t\t\t\t\tfetch('/api/admin/users/' + userId, {\n\t\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t}).then(response => response.json())\n\t\t\t\t\t.then(data => {\n\t\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\t\tshowMessage('User deleted', 'success');\n\t\t\t\t\t\t\tloadUsers();\n\t\t\t\t\t\t} else {\n\t\t\t\t\t\t\tshowMessage('Delete failed: ' + data.error, 'error');\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction escapeHtml(text) {\n\t\t\t\tconst div = document.createElement('div');\n\t\t\t\tdiv.textContent = text;\n\t\t\t\treturn div.innerHTML;\n\t\t\t}\n\n\t\t\tfunction escapeQuotes(text) {\n\t\t\t\treturn text.replace(/'/g, \"\\\\'\").replace(/\"/g, '\\\\\"');\n\t\t\t}\n\n\t\t\tfunction formatBytes(bytes) {\n\t\t\t\tif (bytes === 0) return '0 B';\n\t\t\t\tconst k = 1024;\n\t\t\t\tconst sizes = ['B', 'KB', 'MB', 'GB'];\n\t\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\t\t\t\treturn Math.round(bytes / Math.pow(k, i) * 100) / 100 + ' ' + sizes[i];\n\t\t\t}\n\n\t\t\tfunction logout() {\n\t\t\t\tlocalStorage.removeItem('token');\n\t\t\t\twindow.location.href = '/login';\n\t\t\t}\n\n\t\t\twindow.onload = refreshFiles;\n\t\t</script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</div><div class=\"sidebar-footer\"><button class=\"logout-btn\" onclick=\"logout()\">Logout</button></div></div><div class=\"main-content\"><div class=\"header\"><h1>Document Management System</h1></div><div class=\"content\"><div id=\"message\" class=\"message\"></div><!-- Documents Page --><div id=\"documents\" class=\"page active\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">My Documents</h2><button class=\"button button-secondary\" onclick=\"refreshFiles()\">Refresh</button> <button class=\"button button-secondary\" onclick=\"downloadAllZip()\">Download all as ZIP</button><table class=\"file-table\" id=\"fileTable\" style=\"display: none;\"><thead><tr><th style=\"width: 50%;\" class=\"sortable\" data-sort=\"name\" onclick=\"sortFiles('name')\">File Name</th><th style=\"width: 15%;\" class=\"sortable\" data-sort=\"size\" onclick=\"sortFiles('size')\">Size</th><th style=\"width: 20%;\" class=\"sortable\" data-sort=\"modified\" onclick=\"sortFiles('modified')\">Uploaded</th><th style=\"width: 15%;\">Actions</th></tr></thead> <tbody id=\"fileList\"></tbody></table><div class=\"empty-state\" id=\"emptyState\"><div>No documents</div><div style=\"font-size: 12px; margin-top: 10px; color: #555;\">Upload documents using the Upload page</div></div></div><!-- Upload Page --><div id=\"upload\" class=\"page\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">Upload Document</h2><div class=\"upload-zone\" id=\"uploadZone\"><p>Drag and drop files here or click to browse</p><p id=\"uploadLimit\" style=\"font-size: 12px; margin-top: 8px; color: #666;\">Maximum: 500 MB</p><input type=\"file\" id=\"fileInput\" multiple></div><label style=\"display: block; margin-bottom: 15px; font-size: 13px; color: #b0b0b0;\"><input type=\"checkbox\" id=\"directUpload\"> Upload directly to storage</label> <button class=\"button button-primary\" onclick=\"uploadFile()\">Upload</button></div><!-- Users Page (Admin only) --><div id=\"users\" class=\"page\"><h2 style=\"margin-bottom: 20px; font-size: 16px; color: #e0e0e0;\">User Management</h2><table class=\"user-list\" id=\"userTable\" style=\"display: none;\"><thead><tr><th style=\"width: 30%;\">Username</th><th style=\"width: 30%;\">Email</th><th style=\"width: 20%;\">Role</th><th style=\"width: 20%;\">Actions</th></tr></thead> <tbody id=\"userList\"></tbody></table><div class=\"empty-state\" id=\"emptyUsersState\"><div>No users found</div></div></div></div></div></div><script>\n\t\t\t// Role-based permissions\n\t\t\tconst userRole = '{ role }';\n\t\t\tconst canUpload = ['admin', 'uploader'].includes(userRole);\n\t\t\tconst canDelete = ['admin'].includes(userRole);\n\t\t\tconst canManage = ['admin'].includes(userRole);\n\n\t\t\tconst uploadZone = document.getElementById('uploadZone');\n\t\t\tconst fileInput = document.getElementById('fileInput');\n\t\t\tconst messageDiv = document.getElementById('message');\n\n\t\t\t// Hide upload zone if user doesn't have permission\n\t\t\tif (!canUpload && uploadZone) {\n\t\t\t\tuploadZone.style.display = 'none';\n\t\t\t\tconst uploadBtn = document.querySelector('#upload .button-primary');\n\t\t\t\tif (uploadBtn) uploadBtn.style.display = 'none';\n\t\t\t}\n\n\t\t\tuploadZone.addEventListener('click', () => fileInput.click());\n\n\t\t\tuploadZone.addEventListener('dragover', (e) => {\n\t\t\t\te.preventDefault();\n\t\t\t\tuploadZone.classList.add('dragover');\n\t\t\t});\n\n\t\t\tuploadZone.addEventListener('dragleave', () => {\n\t\t\t\tuploadZone.classList.remove('dragover');\n\t\t\t});\n\n\t\t\tuploadZone.addEventListener('drop', (e) => {\n\t\t\t\te.preventDefault();\n\t\t\t\tuploadZone.classList.remove('dragover');\n\t\t\t\tfileInput.files = e.dataTransfer.files;\n\t\t\t});\n\n\t\t\tlet csrfToken = '';\n\n\t\t\tfunction getAuthHeader() {\n\t\t\t\t// The token itself travels in the HTTP-only cookie; requests that change\n\t\t\t\t// anything must also prove they come from this page\n\t\t\t\treturn csrfToken ? { 'X-CSRF-Token': csrfToken } : {};\n\t\t\t}\n\n\t\t\tasync function loadCSRFToken() {\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/auth/csrf', { credentials: 'include' });\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\tcsrfToken = data.data.csrf_token;\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error loading session: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction showPage(pageName) {\n\t\t\t\tconst pages = document.querySelectorAll('.page');\n\t\t\t\tconst navItems = document.querySelectorAll('.nav-item');\n\n\t\t\t\tpages.forEach(page => page.classList.remove('active'));\n\t\t\t\tnavItems.forEach(item => item.classList.remove('active'));\n\n\t\t\t\tdocument.getElementById(pageName).classList.add('active');\n\t\t\t\tevent.target.classList.add('active');\n\n\t\t\t\tif (pageName === 'documents') {\n\t\t\t\t\trefreshFiles();\n\t\t\t\t} else if (pageName === 'users') {\n\t\t\t\t\tloadUsers();\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction showMessage(message, type) {\n\t\t\t\tmessageDiv.className = 'message show message-' + type;\n\t\t\t\tmessageDiv.textContent = message;\n\t\t\t\tsetTimeout(() => {\n\t\t\t\t\tmessageDiv.classList.remove('show');\n\t\t\t\t}, 4000);\n\t\t\t}\n\n\t\t\tlet maxUploadSize = 500 * 1024 * 1024;\n\n\t\t\tasync function loadLimits() {\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/limits', {\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\tmaxUploadSize = data.data.max_upload_size;\n\t\t\t\t\t\tlet limit = 'Maximum: ' + formatBytes(maxUploadSize);\n\t\t\t\t\t\tif (data.data.quota > 0) {\n\t\t\t\t\t\t\tlimit += ' · Storage used: ' + formatBytes(data.data.quota_used) + ' of ' + formatBytes(data.data.quota);\n\t\t\t\t\t\t}\n\t\t\t\t\t\tdocument.getElementById('uploadLimit').textContent = limit;\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\t// Keep the default; the server enforces the limit anyway\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tasync function uploadFile() {\n\t\t\t\tif (fileInput.files.length > 1) {\n\t\t\t\t\tawait uploadFiles(Array.from(fileInput.files));\n\t\t\t\t\treturn;\n\t\t\t\t}\n\n\t\t\t\tconst file = fileInput.files[0];\n\t\t\t\tif (!file) {\n\t\t\t\t\tshowMessage('Please select a file', 'error');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tif (file.size > maxUploadSize) {\n\t\t\t\t\tshowMessage('File is larger than the maximum of ' + formatBytes(maxUploadSize), 'error');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\n\t\t\t\tif (document.getElementById('directUpload').checked) {\n\t\t\t\t\ttry {\n\t\t\t\t\t\tif (await uploadDirect(file)) {\n\t\t\t\t\t\t\tshowMessage('Document uploaded successfully', 'success');\n\t\t\t\t\t\t\tfileInput.value = '';\n\t\t\t\t\t\t\treturn;\n\t\t\t\t\t\t}\n\t\t\t\t\t} catch (error) {\n\t\t\t\t\t\tshowMessage('Direct upload failed: ' + error.message, 'error');\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t}\n\n\t\t\t\tconst formData = new FormData();\n\t\t\t\tformData.append('file', file);\n\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/upload', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader(),\n\t\t\t\t\t\tbody: formData\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\tshowMessage('Document uploaded successfully', 'success');\n\t\t\t\t\t\tfileInput.value = '';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tshowMessage('Upload failed: ' + data.error, 'error');\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\t// uploadFiles sends several files in one request and reports the ones that failed\n\t\t\tasync function uploadFiles(files) {\n\t\t\t\tconst tooLarge = files.filter(file => file.size > maxUploadSize);\n\t\t\t\tif (tooLarge.length > 0) {\n\t\t\t\t\tshowMessage(tooLarge.map(file => file.name).join(', ') + ' larger than the maximum of ' + formatBytes(maxUploadSize), 'error');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\n\t\t\t\tconst formData = new FormData();\n\t\t\t\tfiles.forEach(file => formData.append('files[]', file));\n\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/upload', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader(),\n\t\t\t\t\t\tbody: formData\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tif (!data.success) {\n\t\t\t\t\t\tshowMessage('Upload failed: ' + data.error, 'error');\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\n\t\t\t\t\tconst failed = data.data.files.filter(result => !result.success);\n\t\t\t\t\tif (failed.length === 0) {\n\t\t\t\t\t\tshowMessage(data.data.uploaded + ' documents uploaded successfully', 'success');\n\t\t\t\t\t} else {\n\t\t\t\t\t\tshowMessage(data.data.uploaded + ' uploaded, ' + data.data.failed + ' failed: ' +\n\t\t\t\t\t\t\tfailed.map(result => result.filename + ' (' + result.error + ')').join(', '), 'error');\n\t\t\t\t\t}\n\t\t\t\t\tfileInput.value = '';\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\t// uploadDirect sends the file straight to storage using a POST policy.\n\t\t\t// Returns false when the backend doesn't support it so the caller can fall back.\n\t\t\tasync function uploadDirect(file) {\n\t\t\t\tconst presignResponse = await fetch('/api/upload/presign-post', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\theaders: Object.assign({ 'Content-Type': 'application/json' }, getAuthHeader()),\n\t\t\t\t\tbody: JSON.stringify({\n\t\t\t\t\t\tfilename: file.name,\n\t\t\t\t\t\tcontent_type: file.type,\n\t\t\t\t\t\tsize: file.size\n\t\t\t\t\t})\n\t\t\t\t});\n\t\t\t\tif (presignResponse.status === 501) {\n\t\t\t\t\treturn false;\n\t\t\t\t}\n\t\t\t\tconst presign = await presignResponse.json();\n\t\t\t\tif (!presign.success) {\n\t\t\t\t\tthrow new Error(presign.error);\n\t\t\t\t}\n\n\t\t\t\tconst formData = new FormData();\n\t\t\t\tObject.entries(presign.data.fields).forEach(([name, value]) => formData.append(name, value));\n\t\t\t\tformData.append('file', file);\n\n\t\t\t\tconst uploadResponse = await fetch(presign.data.url, {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\tbody: formData\n\t\t\t\t});\n\t\t\t\tif (!uploadResponse.ok) {\n\t\t\t\t\tthrow new Error('storage rejected the upload (' + uploadResponse.status + ')');\n\t\t\t\t}\n\n\t\t\t\tconst confirmResponse = await fetch('/api/upload/confirm', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\theaders: Object.assign({ 'Content-Type': 'application/json' }, getAuthHeader()),\n\t\t\t\t\tbody: JSON.stringify({ key: presign.data.key, size: file.size })\n\t\t\t\t});\n\t\t\t\tconst confirm = await confirmResponse.json();\n\t\t\t\tif (!confirm.success) {\n\t\t\t\t\tthrow new Error(confirm.error);\n\t\t\t\t}\n\t\t\t\treturn true;\n\t\t\t}\n\n\t\t\tlet listedKeys = [];\n\n\t\t\tasync function downloadAllZip() {\n\t\t\t\tif (listedKeys.length === 0) {\n\t\t\t\t\tshowMessage('No documents to download', 'error');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/files/download-zip', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: { ...getAuthHeader(), 'Content-Type': 'application/json' },\n\t\t\t\t\t\tbody: JSON.stringify({ keys: listedKeys })\n\t\t\t\t\t});\n\t\t\t\t\tif (!response.ok) {\n\t\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\t\tshowMessage('Download failed: ' + data.error, 'error');\n\t\t\t\t\t\treturn;\n\t\t\t\t\t}\n\t\t\t\t\tconst url = URL.createObjectURL(await response.blob());\n\t\t\t\t\tconst link = document.createElement('a');\n\t\t\t\t\tlink.href = url;\n\t\t\t\t\tlink.download = 'documents.zip';\n\t\t\t\t\tlink.click();\n\t\t\t\t\tURL.revokeObjectURL(url);\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Download failed: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tlet fileSort = { field: '', order: 'asc' };\n\n\t\t\t// sortFiles orders the listing by a column, flipping the order on a second click\n\t\t\tfunction sortFiles(field) {\n\t\t\t\tif (fileSort.field === field) {\n\t\t\t\t\tfileSort.order = fileSort.order === 'asc' ? 'desc' : 'asc';\n\t\t\t\t} else {\n\t\t\t\t\tfileSort = { field: field, order: field === 'name' ? 'asc' : 'desc' };\n\t\t\t\t}\n\t\t\t\tdocument.querySelectorAll('#fileTable th.sortable').forEach(th => {\n\t\t\t\t\tth.textContent = th.textContent.replace(/ [▲▼]$/, '');\n\t\t\t\t\tif (th.dataset.sort === fileSort.field) {\n\t\t\t\t\t\tth.textContent += fileSort.order === 'asc' ? ' ▲' : ' ▼';\n\t\t\t\t\t}\n\t\t\t\t});\n\t\t\t\trefreshFiles();\n\t\t\t}\n\n\t\t\tasync function refreshFiles() {\n\t\t\t\ttry {\n\t\t\t\t\tlet url = '/api/files';\n\t\t\t\t\tif (fileSort.field) {\n\t\t\t\t\t\turl += '?sort=' + fileSort.field + '&order=' + fileSort.order;\n\t\t\t\t\t}\n\t\t\t\t\tconst response = await fetch(url, {\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tlistedKeys = data.success && data.data.files ? data.data.files.map(file => file.key) : [];\n\n\t\t\t\t\tif (data.success && data.data.files && data.data.files.length > 0) {\n\t\t\t\t\t\tconst fileList = document.getElementById('fileList');\n\t\t\t\t\t\tfileList.innerHTML = data.data.files.map(file => {\n\t\t\t\t\t\t\tlet actions = '<a href=\"/api/download?key=' + encodeURIComponent(file.key) + '\" class=\"button button-secondary\" style=\"padding: 6px 12px; font-size: 12px;\">Download</a>';\n\t\t\t\t\t\t\tif (canDelete && !file.legal_hold) {\n\t\t\t\t\t\t\t\tactions += '<button class=\"button button-danger\" onclick=\"deleteFile(\\'' + escapeQuotes(file.key) + '\\')\">Delete</button>';\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tconst icon = '<span class=\"file-icon\" title=\"' + escapeHtml(file.category || 'other') + '\">' + (categoryIcons[file.category] || categoryIcons.other) + '</span>';\n\t\t\t\t\t\t\tconst hold = file.legal_hold ? '<span class=\"role-badge hold\" title=\"Under legal hold: cannot be deleted or renamed\">Legal hold</span>' : '';\n\t\t\t\t\t\t\treturn '<tr>' +\n\t\t\t\t\t\t\t\t'<td class=\"file-name\" title=\"' + escapeHtml(file.key).replace(/\"/g, '&quot;') + '\">' + icon + escapeHtml(file.original_name || file.key) + hold + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888;\">' + formatBytes(file.size) + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888; font-size: 12px;\">' + new Date(file.last_modified).toLocaleString() + '</td>' +\n\t\t\t\t\t\t\t\t'<td class=\"actions\">' + actions + '</td>' +\n\t\t\t\t\t\t\t\t'</tr>';\n\t\t\t\t\t\t}).join('');\n\t\t\t\t\t\tdocument.getElementById('fileTable').style.display = 'table';\n\t\t\t\t\t\tdocument.getElementById('emptyState').style.display = 'none';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tdocument.getElementById('fileTable').style.display = 'none';\n\t\t\t\t\t\tdocument.getElementById('emptyState').style.display = 'block';\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error loading documents: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tasync function loadUsers() {\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/admin/users', {\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t});\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tif (data.success && data.data.users && data.data.users.length > 0) {\n\t\t\t\t\t\tconst userList = document.getElementById('userList');\n\t\t\t\t\t\tuserList.innerHTML = data.data.users.map(user => {\n\t\t\t\t\t\t\tlet roleClass = 'admin';\n\t\t\t\t\t\t\tif (user.role === 'uploader') roleClass = 'uploader';\n\t\t\t\t\t\t\tif (user.role === 'viewer') roleClass = 'viewer';\n\n\t\t\t\t\t\t\treturn '<tr>' +\n\t\t\t\t\t\t\t\t'<td>' + escapeHtml(user.username) + '</td>' +\n\t\t\t\t\t\t\t\t'<td style=\"color: #888;\">' + escapeHtml(user.email) + '</td>' +\n\t\t\t\t\t\t\t\t'<td><span class=\"role-badge ' + roleClass + '\">' + user.role + '</span></td>' +\n\t\t\t\t\t\t\t\t'<td class=\"actions\">' +\n\t\t\t\t\t\t\t\t'<button class=\"button button-danger\" onclick=\"deleteUser(\\'' + escapeQuotes(user.id) + '\\')\">Delete</button>' +\n\t\t\t\t\t\t\t\t'</td>' +\n\t\t\t\t\t\t\t\t'</tr>';\n\t\t\t\t\t\t}).join('');\n\t\t\t\t\t\tdocument.getElementById('userTable').style.display = 'table';\n\t\t\t\t\t\tdocument.getElementById('emptyUsersState').style.display = 'none';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tdocument.getElementById('userTable').style.display = 'none';\n\t\t\t\t\t\tdocument.getElementById('emptyUsersState').style.display = 'block';\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error loading users: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction deleteFile(key) {\n\t\t\t\tif (confirm('Delete this document?')) {\n\t\t\t\t\tfetch('/api/files?key=' + encodeURIComponent(key), {\n\t\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t}).then(response => response.json())\n\t\t\t\t\t.then(data => {\n\t\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\t\tshowMessage('Document moved to trash', 'success');\n\t\t\t\t\t\t\trefreshFiles();\n\t\t\t\t\t\t} else {\n\t\t\t\t\t\t\tshowMessage('Delete failed: ' + data.error, 'error');\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction deleteUser(userId) {\n\t\t\t\tif (confirm('Delete this user?')) {\n\t\t\t\t\tfetch('/api/admin/users/' + userId, {\n\t\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t\t}).then(response => response.json())\n\t\t\t\t\t.then(data => {\n\t\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\t\tshowMessage('User deleted', 'success');\n\t\t\t\t\t\t\tloadUsers();\n\t\t\t\t\t\t} else {\n\t\t\t\t\t\t\tshowMessage('Delete failed: ' + data.error, 'error');\n\t\t\t\t\t\t}\n\t\t\t\t\t});\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tconst categoryIcons = {\n\t\t\t\timage: '🖼️',\n\t\t\t\tvideo: '🎬',\n\t\t\t\taudio: '🎵',\n\t\t\t\tdocument: '📄',\n\t\t\t\tarchive: '🗜️',\n\t\t\t\tcode: '💻',\n\t\t\t\tother: '📁'\n\t\t\t};\n\n\t\t\tfunction escapeHtml(text) {\n\t\t\t\tconst div = document.createElement('div');\n\t\t\t\tdiv.textContent = text;\n\t\t\t\treturn div.innerHTML;\n\t\t\t}\n\n\t\t\tfunction escapeQuotes(text) {\n\t\t\t\treturn text.replace(/'/g, \"\\\\'\").replace(/\"/g, '\\\\\"');\n\t\t\t}\n\n\t\t\tfunction formatBytes(bytes) {\n\t\t\t\tif (bytes === 0) return '0 B';\n\t\t\t\tconst k = 1024;\n\t\t\t\tconst sizes = ['B', 'KB', 'MB', 'GB'];\n\t\t\t\tconst i = Math.floor(Math.log(bytes) / Math.log(k));\n\t\t\t\treturn Math.round(bytes / Math.pow(k, i) * 100) / 100 + ' ' + sizes[i];\n\t\t\t}\n\n\t\t\tfunction logout() {\n\t\t\t\t// Call logout endpoint to clear cookie\n\t\t\t\tfetch('/api/auth/logout', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\theaders: getAuthHeader()\n\t\t\t\t}).then(() => {\n\t\t\t\t\twindow.location.href = '/login';\n\t\t\t\t}).catch(() => {\n\t\t\t\t\t// Even if request fails, redirect to login\n\t\t\t\t\twindow.location.href = '/login';\n\t\t\t\t});\n\t\t\t}\n\n\t\t\t// watchActivity refreshes the document list when files change, instead of polling.\n\t\t\t// EventSource reconnects by itself after the connection drops\n\t\t\tfunction watchActivity() {\n\t\t\t\tif (!window.EventSource) {\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tconst source = new EventSource('/api/events');\n\t\t\t\tconst refreshIfVisible = () => {\n\t\t\t\t\tif (document.getElementById('documents').classList.contains('active')) {\n\t\t\t\t\t\trefreshFiles();\n\t\t\t\t\t}\n\t\t\t\t};\n\t\t\t\tsource.onmessage = refreshIfVisible;\n\t\t\t\tsource.addEventListener('overflow', refreshIfVisible);\n\t\t\t}\n\n\t\t\twindow.onload = () => {\n\t\t\t\tloadCSRFToken();\n\t\t\t\trefreshFiles();\n\t\t\t\tloadLimits();\n\t\t\t\twatchActivity();\n\t\t\t};\n\t\t</script></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
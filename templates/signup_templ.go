@@ -29,7 +29,7 @@ func Signup() templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Sign Up - Document Management System</title><style>\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\n\t\t\thtml, body {\n\t\t\t\theight: 100%;\n\t\t\t\tfont-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif;\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tdisplay: flex;\n\t\t\t\talign-items: center;\n\t\t\t\tjustify-content: center;\n\t\t\t}\n\n\t\t\t.signup-container {\n\t\t\t\twidth: 100%;\n\t\t\t\tmax-width: 400px;\n\t\t\t\tpadding: 20px;\n\t\t\t}\n\n\t\t\t.signup-box {\n\t\t\t\tbackground-color: #262626;\n\t\t\t\tpadding: 40px;\n\t\t\t\tborder: 1px solid #333;\n\t\t\t}\n\n\t\t\t.signup-header {\n\t\t\t\tmargin-bottom: 30px;\n\t\t\t}\n\n\t\t\t.signup-header h1 {\n\t\t\t\tfont-size: 24px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tmargin-bottom: 10px;\n\t\t\t}\n\n\t\t\t.signup-header p {\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #888;\n\t\t\t}\n\n\t\t\t.form-group {\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t}\n\n\t\t\t.form-group label {\n\t\t\t\tdisplay: block;\n\t\t\t\tmargin-bottom: 8px;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.form-group input {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px;\n\t\t\t\tborder: 1px solid #333;\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tfont-size: 13px;\n\t\t\t}\n\n\t\t\t.form-group input:focus {\n\t\t\t\toutline: none;\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #212121;\n\t\t\t}\n\n\t\t\t.form-hint {\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #888;\n\t\t\t\tmargin-top: 4px;\n\t\t\t}\n\n\t\t\t.button {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcursor: pointer;\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t\tmargin-top: 10px;\n\t\t\t}\n\n\t\t\t.button:hover {\n\t\t\t\tbackground-color: #3a8eef;\n\t\t\t}\n\n\t\t\t.message {\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t\tpadding: 10px;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tborder-left: 3px solid;\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.message.show {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.message-error {\n\t\t\t\tbackground-color: #3a1a1a;\n\t\t\t\tcolor: #ff6b6b;\n\t\t\t\tborder-left-color: #ff6b6b;\n\t\t\t}\n\n\t\t\t.login-link {\n\t\t\t\tmargin-top: 20px;\n\t\t\t\ttext-align: center;\n\t\t\t}\n\n\t\t\t.login-link a {\n\t\t\t\tcolor: #4a9eff;\n\t\t\t\ttext-decoration: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t}\n\n\t\t\t.login-link a:hover {\n\t\t\t\ttext-decoration: underline;\n\t\t\t}\n\t\t</style></head><body><div class=\"signup-container\"><div class=\"signup-box\"><div class=\"signup-header\"><h1>Sign Up</h1><p>Create a new account</p></div><div id=\"message\" class=\"message\"></div><form onsubmit=\"handleSignup(event)\"><div class=\"form-group\"><label for=\"username\">Username</label> <input type=\"text\" id=\"username\" name=\"username\" required></div><div class=\"form-group\"><label for=\"email\">Email</label> <input type=\"email\" id=\"email\" name=\"email\" required></div><div class=\"form-group\"><label for=\"password\">Password</label> <input type=\"password\" id=\"password\" name=\"password\" required><div class=\"form-hint\">Minimum 6 characters</div></div><div class=\"form-group\"><label for=\"signup_key\">Signup Key</label> <input type=\"password\" id=\"signup_key\" name=\"signup_key\" required><div class=\"form-hint\">Required to create an account</div></div><button type=\"submit\" class=\"button\">Sign Up</button></form><div class=\"login-link\"><p>Already have an account? <a href=\"/login\">Sign in here</a></p></div></div></div><script>\n\t\t\tasync function handleSignup(event) {\n\t\t\t\tevent.preventDefault();\n\t\t\t\tconst username = document.getElementById('username').value;\n\t\t\t\tconst email = document.getElementById('email').value;\n\t\t\t\tconst password = document.getElementById('password').value;\n\t\t\t\tconst signupKey = document.getElementById('signup_key').value;\n\t\t\t\tconst messageDiv = document.getElementById('message');\n\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/auth/signup', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\theaders: {\n\t\t\t\t\t\t\t'Content-Type': 'application/json'\n\t\t\t\t\t\t},\n\t\t\t\t\t\tbody: JSON.stringify({\n\t\t\t\t\t\t\tusername: username,\n\t\t\t\t\t\t\temail: email,\n\t\t\t\t\t\t\tpassword: password,\n\t\t\t\t\t\t\tsignup_key: signupKey\n\t\t\t\t\t\t})\n\t\t\t\t\t});\n\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\tlocalStorage.setItem('token', data.token);\n\t\t\t\t\t\twindow.location.href = '/dashboard';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tshowMessage(data.error || 'Signup failed', 'error');\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction showMessage(message, type) {\n\t\t\t\tconst messageDiv = document.getElementById('message');\n\t\t\t\tmessageDiv.className = 'message show message-' + type;\n\t\t\t\tmessageDiv.textContent = message;\n\t\t\t}\n\t\t</script></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Sign Up - Document Management System</title><style>\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\n\t\t\thtml, body {\n\t\t\t\theight: 100%;\n\t\t\t\tfont-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Helvetica, Arial, sans-serif;\n\t\t\t\tbackground-color: #1a1a1a;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tdisplay: flex;\n\t\t\t\talign-items: center;\n\t\t\t\tjustify-content: center;\n\t\t\t}\n\n\t\t\t.signup-container {\n\t\t\t\twidth: 100%;\n\t\t\t\tmax-width: 400px;\n\t\t\t\tpadding: 20px;\n\t\t\t}\n\n\t\t\t.signup-box {\n\t\t\t\tbackground-color: #262626;\n\t\t\t\tpadding: 40px;\n\t\t\t\tborder: 1px solid #333;\n\t\t\t}\n\n\t\t\t.signup-header {\n\t\t\t\tmargin-bottom: 30px;\n\t\t\t}\n\n\t\t\t.signup-header h1 {\n\t\t\t\tfont-size: 24px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tmargin-bottom: 10px;\n\t\t\t}\n\n\t\t\t.signup-header p {\n\t\t\t\tfont-size: 13px;\n\t\t\t\tcolor: #888;\n\t\t\t}\n\n\t\t\t.form-group {\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t}\n\n\t\t\t.form-group label {\n\t\t\t\tdisplay: block;\n\t\t\t\tmargin-bottom: 8px;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: #c0c0c0;\n\t\t\t}\n\n\t\t\t.form-group input {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px;\n\t\t\t\tborder: 1px solid #333;\n\t\t\t\tbackground-color: #1f1f1f;\n\t\t\t\tcolor: #e0e0e0;\n\t\t\t\tfont-size: 13px;\n\t\t\t}\n\n\t\t\t.form-group input:focus {\n\t\t\t\toutline: none;\n\t\t\t\tborder-color: #4a9eff;\n\t\t\t\tbackground-color: #212121;\n\t\t\t}\n\n\t\t\t.form-hint {\n\t\t\t\tfont-size: 12px;\n\t\t\t\tcolor: #888;\n\t\t\t\tmargin-top: 4px;\n\t\t\t}\n\n\t\t\t.button {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 10px;\n\t\t\t\tborder: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t\tfont-weight: 600;\n\t\t\t\tcursor: pointer;\n\t\t\t\tbackground-color: #4a9eff;\n\t\t\t\tcolor: #000;\n\t\t\t\tmargin-top: 10px;\n\t\t\t}\n\n\t\t\t.button:hover {\n\t\t\t\tbackground-color: #3a8eef;\n\t\t\t}\n\n\t\t\t.message {\n\t\t\t\tmargin-bottom: 20px;\n\t\t\t\tpadding: 10px;\n\t\t\t\tfont-size: 12px;\n\t\t\t\tborder-left: 3px solid;\n\t\t\t\tdisplay: none;\n\t\t\t}\n\n\t\t\t.message.show {\n\t\t\t\tdisplay: block;\n\t\t\t}\n\n\t\t\t.message-error {\n\t\t\t\tbackground-color: #3a1a1a;\n\t\t\t\tcolor: #ff6b6b;\n\t\t\t\tborder-left-color: #ff6b6b;\n\t\t\t}\n\n\t\t\t.login-link {\n\t\t\t\tmargin-top: 20px;\n\t\t\t\ttext-align: center;\n\t\t\t}\n\n\t\t\t.login-link a {\n\t\t\t\tcolor: #4a9eff;\n\t\t\t\ttext-decoration: none;\n\t\t\t\tfont-size: 13px;\n\t\t\t}\n\n\t\t\t.login-link a:hover {\n\t\t\t\ttext-decoration: underline;\n\t\t\t}\n\t\t</style></head><body><div class=\"signup-container\"><div class=\"signup-box\"><div class=\"signup-header\"><h1>Sign Up</h1><p>Create a new account</p></div><div id=\"message\" class=\"message\"></div><form onsubmit=\"handleSignup(event)\"><div class=\"form-group\"><label for=\"username\">Username</label> <input type=\"text\" id=\"username\" name=\"username\" required></div><div class=\"form-group\"><label for=\"email\">Email</label> <input type=\"email\" id=\"email\" name=\"email\" required></div><div class=\"form-group\"><label for=\"password\">Password</label> <input type=\"password\" id=\"password\" name=\"password\" required><div class=\"form-hint\">Minimum 6 characters</div></div><div class=\"form-group\"><label for=\"signup_key\">Signup Key</label> <input type=\"password\" id=\"signup_key\" name=\"signup_key\" required><div class=\"form-hint\">Required to create an account</div></div><button type=\"submit\" class=\"button\">Sign Up</button></form><div class=\"login-link\"><p>Already have an account? <a href=\"/login\">Sign in here</a></p></div></div></div><script>\n\t\t\tasync function handleSignup(event) {\n\t\t\t\tevent.preventDefault();\n\t\t\t\tconst username = document.getElementById('username').value;\n\t\t\t\tconst email = document.getElementById('email').value;\n\t\t\t\tconst password = document.getElementById('password').value;\n\t\t\t\tconst signupKey = document.getElementById('signup_key').value;\n\t\t\t\tconst messageDiv = document.getElementById('message');\n\n\t\t\t\ttry {\n\t\t\t\t\tconst response = await fetch('/api/auth/signup', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\tcredentials: 'include',\n\t\t\t\t\t\theaders: {\n\t\t\t\t\t\t\t'Content-Type': 'application/json'\n\t\t\t\t\t\t},\n\t\t\t\t\t\tbody: JSON.stringify({\n\t\t\t\t\t\t\tusername: username,\n\t\t\t\t\t\t\temail: email,\n\t\t\t\t\t\t\tpassword: password,\n\t\t\t\t\t\t\tsignup_key: signupKey\n\t\t\t\t\t\t})\n\t\t\t\t\t});\n\n\t\t\t\t\tconst data = await response.json();\n\n\t\t\t\t\tif (data.success) {\n\t\t\t\t\t\twindow.location.href = '/dashboard';\n\t\t\t\t\t} else {\n\t\t\t\t\t\tshowMessage(data.error || 'Signup failed', 'error');\n\t\t\t\t\t}\n\t\t\t\t} catch (error) {\n\t\t\t\t\tshowMessage('Error: ' + error.message, 'error');\n\t\t\t\t}\n\t\t\t}\n\n\t\t\tfunction showMessage(message, type) {\n\t\t\t\tconst messageDiv = document.getElementById('message');\n\t\t\t\tmessageDiv.className = 'message show message-' + type;\n\t\t\t\tmessageDiv.textContent = message;\n\t\t\t}\n\t\t</script></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}